@@ -6,10 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/tyemirov/ghttp/internal/certificates"
+	"github.com/tyemirov/ghttp/internal/server"
 	"github.com/tyemirov/ghttp/pkg/logging"
 )
 
@@ -24,6 +26,8 @@ const (
 	defaultServePort       = "8000"
 	defaultHTTPSServePort  = "8443"
 	defaultProtocolVersion = "HTTP/1.1"
+	protocolVersionHTTP10  = "HTTP/1.0"
+	protocolVersionHTTP2   = "HTTP/2"
 	defaultConfigFileName  = "config"
 	defaultConfigFileType  = "yaml"
 	defaultApplicationName = "ghttp"
@@ -32,37 +36,135 @@ const (
 	flagNameBindAddress        = "bind"
 	flagNameDirectory          = "directory"
 	flagNameProtocol           = "protocol"
+	flagNameH2C                = "h2c"
 	flagNameTLSCertificatePath = "tls-cert"
 	flagNameTLSKeyPath         = "tls-key"
 	flagNameNoMarkdown         = "no-md"
 	flagNameHTTPS              = "https"
+	flagNameHTTPPort           = "http-port"
+	flagNameHTTPSRedirect      = "https-redirect"
+	flagNameHTTPSRedirectSkip  = "https-redirect-exclude"
 	flagNameBrowse             = "browse"
 	flagNameLoggingType        = "logging-type"
+	flagNameReadHeaderTimeout  = "read-header-timeout"
+	flagNameReadTimeout        = "read-timeout"
+	flagNameWriteTimeout       = "write-timeout"
+	flagNameIdleTimeout        = "idle-timeout"
+	flagNameMaxHeaderBytes     = "max-header-bytes"
+	flagNameMaxBodyBytes       = "max-body-bytes"
+	flagNameRouteLimit         = "route-limit"
 	flagNameHTTPSHosts         = "https-host"
+	flagNameHTTPSKeyAlgorithm  = "https-key-algorithm"
+	flagNameHTTPSPersist       = "https-persist"
+	flagNameHTTPSOnDemand      = "https-on-demand"
+	flagNameHTTPSOnDemandStore = "https-on-demand-persist"
+	flagNameHTTPSOnDemandLimit = "https-on-demand-cache-size"
+	flagNameHTTPSNameConstrain = "https-name-constraints"
+	flagNameHTTPSCADomain      = "https-ca-domain"
 	flagNameProxy              = "proxy"
 	flagNameResponseHeader     = "response-header"
 	flagNameProxyStreaming     = "proxy-streaming"
+	flagNameProxyBackendTLS    = "proxy-backend-tls"
+	flagNameProxyGRPCWeb       = "proxy-grpc-web"
+	flagNameProxyFallback      = "proxy-fallback"
+	flagNameProxyRewrite       = "proxy-rewrite"
+	flagNameProxyCache         = "proxy-cache"
+	flagNameProxyCacheDir      = "proxy-cache-dir"
+	flagNameProxyCacheTTL      = "proxy-cache-ttl"
+	flagNameProxyWSPing        = "proxy-websocket-ping"
+	flagNameProxyWSIdle        = "proxy-websocket-idle-timeout"
+	flagNameProxyWSLog         = "proxy-websocket-log"
+	flagNameProxyWSRedact      = "proxy-websocket-redact"
+	flagNameMocks              = "mocks"
+	flagNameREST               = "rest"
+	flagNameFaults             = "faults"
+	flagNameProxyRecord        = "proxy-record"
+	flagNameProxyRecordRedact  = "proxy-record-redact-header"
+	flagNameProxyReplay        = "proxy-replay"
+	flagNameProxyReplayMatch   = "proxy-replay-match"
+	flagNameProxyReplayMiss    = "proxy-replay-miss"
+	flagNameProxyMirror        = "proxy-mirror"
+	flagNameProxyMirrorLimit   = "proxy-mirror-concurrency"
+	flagNameProxyMirrorBody    = "proxy-mirror-body-limit"
 	flagNameProxyBackend       = "proxy-backend"
+	flagNameClientCA           = "client-ca"
+	flagNameClientAuth         = "client-auth"
+	flagNameClientAuthRoute    = "client-auth-route"
+	flagNameProxyRequestHeader = "proxy-request-header"
 	flagNameProxyPathPrefix    = "proxy-path"
+	flagNameTLSPreset          = "tls-preset"
+	flagNameTLSMinVersion      = "tls-min-version"
+	flagNameTLSMaxVersion      = "tls-max-version"
+	flagNameTLSCipherSuites    = "tls-cipher-suites"
+	flagNameTLSCurves          = "tls-curves"
 
 	configKeyConfigFile              = "config.file"
 	configKeyServeBindAddress        = "serve.bind_address"
 	configKeyServeDirectory          = "serve.directory"
 	configKeyServeProtocol           = "serve.protocol"
+	configKeyServeH2C                = "serve.h2c"
 	configKeyServePort               = "serve.port"
 	configKeyServeTLSCertificatePath = "serve.tls_certificate"
 	configKeyServeTLSKeyPath         = "serve.tls_private_key"
 	configKeyServeNoMarkdown         = "serve.no_markdown"
 	configKeyServeBrowse             = "serve.browse"
 	configKeyServeHTTPS              = "serve.https"
+	configKeyServeHTTPPort           = "serve.http_port"
+	configKeyServeHTTPSRedirect      = "serve.https_redirect"
+	configKeyServeHTTPSRedirectSkip  = "serve.https_redirect_exclude"
 	configKeyServeLoggingType        = "serve.logging_type"
+	configKeyServeReadHeaderTimeout  = "serve.read_header_timeout"
+	configKeyServeReadTimeout        = "serve.read_timeout"
+	configKeyServeWriteTimeout       = "serve.write_timeout"
+	configKeyServeIdleTimeout        = "serve.idle_timeout"
+	configKeyServeMaxHeaderBytes     = "serve.max_header_bytes"
+	configKeyServeMaxBodyBytes       = "serve.max_body_bytes"
+	configKeyServeRouteLimits        = "serve.route_limits"
 	configKeyHTTPSCertificateDir     = "https.certificate_directory"
 	configKeyHTTPSHosts              = "https.hosts"
+	configKeyHTTPSKeyAlgorithm       = "https.key_algorithm"
+	configKeyHTTPSPersistCA          = "https.persist_ca"
+	configKeyHTTPSOnDemandHosts      = "https.on_demand_hosts"
+	configKeyHTTPSOnDemandPersist    = "https.on_demand_persist"
+	configKeyHTTPSOnDemandCacheSize  = "https.on_demand_cache_size"
+	configKeyHTTPSNameConstraints    = "https.name_constraints"
+	configKeyHTTPSCADomains          = "https.ca_domains"
 	configKeyServeProxies            = "serve.proxies"
 	configKeyServeResponseHeaders    = "serve.response_headers"
 	configKeyServeProxyStreaming     = "serve.proxy_streaming"
+	configKeyServeProxyBackendTLS    = "serve.proxy_backend_tls"
+	configKeyServeProxyGRPCWeb       = "serve.proxy_grpc_web"
+	configKeyServeProxyFallback      = "serve.proxy_fallback"
+	configKeyServeProxyRewrite       = "serve.proxy_rewrite"
+	configKeyServeProxyCache         = "serve.proxy_cache"
+	configKeyServeProxyCacheDir      = "serve.proxy_cache_dir"
+	configKeyServeProxyCacheTTL      = "serve.proxy_cache_ttl"
+	configKeyServeProxyWSPing        = "serve.proxy_websocket_ping"
+	configKeyServeProxyWSIdle        = "serve.proxy_websocket_idle_timeout"
+	configKeyServeProxyWSLog         = "serve.proxy_websocket_log"
+	configKeyServeProxyWSRedact      = "serve.proxy_websocket_redact"
+	configKeyServeMocks              = "serve.mocks"
+	configKeyServeREST               = "serve.rest"
+	configKeyServeFaults             = "serve.faults"
+	configKeyServeProxyRecord        = "serve.proxy_record"
+	configKeyServeProxyRecordRedact  = "serve.proxy_record_redact_headers"
+	configKeyServeProxyReplay        = "serve.proxy_replay"
+	configKeyServeProxyReplayMatch   = "serve.proxy_replay_match"
+	configKeyServeProxyReplayMiss    = "serve.proxy_replay_miss"
+	configKeyServeProxyMirrors       = "serve.proxy_mirrors"
+	configKeyServeProxyMirrorLimit   = "serve.proxy_mirror_concurrency"
+	configKeyServeProxyMirrorBody    = "serve.proxy_mirror_body_limit"
 	configKeyProxyBackend            = "serve.proxy_backend"
+	configKeyServeClientCA           = "serve.client_ca"
+	configKeyServeClientAuth         = "serve.client_auth"
+	configKeyServeClientAuthRoutes   = "serve.client_auth_routes"
+	configKeyServeProxyRequestHeader = "serve.proxy_request_headers"
 	configKeyProxyPathPrefix         = "serve.proxy_path_prefix"
+	configKeyServeTLSPreset          = "serve.tls_preset"
+	configKeyServeTLSMinVersion      = "serve.tls_min_version"
+	configKeyServeTLSMaxVersion      = "serve.tls_max_version"
+	configKeyServeTLSCipherSuites    = "serve.tls_cipher_suites"
+	configKeyServeTLSCurves          = "serve.tls_curves"
 
 	logMessageFailedInitializeLogger = "failed to initialize logger"
 	logMessageResolveUserConfigDir   = "resolve user config directory"
@@ -116,20 +218,69 @@ func Execute(ctx context.Context, arguments []string) int {
 	configurationManager.SetDefault(configKeyServeBindAddress, "")
 	configurationManager.SetDefault(configKeyServeDirectory, ".")
 	configurationManager.SetDefault(configKeyServeProtocol, defaultProtocolVersion)
+	configurationManager.SetDefault(configKeyServeH2C, false)
 	configurationManager.SetDefault(configKeyServeTLSCertificatePath, "")
 	configurationManager.SetDefault(configKeyServeTLSKeyPath, "")
+	configurationManager.SetDefault(configKeyServeHTTPPort, "")
+	configurationManager.SetDefault(configKeyServeHTTPSRedirect, false)
+	configurationManager.SetDefault(configKeyServeHTTPSRedirectSkip, []string{})
 	configurationManager.SetDefault(configKeyServeNoMarkdown, false)
 	configurationManager.SetDefault(configKeyServeBrowse, false)
 	configurationManager.SetDefault(configKeyServeHTTPS, false)
 	configurationManager.SetDefault(configKeyServeLoggingType, logging.TypeConsole)
+	configurationManager.SetDefault(configKeyServeReadHeaderTimeout, 15*time.Second)
+	configurationManager.SetDefault(configKeyServeReadTimeout, time.Duration(0))
+	configurationManager.SetDefault(configKeyServeWriteTimeout, time.Duration(0))
+	configurationManager.SetDefault(configKeyServeIdleTimeout, time.Duration(0))
+	configurationManager.SetDefault(configKeyServeMaxHeaderBytes, 0)
+	configurationManager.SetDefault(configKeyServeMaxBodyBytes, int64(0))
+	configurationManager.SetDefault(configKeyServeRouteLimits, []string{})
 	configurationManager.SetDefault(configKeyConfigFile, "")
 	configurationManager.SetDefault(configKeyHTTPSCertificateDir, filepath.Join(applicationConfigDir, certificates.DefaultCertificateDirectoryName))
 	configurationManager.SetDefault(configKeyHTTPSHosts, []string{"localhost", "127.0.0.1", "::1"})
+	configurationManager.SetDefault(configKeyHTTPSKeyAlgorithm, string(certificates.KeyAlgorithmRSA))
+	configurationManager.SetDefault(configKeyHTTPSPersistCA, false)
+	configurationManager.SetDefault(configKeyHTTPSOnDemandHosts, []string{})
+	configurationManager.SetDefault(configKeyHTTPSOnDemandPersist, false)
+	configurationManager.SetDefault(configKeyHTTPSOnDemandCacheSize, defaultOnDemandCacheSize)
+	configurationManager.SetDefault(configKeyHTTPSNameConstraints, false)
+	configurationManager.SetDefault(configKeyHTTPSCADomains, []string{})
 	configurationManager.SetDefault(configKeyServeProxies, []string{})
 	configurationManager.SetDefault(configKeyServeResponseHeaders, []string{})
 	configurationManager.SetDefault(configKeyServeProxyStreaming, []string{})
+	configurationManager.SetDefault(configKeyServeProxyBackendTLS, []string{})
+	configurationManager.SetDefault(configKeyServeProxyGRPCWeb, []string{})
+	configurationManager.SetDefault(configKeyServeProxyFallback, []string{})
+	configurationManager.SetDefault(configKeyServeProxyRewrite, []string{})
+	configurationManager.SetDefault(configKeyServeProxyCache, "")
+	configurationManager.SetDefault(configKeyServeProxyCacheDir, "")
+	configurationManager.SetDefault(configKeyServeProxyCacheTTL, []string{})
+	configurationManager.SetDefault(configKeyServeProxyWSPing, time.Duration(0))
+	configurationManager.SetDefault(configKeyServeProxyWSIdle, time.Duration(0))
+	configurationManager.SetDefault(configKeyServeProxyWSLog, []string{})
+	configurationManager.SetDefault(configKeyServeProxyWSRedact, []string{})
+	configurationManager.SetDefault(configKeyServeMocks, "")
+	configurationManager.SetDefault(configKeyServeREST, []string{})
+	configurationManager.SetDefault(configKeyServeFaults, "")
+	configurationManager.SetDefault(configKeyServeProxyRecord, "")
+	configurationManager.SetDefault(configKeyServeProxyRecordRedact, []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"})
+	configurationManager.SetDefault(configKeyServeProxyReplay, "")
+	configurationManager.SetDefault(configKeyServeProxyReplayMatch, []string{"method", "path", "query"})
+	configurationManager.SetDefault(configKeyServeProxyReplayMiss, "fail")
+	configurationManager.SetDefault(configKeyServeProxyMirrors, []string{})
+	configurationManager.SetDefault(configKeyServeProxyMirrorLimit, 16)
+	configurationManager.SetDefault(configKeyServeProxyMirrorBody, 1<<20)
 	configurationManager.SetDefault(configKeyProxyBackend, "")
 	configurationManager.SetDefault(configKeyProxyPathPrefix, "")
+	configurationManager.SetDefault(configKeyServeClientCA, "")
+	configurationManager.SetDefault(configKeyServeClientAuth, server.ClientAuthModeRequire)
+	configurationManager.SetDefault(configKeyServeClientAuthRoutes, []string{})
+	configurationManager.SetDefault(configKeyServeProxyRequestHeader, []string{})
+	configurationManager.SetDefault(configKeyServeTLSPreset, "")
+	configurationManager.SetDefault(configKeyServeTLSMinVersion, "")
+	configurationManager.SetDefault(configKeyServeTLSMaxVersion, "")
+	configurationManager.SetDefault(configKeyServeTLSCipherSuites, []string{})
+	configurationManager.SetDefault(configKeyServeTLSCurves, []string{})
 	resources := &applicationResources{
 		configurationManager: configurationManager,
 		loggingService:       initialService,
@@ -0,0 +1,536 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tyemirov/ghttp/internal/certificates"
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	flagNameCertHost            = "host"
+	flagNameCertName            = "name"
+	flagNameCertOutputDirectory = "output-directory"
+	flagNameCertValidity        = "validity"
+	flagNameCertFormat          = "format"
+	flagNameCertPassword        = "password"
+	flagNameCertLeafCertificate = "leaf-cert"
+	flagNameCertLeafPrivateKey  = "leaf-key"
+	flagNameCertCommonName      = "common-name"
+	flagNameCertEmail           = "email"
+	configKeyCertExportPassword = "cert.export_password"
+	certExportFormatPEM         = "pem"
+	certExportFormatDER         = "der"
+	certExportFormatPKCS12      = "p12"
+	certExportBaseName          = "ghttp-ca"
+	certExportFilePermissions   = 0o644
+	logFieldFiles               = "files"
+	logFieldCommonName          = "common_name"
+	certStatusTimeLayout        = "2006-01-02 15:04:05 MST"
+)
+
+type certIssueTarget struct {
+	issuer               certificates.ServerCertificateIssuer
+	authorityMaterial    certificates.CertificateAuthorityMaterial
+	certificateDirectory string
+	certificatePath      string
+	privateKeyPath       string
+}
+
+type certExportFile struct {
+	path        string
+	content     []byte
+	permissions fs.FileMode
+}
+
+func newCertCommand(resources *applicationResources) *cobra.Command {
+	certCommand := &cobra.Command{
+		Use:   "cert",
+		Short: "Inspect, export, and issue certificates from the development certificate authority",
+		Args:  cobra.NoArgs,
+	}
+
+	statusCommand := &cobra.Command{
+		Use:   "status",
+		Short: "Show the certificate authority, the --https leaf certificate, and trust store installation state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCertStatus(cmd)
+		},
+	}
+
+	issueCommand := &cobra.Command{
+		Use:   "issue",
+		Short: "Issue a standalone leaf certificate and key signed by the development certificate authority",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return bindCertificateAuthorityFlags(resources.configurationManager, cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCertIssue(cmd)
+		},
+	}
+	issueCommand.Flags().StringSlice(flagNameCertHost, nil, "Hostname or IP address to include in the certificate (repeatable, required)")
+	issueCommand.Flags().String(flagNameCertName, "", "Base name of the written .pem and .key files (defaults to the first host)")
+	issueCommand.Flags().String(flagNameCertOutputDirectory, ".", "Directory the certificate and key are written to")
+	issueCommand.Flags().Duration(flagNameCertValidity, leafCertificateValidityDuration, "Validity period of the issued certificate")
+	issueCommand.Flags().String(flagNameHTTPSKeyAlgorithm, resources.configurationManager.GetString(configKeyHTTPSKeyAlgorithm), "Key algorithm for the certificate: rsa, ecdsa-p256, ecdsa-p384, or ed25519")
+	addNameConstraintFlags(issueCommand.Flags(), resources.configurationManager)
+
+	issueClientCommand := &cobra.Command{
+		Use:   "issue-client",
+		Short: "Issue a client certificate and key for mutual TLS signed by the development certificate authority",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return bindCertificateAuthorityFlags(resources.configurationManager, cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCertIssueClient(cmd)
+		},
+	}
+	issueClientCommand.Flags().String(flagNameCertCommonName, "", "Common name identifying the client (required)")
+	issueClientCommand.Flags().StringSlice(flagNameCertEmail, nil, "Email address to include as a subject alternative name (repeatable)")
+	issueClientCommand.Flags().String(flagNameCertName, "", "Base name of the written .pem and .key files (defaults to the common name)")
+	issueClientCommand.Flags().String(flagNameCertOutputDirectory, ".", "Directory the certificate and key are written to")
+	issueClientCommand.Flags().Duration(flagNameCertValidity, leafCertificateValidityDuration, "Validity period of the issued certificate")
+	issueClientCommand.Flags().String(flagNameHTTPSKeyAlgorithm, resources.configurationManager.GetString(configKeyHTTPSKeyAlgorithm), "Key algorithm for the certificate: rsa, ecdsa-p256, ecdsa-p384, or ed25519")
+	addNameConstraintFlags(issueClientCommand.Flags(), resources.configurationManager)
+
+	exportCommand := &cobra.Command{
+		Use:   "export",
+		Short: "Write the certificate authority as PEM or DER and bundle a leaf certificate as PKCS#12",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return resources.configurationManager.BindPFlag(configKeyCertExportPassword, cmd.Flags().Lookup(flagNameCertPassword))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCertExport(cmd)
+		},
+	}
+	exportCommand.Flags().StringSlice(flagNameCertFormat, []string{certExportFormatPEM, certExportFormatDER}, "Formats to write: pem and der (certificate authority), p12 (leaf certificate, key, and chain)")
+	exportCommand.Flags().String(flagNameCertOutputDirectory, ".", "Directory the exported files are written to")
+	exportCommand.Flags().String(flagNameCertPassword, "", "Password protecting the p12 bundle (or GHTTP_CERT_EXPORT_PASSWORD)")
+	exportCommand.Flags().String(flagNameCertLeafCertificate, "", "Leaf certificate bundled into the p12 file (defaults to the --https leaf)")
+	exportCommand.Flags().String(flagNameCertLeafPrivateKey, "", "Private key of the bundled leaf certificate (defaults to the --https leaf key)")
+
+	trustCommand := &cobra.Command{
+		Use:   "trust",
+		Short: "Install the existing certificate authority into the system trust stores",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCertTrust(cmd)
+		},
+	}
+
+	untrustCommand := &cobra.Command{
+		Use:   "untrust",
+		Short: "Remove the certificate authority from the system trust stores and keep its files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCertUntrust(cmd)
+		},
+	}
+
+	certCommand.AddCommand(statusCommand, issueCommand, issueClientCommand, exportCommand, trustCommand, untrustCommand)
+	return certCommand
+}
+
+func runCertStatus(cmd *cobra.Command) error {
+	resources, err := getApplicationResources(cmd)
+	if err != nil {
+		return err
+	}
+	certificateDirectory, err := resolveCertificateDirectory(resources.configurationManager)
+	if err != nil {
+		return err
+	}
+	fileSystem := certificates.NewOperatingSystemFileSystem()
+	output := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	defer output.Flush()
+
+	rootCertificatePath := filepath.Join(certificateDirectory, certificates.DefaultRootCertificateFileName)
+	manager := certificates.NewCertificateAuthorityManager(fileSystem, certificates.NewSystemClock(), rand.Reader, buildCertificateAuthorityConfiguration(certificateDirectory, "", certificates.NameConstraints{}))
+	authorityMaterial, authorityErr := manager.LoadCertificateAuthority()
+	if errors.Is(authorityErr, fs.ErrNotExist) {
+		fmt.Fprintf(output, "Certificate authority\tnot found in %s (run ghttp https install)\n", certificateDirectory)
+		return nil
+	}
+	if authorityErr != nil {
+		return fmt.Errorf("load certificate authority: %w", authorityErr)
+	}
+	fmt.Fprintf(output, "Certificate authority\t%s\n", rootCertificatePath)
+	writeCertificateSummary(output, certificates.SummarizeCertificate(authorityMaterial.Certificate))
+
+	issuer := certificates.NewServerCertificateIssuer(fileSystem, certificates.NewSystemClock(), rand.Reader, certificates.ServerCertificateConfiguration{})
+	leafMaterial, leafErr := issuer.LoadServerCertificate(certificates.ServerCertificateRequest{
+		CertificateOutputPath: filepath.Join(certificateDirectory, certificates.DefaultLeafCertificateFileName),
+		PrivateKeyOutputPath:  filepath.Join(certificateDirectory, certificates.DefaultLeafPrivateKeyFileName),
+	})
+	switch {
+	case errors.Is(leafErr, fs.ErrNotExist):
+		fmt.Fprintf(output, "\nLeaf certificate\tnot issued yet\n")
+	case leafErr != nil:
+		fmt.Fprintf(output, "\nLeaf certificate\tunreadable: %v\n", leafErr)
+	default:
+		fmt.Fprintf(output, "\nLeaf certificate\t%s\n", filepath.Join(certificateDirectory, certificates.DefaultLeafCertificateFileName))
+		writeCertificateSummary(output, certificates.SummarizeCertificate(leafMaterial.TLSCertificate))
+		signedByAuthority := "yes"
+		if signatureErr := leafMaterial.TLSCertificate.CheckSignatureFrom(authorityMaterial.Certificate); signatureErr != nil {
+			signedByAuthority = "no (reissued on the next --https start)"
+		}
+		fmt.Fprintf(output, "  Signed by CA:\t%s\n", signedByAuthority)
+	}
+
+	fmt.Fprintf(output, "\nTrust stores\n")
+	installer, installerErr := buildTrustStoreInstaller(fileSystem)
+	if installerErr != nil {
+		fmt.Fprintf(output, "  unavailable:\t%v\n", installerErr)
+		return nil
+	}
+	storeStatuses, statusErr := installer.Status(cmd.Context(), rootCertificatePath)
+	if statusErr != nil {
+		return fmt.Errorf("check trust stores: %w", statusErr)
+	}
+	for _, storeStatus := range storeStatuses {
+		installedState := "not installed"
+		if storeStatus.Installed {
+			installedState = "installed"
+		}
+		fmt.Fprintf(output, "  %s:\t%s\t%s\n", storeStatus.Store, installedState, storeStatus.Detail)
+	}
+	return nil
+}
+
+func writeCertificateSummary(output io.Writer, summary certificates.CertificateSummary) {
+	fmt.Fprintf(output, "  Subject:\t%s\n", summary.Subject)
+	fmt.Fprintf(output, "  Issuer:\t%s\n", summary.Issuer)
+	if len(summary.Hosts) > 0 {
+		fmt.Fprintf(output, "  SANs:\t%s\n", strings.Join(summary.Hosts, ", "))
+	}
+	fmt.Fprintf(output, "  Key type:\t%s\n", summary.KeyType)
+	fmt.Fprintf(output, "  Not before:\t%s\n", summary.NotBefore.Local().Format(certStatusTimeLayout))
+	remaining := time.Until(summary.NotAfter)
+	expiry := fmt.Sprintf("%d days left", int(remaining.Hours()/24))
+	if remaining <= 0 {
+		expiry = "expired"
+	}
+	fmt.Fprintf(output, "  Not after:\t%s (%s)\n", summary.NotAfter.Local().Format(certStatusTimeLayout), expiry)
+	fmt.Fprintf(output, "  SHA-256:\t%s\n", summary.SHA256Fingerprint)
+	fmt.Fprintf(output, "  SHA-1:\t%s\n", summary.SHA1Fingerprint)
+	if len(summary.NameConstraints) > 0 {
+		fmt.Fprintf(output, "  Name constraints:\t%s\n", strings.Join(summary.NameConstraints, ", "))
+	}
+}
+
+func runCertIssue(cmd *cobra.Command) error {
+	resources, err := getApplicationResources(cmd)
+	if err != nil {
+		return err
+	}
+	hostValues, _ := cmd.Flags().GetStringSlice(flagNameCertHost)
+	hosts := sanitizeHosts(hostValues)
+	if len(hosts) == 0 {
+		return fmt.Errorf("at least one --%s must be specified", flagNameCertHost)
+	}
+	for _, host := range hosts {
+		if validateErr := certificates.ValidateCertificateHost(host); validateErr != nil {
+			return validateErr
+		}
+	}
+	baseName, _ := cmd.Flags().GetString(flagNameCertName)
+	if strings.TrimSpace(baseName) == "" {
+		baseName = strings.ReplaceAll(hosts[0], "*", "_wildcard")
+	}
+	target, err := prepareCertIssue(cmd, resources, baseName)
+	if err != nil {
+		return err
+	}
+	_, issueErr := target.issuer.IssueServerCertificate(cmd.Context(), target.authorityMaterial, certificates.ServerCertificateRequest{
+		Hosts:                 hosts,
+		CertificateOutputPath: target.certificatePath,
+		PrivateKeyOutputPath:  target.privateKeyPath,
+	})
+	if issueErr != nil {
+		return fmt.Errorf("issue certificate: %w", issueErr)
+	}
+	logCertificateFiles(resources, "certificate issued", target.certificateDirectory, []string{target.certificatePath, target.privateKeyPath}, logging.Strings(logFieldHosts, hosts))
+	return nil
+}
+
+func runCertIssueClient(cmd *cobra.Command) error {
+	resources, err := getApplicationResources(cmd)
+	if err != nil {
+		return err
+	}
+	commonName, _ := cmd.Flags().GetString(flagNameCertCommonName)
+	commonName = strings.TrimSpace(commonName)
+	if commonName == "" {
+		return fmt.Errorf("--%s must be specified", flagNameCertCommonName)
+	}
+	emailValues, _ := cmd.Flags().GetStringSlice(flagNameCertEmail)
+	emailAddresses := make([]string, 0, len(emailValues))
+	for _, emailValue := range emailValues {
+		emailAddress := strings.TrimSpace(emailValue)
+		if emailAddress == "" {
+			continue
+		}
+		if _, parseErr := mail.ParseAddress(emailAddress); parseErr != nil || strings.Contains(emailAddress, "<") {
+			return fmt.Errorf("invalid --%s %q", flagNameCertEmail, emailValue)
+		}
+		emailAddresses = append(emailAddresses, emailAddress)
+	}
+	baseName, _ := cmd.Flags().GetString(flagNameCertName)
+	if strings.TrimSpace(baseName) == "" {
+		baseName = strings.ReplaceAll(commonName, " ", "_")
+	}
+	target, err := prepareCertIssue(cmd, resources, baseName)
+	if err != nil {
+		return err
+	}
+	_, issueErr := target.issuer.IssueClientCertificate(cmd.Context(), target.authorityMaterial, certificates.ClientCertificateRequest{
+		CommonName:            commonName,
+		EmailAddresses:        emailAddresses,
+		CertificateOutputPath: target.certificatePath,
+		PrivateKeyOutputPath:  target.privateKeyPath,
+	})
+	if issueErr != nil {
+		return fmt.Errorf("issue client certificate: %w", issueErr)
+	}
+	logCertificateFiles(resources, "client certificate issued", target.certificateDirectory, []string{target.certificatePath, target.privateKeyPath}, logging.String(logFieldCommonName, commonName))
+	return nil
+}
+
+// prepareCertIssue validates the shared issue flags, loads or creates the certificate authority, ensures the output
+// directory, and returns an issuer configured with the requested validity and key algorithm along with the output
+// paths.
+func prepareCertIssue(cmd *cobra.Command, resources *applicationResources, baseName string) (certIssueTarget, error) {
+	if strings.ContainsAny(baseName, `/\:`) {
+		return certIssueTarget{}, fmt.Errorf("certificate name %q must not contain path separators", baseName)
+	}
+	outputDirectory, _ := cmd.Flags().GetString(flagNameCertOutputDirectory)
+	validity, _ := cmd.Flags().GetDuration(flagNameCertValidity)
+	if validity <= 0 {
+		return certIssueTarget{}, fmt.Errorf("--%s must be positive", flagNameCertValidity)
+	}
+	certificateDirectory, err := resolveCertificateDirectory(resources.configurationManager)
+	if err != nil {
+		return certIssueTarget{}, err
+	}
+	keyAlgorithm, err := resolveKeyAlgorithm(resources.configurationManager)
+	if err != nil {
+		return certIssueTarget{}, err
+	}
+	nameConstraints, err := resolveNameConstraints(resources.configurationManager)
+	if err != nil {
+		return certIssueTarget{}, err
+	}
+
+	fileSystem := certificates.NewOperatingSystemFileSystem()
+	manager := certificates.NewCertificateAuthorityManager(fileSystem, certificates.NewSystemClock(), rand.Reader, buildCertificateAuthorityConfiguration(certificateDirectory, keyAlgorithm, nameConstraints))
+	authorityMaterial, authorityErr := loadCertIssueAuthority(cmd.Context(), manager, certificateDirectory)
+	if authorityErr != nil {
+		return certIssueTarget{}, authorityErr
+	}
+	if authorityMaterial.Generated {
+		logCertificateMessage(resources, "certificate authority created (run ghttp cert trust to trust it)", certificateDirectory)
+	}
+	if makeErr := fileSystem.EnsureDirectory(outputDirectory, 0o755); makeErr != nil {
+		return certIssueTarget{}, fmt.Errorf("create output directory: %w", makeErr)
+	}
+
+	issuer := certificates.NewServerCertificateIssuer(fileSystem, certificates.NewSystemClock(), rand.Reader, certificates.ServerCertificateConfiguration{
+		CertificateValidityDuration:      validity,
+		CertificateRenewalWindowDuration: min(leafCertificateRenewalWindow, validity/2),
+		KeyAlgorithm:                     keyAlgorithm,
+		LeafPrivateKeyBitSize:            leafCertificateKeyBits,
+		CertificateFilePermissions:       certExportFilePermissions,
+		PrivateKeyFilePermissions:        0o600,
+	})
+	return certIssueTarget{
+		issuer:               issuer,
+		authorityMaterial:    authorityMaterial,
+		certificateDirectory: certificateDirectory,
+		certificatePath:      filepath.Join(outputDirectory, baseName+".pem"),
+		privateKeyPath:       filepath.Join(outputDirectory, baseName+".key"),
+	}, nil
+}
+
+// loadCertIssueAuthority returns the persisted certificate authority, creating it only when none exists. Issuing a leaf
+// never replaces a possibly trusted authority, so one created with another key algorithm or other name constraints
+// is refused rather than rotated.
+func loadCertIssueAuthority(ctx context.Context, manager certificates.CertificateAuthorityManager, certificateDirectory string) (certificates.CertificateAuthorityMaterial, error) {
+	authorityMaterial, loadErr := manager.LoadCertificateAuthority()
+	if errors.Is(loadErr, fs.ErrNotExist) {
+		authorityMaterial, ensureErr := manager.EnsureCertificateAuthority(ctx)
+		if ensureErr != nil {
+			return certificates.CertificateAuthorityMaterial{}, fmt.Errorf("ensure certificate authority: %w", ensureErr)
+		}
+		return authorityMaterial, nil
+	}
+	if loadErr != nil {
+		return certificates.CertificateAuthorityMaterial{}, fmt.Errorf("load certificate authority: %w", loadErr)
+	}
+	if mismatchErr := manager.CheckConfiguration(authorityMaterial.Certificate); mismatchErr != nil {
+		return certificates.CertificateAuthorityMaterial{}, fmt.Errorf("certificate authority in %s does not match the requested flags (run ghttp https install with them to replace it): %w", certificateDirectory, mismatchErr)
+	}
+	return authorityMaterial, nil
+}
+
+func runCertExport(cmd *cobra.Command) error {
+	resources, err := getApplicationResources(cmd)
+	if err != nil {
+		return err
+	}
+	formats, _ := cmd.Flags().GetStringSlice(flagNameCertFormat)
+	outputDirectory, _ := cmd.Flags().GetString(flagNameCertOutputDirectory)
+	certificateDirectory, err := resolveCertificateDirectory(resources.configurationManager)
+	if err != nil {
+		return err
+	}
+	fileSystem := certificates.NewOperatingSystemFileSystem()
+	manager := certificates.NewCertificateAuthorityManager(fileSystem, certificates.NewSystemClock(), rand.Reader, buildCertificateAuthorityConfiguration(certificateDirectory, "", certificates.NameConstraints{}))
+	authorityMaterial, authorityErr := manager.LoadCertificateAuthority()
+	if errors.Is(authorityErr, fs.ErrNotExist) {
+		return fmt.Errorf("no certificate authority in %s (run ghttp https install)", certificateDirectory)
+	}
+	if authorityErr != nil {
+		return fmt.Errorf("load certificate authority: %w", authorityErr)
+	}
+
+	exportedFiles := make([]certExportFile, 0, len(formats))
+	for _, format := range formats {
+		switch strings.ToLower(strings.TrimSpace(format)) {
+		case certExportFormatPEM:
+			exportedFiles = append(exportedFiles, certExportFile{path: filepath.Join(outputDirectory, certExportBaseName+".pem"), content: authorityMaterial.CertificateBytes, permissions: certExportFilePermissions})
+		case certExportFormatDER:
+			exportedFiles = append(exportedFiles, certExportFile{path: filepath.Join(outputDirectory, certExportBaseName+".der"), content: authorityMaterial.Certificate.Raw, permissions: certExportFilePermissions})
+		case certExportFormatPKCS12:
+			bundlePath, bundle, bundleErr := buildLeafPKCS12Bundle(cmd, resources, fileSystem, certificateDirectory, authorityMaterial, outputDirectory)
+			if bundleErr != nil {
+				return bundleErr
+			}
+			exportedFiles = append(exportedFiles, certExportFile{path: bundlePath, content: bundle, permissions: 0o600})
+		default:
+			return fmt.Errorf("unsupported export format %q (use pem, der, or p12)", format)
+		}
+	}
+	if len(exportedFiles) == 0 {
+		return fmt.Errorf("at least one --%s must be specified", flagNameCertFormat)
+	}
+
+	if makeErr := fileSystem.EnsureDirectory(outputDirectory, 0o755); makeErr != nil {
+		return fmt.Errorf("create output directory: %w", makeErr)
+	}
+	writtenFiles := make([]string, 0, len(exportedFiles))
+	for _, exportedFile := range exportedFiles {
+		if writeErr := fileSystem.WriteFile(exportedFile.path, exportedFile.content, exportedFile.permissions); writeErr != nil {
+			return fmt.Errorf("write %s: %w", exportedFile.path, writeErr)
+		}
+		writtenFiles = append(writtenFiles, exportedFile.path)
+	}
+	logCertificateFiles(resources, "certificate exported", certificateDirectory, writtenFiles)
+	return nil
+}
+
+// buildLeafPKCS12Bundle packs a leaf certificate, its key, and the certificate authority into a PKCS#12 file named
+// after the leaf certificate.
+func buildLeafPKCS12Bundle(cmd *cobra.Command, resources *applicationResources, fileSystem certificates.FileSystem, certificateDirectory string, authorityMaterial certificates.CertificateAuthorityMaterial, outputDirectory string) (string, []byte, error) {
+	password := resources.configurationManager.GetString(configKeyCertExportPassword)
+	if password == "" {
+		return "", nil, fmt.Errorf("the p12 format needs --%s or GHTTP_CERT_EXPORT_PASSWORD", flagNameCertPassword)
+	}
+	leafCertificatePath, _ := cmd.Flags().GetString(flagNameCertLeafCertificate)
+	leafPrivateKeyPath, _ := cmd.Flags().GetString(flagNameCertLeafPrivateKey)
+	if leafCertificatePath == "" {
+		leafCertificatePath = filepath.Join(certificateDirectory, certificates.DefaultLeafCertificateFileName)
+	}
+	if leafPrivateKeyPath == "" {
+		leafPrivateKeyPath = strings.TrimSuffix(leafCertificatePath, filepath.Ext(leafCertificatePath)) + ".key"
+	}
+	issuer := certificates.NewServerCertificateIssuer(fileSystem, certificates.NewSystemClock(), rand.Reader, certificates.ServerCertificateConfiguration{})
+	leafMaterial, leafErr := issuer.LoadServerCertificate(certificates.ServerCertificateRequest{
+		CertificateOutputPath: leafCertificatePath,
+		PrivateKeyOutputPath:  leafPrivateKeyPath,
+	})
+	if errors.Is(leafErr, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("no leaf certificate at %s (run ghttp --https or ghttp cert issue)", leafCertificatePath)
+	}
+	if leafErr != nil {
+		return "", nil, fmt.Errorf("load leaf certificate: %w", leafErr)
+	}
+	leafName := strings.TrimSuffix(filepath.Base(leafCertificatePath), filepath.Ext(leafCertificatePath))
+	bundle, bundleErr := certificates.EncodePKCS12(leafMaterial.PrivateKey, leafMaterial.TLSCertificate, []*x509.Certificate{authorityMaterial.Certificate}, leafName, password, rand.Reader)
+	if bundleErr != nil {
+		return "", nil, fmt.Errorf("encode p12 bundle: %w", bundleErr)
+	}
+	return filepath.Join(outputDirectory, leafName+".p12"), bundle, nil
+}
+
+func runCertTrust(cmd *cobra.Command) error {
+	resources, err := getApplicationResources(cmd)
+	if err != nil {
+		return err
+	}
+	certificateDirectory, err := resolveCertificateDirectory(resources.configurationManager)
+	if err != nil {
+		return err
+	}
+	fileSystem := certificates.NewOperatingSystemFileSystem()
+	rootCertificatePath := filepath.Join(certificateDirectory, certificates.DefaultRootCertificateFileName)
+	exists, existsErr := fileSystem.FileExists(rootCertificatePath)
+	if existsErr != nil {
+		return fmt.Errorf("check certificate authority: %w", existsErr)
+	}
+	if !exists {
+		return fmt.Errorf("no certificate authority in %s (run ghttp https install)", certificateDirectory)
+	}
+	installer, installerErr := buildTrustStoreInstaller(fileSystem)
+	if installerErr != nil {
+		return installerErr
+	}
+	if installErr := installer.Install(cmd.Context(), rootCertificatePath); installErr != nil {
+		return fmt.Errorf("install certificate authority: %w", installErr)
+	}
+	logCertificateMessage(resources, "certificate authority trusted", certificateDirectory)
+	return nil
+}
+
+func runCertUntrust(cmd *cobra.Command) error {
+	resources, err := getApplicationResources(cmd)
+	if err != nil {
+		return err
+	}
+	certificateDirectory, err := resolveCertificateDirectory(resources.configurationManager)
+	if err != nil {
+		return err
+	}
+	installer, installerErr := buildTrustStoreInstaller(certificates.NewOperatingSystemFileSystem())
+	if installerErr != nil {
+		return installerErr
+	}
+	if uninstallErr := installer.Uninstall(cmd.Context()); uninstallErr != nil {
+		return fmt.Errorf("uninstall certificate authority: %w", uninstallErr)
+	}
+	logCertificateMessage(resources, "certificate authority untrusted", certificateDirectory)
+	return nil
+}
+
+func logCertificateFiles(resources *applicationResources, message string, directory string, files []string, fields ...logging.Field) {
+	if resources.loggingService == nil {
+		return
+	}
+	resources.loggingService.Info(message, append([]logging.Field{certificateDirectoryField(directory), logging.Strings(logFieldFiles, files)}, fields...)...)
+}
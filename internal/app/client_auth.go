@@ -0,0 +1,39 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveClientAuthPolicies(configurationManager *viper.Viper, tlsEnabled bool) (server.ClientAuthPolicies, error) {
+	clientCABundlePath := strings.TrimSpace(configurationManager.GetString(configKeyServeClientCA))
+	if clientCABundlePath != "" && !tlsEnabled {
+		return server.ClientAuthPolicies{}, errors.New("client certificate verification requires --https or --tls-cert")
+	}
+	clientAuthPolicies, clientAuthErr := server.NewClientAuthPolicies(
+		clientCABundlePath,
+		configurationManager.GetString(configKeyServeClientAuth),
+		normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeClientAuthRoutes)),
+	)
+	if clientAuthErr != nil {
+		return server.ClientAuthPolicies{}, fmt.Errorf("parse client auth configuration: %w", clientAuthErr)
+	}
+	return clientAuthPolicies, nil
+}
+
+func resolveProxyRequestHeaders(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (server.ProxyRequestHeaders, error) {
+	requestHeaderMappings := resolveMappingValues(configurationManager, configKeyServeProxyRequestHeader)
+	proxyRequestHeaders, requestHeaderErr := server.NewProxyRequestHeaders(requestHeaderMappings)
+	if requestHeaderErr != nil {
+		return server.ProxyRequestHeaders{}, fmt.Errorf("parse proxy request header mappings: %w", requestHeaderErr)
+	}
+	if proxyRoutes.IsEmpty() && !proxyRequestHeaders.IsEmpty() {
+		return server.ProxyRequestHeaders{}, fmt.Errorf("%w: proxy request header mappings require proxy mappings", errInvalidProxyConfiguration)
+	}
+	return proxyRequestHeaders, nil
+}
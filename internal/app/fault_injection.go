@@ -0,0 +1,22 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveFaultInjector(configurationManager *viper.Viper) (*server.FaultInjector, error) {
+	fixturePath := strings.TrimSpace(configurationManager.GetString(configKeyServeFaults))
+	if fixturePath == "" {
+		return nil, nil
+	}
+	faultInjector, loadErr := server.LoadFaultInjector(fixturePath)
+	if loadErr != nil {
+		return nil, fmt.Errorf("load fault fixture: %w", loadErr)
+	}
+	return faultInjector, nil
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveHTTPListener(configurationManager *viper.Viper, tlsEnabled bool, httpsPort string) (*server.HTTPListenerConfiguration, error) {
+	httpPort := strings.TrimSpace(configurationManager.GetString(configKeyServeHTTPPort))
+	redirectToHTTPS := configurationManager.GetBool(configKeyServeHTTPSRedirect)
+	excludedPaths := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeHTTPSRedirectSkip))
+	if len(excludedPaths) > 0 && !redirectToHTTPS {
+		return nil, fmt.Errorf("--%s requires --%s", flagNameHTTPSRedirectSkip, flagNameHTTPSRedirect)
+	}
+	if httpPort == "" {
+		if redirectToHTTPS {
+			return nil, fmt.Errorf("--%s requires --%s", flagNameHTTPSRedirect, flagNameHTTPPort)
+		}
+		return nil, nil
+	}
+	if !tlsEnabled {
+		return nil, fmt.Errorf("--%s requires --https or --tls-cert", flagNameHTTPPort)
+	}
+	portNumber, portErr := strconv.Atoi(httpPort)
+	if portErr != nil || portNumber <= 0 || portNumber > 65535 {
+		return nil, fmt.Errorf("invalid http port %s", httpPort)
+	}
+	if httpPort == httpsPort {
+		return nil, fmt.Errorf("--%s must differ from the https port %s", flagNameHTTPPort, httpsPort)
+	}
+	for _, excludedPath := range excludedPaths {
+		if !strings.HasPrefix(excludedPath, "/") {
+			return nil, fmt.Errorf("--%s path %q must start with /", flagNameHTTPSRedirectSkip, excludedPath)
+		}
+	}
+	return &server.HTTPListenerConfiguration{
+		Port:                  httpPort,
+		RedirectToHTTPS:       redirectToHTTPS,
+		RedirectExcludedPaths: excludedPaths,
+	}, nil
+}
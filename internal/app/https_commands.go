@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/tyemirov/ghttp/internal/certificates"
@@ -31,9 +32,50 @@ const (
 	leafCertificateRenewalWindow         = 72 * time.Hour
 	logFieldCertificateDirectory         = "certificate_directory"
 	logFieldHosts                        = "hosts"
+	logFieldHost                         = "host"
+	logFieldNotAfter                     = "not_after"
+	onDemandCertificateDirectoryName     = "on-demand"
+	defaultOnDemandCacheSize             = 1024
 )
 
-func runHTTPSSetup(cmd *cobra.Command) error {
+func newHTTPSCommand(resources *applicationResources) *cobra.Command {
+	httpsCommand := &cobra.Command{
+		Use:   "https",
+		Short: "Manage the development certificate authority used by --https",
+		Args:  cobra.NoArgs,
+	}
+
+	installCommand := &cobra.Command{
+		Use:   "install",
+		Short: "Create the development certificate authority and install it into the system trust stores",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return bindCertificateAuthorityFlags(resources.configurationManager, cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHTTPSSetup(cmd, false)
+		},
+	}
+	installCommand.Flags().String(flagNameHTTPSKeyAlgorithm, resources.configurationManager.GetString(configKeyHTTPSKeyAlgorithm), "Key algorithm for the certificate authority: rsa, ecdsa-p256, ecdsa-p384, or ed25519")
+	addNameConstraintFlags(installCommand.Flags(), resources.configurationManager)
+
+	uninstallCommand := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the development certificate authority from the system trust stores and delete its files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHTTPSUninstall(cmd)
+		},
+	}
+
+	httpsCommand.AddCommand(installCommand, uninstallCommand)
+	return httpsCommand
+}
+
+// runHTTPSSetup ensures the certificate authority exists and installs it into the trust stores. With reuseInstalled
+// set, an authority loaded from disk that every trust store already holds is left alone; a new or rotated authority,
+// or one removed with cert untrust, is installed.
+func runHTTPSSetup(cmd *cobra.Command, reuseInstalled bool) error {
 	resources, err := getApplicationResources(cmd)
 	if err != nil {
 		return err
@@ -43,10 +85,19 @@ func runHTTPSSetup(cmd *cobra.Command) error {
 		return err
 	}
 
+	keyAlgorithm, err := resolveKeyAlgorithm(resources.configurationManager)
+	if err != nil {
+		return err
+	}
+	nameConstraints, err := resolveNameConstraints(resources.configurationManager)
+	if err != nil {
+		return err
+	}
+
 	fileSystem := certificates.NewOperatingSystemFileSystem()
-	certificateConfiguration := buildCertificateAuthorityConfiguration(certificateDirectory)
+	certificateConfiguration := buildCertificateAuthorityConfiguration(certificateDirectory, keyAlgorithm, nameConstraints)
 	manager := certificates.NewCertificateAuthorityManager(fileSystem, certificates.NewSystemClock(), rand.Reader, certificateConfiguration)
-	_, ensureErr := manager.EnsureCertificateAuthority(cmd.Context())
+	material, ensureErr := manager.EnsureCertificateAuthority(cmd.Context())
 	if ensureErr != nil {
 		return fmt.Errorf("ensure certificate authority: %w", ensureErr)
 	}
@@ -55,7 +106,15 @@ func runHTTPSSetup(cmd *cobra.Command) error {
 	if installerErr != nil {
 		return installerErr
 	}
-	installErr := installer.Install(cmd.Context(), filepath.Join(certificateDirectory, certificates.DefaultRootCertificateFileName))
+	rootCertificatePath := filepath.Join(certificateDirectory, certificates.DefaultRootCertificateFileName)
+	if reuseInstalled && !material.Generated {
+		storeStatuses, statusErr := installer.Status(cmd.Context(), rootCertificatePath)
+		if statusErr == nil && allTrustStoresInstalled(storeStatuses) {
+			logCertificateMessage(resources, "certificate authority reused", certificateDirectory)
+			return nil
+		}
+	}
+	installErr := installer.Install(cmd.Context(), rootCertificatePath)
 	if installErr != nil {
 		return fmt.Errorf("install certificate authority: %w", installErr)
 	}
@@ -64,9 +123,26 @@ func runHTTPSSetup(cmd *cobra.Command) error {
 	return nil
 }
 
+func allTrustStoresInstalled(storeStatuses []truststore.StoreStatus) bool {
+	for _, storeStatus := range storeStatuses {
+		if !storeStatus.Installed {
+			return false
+		}
+	}
+	return true
+}
+
 func executeHTTPSServe(cmd *cobra.Command, resources *applicationResources, serveConfiguration ServeConfiguration, hosts []string, certificateDirectory string) error {
+	keyAlgorithm, keyAlgorithmErr := resolveKeyAlgorithm(resources.configurationManager)
+	if keyAlgorithmErr != nil {
+		return keyAlgorithmErr
+	}
+	nameConstraints, nameConstraintsErr := resolveNameConstraints(resources.configurationManager)
+	if nameConstraintsErr != nil {
+		return nameConstraintsErr
+	}
 	fileSystem := certificates.NewOperatingSystemFileSystem()
-	certificateAuthorityConfiguration := buildCertificateAuthorityConfiguration(certificateDirectory)
+	certificateAuthorityConfiguration := buildCertificateAuthorityConfiguration(certificateDirectory, keyAlgorithm, nameConstraints)
 	certificateAuthorityManager := certificates.NewCertificateAuthorityManager(fileSystem, certificates.NewSystemClock(), rand.Reader, certificateAuthorityConfiguration)
 	certificateAuthorityMaterial, ensureErr := certificateAuthorityManager.EnsureCertificateAuthority(cmd.Context())
 	if ensureErr != nil {
@@ -76,6 +152,7 @@ func executeHTTPSServe(cmd *cobra.Command, resources *applicationResources, serv
 	issuerConfiguration := certificates.ServerCertificateConfiguration{
 		CertificateValidityDuration:      leafCertificateValidityDuration,
 		CertificateRenewalWindowDuration: leafCertificateRenewalWindow,
+		KeyAlgorithm:                     keyAlgorithm,
 		LeafPrivateKeyBitSize:            leafCertificateKeyBits,
 		CertificateFilePermissions:       0o600,
 		PrivateKeyFilePermissions:        0o600,
@@ -88,14 +165,40 @@ func executeHTTPSServe(cmd *cobra.Command, resources *applicationResources, serv
 		CertificateOutputPath: leafCertificatePath,
 		PrivateKeyOutputPath:  leafKeyPath,
 	}
-	leafMaterial, issueErr := issuer.IssueServerCertificate(cmd.Context(), certificateAuthorityMaterial, serverCertificateRequest)
-	if issueErr != nil {
-		return fmt.Errorf("issue server certificate: %w", issueErr)
+	renewingLeaf, leafErr := certificates.NewRenewingLeafCertificate(cmd.Context(), issuer, certificateAuthorityMaterial, serverCertificateRequest, func(certificate *tls.Certificate, err error) {
+		logLeafCertificateRenewal(resources, certificate, err)
+	})
+	if leafErr != nil {
+		return leafErr
+	}
+	tlsConfiguration := &server.TLSConfiguration{
+		DefaultCertificate: renewingLeaf.Certificate,
+		ClientAuth:         serveConfiguration.ClientAuth,
+		ProtocolPolicy:     serveConfiguration.TLSProtocolPolicy,
 	}
 
-	tlsCertificate, parseErr := tls.X509KeyPair(leafMaterial.CertificateBytes, leafMaterial.PrivateKeyBytes)
-	if parseErr != nil {
-		return fmt.Errorf("parse server certificate: %w", parseErr)
+	onDemandPatterns := sanitizeHosts(normalizeCommaDelimitedMappings(resources.configurationManager.GetStringSlice(configKeyHTTPSOnDemandHosts)))
+	if len(onDemandPatterns) > 0 {
+		onDemandCacheSize := resources.configurationManager.GetInt(configKeyHTTPSOnDemandCacheSize)
+		if onDemandCacheSize < 1 {
+			return fmt.Errorf("--%s must be at least 1", flagNameHTTPSOnDemandLimit)
+		}
+		onDemandConfiguration := certificates.OnDemandConfiguration{
+			HostPatterns:          onDemandPatterns,
+			DefaultHosts:          hosts,
+			MaxCachedCertificates: onDemandCacheSize,
+			Observer: func(host string, err error) {
+				logOnDemandCertificate(resources, host, err)
+			},
+		}
+		if resources.configurationManager.GetBool(configKeyHTTPSOnDemandPersist) {
+			onDemandConfiguration.CacheDirectoryPath = filepath.Join(certificateDirectory, onDemandCertificateDirectoryName)
+		}
+		onDemandIssuer, onDemandErr := certificates.NewOnDemandCertificateIssuer(issuer, certificateAuthorityMaterial, onDemandConfiguration)
+		if onDemandErr != nil {
+			return fmt.Errorf("configure on-demand certificates: %w", onDemandErr)
+		}
+		tlsConfiguration.GetCertificate = onDemandIssuer.GetCertificate
 	}
 
 	fileServerConfiguration := server.FileServerConfiguration{
@@ -103,17 +206,38 @@ func executeHTTPSServe(cmd *cobra.Command, resources *applicationResources, serv
 		Port:                    serveConfiguration.Port,
 		DirectoryPath:           serveConfiguration.DirectoryPath,
 		ProtocolVersion:         serveConfiguration.ProtocolVersion,
+		EnableH2C:               serveConfiguration.EnableH2C,
 		DisableDirectoryListing: serveConfiguration.DisableDirectoryListing,
 		EnableMarkdown:          serveConfiguration.EnableMarkdown,
 		BrowseDirectories:       serveConfiguration.BrowseDirectories,
 		InitialFileRelativePath: serveConfiguration.InitialFileRelativePath,
 		LoggingType:             serveConfiguration.LoggingType,
+		ReadHeaderTimeout:       serveConfiguration.ReadHeaderTimeout,
+		ReadTimeout:             serveConfiguration.ReadTimeout,
+		WriteTimeout:            serveConfiguration.WriteTimeout,
+		IdleTimeout:             serveConfiguration.IdleTimeout,
+		MaxHeaderBytes:          serveConfiguration.MaxHeaderBytes,
+		RequestLimits:           serveConfiguration.RequestLimits,
 		ProxyRoutes:             serveConfiguration.ProxyRoutes,
 		RouteResponsePolicies:   serveConfiguration.RouteResponsePolicies,
 		ProxyStreamingPolicies:  serveConfiguration.ProxyStreamingPolicies,
-		TLS: &server.TLSConfiguration{
-			LoadedCertificate: &tlsCertificate,
-		},
+		ProxyBackendTLSPolicies: serveConfiguration.ProxyBackendTLSPolicies,
+		ProxyGRPCWebPolicies:    serveConfiguration.ProxyGRPCWebPolicies,
+		ProxyFallbackPolicies:   serveConfiguration.ProxyFallbackPolicies,
+		ProxyMirrors:            serveConfiguration.ProxyMirrors,
+		ProxyRewritePolicies:    serveConfiguration.ProxyRewritePolicies,
+		ProxyCache:              serveConfiguration.ProxyCache,
+		ProxyWebSocketPing:      serveConfiguration.ProxyWebSocketPing,
+		ProxyWebSocketIdle:      serveConfiguration.ProxyWebSocketIdle,
+		ProxyWebSocketInspector: serveConfiguration.ProxyWebSocketInspector,
+		MockRoutes:              serveConfiguration.MockRoutes,
+		RESTStores:              serveConfiguration.RESTStores,
+		FaultInjector:           serveConfiguration.FaultInjector,
+		ProxyTrafficRecorder:    serveConfiguration.ProxyTrafficRecorder,
+		ProxyTrafficReplayer:    serveConfiguration.ProxyTrafficReplayer,
+		ProxyRequestHeaders:     serveConfiguration.ProxyRequestHeaders,
+		HTTPListener:            serveConfiguration.HTTPListener,
+		TLS:                     tlsConfiguration,
 	}
 
 	logServingHTTPSMessage(resources, certificateDirectory, hosts)
@@ -153,6 +277,7 @@ func runHTTPSUninstall(cmd *cobra.Command) error {
 	for _, target := range removalTargets {
 		_ = fileSystem.Remove(target)
 	}
+	_ = os.RemoveAll(filepath.Join(certificateDirectory, onDemandCertificateDirectoryName))
 	logCertificateMessage(resources, "certificate authority uninstalled", certificateDirectory)
 	return nil
 }
@@ -188,7 +313,46 @@ func resolveCertificateDirectory(configurationManager *viper.Viper) (string, err
 	return absoluteDirectory, nil
 }
 
-func buildCertificateAuthorityConfiguration(certificateDirectory string) certificates.CertificateAuthorityConfiguration {
+func resolveKeyAlgorithm(configurationManager *viper.Viper) (certificates.KeyAlgorithm, error) {
+	keyAlgorithm, parseErr := certificates.ParseKeyAlgorithm(configurationManager.GetString(configKeyHTTPSKeyAlgorithm))
+	if parseErr != nil {
+		return "", fmt.Errorf("resolve https key algorithm: %w", parseErr)
+	}
+	return keyAlgorithm, nil
+}
+
+// resolveNameConstraints returns no constraints unless https.name_constraints is set. The constrained authority
+// permits the development defaults plus the configured --https-host names, --https-on-demand patterns, and
+// --https-ca-domain domains, so changing any of them rotates it.
+func resolveNameConstraints(configurationManager *viper.Viper) (certificates.NameConstraints, error) {
+	if !configurationManager.GetBool(configKeyHTTPSNameConstraints) {
+		return certificates.NameConstraints{}, nil
+	}
+	permittedNames := sanitizeHosts(configurationManager.GetStringSlice(configKeyHTTPSHosts))
+	permittedNames = append(permittedNames, normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyHTTPSOnDemandHosts))...)
+	permittedNames = append(permittedNames, normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyHTTPSCADomains))...)
+	nameConstraints, buildErr := certificates.BuildNameConstraints(permittedNames)
+	if buildErr != nil {
+		return certificates.NameConstraints{}, fmt.Errorf("resolve https name constraints: %w", buildErr)
+	}
+	return nameConstraints, nil
+}
+
+func addNameConstraintFlags(flagSet *pflag.FlagSet, configurationManager *viper.Viper) {
+	flagSet.Bool(flagNameHTTPSNameConstrain, configurationManager.GetBool(configKeyHTTPSNameConstraints), "Limit the development CA to localhost, .test, .local, private IPs, and the configured hosts and domains with X.509 name constraints")
+	flagSet.StringSlice(flagNameHTTPSCADomain, configurationManager.GetStringSlice(configKeyHTTPSCADomains), "Additional domain or IP range the name-constrained development CA may sign for (repeatable)")
+}
+
+// bindCertificateAuthorityFlags binds the subcommand flags that shape the development CA.
+func bindCertificateAuthorityFlags(configurationManager *viper.Viper, flagSet *pflag.FlagSet) error {
+	return errors.Join(
+		configurationManager.BindPFlag(configKeyHTTPSKeyAlgorithm, flagSet.Lookup(flagNameHTTPSKeyAlgorithm)),
+		configurationManager.BindPFlag(configKeyHTTPSNameConstraints, flagSet.Lookup(flagNameHTTPSNameConstrain)),
+		configurationManager.BindPFlag(configKeyHTTPSCADomains, flagSet.Lookup(flagNameHTTPSCADomain)),
+	)
+}
+
+func buildCertificateAuthorityConfiguration(certificateDirectory string, keyAlgorithm certificates.KeyAlgorithm, nameConstraints certificates.NameConstraints) certificates.CertificateAuthorityConfiguration {
 	return certificates.CertificateAuthorityConfiguration{
 		DirectoryPath:                    certificateDirectory,
 		CertificateFileName:              certificates.DefaultRootCertificateFileName,
@@ -196,12 +360,14 @@ func buildCertificateAuthorityConfiguration(certificateDirectory string) certifi
 		DirectoryPermissions:             0o700,
 		CertificateFilePermissions:       0o600,
 		PrivateKeyFilePermissions:        0o600,
+		KeyAlgorithm:                     keyAlgorithm,
 		RSAKeyBitSize:                    certificateAuthorityKeyBits,
 		CertificateValidityDuration:      certificateAuthorityValidityDuration,
 		CertificateRenewalWindowDuration: certificateAuthorityRenewalWindow,
 		SubjectCommonName:                certificates.DefaultCertificateAuthorityCommonName,
 		SubjectOrganizationalUnit:        certificates.DefaultCertificateAuthorityOrganizationalUnit,
 		SubjectOrganization:              certificates.DefaultCertificateAuthorityOrganization,
+		NameConstraints:                  nameConstraints,
 	}
 }
 
@@ -258,3 +424,26 @@ func logServingHTTPSMessage(resources *applicationResources, directory string, h
 	}
 	resources.loggingService.Info("serving https", certificateDirectoryField(directory), logging.Strings(logFieldHosts, hosts))
 }
+
+func logLeafCertificateRenewal(resources *applicationResources, certificate *tls.Certificate, err error) {
+	if resources.loggingService == nil {
+		return
+	}
+	expiryField := logging.String(logFieldNotAfter, certificate.Leaf.NotAfter.UTC().Format(time.RFC3339))
+	if err != nil {
+		resources.loggingService.Error("https certificate renewal failed", err, expiryField)
+		return
+	}
+	resources.loggingService.Info("https certificate renewed", expiryField)
+}
+
+func logOnDemandCertificate(resources *applicationResources, host string, err error) {
+	if resources.loggingService == nil {
+		return
+	}
+	if err != nil {
+		resources.loggingService.Error("https certificate issue failed", err, logging.String(logFieldHost, host))
+		return
+	}
+	resources.loggingService.Info("https certificate issued", logging.String(logFieldHost, host))
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveMockRoutes(configurationManager *viper.Viper, directoryPath string) (*server.MockRoutes, error) {
+	fixturePath := strings.TrimSpace(configurationManager.GetString(configKeyServeMocks))
+	if fixturePath == "" {
+		return nil, nil
+	}
+	absoluteFixturePath, absoluteErr := filepath.Abs(fixturePath)
+	if absoluteErr != nil {
+		return nil, fmt.Errorf("resolve mock fixture path: %w", absoluteErr)
+	}
+	mockRoutes, loadErr := server.LoadMockRoutes(absoluteFixturePath, directoryPath)
+	if loadErr != nil {
+		return nil, fmt.Errorf("load mock fixture: %w", loadErr)
+	}
+	return mockRoutes, nil
+}
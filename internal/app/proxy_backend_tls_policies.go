@@ -0,0 +1,54 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/certificates"
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyBackendTLSPolicies(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (server.ProxyBackendTLSPolicies, error) {
+	backendTLSMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyBackendTLS))
+	if proxyRoutes.IsEmpty() {
+		if len(backendTLSMappings) > 0 {
+			return server.ProxyBackendTLSPolicies{}, fmt.Errorf("%w: proxy backend tls mappings require proxy mappings", errInvalidProxyConfiguration)
+		}
+		return server.ProxyBackendTLSPolicies{}, nil
+	}
+	defaultCertificateAuthorityPEM, readErr := readDevelopmentCertificateAuthority(configurationManager)
+	if readErr != nil {
+		return server.ProxyBackendTLSPolicies{}, readErr
+	}
+	backendTLSPolicies, backendTLSErr := server.NewProxyBackendTLSPolicies(backendTLSMappings, defaultCertificateAuthorityPEM)
+	if backendTLSErr != nil {
+		return server.ProxyBackendTLSPolicies{}, fmt.Errorf("parse proxy backend tls mappings: %w", backendTLSErr)
+	}
+	if routesErr := backendTLSPolicies.ValidateRoutes(proxyRoutes); routesErr != nil {
+		return server.ProxyBackendTLSPolicies{}, fmt.Errorf("parse proxy backend tls mappings: %w", routesErr)
+	}
+	return backendTLSPolicies, nil
+}
+
+// readDevelopmentCertificateAuthority returns the development root certificate when one has been provisioned,
+// so proxied https backends using certificates issued by gHTTP are trusted without extra configuration.
+func readDevelopmentCertificateAuthority(configurationManager *viper.Viper) ([]byte, error) {
+	certificateDirectory := strings.TrimSpace(configurationManager.GetString(configKeyHTTPSCertificateDir))
+	if certificateDirectory == "" {
+		return nil, nil
+	}
+	certificateBytes, readErr := os.ReadFile(filepath.Join(certificateDirectory, certificates.DefaultRootCertificateFileName))
+	if readErr != nil {
+		if errors.Is(readErr, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read development certificate authority: %w", readErr)
+	}
+	return certificateBytes, nil
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyCache(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (*server.ProxyCache, error) {
+	cacheMode := strings.TrimSpace(configurationManager.GetString(configKeyServeProxyCache))
+	cacheDirectory := strings.TrimSpace(configurationManager.GetString(configKeyServeProxyCacheDir))
+	ttlMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyCacheTTL))
+	if cacheMode == "" {
+		if cacheDirectory != "" || len(ttlMappings) > 0 {
+			return nil, fmt.Errorf("%w: proxy cache options require --%s", errInvalidProxyConfiguration, flagNameProxyCache)
+		}
+		return nil, nil
+	}
+	if proxyRoutes.IsEmpty() {
+		return nil, fmt.Errorf("%w: proxy cache requires proxy mappings", errInvalidProxyConfiguration)
+	}
+	proxyCache, cacheErr := server.NewProxyCache(cacheMode, cacheDirectory, ttlMappings)
+	if cacheErr != nil {
+		return nil, fmt.Errorf("configure proxy cache: %w", cacheErr)
+	}
+	return proxyCache, nil
+}
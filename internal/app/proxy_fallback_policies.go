@@ -0,0 +1,21 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyFallbackPolicies(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (server.ProxyFallbackPolicies, error) {
+	fallbackMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyFallback))
+	fallbackPolicies, fallbackErr := server.NewProxyFallbackPolicies(fallbackMappings)
+	if fallbackErr != nil {
+		return server.ProxyFallbackPolicies{}, fmt.Errorf("parse proxy fallback mappings: %w", fallbackErr)
+	}
+	if proxyRoutes.IsEmpty() && !fallbackPolicies.IsEmpty() {
+		return server.ProxyFallbackPolicies{}, fmt.Errorf("%w: proxy fallback mappings require proxy mappings", errInvalidProxyConfiguration)
+	}
+	return fallbackPolicies, nil
+}
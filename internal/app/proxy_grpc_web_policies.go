@@ -0,0 +1,21 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyGRPCWebPolicies(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (server.ProxyGRPCWebPolicies, error) {
+	grpcWebMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyGRPCWeb))
+	grpcWebPolicies, grpcWebErr := server.NewProxyGRPCWebPolicies(grpcWebMappings)
+	if grpcWebErr != nil {
+		return server.ProxyGRPCWebPolicies{}, fmt.Errorf("parse proxy grpc-web mappings: %w", grpcWebErr)
+	}
+	if proxyRoutes.IsEmpty() && !grpcWebPolicies.IsEmpty() {
+		return server.ProxyGRPCWebPolicies{}, fmt.Errorf("%w: proxy grpc-web mappings require proxy mappings", errInvalidProxyConfiguration)
+	}
+	return grpcWebPolicies, nil
+}
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyMirrors(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (server.ProxyMirrors, error) {
+	mirrorMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyMirrors))
+	proxyMirrors, mirrorErr := server.NewProxyMirrors(
+		mirrorMappings,
+		configurationManager.GetInt(configKeyServeProxyMirrorLimit),
+		configurationManager.GetInt64(configKeyServeProxyMirrorBody),
+	)
+	if mirrorErr != nil {
+		return server.ProxyMirrors{}, fmt.Errorf("parse proxy mirror mappings: %w", mirrorErr)
+	}
+	if proxyRoutes.IsEmpty() && !proxyMirrors.IsEmpty() {
+		return server.ProxyMirrors{}, fmt.Errorf("%w: proxy mirror mappings require proxy mappings", errInvalidProxyConfiguration)
+	}
+	return proxyMirrors, nil
+}
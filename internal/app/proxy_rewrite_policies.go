@@ -0,0 +1,21 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyRewritePolicies(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (server.ProxyRewritePolicies, error) {
+	rewriteMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyRewrite))
+	rewritePolicies, rewriteErr := server.NewProxyRewritePolicies(rewriteMappings)
+	if rewriteErr != nil {
+		return server.ProxyRewritePolicies{}, fmt.Errorf("parse proxy rewrite mappings: %w", rewriteErr)
+	}
+	if proxyRoutes.IsEmpty() && !rewritePolicies.IsEmpty() {
+		return server.ProxyRewritePolicies{}, fmt.Errorf("%w: proxy rewrite mappings require proxy mappings", errInvalidProxyConfiguration)
+	}
+	return rewritePolicies, nil
+}
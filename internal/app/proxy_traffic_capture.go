@@ -0,0 +1,38 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyTrafficCapture(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (*server.ProxyTrafficRecorder, *server.ProxyTrafficReplayer, error) {
+	recordDirectory := strings.TrimSpace(configurationManager.GetString(configKeyServeProxyRecord))
+	replayDirectory := strings.TrimSpace(configurationManager.GetString(configKeyServeProxyReplay))
+	if recordDirectory == "" && replayDirectory == "" {
+		return nil, nil, nil
+	}
+	if recordDirectory != "" && replayDirectory != "" {
+		return nil, nil, fmt.Errorf("%w: --proxy-record and --proxy-replay cannot be combined", errInvalidProxyConfiguration)
+	}
+	if proxyRoutes.IsEmpty() {
+		return nil, nil, fmt.Errorf("%w: proxy record and replay require proxy mappings", errInvalidProxyConfiguration)
+	}
+	if recordDirectory != "" {
+		redactedHeaders := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyRecordRedact))
+		recorder, recorderErr := server.NewProxyTrafficRecorder(recordDirectory, redactedHeaders)
+		if recorderErr != nil {
+			return nil, nil, fmt.Errorf("configure proxy recording: %w", recorderErr)
+		}
+		return recorder, nil, nil
+	}
+	matchFields := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyReplayMatch))
+	replayer, replayerErr := server.NewProxyTrafficReplayer(replayDirectory, matchFields, configurationManager.GetString(configKeyServeProxyReplayMiss))
+	if replayerErr != nil {
+		return nil, nil, fmt.Errorf("configure proxy replay: %w", replayerErr)
+	}
+	return nil, replayer, nil
+}
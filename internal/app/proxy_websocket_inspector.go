@@ -0,0 +1,28 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyWebSocketInspector(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (*server.ProxyWebSocketInspector, error) {
+	inspectionMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeProxyWSLog))
+	redactPatterns := normalizeTrimmedMappings(configurationManager.GetStringSlice(configKeyServeProxyWSRedact))
+	if len(inspectionMappings) == 0 {
+		if len(redactPatterns) > 0 {
+			return nil, fmt.Errorf("%w: websocket redaction requires --%s", errInvalidProxyConfiguration, flagNameProxyWSLog)
+		}
+		return nil, nil
+	}
+	if proxyRoutes.IsEmpty() {
+		return nil, fmt.Errorf("%w: websocket frame logging requires proxy mappings", errInvalidProxyConfiguration)
+	}
+	inspector, inspectorErr := server.NewProxyWebSocketInspector(inspectionMappings, redactPatterns)
+	if inspectorErr != nil {
+		return nil, fmt.Errorf("parse websocket frame logging: %w", inspectorErr)
+	}
+	return inspector, nil
+}
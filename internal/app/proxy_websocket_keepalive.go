@@ -0,0 +1,22 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveProxyWebSocketKeepalive(configurationManager *viper.Viper, proxyRoutes server.ProxyRoutes) (time.Duration, time.Duration, error) {
+	pingInterval := configurationManager.GetDuration(configKeyServeProxyWSPing)
+	idleTimeout := configurationManager.GetDuration(configKeyServeProxyWSIdle)
+	if pingInterval < 0 || idleTimeout < 0 {
+		return 0, 0, fmt.Errorf("%w: websocket keepalive durations must not be negative", errInvalidProxyConfiguration)
+	}
+	if (pingInterval > 0 || idleTimeout > 0) && proxyRoutes.IsEmpty() {
+		return 0, 0, fmt.Errorf("%w: websocket keepalive requires proxy mappings", errInvalidProxyConfiguration)
+	}
+	return pingInterval, idleTimeout, nil
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveRequestLimits(configurationManager *viper.Viper) (server.RequestLimits, error) {
+	for _, timeoutFlag := range []struct {
+		configKey string
+		flagName  string
+	}{
+		{configKeyServeReadHeaderTimeout, flagNameReadHeaderTimeout},
+		{configKeyServeReadTimeout, flagNameReadTimeout},
+		{configKeyServeWriteTimeout, flagNameWriteTimeout},
+		{configKeyServeIdleTimeout, flagNameIdleTimeout},
+	} {
+		if configurationManager.GetDuration(timeoutFlag.configKey) < 0 {
+			return server.RequestLimits{}, fmt.Errorf("--%s must not be negative", timeoutFlag.flagName)
+		}
+	}
+	if configurationManager.GetInt(configKeyServeMaxHeaderBytes) < 0 {
+		return server.RequestLimits{}, fmt.Errorf("--%s must not be negative", flagNameMaxHeaderBytes)
+	}
+	requestLimits, limitsErr := server.NewRequestLimits(
+		configurationManager.GetInt64(configKeyServeMaxBodyBytes),
+		normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeRouteLimits)),
+	)
+	if limitsErr != nil {
+		return server.RequestLimits{}, fmt.Errorf("parse request limits: %w", limitsErr)
+	}
+	return requestLimits, nil
+}
@@ -0,0 +1,21 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveRESTStores(configurationManager *viper.Viper, directoryPath string) (*server.RESTStores, error) {
+	restMappings := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeREST))
+	if len(restMappings) == 0 {
+		return nil, nil
+	}
+	restStores, restErr := server.NewRESTStores(restMappings, directoryPath)
+	if restErr != nil {
+		return nil, fmt.Errorf("load rest stores: %w", restErr)
+	}
+	return restStores, nil
+}
@@ -39,6 +39,8 @@ func newRootCommand(resources *applicationResources) *cobra.Command {
 	_ = resources.configurationManager.BindPFlag(configKeyServeTLSCertificatePath, rootCommand.Flags().Lookup(flagNameTLSCertificatePath))
 	_ = resources.configurationManager.BindPFlag(configKeyServeTLSKeyPath, rootCommand.Flags().Lookup(flagNameTLSKeyPath))
 
+	rootCommand.AddCommand(newHTTPSCommand(resources), newCertCommand(resources))
+
 	rootCommand.PersistentFlags().String(flagNameConfigFile, "", "Path to configuration file")
 	_ = resources.configurationManager.BindPFlag(configKeyConfigFile, rootCommand.PersistentFlags().Lookup(flagNameConfigFile))
 
@@ -48,31 +50,128 @@ func newRootCommand(resources *applicationResources) *cobra.Command {
 func configureServeFlags(flagSet *pflag.FlagSet, configurationManager *viper.Viper) {
 	flagSet.String(flagNameBindAddress, configurationManager.GetString(configKeyServeBindAddress), "Specify bind address")
 	flagSet.String(flagNameDirectory, configurationManager.GetString(configKeyServeDirectory), "Serve files from this directory")
-	flagSet.String(flagNameProtocol, configurationManager.GetString(configKeyServeProtocol), "HTTP protocol version (HTTP/1.0 or HTTP/1.1)")
+	flagSet.String(flagNameProtocol, configurationManager.GetString(configKeyServeProtocol), "HTTP protocol version (HTTP/1.0, HTTP/1.1, or HTTP/2)")
+	flagSet.Bool(flagNameH2C, configurationManager.GetBool(configKeyServeH2C), "Accept cleartext HTTP/2 with prior knowledge (h2c) on HTTP listeners")
 	flagSet.Bool(flagNameNoMarkdown, configurationManager.GetBool(configKeyServeNoMarkdown), "Disable Markdown rendering")
 	flagSet.Bool(flagNameBrowse, configurationManager.GetBool(configKeyServeBrowse), "Browse directories without automatic rendering")
 	flagSet.String(flagNameLoggingType, configurationManager.GetString(configKeyServeLoggingType), "Logging type (CONSOLE or JSON)")
+	flagSet.Duration(flagNameReadHeaderTimeout, configurationManager.GetDuration(configKeyServeReadHeaderTimeout), "Maximum time to read request headers")
+	flagSet.Duration(flagNameReadTimeout, configurationManager.GetDuration(configKeyServeReadTimeout), "Maximum time to read a whole request including its body (0 disables); slow bodies get 408")
+	flagSet.Duration(flagNameWriteTimeout, configurationManager.GetDuration(configKeyServeWriteTimeout), "Maximum time to write a response (0 disables)")
+	flagSet.Duration(flagNameIdleTimeout, configurationManager.GetDuration(configKeyServeIdleTimeout), "Maximum time to keep an idle keep-alive connection open (0 uses the read timeout)")
+	flagSet.Int(flagNameMaxHeaderBytes, configurationManager.GetInt(configKeyServeMaxHeaderBytes), "Maximum size of request headers in bytes (0 uses the 1 MiB default)")
+	flagSet.Int64(flagNameMaxBodyBytes, configurationManager.GetInt64(configKeyServeMaxBodyBytes), "Maximum request body size in bytes (0 disables); larger bodies get 413")
+	flagSet.StringArray(flagNameRouteLimit, configurationManager.GetStringSlice(configKeyServeRouteLimits), "Route-scoped limit in the form /path=max_body_bytes|write_timeout:value (repeatable)")
 	flagSet.StringSlice(flagNameProxy, configurationManager.GetStringSlice(configKeyServeProxies), "Proxy mapping in the form /from=http://backend:8081 (repeatable)")
 	flagSet.StringArray(flagNameResponseHeader, configurationManager.GetStringSlice(configKeyServeResponseHeaders), "Response header policy in the form /path=Header-Name:Header-Value (repeatable)")
-	flagSet.StringArray(flagNameProxyStreaming, configurationManager.GetStringSlice(configKeyServeProxyStreaming), "Proxy streaming policy in the form /path=unbuffered|buffered (repeatable)")
+	flagSet.StringArray(flagNameProxyStreaming, configurationManager.GetStringSlice(configKeyServeProxyStreaming), "Proxy streaming policy in the form /path=unbuffered|buffered|sse[:heartbeat-interval] (repeatable)")
+	flagSet.StringArray(flagNameProxyBackendTLS, configurationManager.GetStringSlice(configKeyServeProxyBackendTLS), "Proxy backend TLS option in the form /path=ca|insecure_skip_verify|server_name|client_certificate|client_key:value (repeatable)")
+	flagSet.StringArray(flagNameProxyGRPCWeb, configurationManager.GetStringSlice(configKeyServeProxyGRPCWeb), "Proxy gRPC-web translation policy in the form /path=enabled|disabled (repeatable)")
+	flagSet.StringArray(flagNameProxyFallback, configurationManager.GetStringSlice(configKeyServeProxyFallback), "Proxy fallback policy in the form /path=proxy|static-first|proxy-first (repeatable)")
+	flagSet.StringArray(flagNameProxyRewrite, configurationManager.GetStringSlice(configKeyServeProxyRewrite), "Mount a backend that assumes it lives at / under the path, rewriting responses, in the form /path=html[+css][+js] (repeatable)")
+	flagSet.String(flagNameProxyCache, configurationManager.GetString(configKeyServeProxyCache), "Cache proxied GET responses (memory or disk), honoring Cache-Control, Expires and Vary; purge via /__ghttp/cache")
+	flagSet.String(flagNameProxyCacheDir, configurationManager.GetString(configKeyServeProxyCacheDir), "Directory that persists the proxy cache in disk mode")
+	flagSet.StringArray(flagNameProxyCacheTTL, configurationManager.GetStringSlice(configKeyServeProxyCacheTTL), "Cache lifetime for responses without caching headers in the form /path=duration (repeatable)")
+	flagSet.Duration(flagNameProxyWSPing, configurationManager.GetDuration(configKeyServeProxyWSPing), "Ping both peers of a proxied WebSocket after this much idle time (0 disables)")
+	flagSet.Duration(flagNameProxyWSIdle, configurationManager.GetDuration(configKeyServeProxyWSIdle), "Close proxied WebSocket connections idle for this long (0 disables)")
+	flagSet.StringArray(flagNameProxyWSLog, configurationManager.GetStringSlice(configKeyServeProxyWSLog), "Log proxied WebSocket frames in the form /path=frames|payload[:bytes] (repeatable); view them live at /__ghttp/websocket")
+	flagSet.StringArray(flagNameProxyWSRedact, configurationManager.GetStringSlice(configKeyServeProxyWSRedact), "Regular expression whose matches are replaced with [REDACTED] in logged WebSocket payloads (repeatable)")
+	flagSet.String(flagNameMocks, configurationManager.GetString(configKeyServeMocks), "Mock API fixture file (YAML or JSON) answered before proxy routes")
+	flagSet.StringArray(flagNameREST, configurationManager.GetStringSlice(configKeyServeREST), "JSON REST store in the form /path=file.json, with the file relative to the served directory (repeatable)")
+	flagSet.String(flagNameFaults, configurationManager.GetString(configKeyServeFaults), "Fault injection rules file (YAML or JSON), toggleable at runtime via /__ghttp/faults")
+	flagSet.StringArray(flagNameProxyMirror, configurationManager.GetStringSlice(configKeyServeProxyMirrors), "Mirror proxied requests to a shadow backend in the form /path=http://shadow (repeatable)")
+	flagSet.Int(flagNameProxyMirrorLimit, configurationManager.GetInt(configKeyServeProxyMirrorLimit), "Maximum number of in-flight mirror requests; further copies are dropped")
+	flagSet.Int64(flagNameProxyMirrorBody, configurationManager.GetInt64(configKeyServeProxyMirrorBody), "Largest request body in bytes copied to mirrors; larger requests are not mirrored")
+	flagSet.String(flagNameProxyRecord, configurationManager.GetString(configKeyServeProxyRecord), "Record proxied request/response pairs as JSON files in this directory")
+	flagSet.StringSlice(flagNameProxyRecordRedact, configurationManager.GetStringSlice(configKeyServeProxyRecordRedact), "Header names whose values are redacted in recordings (repeatable)")
+	flagSet.String(flagNameProxyReplay, configurationManager.GetString(configKeyServeProxyReplay), "Answer proxied requests from recordings in this directory instead of the backend")
+	flagSet.StringSlice(flagNameProxyReplayMatch, configurationManager.GetStringSlice(configKeyServeProxyReplayMatch), "Request fields used to match recordings (method, path, query, body)")
+	flagSet.String(flagNameProxyReplayMiss, configurationManager.GetString(configKeyServeProxyReplayMiss), "Behavior for requests without a recording (fail or passthrough)")
 	flagSet.String(flagNameProxyBackend, configurationManager.GetString(configKeyProxyBackend), "Backend URL to proxy requests to (e.g., http://backend:8001)")
 	flagSet.String(flagNameProxyPathPrefix, configurationManager.GetString(configKeyProxyPathPrefix), "Path prefix to proxy (e.g., /api/)")
+	flagSet.String(flagNameClientCA, configurationManager.GetString(configKeyServeClientCA), "PEM bundle of certificate authorities trusted to sign TLS client certificates")
+	flagSet.String(flagNameClientAuth, configurationManager.GetString(configKeyServeClientAuth), "Client certificate mode with --client-ca (request, require, or verify-if-given)")
+	flagSet.StringArray(flagNameClientAuthRoute, configurationManager.GetStringSlice(configKeyServeClientAuthRoutes), "Client certificate mode for a path prefix in the form /path=request|require|verify-if-given (repeatable)")
+	flagSet.StringArray(flagNameProxyRequestHeader, configurationManager.GetStringSlice(configKeyServeProxyRequestHeader), "Templated request header sent to proxied backends in the form /path=Header-Name:{{.ClientCert.CommonName}} (repeatable)")
 	_ = configurationManager.BindPFlag(configKeyServeBindAddress, flagSet.Lookup(flagNameBindAddress))
 	_ = configurationManager.BindPFlag(configKeyServeDirectory, flagSet.Lookup(flagNameDirectory))
 	_ = configurationManager.BindPFlag(configKeyServeProtocol, flagSet.Lookup(flagNameProtocol))
+	_ = configurationManager.BindPFlag(configKeyServeH2C, flagSet.Lookup(flagNameH2C))
 	_ = configurationManager.BindPFlag(configKeyServeNoMarkdown, flagSet.Lookup(flagNameNoMarkdown))
 	_ = configurationManager.BindPFlag(configKeyServeBrowse, flagSet.Lookup(flagNameBrowse))
 	_ = configurationManager.BindPFlag(configKeyServeLoggingType, flagSet.Lookup(flagNameLoggingType))
+	_ = configurationManager.BindPFlag(configKeyServeReadHeaderTimeout, flagSet.Lookup(flagNameReadHeaderTimeout))
+	_ = configurationManager.BindPFlag(configKeyServeReadTimeout, flagSet.Lookup(flagNameReadTimeout))
+	_ = configurationManager.BindPFlag(configKeyServeWriteTimeout, flagSet.Lookup(flagNameWriteTimeout))
+	_ = configurationManager.BindPFlag(configKeyServeIdleTimeout, flagSet.Lookup(flagNameIdleTimeout))
+	_ = configurationManager.BindPFlag(configKeyServeMaxHeaderBytes, flagSet.Lookup(flagNameMaxHeaderBytes))
+	_ = configurationManager.BindPFlag(configKeyServeMaxBodyBytes, flagSet.Lookup(flagNameMaxBodyBytes))
+	_ = configurationManager.BindPFlag(configKeyServeRouteLimits, flagSet.Lookup(flagNameRouteLimit))
 	_ = configurationManager.BindPFlag(configKeyServeProxies, flagSet.Lookup(flagNameProxy))
 	_ = configurationManager.BindPFlag(configKeyServeResponseHeaders, flagSet.Lookup(flagNameResponseHeader))
 	_ = configurationManager.BindPFlag(configKeyServeProxyStreaming, flagSet.Lookup(flagNameProxyStreaming))
+	_ = configurationManager.BindPFlag(configKeyServeProxyBackendTLS, flagSet.Lookup(flagNameProxyBackendTLS))
+	_ = configurationManager.BindPFlag(configKeyServeProxyGRPCWeb, flagSet.Lookup(flagNameProxyGRPCWeb))
+	_ = configurationManager.BindPFlag(configKeyServeProxyFallback, flagSet.Lookup(flagNameProxyFallback))
+	_ = configurationManager.BindPFlag(configKeyServeProxyRewrite, flagSet.Lookup(flagNameProxyRewrite))
+	_ = configurationManager.BindPFlag(configKeyServeProxyCache, flagSet.Lookup(flagNameProxyCache))
+	_ = configurationManager.BindPFlag(configKeyServeProxyCacheDir, flagSet.Lookup(flagNameProxyCacheDir))
+	_ = configurationManager.BindPFlag(configKeyServeProxyCacheTTL, flagSet.Lookup(flagNameProxyCacheTTL))
+	_ = configurationManager.BindPFlag(configKeyServeProxyWSPing, flagSet.Lookup(flagNameProxyWSPing))
+	_ = configurationManager.BindPFlag(configKeyServeProxyWSIdle, flagSet.Lookup(flagNameProxyWSIdle))
+	_ = configurationManager.BindPFlag(configKeyServeProxyWSLog, flagSet.Lookup(flagNameProxyWSLog))
+	_ = configurationManager.BindPFlag(configKeyServeProxyWSRedact, flagSet.Lookup(flagNameProxyWSRedact))
+	_ = configurationManager.BindPFlag(configKeyServeMocks, flagSet.Lookup(flagNameMocks))
+	_ = configurationManager.BindPFlag(configKeyServeREST, flagSet.Lookup(flagNameREST))
+	_ = configurationManager.BindPFlag(configKeyServeFaults, flagSet.Lookup(flagNameFaults))
+	_ = configurationManager.BindPFlag(configKeyServeProxyMirrors, flagSet.Lookup(flagNameProxyMirror))
+	_ = configurationManager.BindPFlag(configKeyServeProxyMirrorLimit, flagSet.Lookup(flagNameProxyMirrorLimit))
+	_ = configurationManager.BindPFlag(configKeyServeProxyMirrorBody, flagSet.Lookup(flagNameProxyMirrorBody))
+	_ = configurationManager.BindPFlag(configKeyServeProxyRecord, flagSet.Lookup(flagNameProxyRecord))
+	_ = configurationManager.BindPFlag(configKeyServeProxyRecordRedact, flagSet.Lookup(flagNameProxyRecordRedact))
+	_ = configurationManager.BindPFlag(configKeyServeProxyReplay, flagSet.Lookup(flagNameProxyReplay))
+	_ = configurationManager.BindPFlag(configKeyServeProxyReplayMatch, flagSet.Lookup(flagNameProxyReplayMatch))
+	_ = configurationManager.BindPFlag(configKeyServeProxyReplayMiss, flagSet.Lookup(flagNameProxyReplayMiss))
 	_ = configurationManager.BindPFlag(configKeyProxyBackend, flagSet.Lookup(flagNameProxyBackend))
 	_ = configurationManager.BindPFlag(configKeyProxyPathPrefix, flagSet.Lookup(flagNameProxyPathPrefix))
+	_ = configurationManager.BindPFlag(configKeyServeClientCA, flagSet.Lookup(flagNameClientCA))
+	_ = configurationManager.BindPFlag(configKeyServeClientAuth, flagSet.Lookup(flagNameClientAuth))
+	_ = configurationManager.BindPFlag(configKeyServeClientAuthRoutes, flagSet.Lookup(flagNameClientAuthRoute))
+	_ = configurationManager.BindPFlag(configKeyServeProxyRequestHeader, flagSet.Lookup(flagNameProxyRequestHeader))
 }
 
 func configureServeHTTPSOptions(flagSet *pflag.FlagSet, configurationManager *viper.Viper) {
 	flagSet.Bool(flagNameHTTPS, configurationManager.GetBool(configKeyServeHTTPS), "Serve over HTTPS using a self-signed certificate")
 	flagSet.StringSlice(flagNameHTTPSHosts, configurationManager.GetStringSlice(configKeyHTTPSHosts), "Hostnames or IP addresses included in generated HTTPS certificates (only used with --https)")
+	flagSet.String(flagNameHTTPSKeyAlgorithm, configurationManager.GetString(configKeyHTTPSKeyAlgorithm), "Key algorithm for the generated CA and certificates: rsa, ecdsa-p256, ecdsa-p384, or ed25519 (only used with --https)")
+	flagSet.Bool(flagNameHTTPSPersist, configurationManager.GetBool(configKeyHTTPSPersistCA), "Keep the development CA installed and reuse it across runs instead of removing it on exit (only used with --https)")
+	flagSet.StringSlice(flagNameHTTPSOnDemand, configurationManager.GetStringSlice(configKeyHTTPSOnDemandHosts), "Mint a certificate during the handshake for names matching these patterns: hostname, *.domain, IP, CIDR, or private (only used with --https)")
+	flagSet.Bool(flagNameHTTPSOnDemandStore, configurationManager.GetBool(configKeyHTTPSOnDemandPersist), "Write on-demand certificates to the certificate directory and reuse them across runs (only used with --https-on-demand)")
+	flagSet.Int(flagNameHTTPSOnDemandLimit, configurationManager.GetInt(configKeyHTTPSOnDemandCacheSize), "Maximum on-demand certificates kept in memory; the oldest name is evicted beyond it (only used with --https-on-demand)")
+	flagSet.String(flagNameHTTPPort, configurationManager.GetString(configKeyServeHTTPPort), "Also serve cleartext HTTP on this port next to HTTPS (requires --https or --tls-cert)")
+	flagSet.Bool(flagNameHTTPSRedirect, configurationManager.GetBool(configKeyServeHTTPSRedirect), "Redirect requests on the --http-port listener to HTTPS, preserving path and query")
+	flagSet.StringSlice(flagNameHTTPSRedirectSkip, configurationManager.GetStringSlice(configKeyServeHTTPSRedirectSkip), "Path prefixes served over HTTP instead of redirected (repeatable)")
+	flagSet.String(flagNameTLSPreset, configurationManager.GetString(configKeyServeTLSPreset), "TLS settings preset: modern, intermediate, or old (requires --https or --tls-cert)")
+	flagSet.String(flagNameTLSMinVersion, configurationManager.GetString(configKeyServeTLSMinVersion), "Minimum TLS version: 1.0, 1.1, 1.2, or 1.3")
+	flagSet.String(flagNameTLSMaxVersion, configurationManager.GetString(configKeyServeTLSMaxVersion), "Maximum TLS version: 1.0, 1.1, 1.2, or 1.3")
+	flagSet.StringSlice(flagNameTLSCipherSuites, configurationManager.GetStringSlice(configKeyServeTLSCipherSuites), "TLS 1.0-1.2 cipher suites by IANA name, for example TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (repeatable)")
+	flagSet.StringSlice(flagNameTLSCurves, configurationManager.GetStringSlice(configKeyServeTLSCurves), "Key exchange curves: X25519, X25519MLKEM768, P-256, P-384, or P-521 (repeatable)")
+	addNameConstraintFlags(flagSet, configurationManager)
 	_ = configurationManager.BindPFlag(configKeyServeHTTPS, flagSet.Lookup(flagNameHTTPS))
 	_ = configurationManager.BindPFlag(configKeyHTTPSHosts, flagSet.Lookup(flagNameHTTPSHosts))
+	_ = configurationManager.BindPFlag(configKeyHTTPSKeyAlgorithm, flagSet.Lookup(flagNameHTTPSKeyAlgorithm))
+	_ = configurationManager.BindPFlag(configKeyHTTPSPersistCA, flagSet.Lookup(flagNameHTTPSPersist))
+	_ = configurationManager.BindPFlag(configKeyHTTPSOnDemandHosts, flagSet.Lookup(flagNameHTTPSOnDemand))
+	_ = configurationManager.BindPFlag(configKeyHTTPSOnDemandPersist, flagSet.Lookup(flagNameHTTPSOnDemandStore))
+	_ = configurationManager.BindPFlag(configKeyHTTPSOnDemandCacheSize, flagSet.Lookup(flagNameHTTPSOnDemandLimit))
+	_ = configurationManager.BindPFlag(configKeyHTTPSNameConstraints, flagSet.Lookup(flagNameHTTPSNameConstrain))
+	_ = configurationManager.BindPFlag(configKeyHTTPSCADomains, flagSet.Lookup(flagNameHTTPSCADomain))
+	_ = configurationManager.BindPFlag(configKeyServeHTTPPort, flagSet.Lookup(flagNameHTTPPort))
+	_ = configurationManager.BindPFlag(configKeyServeHTTPSRedirect, flagSet.Lookup(flagNameHTTPSRedirect))
+	_ = configurationManager.BindPFlag(configKeyServeHTTPSRedirectSkip, flagSet.Lookup(flagNameHTTPSRedirectSkip))
+	_ = configurationManager.BindPFlag(configKeyServeTLSPreset, flagSet.Lookup(flagNameTLSPreset))
+	_ = configurationManager.BindPFlag(configKeyServeTLSMinVersion, flagSet.Lookup(flagNameTLSMinVersion))
+	_ = configurationManager.BindPFlag(configKeyServeTLSMaxVersion, flagSet.Lookup(flagNameTLSMaxVersion))
+	_ = configurationManager.BindPFlag(configKeyServeTLSCipherSuites, flagSet.Lookup(flagNameTLSCipherSuites))
+	_ = configurationManager.BindPFlag(configKeyServeTLSCurves, flagSet.Lookup(flagNameTLSCurves))
 }
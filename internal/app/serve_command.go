@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -36,6 +37,7 @@ type ServeConfiguration struct {
 	Port                    string
 	DirectoryPath           string
 	ProtocolVersion         string
+	EnableH2C               bool
 	TLSCertificatePath      string
 	TLSPrivateKeyPath       string
 	DisableDirectoryListing bool
@@ -44,9 +46,33 @@ type ServeConfiguration struct {
 	BrowseDirectories       bool
 	InitialFileRelativePath string
 	LoggingType             string
+	ReadHeaderTimeout       time.Duration
+	ReadTimeout             time.Duration
+	WriteTimeout            time.Duration
+	IdleTimeout             time.Duration
+	MaxHeaderBytes          int
+	RequestLimits           server.RequestLimits
 	ProxyRoutes             server.ProxyRoutes
 	RouteResponsePolicies   server.RouteResponsePolicies
 	ProxyStreamingPolicies  server.ProxyStreamingPolicies
+	ProxyBackendTLSPolicies server.ProxyBackendTLSPolicies
+	ProxyGRPCWebPolicies    server.ProxyGRPCWebPolicies
+	ProxyFallbackPolicies   server.ProxyFallbackPolicies
+	ProxyMirrors            server.ProxyMirrors
+	ProxyRewritePolicies    server.ProxyRewritePolicies
+	ProxyCache              *server.ProxyCache
+	ProxyWebSocketPing      time.Duration
+	ProxyWebSocketIdle      time.Duration
+	ProxyWebSocketInspector *server.ProxyWebSocketInspector
+	MockRoutes              *server.MockRoutes
+	RESTStores              *server.RESTStores
+	FaultInjector           *server.FaultInjector
+	ProxyTrafficRecorder    *server.ProxyTrafficRecorder
+	ProxyTrafficReplayer    *server.ProxyTrafficReplayer
+	ProxyRequestHeaders     server.ProxyRequestHeaders
+	ClientAuth              server.ClientAuthPolicies
+	TLSProtocolPolicy       server.TLSProtocolPolicy
+	HTTPListener            *server.HTTPListenerConfiguration
 }
 
 func prepareServeConfiguration(cmd *cobra.Command, args []string, portConfigKey string, allowTLSFiles bool) error {
@@ -97,8 +123,8 @@ func prepareServeConfiguration(cmd *cobra.Command, args []string, portConfigKey
 	}
 
 	protocolValue := strings.ToUpper(strings.TrimSpace(configurationManager.GetString(configKeyServeProtocol)))
-	if protocolValue != "HTTP/1.0" && protocolValue != "HTTP/1.1" {
-		return fmt.Errorf("unsupported protocol %s", protocolValue)
+	if protocolValue != protocolVersionHTTP10 && protocolValue != defaultProtocolVersion && protocolValue != protocolVersionHTTP2 {
+		return fmt.Errorf("unsupported protocol %s (use %s, %s, or %s)", protocolValue, protocolVersionHTTP10, defaultProtocolVersion, protocolVersionHTTP2)
 	}
 
 	if portValue == "" {
@@ -134,6 +160,13 @@ func prepareServeConfiguration(cmd *cobra.Command, args []string, portConfigKey
 	if enableDynamicHTTPS && (tlsCertificatePath != "" || tlsKeyPath != "") {
 		return errors.New("cannot combine https flag with tls certificate flags")
 	}
+	enableH2C := configurationManager.GetBool(configKeyServeH2C)
+	if enableH2C && protocolValue == protocolVersionHTTP10 {
+		return fmt.Errorf("--%s requires %s or %s", flagNameH2C, defaultProtocolVersion, protocolVersionHTTP2)
+	}
+	if protocolValue == protocolVersionHTTP2 && !enableH2C && !enableDynamicHTTPS && tlsCertificatePath == "" {
+		return fmt.Errorf("%s without TLS requires --%s", protocolVersionHTTP2, flagNameH2C)
+	}
 	if tlsCertificatePath != "" {
 		if _, certErr := os.Stat(tlsCertificatePath); certErr != nil {
 			return fmt.Errorf("stat tls certificate: %w", certErr)
@@ -160,12 +193,81 @@ func prepareServeConfiguration(cmd *cobra.Command, args []string, portConfigKey
 	if streamingPolicyErr != nil {
 		return streamingPolicyErr
 	}
+	proxyBackendTLSPolicies, backendTLSPolicyErr := resolveProxyBackendTLSPolicies(configurationManager, proxyRoutes)
+	if backendTLSPolicyErr != nil {
+		return backendTLSPolicyErr
+	}
+	proxyGRPCWebPolicies, grpcWebPolicyErr := resolveProxyGRPCWebPolicies(configurationManager, proxyRoutes)
+	if grpcWebPolicyErr != nil {
+		return grpcWebPolicyErr
+	}
+	proxyFallbackPolicies, fallbackPolicyErr := resolveProxyFallbackPolicies(configurationManager, proxyRoutes)
+	if fallbackPolicyErr != nil {
+		return fallbackPolicyErr
+	}
+	mockRoutes, mockErr := resolveMockRoutes(configurationManager, absoluteDirectory)
+	if mockErr != nil {
+		return mockErr
+	}
+	restStores, restErr := resolveRESTStores(configurationManager, absoluteDirectory)
+	if restErr != nil {
+		return restErr
+	}
+	faultInjector, faultErr := resolveFaultInjector(configurationManager)
+	if faultErr != nil {
+		return faultErr
+	}
+	proxyRewritePolicies, rewritePolicyErr := resolveProxyRewritePolicies(configurationManager, proxyRoutes)
+	if rewritePolicyErr != nil {
+		return rewritePolicyErr
+	}
+	proxyCache, proxyCacheErr := resolveProxyCache(configurationManager, proxyRoutes)
+	if proxyCacheErr != nil {
+		return proxyCacheErr
+	}
+	proxyWebSocketPing, proxyWebSocketIdle, webSocketKeepaliveErr := resolveProxyWebSocketKeepalive(configurationManager, proxyRoutes)
+	if webSocketKeepaliveErr != nil {
+		return webSocketKeepaliveErr
+	}
+	proxyWebSocketInspector, webSocketInspectorErr := resolveProxyWebSocketInspector(configurationManager, proxyRoutes)
+	if webSocketInspectorErr != nil {
+		return webSocketInspectorErr
+	}
+	proxyMirrors, proxyMirrorErr := resolveProxyMirrors(configurationManager, proxyRoutes)
+	if proxyMirrorErr != nil {
+		return proxyMirrorErr
+	}
+	requestLimits, requestLimitsErr := resolveRequestLimits(configurationManager)
+	if requestLimitsErr != nil {
+		return requestLimitsErr
+	}
+	proxyTrafficRecorder, proxyTrafficReplayer, trafficCaptureErr := resolveProxyTrafficCapture(configurationManager, proxyRoutes)
+	if trafficCaptureErr != nil {
+		return trafficCaptureErr
+	}
+	proxyRequestHeaders, requestHeaderErr := resolveProxyRequestHeaders(configurationManager, proxyRoutes)
+	if requestHeaderErr != nil {
+		return requestHeaderErr
+	}
+	clientAuthPolicies, clientAuthErr := resolveClientAuthPolicies(configurationManager, enableDynamicHTTPS || tlsCertificatePath != "")
+	if clientAuthErr != nil {
+		return clientAuthErr
+	}
+	tlsProtocolPolicy, tlsProtocolErr := resolveTLSProtocolPolicy(configurationManager, enableDynamicHTTPS || tlsCertificatePath != "", protocolValue == protocolVersionHTTP2 || !proxyRoutes.IsEmpty())
+	if tlsProtocolErr != nil {
+		return tlsProtocolErr
+	}
+	httpListener, httpListenerErr := resolveHTTPListener(configurationManager, enableDynamicHTTPS || tlsCertificatePath != "", portValue)
+	if httpListenerErr != nil {
+		return httpListenerErr
+	}
 
 	serveConfiguration := ServeConfiguration{
 		BindAddress:             bindAddress,
 		Port:                    portValue,
 		DirectoryPath:           absoluteDirectory,
 		ProtocolVersion:         protocolValue,
+		EnableH2C:               enableH2C,
 		TLSCertificatePath:      tlsCertificatePath,
 		TLSPrivateKeyPath:       tlsKeyPath,
 		DisableDirectoryListing: disableDirectoryListing,
@@ -174,9 +276,33 @@ func prepareServeConfiguration(cmd *cobra.Command, args []string, portConfigKey
 		BrowseDirectories:       browseDirectories,
 		InitialFileRelativePath: initialFileRelativePath,
 		LoggingType:             loggingTypeValue,
+		ReadHeaderTimeout:       configurationManager.GetDuration(configKeyServeReadHeaderTimeout),
+		ReadTimeout:             configurationManager.GetDuration(configKeyServeReadTimeout),
+		WriteTimeout:            configurationManager.GetDuration(configKeyServeWriteTimeout),
+		IdleTimeout:             configurationManager.GetDuration(configKeyServeIdleTimeout),
+		MaxHeaderBytes:          configurationManager.GetInt(configKeyServeMaxHeaderBytes),
+		RequestLimits:           requestLimits,
 		ProxyRoutes:             proxyRoutes,
 		RouteResponsePolicies:   responsePolicies,
 		ProxyStreamingPolicies:  proxyStreamingPolicies,
+		ProxyBackendTLSPolicies: proxyBackendTLSPolicies,
+		ProxyGRPCWebPolicies:    proxyGRPCWebPolicies,
+		ProxyFallbackPolicies:   proxyFallbackPolicies,
+		ProxyMirrors:            proxyMirrors,
+		ProxyRewritePolicies:    proxyRewritePolicies,
+		ProxyCache:              proxyCache,
+		ProxyWebSocketPing:      proxyWebSocketPing,
+		ProxyWebSocketIdle:      proxyWebSocketIdle,
+		ProxyWebSocketInspector: proxyWebSocketInspector,
+		MockRoutes:              mockRoutes,
+		RESTStores:              restStores,
+		FaultInjector:           faultInjector,
+		ProxyTrafficRecorder:    proxyTrafficRecorder,
+		ProxyTrafficReplayer:    proxyTrafficReplayer,
+		ProxyRequestHeaders:     proxyRequestHeaders,
+		ClientAuth:              clientAuthPolicies,
+		TLSProtocolPolicy:       tlsProtocolPolicy,
+		HTTPListener:            httpListener,
 	}
 
 	if loggerErr := resources.updateLogger(loggingTypeValue); loggerErr != nil {
@@ -202,19 +328,44 @@ func runServe(cmd *cobra.Command) error {
 		Port:                    serveConfiguration.Port,
 		DirectoryPath:           serveConfiguration.DirectoryPath,
 		ProtocolVersion:         serveConfiguration.ProtocolVersion,
+		EnableH2C:               serveConfiguration.EnableH2C,
 		DisableDirectoryListing: serveConfiguration.DisableDirectoryListing,
 		EnableMarkdown:          serveConfiguration.EnableMarkdown,
 		BrowseDirectories:       serveConfiguration.BrowseDirectories,
 		InitialFileRelativePath: serveConfiguration.InitialFileRelativePath,
 		LoggingType:             serveConfiguration.LoggingType,
+		ReadHeaderTimeout:       serveConfiguration.ReadHeaderTimeout,
+		ReadTimeout:             serveConfiguration.ReadTimeout,
+		WriteTimeout:            serveConfiguration.WriteTimeout,
+		IdleTimeout:             serveConfiguration.IdleTimeout,
+		MaxHeaderBytes:          serveConfiguration.MaxHeaderBytes,
+		RequestLimits:           serveConfiguration.RequestLimits,
 		ProxyRoutes:             serveConfiguration.ProxyRoutes,
 		RouteResponsePolicies:   serveConfiguration.RouteResponsePolicies,
 		ProxyStreamingPolicies:  serveConfiguration.ProxyStreamingPolicies,
+		ProxyBackendTLSPolicies: serveConfiguration.ProxyBackendTLSPolicies,
+		ProxyGRPCWebPolicies:    serveConfiguration.ProxyGRPCWebPolicies,
+		ProxyFallbackPolicies:   serveConfiguration.ProxyFallbackPolicies,
+		ProxyMirrors:            serveConfiguration.ProxyMirrors,
+		ProxyRewritePolicies:    serveConfiguration.ProxyRewritePolicies,
+		ProxyCache:              serveConfiguration.ProxyCache,
+		ProxyWebSocketPing:      serveConfiguration.ProxyWebSocketPing,
+		ProxyWebSocketIdle:      serveConfiguration.ProxyWebSocketIdle,
+		ProxyWebSocketInspector: serveConfiguration.ProxyWebSocketInspector,
+		MockRoutes:              serveConfiguration.MockRoutes,
+		RESTStores:              serveConfiguration.RESTStores,
+		FaultInjector:           serveConfiguration.FaultInjector,
+		ProxyTrafficRecorder:    serveConfiguration.ProxyTrafficRecorder,
+		ProxyTrafficReplayer:    serveConfiguration.ProxyTrafficReplayer,
+		ProxyRequestHeaders:     serveConfiguration.ProxyRequestHeaders,
+		HTTPListener:            serveConfiguration.HTTPListener,
 	}
 	if serveConfiguration.TLSCertificatePath != "" {
 		fileServerConfiguration.TLS = &server.TLSConfiguration{
 			CertificatePath: serveConfiguration.TLSCertificatePath,
 			PrivateKeyPath:  serveConfiguration.TLSPrivateKeyPath,
+			ClientAuth:      serveConfiguration.ClientAuth,
+			ProtocolPolicy:  serveConfiguration.TLSProtocolPolicy,
 		}
 	}
 
@@ -276,7 +427,8 @@ func serveWithDynamicHTTPS(cmd *cobra.Command, resources *applicationResources,
 	if err := prepareHTTPSContext(cmd); err != nil {
 		return err
 	}
-	setupErr := runHTTPSSetup(cmd)
+	persistCertificateAuthority := resources.configurationManager.GetBool(configKeyHTTPSPersistCA)
+	setupErr := runHTTPSSetup(cmd, persistCertificateAuthority)
 	if setupErr != nil {
 		return setupErr
 	}
@@ -284,6 +436,10 @@ func serveWithDynamicHTTPS(cmd *cobra.Command, resources *applicationResources,
 	certificateDirectory := cmd.Context().Value(contextKeyHTTPSCertificateDir).(string)
 
 	serveErr := executeHTTPSServe(cmd, resources, serveConfiguration, hosts, certificateDirectory)
+	if persistCertificateAuthority {
+		logCertificateMessage(resources, "certificate authority kept installed", certificateDirectory)
+		return serveErr
+	}
 	uninstallErr := runHTTPSUninstall(cmd)
 	if uninstallErr != nil {
 		if serveErr != nil {
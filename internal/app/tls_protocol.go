@@ -0,0 +1,30 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/tyemirov/ghttp/internal/server"
+)
+
+func resolveTLSProtocolPolicy(configurationManager *viper.Viper, tlsEnabled bool, http2Offered bool) (server.TLSProtocolPolicy, error) {
+	preset := strings.TrimSpace(configurationManager.GetString(configKeyServeTLSPreset))
+	minVersion := strings.TrimSpace(configurationManager.GetString(configKeyServeTLSMinVersion))
+	maxVersion := strings.TrimSpace(configurationManager.GetString(configKeyServeTLSMaxVersion))
+	cipherSuites := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeTLSCipherSuites))
+	curves := normalizeCommaDelimitedMappings(configurationManager.GetStringSlice(configKeyServeTLSCurves))
+	if preset == "" && minVersion == "" && maxVersion == "" && len(cipherSuites) == 0 && len(curves) == 0 {
+		return server.TLSProtocolPolicy{}, nil
+	}
+	if !tlsEnabled {
+		return server.TLSProtocolPolicy{}, errors.New("tls version, cipher suite, and curve settings require --https or --tls-cert")
+	}
+	tlsProtocolPolicy, policyErr := server.NewTLSProtocolPolicy(preset, minVersion, maxVersion, cipherSuites, curves, http2Offered)
+	if policyErr != nil {
+		return server.TLSProtocolPolicy{}, fmt.Errorf("parse tls settings: %w", policyErr)
+	}
+	return tlsProtocolPolicy, nil
+}
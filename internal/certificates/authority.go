@@ -2,8 +2,8 @@ package certificates
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -13,6 +13,7 @@ import (
 	"io/fs"
 	"math/big"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -20,8 +21,13 @@ const (
 	certificatePemBlockType                   = "CERTIFICATE"
 	privateKeyPemBlockType                    = "RSA PRIVATE KEY"
 	defaultCertificateSerialNumberUpperBitLen = 128
+	noNameConstraintsDescription              = "none"
 )
 
+// ErrCertificateAuthorityMismatch reports a persisted authority whose key algorithm or name constraints differ from
+// the configuration.
+var ErrCertificateAuthorityMismatch = errors.New("certificates.authority.mismatch")
+
 // CertificateAuthorityConfiguration defines storage and lifetime parameters for the root certificate authority.
 type CertificateAuthorityConfiguration struct {
 	DirectoryPath                    string
@@ -30,12 +36,16 @@ type CertificateAuthorityConfiguration struct {
 	DirectoryPermissions             fs.FileMode
 	CertificateFilePermissions       fs.FileMode
 	PrivateKeyFilePermissions        fs.FileMode
+	KeyAlgorithm                     KeyAlgorithm
 	RSAKeyBitSize                    int
 	CertificateValidityDuration      time.Duration
 	CertificateRenewalWindowDuration time.Duration
 	SubjectCommonName                string
 	SubjectOrganizationalUnit        string
 	SubjectOrganization              string
+	// NameConstraints, when not empty, limits the names the authority may sign for through a critical X.509 Name
+	// Constraints extension.
+	NameConstraints NameConstraints
 }
 
 // CertificateAuthorityMaterial contains the root certificate authority artifacts.
@@ -43,7 +53,9 @@ type CertificateAuthorityMaterial struct {
 	CertificateBytes []byte
 	PrivateKeyBytes  []byte
 	Certificate      *x509.Certificate
-	PrivateKey       *rsa.PrivateKey
+	PrivateKey       crypto.Signer
+	// Generated reports whether the authority was created or rotated rather than loaded from disk.
+	Generated bool
 }
 
 // CertificateAuthorityManager provisions and loads root certificate authorities.
@@ -64,7 +76,8 @@ func NewCertificateAuthorityManager(fileSystem FileSystem, clock Clock, randomne
 	}
 }
 
-// EnsureCertificateAuthority returns a valid root certificate authority, creating or rotating it when necessary.
+// EnsureCertificateAuthority returns a valid root certificate authority, creating or rotating it when necessary. An
+// authority whose key algorithm or name constraints differ from the configuration is rotated as well.
 func (manager CertificateAuthorityManager) EnsureCertificateAuthority(ctx context.Context) (CertificateAuthorityMaterial, error) {
 	rootCertificatePath := filepath.Join(manager.configuration.DirectoryPath, manager.configuration.CertificateFileName)
 	rootPrivateKeyPath := filepath.Join(manager.configuration.DirectoryPath, manager.configuration.PrivateKeyFileName)
@@ -88,6 +101,15 @@ func (manager CertificateAuthorityManager) EnsureCertificateAuthority(ctx contex
 	return manager.generateAndPersist(ctx, rootCertificatePath, rootPrivateKeyPath)
 }
 
+// LoadCertificateAuthority reads the persisted root certificate authority without creating or rotating it. A missing
+// authority is reported as fs.ErrNotExist.
+func (manager CertificateAuthorityManager) LoadCertificateAuthority() (CertificateAuthorityMaterial, error) {
+	return manager.loadExisting(
+		filepath.Join(manager.configuration.DirectoryPath, manager.configuration.CertificateFileName),
+		filepath.Join(manager.configuration.DirectoryPath, manager.configuration.PrivateKeyFileName),
+	)
+}
+
 func (manager CertificateAuthorityManager) loadExisting(rootCertificatePath string, rootPrivateKeyPath string) (CertificateAuthorityMaterial, error) {
 	certificateExists, certificateExistsErr := manager.fileSystem.FileExists(rootCertificatePath)
 	if certificateExistsErr != nil {
@@ -115,7 +137,7 @@ func (manager CertificateAuthorityManager) loadExisting(rootCertificatePath stri
 		return CertificateAuthorityMaterial{}, fmt.Errorf("parse certificate: %w", parseCertificateErr)
 	}
 
-	privateKey, parsePrivateKeyErr := parseRSAPrivateKeyFromPEM(privateKeyBytes)
+	privateKey, parsePrivateKeyErr := parsePrivateKeyFromPEM(privateKeyBytes)
 	if parsePrivateKeyErr != nil {
 		return CertificateAuthorityMaterial{}, fmt.Errorf("parse private key: %w", parsePrivateKeyErr)
 	}
@@ -128,7 +150,32 @@ func (manager CertificateAuthorityManager) loadExisting(rootCertificatePath stri
 	}, nil
 }
 
+// CheckConfiguration reports ErrCertificateAuthorityMismatch when the authority certificate was not created with the
+// configured key algorithm and name constraints. Nothing is rotated, so callers that must keep the trust anchor can
+// refuse to continue instead.
+func (manager CertificateAuthorityManager) CheckConfiguration(certificate *x509.Certificate) error {
+	existingAlgorithm := keyAlgorithmOf(certificate.PublicKey)
+	if configuredAlgorithm := effectiveKeyAlgorithm(manager.configuration.KeyAlgorithm); existingAlgorithm != configuredAlgorithm {
+		return fmt.Errorf("%w: the authority uses %s keys, not %s", ErrCertificateAuthorityMismatch, existingAlgorithm, configuredAlgorithm)
+	}
+	existingConstraints := nameConstraintsOf(certificate)
+	if !existingConstraints.equal(manager.configuration.NameConstraints) {
+		return fmt.Errorf("%w: the authority name constraints are %s, not %s", ErrCertificateAuthorityMismatch, describeNameConstraints(existingConstraints), describeNameConstraints(manager.configuration.NameConstraints))
+	}
+	return nil
+}
+
+func describeNameConstraints(constraints NameConstraints) string {
+	if constraints.IsEmpty() {
+		return noNameConstraintsDescription
+	}
+	return strings.Join(constraints.Describe(), ", ")
+}
+
 func (manager CertificateAuthorityManager) shouldRotate(certificate *x509.Certificate) bool {
+	if manager.CheckConfiguration(certificate) != nil {
+		return true
+	}
 	currentTime := manager.clock.Now()
 	if currentTime.After(certificate.NotAfter) {
 		return true
@@ -144,7 +191,7 @@ func (manager CertificateAuthorityManager) generateAndPersist(ctx context.Contex
 	default:
 	}
 
-	privateKey, privateKeyErr := rsa.GenerateKey(manager.randomnessSource, manager.configuration.RSAKeyBitSize)
+	privateKey, privateKeyErr := generatePrivateKey(manager.configuration.KeyAlgorithm, manager.configuration.RSAKeyBitSize, manager.randomnessSource)
 	if privateKeyErr != nil {
 		return CertificateAuthorityMaterial{}, fmt.Errorf("generate private key: %w", privateKeyErr)
 	}
@@ -168,14 +215,22 @@ func (manager CertificateAuthorityManager) generateAndPersist(ctx context.Contex
 		MaxPathLen:            1,
 		MaxPathLenZero:        false,
 	}
+	if !manager.configuration.NameConstraints.IsEmpty() {
+		template.PermittedDNSDomainsCritical = true
+		template.PermittedDNSDomains = manager.configuration.NameConstraints.PermittedDNSDomains
+		template.PermittedIPRanges = manager.configuration.NameConstraints.PermittedIPRanges
+	}
 
-	certificateBytesDer, certificateErr := x509.CreateCertificate(manager.randomnessSource, &template, &template, &privateKey.PublicKey, privateKey)
+	certificateBytesDer, certificateErr := x509.CreateCertificate(manager.randomnessSource, &template, &template, privateKey.Public(), privateKey)
 	if certificateErr != nil {
 		return CertificateAuthorityMaterial{}, fmt.Errorf("create certificate: %w", certificateErr)
 	}
 
 	certificatePem := pem.EncodeToMemory(&pem.Block{Type: certificatePemBlockType, Bytes: certificateBytesDer})
-	privateKeyPem := pem.EncodeToMemory(&pem.Block{Type: privateKeyPemBlockType, Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	privateKeyPem, encodeErr := encodePrivateKeyToPEM(privateKey)
+	if encodeErr != nil {
+		return CertificateAuthorityMaterial{}, fmt.Errorf("encode private key: %w", encodeErr)
+	}
 
 	writeCertificateErr := manager.fileSystem.WriteFile(rootCertificatePath, certificatePem, manager.configuration.CertificateFilePermissions)
 	if writeCertificateErr != nil {
@@ -192,6 +247,7 @@ func (manager CertificateAuthorityManager) generateAndPersist(ctx context.Contex
 		PrivateKeyBytes:  privateKeyPem,
 		Certificate:      certificate,
 		PrivateKey:       privateKey,
+		Generated:        true,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package certificates
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -14,6 +15,7 @@ import (
 	"math/big"
 	"net"
 	"slices"
+	"strings"
 	"time"
 )
 
@@ -21,6 +23,7 @@ import (
 type ServerCertificateConfiguration struct {
 	CertificateValidityDuration      time.Duration
 	CertificateRenewalWindowDuration time.Duration
+	KeyAlgorithm                     KeyAlgorithm
 	LeafPrivateKeyBitSize            int
 	CertificateFilePermissions       fs.FileMode
 	PrivateKeyFilePermissions        fs.FileMode
@@ -33,12 +36,20 @@ type ServerCertificateRequest struct {
 	PrivateKeyOutputPath  string
 }
 
+// ClientCertificateRequest describes a client certificate for mutual TLS and its output paths.
+type ClientCertificateRequest struct {
+	CommonName            string
+	EmailAddresses        []string
+	CertificateOutputPath string
+	PrivateKeyOutputPath  string
+}
+
 // ServerCertificateMaterial contains the leaf certificate artifacts.
 type ServerCertificateMaterial struct {
 	CertificateBytes []byte
 	PrivateKeyBytes  []byte
 	TLSCertificate   *x509.Certificate
-	PrivateKey       *rsa.PrivateKey
+	PrivateKey       crypto.Signer
 }
 
 // ServerCertificateIssuer signs leaf certificates using a root certificate authority.
@@ -59,11 +70,13 @@ func NewServerCertificateIssuer(fileSystem FileSystem, clock Clock, randomnessSo
 	}
 }
 
-// IssueServerCertificate returns a valid leaf certificate for the requested hosts.
+// IssueServerCertificate returns a valid leaf certificate for the requested hosts. Existing certificates are reissued
+// when they expire, cover other hosts, use another key algorithm, or were not signed by certificateAuthority. Hosts must
+// pass ValidateCertificateHost and fall inside the authority's name constraints.
 func (issuer ServerCertificateIssuer) IssueServerCertificate(ctx context.Context, certificateAuthority CertificateAuthorityMaterial, request ServerCertificateRequest) (ServerCertificateMaterial, error) {
 	existingMaterial, existingErr := issuer.loadExisting(request)
 	if existingErr == nil {
-		shouldRotate := issuer.shouldRotate(existingMaterial.TLSCertificate, request.Hosts)
+		shouldRotate := issuer.shouldRotate(existingMaterial.TLSCertificate, certificateAuthority.Certificate, request.Hosts)
 		if !shouldRotate {
 			return existingMaterial, nil
 		}
@@ -72,33 +85,46 @@ func (issuer ServerCertificateIssuer) IssueServerCertificate(ctx context.Context
 		return ServerCertificateMaterial{}, fmt.Errorf("load existing server certificate: %w", existingErr)
 	}
 
+	material, createErr := issuer.createServerCertificate(ctx, certificateAuthority, request.Hosts)
+	if createErr != nil {
+		return ServerCertificateMaterial{}, createErr
+	}
+
+	writeCertificateErr := issuer.fileSystem.WriteFile(request.CertificateOutputPath, material.CertificateBytes, issuer.configuration.CertificateFilePermissions)
+	if writeCertificateErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("write server certificate: %w", writeCertificateErr)
+	}
+	writePrivateKeyErr := issuer.fileSystem.WriteFile(request.PrivateKeyOutputPath, material.PrivateKeyBytes, issuer.configuration.PrivateKeyFilePermissions)
+	if writePrivateKeyErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("write server private key: %w", writePrivateKeyErr)
+	}
+	return material, nil
+}
+
+// createServerCertificate signs a new leaf certificate for hosts without persisting it.
+func (issuer ServerCertificateIssuer) createServerCertificate(ctx context.Context, certificateAuthority CertificateAuthorityMaterial, hosts []string) (ServerCertificateMaterial, error) {
 	select {
 	case <-ctx.Done():
 		return ServerCertificateMaterial{}, fmt.Errorf("issue server certificate: %w", ctx.Err())
 	default:
 	}
-
-	privateKey, privateKeyErr := rsa.GenerateKey(issuer.randomnessSource, issuer.configuration.LeafPrivateKeyBitSize)
-	if privateKeyErr != nil {
-		return ServerCertificateMaterial{}, fmt.Errorf("generate leaf private key: %w", privateKeyErr)
+	authorityConstraints := nameConstraintsOf(certificateAuthority.Certificate)
+	for _, host := range hosts {
+		if validateErr := ValidateCertificateHost(host); validateErr != nil {
+			return ServerCertificateMaterial{}, validateErr
+		}
+		if !authorityConstraints.Permits(host) {
+			return ServerCertificateMaterial{}, fmt.Errorf("%w: %s is not within %s", ErrHostOutsideNameConstraints, host, strings.Join(authorityConstraints.Describe(), ", "))
+		}
 	}
 
-	now := issuer.clock.Now()
-	serialNumber := issuer.generateSerialNumber()
-
 	template := x509.Certificate{
-		SerialNumber: serialNumber,
 		Subject: pkix.Name{
-			CommonName: request.Hosts[0],
+			CommonName: hosts[0],
 		},
-		NotBefore:             now.Add(-time.Hour),
-		NotAfter:              now.Add(issuer.configuration.CertificateValidityDuration),
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 	}
-
-	for _, host := range request.Hosts {
+	for _, host := range hosts {
 		ip := net.ParseIP(host)
 		if ip != nil {
 			if ip.To4() != nil {
@@ -110,22 +136,63 @@ func (issuer ServerCertificateIssuer) IssueServerCertificate(ctx context.Context
 			template.DNSNames = append(template.DNSNames, host)
 		}
 	}
+	return issuer.signLeafCertificate(certificateAuthority, template)
+}
 
-	certificateDer, certificateErr := x509.CreateCertificate(issuer.randomnessSource, &template, certificateAuthority.Certificate, &privateKey.PublicKey, certificateAuthority.PrivateKey)
-	if certificateErr != nil {
-		return ServerCertificateMaterial{}, fmt.Errorf("create server certificate: %w", certificateErr)
+// IssueClientCertificate signs a new client authentication certificate and writes it to the requested paths.
+func (issuer ServerCertificateIssuer) IssueClientCertificate(ctx context.Context, certificateAuthority CertificateAuthorityMaterial, request ClientCertificateRequest) (ServerCertificateMaterial, error) {
+	select {
+	case <-ctx.Done():
+		return ServerCertificateMaterial{}, fmt.Errorf("issue client certificate: %w", ctx.Err())
+	default:
+	}
+	if strings.TrimSpace(request.CommonName) == "" {
+		return ServerCertificateMaterial{}, errors.New("client certificate needs a common name")
+	}
+	material, createErr := issuer.signLeafCertificate(certificateAuthority, x509.Certificate{
+		Subject:        pkix.Name{CommonName: request.CommonName},
+		EmailAddresses: request.EmailAddresses,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if createErr != nil {
+		return ServerCertificateMaterial{}, createErr
+	}
+	if writeErr := issuer.fileSystem.WriteFile(request.CertificateOutputPath, material.CertificateBytes, issuer.configuration.CertificateFilePermissions); writeErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("write client certificate: %w", writeErr)
 	}
+	if writeErr := issuer.fileSystem.WriteFile(request.PrivateKeyOutputPath, material.PrivateKeyBytes, issuer.configuration.PrivateKeyFilePermissions); writeErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("write client private key: %w", writeErr)
+	}
+	return material, nil
+}
 
-	certificatePem := pem.EncodeToMemory(&pem.Block{Type: certificatePemBlockType, Bytes: certificateDer})
-	privateKeyPem := pem.EncodeToMemory(&pem.Block{Type: privateKeyPemBlockType, Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+// signLeafCertificate completes template with a fresh key, serial number, and validity period and signs it with
+// certificateAuthority.
+func (issuer ServerCertificateIssuer) signLeafCertificate(certificateAuthority CertificateAuthorityMaterial, template x509.Certificate) (ServerCertificateMaterial, error) {
+	privateKey, privateKeyErr := generatePrivateKey(issuer.configuration.KeyAlgorithm, issuer.configuration.LeafPrivateKeyBitSize, issuer.randomnessSource)
+	if privateKeyErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("generate leaf private key: %w", privateKeyErr)
+	}
 
-	writeCertificateErr := issuer.fileSystem.WriteFile(request.CertificateOutputPath, certificatePem, issuer.configuration.CertificateFilePermissions)
-	if writeCertificateErr != nil {
-		return ServerCertificateMaterial{}, fmt.Errorf("write server certificate: %w", writeCertificateErr)
+	now := issuer.clock.Now()
+	template.SerialNumber = issuer.generateSerialNumber()
+	template.NotBefore = now.Add(-time.Hour)
+	template.NotAfter = now.Add(issuer.configuration.CertificateValidityDuration)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.BasicConstraintsValid = true
+	if _, isRSA := privateKey.(*rsa.PrivateKey); isRSA && slices.Contains(template.ExtKeyUsage, x509.ExtKeyUsageServerAuth) {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
 	}
-	writePrivateKeyErr := issuer.fileSystem.WriteFile(request.PrivateKeyOutputPath, privateKeyPem, issuer.configuration.PrivateKeyFilePermissions)
-	if writePrivateKeyErr != nil {
-		return ServerCertificateMaterial{}, fmt.Errorf("write server private key: %w", writePrivateKeyErr)
+
+	certificateDer, certificateErr := x509.CreateCertificate(issuer.randomnessSource, &template, certificateAuthority.Certificate, privateKey.Public(), certificateAuthority.PrivateKey)
+	if certificateErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("create leaf certificate: %w", certificateErr)
+	}
+
+	certificatePem := pem.EncodeToMemory(&pem.Block{Type: certificatePemBlockType, Bytes: certificateDer})
+	privateKeyPem, encodeErr := encodePrivateKeyToPEM(privateKey)
+	if encodeErr != nil {
+		return ServerCertificateMaterial{}, fmt.Errorf("encode leaf private key: %w", encodeErr)
 	}
 
 	parsedCertificate, _ := parseCertificateFromPEM(certificatePem)
@@ -138,6 +205,12 @@ func (issuer ServerCertificateIssuer) IssueServerCertificate(ctx context.Context
 	}, nil
 }
 
+// LoadServerCertificate reads a previously issued leaf certificate without reissuing it. A missing certificate is
+// reported as fs.ErrNotExist.
+func (issuer ServerCertificateIssuer) LoadServerCertificate(request ServerCertificateRequest) (ServerCertificateMaterial, error) {
+	return issuer.loadExisting(request)
+}
+
 func (issuer ServerCertificateIssuer) loadExisting(request ServerCertificateRequest) (ServerCertificateMaterial, error) {
 	certificateExists, certificateExistsErr := issuer.fileSystem.FileExists(request.CertificateOutputPath)
 	if certificateExistsErr != nil {
@@ -165,7 +238,7 @@ func (issuer ServerCertificateIssuer) loadExisting(request ServerCertificateRequ
 		return ServerCertificateMaterial{}, fmt.Errorf("parse existing certificate: %w", parseCertificateErr)
 	}
 
-	privateKey, parsePrivateKeyErr := parseRSAPrivateKeyFromPEM(privateKeyBytes)
+	privateKey, parsePrivateKeyErr := parsePrivateKeyFromPEM(privateKeyBytes)
 	if parsePrivateKeyErr != nil {
 		return ServerCertificateMaterial{}, fmt.Errorf("parse existing private key: %w", parsePrivateKeyErr)
 	}
@@ -178,7 +251,13 @@ func (issuer ServerCertificateIssuer) loadExisting(request ServerCertificateRequ
 	}, nil
 }
 
-func (issuer ServerCertificateIssuer) shouldRotate(certificate *x509.Certificate, requestedHosts []string) bool {
+func (issuer ServerCertificateIssuer) shouldRotate(certificate *x509.Certificate, authorityCertificate *x509.Certificate, requestedHosts []string) bool {
+	if keyAlgorithmOf(certificate.PublicKey) != effectiveKeyAlgorithm(issuer.configuration.KeyAlgorithm) {
+		return true
+	}
+	if certificate.CheckSignatureFrom(authorityCertificate) != nil {
+		return true
+	}
 	currentTime := issuer.clock.Now()
 	if currentTime.After(certificate.NotAfter) {
 		return true
@@ -0,0 +1,96 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// KeyAlgorithm selects the key type generated for the certificate authority and leaf certificates.
+type KeyAlgorithm string
+
+const (
+	// KeyAlgorithmRSA generates RSA keys of the configured bit size.
+	KeyAlgorithmRSA KeyAlgorithm = "rsa"
+	// KeyAlgorithmECDSAP256 generates ECDSA keys on the NIST P-256 curve.
+	KeyAlgorithmECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	// KeyAlgorithmECDSAP384 generates ECDSA keys on the NIST P-384 curve.
+	KeyAlgorithmECDSAP384 KeyAlgorithm = "ecdsa-p384"
+	// KeyAlgorithmEd25519 generates Ed25519 keys.
+	KeyAlgorithmEd25519 KeyAlgorithm = "ed25519"
+)
+
+const (
+	pkcs8PrivateKeyPemBlockType = "PRIVATE KEY"
+	ecPrivateKeyPemBlockType    = "EC PRIVATE KEY"
+)
+
+// ParseKeyAlgorithm normalizes a key algorithm name. An empty value selects RSA.
+func ParseKeyAlgorithm(value string) (KeyAlgorithm, error) {
+	normalizedValue := KeyAlgorithm(strings.ToLower(strings.TrimSpace(value)))
+	switch normalizedValue {
+	case "":
+		return KeyAlgorithmRSA, nil
+	case KeyAlgorithmRSA, KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384, KeyAlgorithmEd25519:
+		return normalizedValue, nil
+	default:
+		return "", fmt.Errorf("unsupported key algorithm %q (use rsa, ecdsa-p256, ecdsa-p384, or ed25519)", value)
+	}
+}
+
+func generatePrivateKey(keyAlgorithm KeyAlgorithm, rsaKeyBitSize int, randomnessSource io.Reader) (crypto.Signer, error) {
+	switch keyAlgorithm {
+	case KeyAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), randomnessSource)
+	case KeyAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), randomnessSource)
+	case KeyAlgorithmEd25519:
+		_, privateKey, err := ed25519.GenerateKey(randomnessSource)
+		return privateKey, err
+	case KeyAlgorithmRSA, "":
+		return rsa.GenerateKey(randomnessSource, rsaKeyBitSize)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", keyAlgorithm)
+	}
+}
+
+// keyAlgorithmOf reports the algorithm of a certificate public key, or an empty value for key types ghttp does not
+// generate.
+func keyAlgorithmOf(publicKey crypto.PublicKey) KeyAlgorithm {
+	switch typedKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return KeyAlgorithmRSA
+	case *ecdsa.PublicKey:
+		switch typedKey.Curve {
+		case elliptic.P256():
+			return KeyAlgorithmECDSAP256
+		case elliptic.P384():
+			return KeyAlgorithmECDSAP384
+		}
+	case ed25519.PublicKey:
+		return KeyAlgorithmEd25519
+	}
+	return ""
+}
+
+func effectiveKeyAlgorithm(keyAlgorithm KeyAlgorithm) KeyAlgorithm {
+	if keyAlgorithm == "" {
+		return KeyAlgorithmRSA
+	}
+	return keyAlgorithm
+}
+
+func encodePrivateKeyToPEM(privateKey crypto.Signer) ([]byte, error) {
+	privateKeyDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pkcs8PrivateKeyPemBlockType, Bytes: privateKeyDer}), nil
+}
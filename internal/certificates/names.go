@@ -0,0 +1,179 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+const (
+	wildcardLabel              = "*"
+	maximumHostnameLength      = 253
+	maximumHostnameLabelLength = 63
+)
+
+var (
+	// ErrInvalidCertificateHost reports a host that cannot be placed in a certificate subject alternative name.
+	ErrInvalidCertificateHost = errors.New("certificates.host.invalid")
+	// ErrHostOutsideNameConstraints reports a host that the name-constrained certificate authority may not sign.
+	ErrHostOutsideNameConstraints = errors.New("certificates.host.outside_name_constraints")
+	// DefaultPermittedDNSDomains are always permitted by a name-constrained development certificate authority.
+	DefaultPermittedDNSDomains = []string{"localhost", "test", "local"}
+	privateNetworkCIDRs        = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "::1/128", "fc00::/7", "fe80::/10"}
+)
+
+// NameConstraints lists the DNS domains and IP ranges a certificate authority may sign for. The zero value leaves the
+// authority unconstrained.
+type NameConstraints struct {
+	PermittedDNSDomains []string
+	PermittedIPRanges   []*net.IPNet
+}
+
+// ValidateCertificateHost accepts IP addresses and DNS names made of letters, digits, hyphens, and underscores. A
+// wildcard must be the entire leftmost label and cover at least two further labels, so *.app.localhost is accepted
+// while *.localhost, app.*.localhost, and app*.localhost are not.
+func ValidateCertificateHost(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if host == "" || len(host) > maximumHostnameLength {
+		return fmt.Errorf("%w: %q must be between 1 and %d characters", ErrInvalidCertificateHost, host, maximumHostnameLength)
+	}
+	labels := strings.Split(strings.ToLower(host), ".")
+	if labels[0] == wildcardLabel {
+		if len(labels) < 3 {
+			return fmt.Errorf("%w: wildcard %q must cover a domain with at least two labels", ErrInvalidCertificateHost, host)
+		}
+		labels = labels[1:]
+	}
+	for _, label := range labels {
+		if !isValidHostnameLabel(label) {
+			return fmt.Errorf("%w: %q has an invalid label %q", ErrInvalidCertificateHost, host, label)
+		}
+	}
+	return nil
+}
+
+func isValidHostnameLabel(label string) bool {
+	if label == "" || len(label) > maximumHostnameLabelLength || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return false
+	}
+	for _, character := range label {
+		if (character < 'a' || character > 'z') && (character < '0' || character > '9') && character != '-' && character != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildNameConstraints permits DefaultPermittedDNSDomains and the private address ranges plus additional names:
+// hostnames, *.domain wildcards (permitting the domain), IP addresses, CIDR ranges, and the "private" keyword. Names
+// already covered are skipped.
+func BuildNameConstraints(additionalNames []string) (NameConstraints, error) {
+	constraints := NameConstraints{PermittedDNSDomains: append([]string{}, DefaultPermittedDNSDomains...)}
+	for _, privateNetwork := range privateNetworkCIDRs {
+		_, network, _ := net.ParseCIDR(privateNetwork)
+		constraints.PermittedIPRanges = append(constraints.PermittedIPRanges, network)
+	}
+	for _, rawName := range additionalNames {
+		name := strings.ToLower(strings.TrimSpace(rawName))
+		if name == "" || name == OnDemandPrivatePattern {
+			continue
+		}
+		if _, network, parseErr := net.ParseCIDR(name); parseErr == nil {
+			if !constraints.permitsNetwork(network) {
+				constraints.PermittedIPRanges = append(constraints.PermittedIPRanges, network)
+			}
+			continue
+		}
+		if address := net.ParseIP(name); address != nil {
+			if !constraints.permitsAddress(address) {
+				bitLength := len(address.To16()) * 8
+				if address.To4() != nil {
+					address, bitLength = address.To4(), 32
+				}
+				constraints.PermittedIPRanges = append(constraints.PermittedIPRanges, &net.IPNet{IP: address, Mask: net.CIDRMask(bitLength, bitLength)})
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(name, wildcardLabel+".")
+		if validateErr := ValidateCertificateHost(domain); validateErr != nil {
+			return NameConstraints{}, validateErr
+		}
+		if !constraints.permitsDomain(domain) {
+			constraints.PermittedDNSDomains = append(constraints.PermittedDNSDomains, domain)
+		}
+	}
+	return constraints, nil
+}
+
+// IsEmpty reports whether no constraints are set.
+func (constraints NameConstraints) IsEmpty() bool {
+	return len(constraints.PermittedDNSDomains) == 0 && len(constraints.PermittedIPRanges) == 0
+}
+
+// Permits reports whether host falls inside the constraints. Wildcards are checked against the domain they cover.
+func (constraints NameConstraints) Permits(host string) bool {
+	if constraints.IsEmpty() {
+		return true
+	}
+	if address := net.ParseIP(host); address != nil {
+		return constraints.permitsAddress(address)
+	}
+	return constraints.permitsDomain(strings.TrimPrefix(strings.ToLower(host), wildcardLabel+"."))
+}
+
+// Describe lists the permitted domains and ranges for display.
+func (constraints NameConstraints) Describe() []string {
+	described := append([]string{}, constraints.PermittedDNSDomains...)
+	for _, network := range constraints.PermittedIPRanges {
+		described = append(described, network.String())
+	}
+	return described
+}
+
+func (constraints NameConstraints) permitsDomain(domain string) bool {
+	for _, permittedDomain := range constraints.PermittedDNSDomains {
+		if domain == permittedDomain || strings.HasSuffix(domain, "."+permittedDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (constraints NameConstraints) permitsAddress(address net.IP) bool {
+	for _, network := range constraints.PermittedIPRanges {
+		if network.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// permitsNetwork reports whether a permitted range of the same address family and an equal or shorter prefix holds
+// the whole network, not just its first address.
+func (constraints NameConstraints) permitsNetwork(network *net.IPNet) bool {
+	prefixLength, bitLength := network.Mask.Size()
+	for _, permittedNetwork := range constraints.PermittedIPRanges {
+		permittedPrefixLength, permittedBitLength := permittedNetwork.Mask.Size()
+		if permittedBitLength == bitLength && permittedPrefixLength <= prefixLength && permittedNetwork.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (constraints NameConstraints) equal(other NameConstraints) bool {
+	currentNames := constraints.Describe()
+	otherNames := other.Describe()
+	slices.Sort(currentNames)
+	slices.Sort(otherNames)
+	return slices.Equal(currentNames, otherNames)
+}
+
+func nameConstraintsOf(certificate *x509.Certificate) NameConstraints {
+	return NameConstraints{PermittedDNSDomains: certificate.PermittedDNSDomains, PermittedIPRanges: certificate.PermittedIPRanges}
+}
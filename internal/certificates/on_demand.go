@@ -0,0 +1,250 @@
+package certificates
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// OnDemandPrivatePattern expands to the loopback, private, unique local, and link-local address ranges.
+	OnDemandPrivatePattern = "private"
+	onDemandWildcardPrefix = "*."
+	onDemandCIDRSeparator  = "/"
+)
+
+// ErrInvalidOnDemandPattern reports an on-demand host pattern that cannot be parsed.
+var ErrInvalidOnDemandPattern = errors.New("certificates.on_demand.pattern.invalid")
+
+// OnDemandConfiguration selects the names that get a leaf certificate minted during the TLS handshake.
+type OnDemandConfiguration struct {
+	// HostPatterns lists exact hostnames, *.suffix wildcards, IP addresses, CIDR ranges, and "private".
+	HostPatterns []string
+	// DefaultHosts are covered by the default leaf certificate and never minted separately.
+	DefaultHosts []string
+	// CacheDirectoryPath persists minted certificates across runs; empty keeps them in memory only.
+	CacheDirectoryPath string
+	// MaxCachedCertificates bounds the in-memory cache; the oldest minted name is evicted to make room. Zero or less
+	// means unbounded.
+	MaxCachedCertificates int
+	// Observer, when set, is told about every certificate minted or failed.
+	Observer func(host string, err error)
+}
+
+// OnDemandCertificateIssuer mints and caches per-name leaf certificates from the development certificate authority.
+type OnDemandCertificateIssuer struct {
+	issuer          ServerCertificateIssuer
+	authority       CertificateAuthorityMaterial
+	configuration   OnDemandConfiguration
+	exactHosts      map[string]struct{}
+	wildcardSuffix  []string
+	networks        []*net.IPNet
+	defaultHosts    map[string]struct{}
+	mutex           sync.Mutex
+	cachedByHost    map[string]*onDemandCertificate
+	cachedHosts     []string
+	persistenceLock sync.Mutex
+}
+
+type onDemandCertificate struct {
+	ready       chan struct{}
+	certificate *tls.Certificate
+	err         error
+}
+
+// NewOnDemandCertificateIssuer validates the host patterns and prepares the certificate cache.
+func NewOnDemandCertificateIssuer(issuer ServerCertificateIssuer, authority CertificateAuthorityMaterial, configuration OnDemandConfiguration) (*OnDemandCertificateIssuer, error) {
+	onDemandIssuer := &OnDemandCertificateIssuer{
+		issuer:        issuer,
+		authority:     authority,
+		configuration: configuration,
+		exactHosts:    map[string]struct{}{},
+		defaultHosts:  map[string]struct{}{},
+		cachedByHost:  map[string]*onDemandCertificate{},
+	}
+	for _, rawPattern := range configuration.HostPatterns {
+		pattern := strings.ToLower(strings.TrimSpace(rawPattern))
+		switch {
+		case pattern == "":
+			continue
+		case pattern == OnDemandPrivatePattern:
+			for _, privateNetwork := range privateNetworkCIDRs {
+				_, network, _ := net.ParseCIDR(privateNetwork)
+				onDemandIssuer.networks = append(onDemandIssuer.networks, network)
+			}
+		case strings.Contains(pattern, onDemandCIDRSeparator):
+			_, network, parseErr := net.ParseCIDR(pattern)
+			if parseErr != nil {
+				return nil, fmt.Errorf("%w: %q is not a valid CIDR range", ErrInvalidOnDemandPattern, rawPattern)
+			}
+			onDemandIssuer.networks = append(onDemandIssuer.networks, network)
+		case net.ParseIP(pattern) != nil:
+			address := net.ParseIP(pattern)
+			onDemandIssuer.networks = append(onDemandIssuer.networks, &net.IPNet{IP: address, Mask: net.CIDRMask(len(address)*8, len(address)*8)})
+		case strings.HasPrefix(pattern, onDemandWildcardPrefix):
+			suffix := strings.TrimPrefix(pattern, "*")
+			if !isOnDemandHostname(strings.TrimPrefix(suffix, ".")) {
+				return nil, fmt.Errorf("%w: %q must be *. followed by a domain", ErrInvalidOnDemandPattern, rawPattern)
+			}
+			onDemandIssuer.wildcardSuffix = append(onDemandIssuer.wildcardSuffix, suffix)
+		default:
+			if !isOnDemandHostname(pattern) {
+				return nil, fmt.Errorf("%w: %q is not a hostname, wildcard, address, or range", ErrInvalidOnDemandPattern, rawPattern)
+			}
+			onDemandIssuer.exactHosts[pattern] = struct{}{}
+		}
+	}
+	for _, defaultHost := range configuration.DefaultHosts {
+		onDemandIssuer.defaultHosts[normalizeOnDemandHost(defaultHost)] = struct{}{}
+	}
+	if configuration.CacheDirectoryPath != "" {
+		if ensureErr := issuer.fileSystem.EnsureDirectory(configuration.CacheDirectoryPath, 0o700); ensureErr != nil {
+			return nil, fmt.Errorf("ensure on-demand certificate directory: %w", ensureErr)
+		}
+	}
+	return onDemandIssuer, nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate callback. Names covered by the default leaf certificate or not matched
+// by any pattern return no certificate, so the handshake falls back to the default one. Clients that connect by IP
+// address send no server name; the local address of the connection is used instead.
+func (onDemandIssuer *OnDemandCertificateIssuer) GetCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := normalizeOnDemandHost(clientHello.ServerName)
+	if host == "" && clientHello.Conn != nil {
+		if localAddress, isTCP := clientHello.Conn.LocalAddr().(*net.TCPAddr); isTCP {
+			host = normalizeOnDemandHost(localAddress.IP.String())
+		}
+	}
+	if host == "" || !onDemandIssuer.matches(host) {
+		return nil, nil
+	}
+	if _, coveredByDefault := onDemandIssuer.defaultHosts[host]; coveredByDefault {
+		return nil, nil
+	}
+
+	onDemandIssuer.mutex.Lock()
+	cached, exists := onDemandIssuer.cachedByHost[host]
+	if exists && onDemandIssuer.isFresh(cached) {
+		onDemandIssuer.mutex.Unlock()
+		<-cached.ready
+		return cached.certificate, cached.err
+	}
+	cached = &onDemandCertificate{ready: make(chan struct{})}
+	onDemandIssuer.storeLocked(host, cached)
+	onDemandIssuer.mutex.Unlock()
+
+	cached.certificate, cached.err = onDemandIssuer.mint(clientHello.Context(), host)
+	if cached.err != nil {
+		onDemandIssuer.mutex.Lock()
+		if onDemandIssuer.cachedByHost[host] == cached {
+			onDemandIssuer.removeLocked(host)
+		}
+		onDemandIssuer.mutex.Unlock()
+	}
+	close(cached.ready)
+	if onDemandIssuer.configuration.Observer != nil {
+		onDemandIssuer.configuration.Observer(host, cached.err)
+	}
+	return cached.certificate, cached.err
+}
+
+// storeLocked caches the entry for host as the newest one, evicting the oldest names beyond MaxCachedCertificates.
+// Handshakes already waiting on an evicted entry still receive its certificate. Callers hold the mutex.
+func (onDemandIssuer *OnDemandCertificateIssuer) storeLocked(host string, cached *onDemandCertificate) {
+	if _, exists := onDemandIssuer.cachedByHost[host]; exists {
+		onDemandIssuer.removeLocked(host)
+	}
+	maxCached := onDemandIssuer.configuration.MaxCachedCertificates
+	for maxCached > 0 && len(onDemandIssuer.cachedHosts) >= maxCached {
+		onDemandIssuer.removeLocked(onDemandIssuer.cachedHosts[0])
+	}
+	onDemandIssuer.cachedByHost[host] = cached
+	onDemandIssuer.cachedHosts = append(onDemandIssuer.cachedHosts, host)
+}
+
+// removeLocked drops host from the cache. Callers hold the mutex.
+func (onDemandIssuer *OnDemandCertificateIssuer) removeLocked(host string) {
+	delete(onDemandIssuer.cachedByHost, host)
+	if hostIndex := slices.Index(onDemandIssuer.cachedHosts, host); hostIndex >= 0 {
+		onDemandIssuer.cachedHosts = slices.Delete(onDemandIssuer.cachedHosts, hostIndex, hostIndex+1)
+	}
+}
+
+func (onDemandIssuer *OnDemandCertificateIssuer) matches(host string) bool {
+	if address := net.ParseIP(host); address != nil {
+		for _, network := range onDemandIssuer.networks {
+			if network.Contains(address) {
+				return true
+			}
+		}
+		return false
+	}
+	if !isOnDemandHostname(host) {
+		return false
+	}
+	if _, exact := onDemandIssuer.exactHosts[host]; exact {
+		return true
+	}
+	for _, suffix := range onDemandIssuer.wildcardSuffix {
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFresh reports whether a cached certificate is still being minted or outside its renewal window. Callers hold the
+// mutex.
+func (onDemandIssuer *OnDemandCertificateIssuer) isFresh(cached *onDemandCertificate) bool {
+	select {
+	case <-cached.ready:
+	default:
+		return true
+	}
+	if cached.certificate == nil || cached.certificate.Leaf == nil {
+		return false
+	}
+	renewalThreshold := cached.certificate.Leaf.NotAfter.Add(-onDemandIssuer.issuer.configuration.CertificateRenewalWindowDuration)
+	return onDemandIssuer.issuer.clock.Now().Before(renewalThreshold)
+}
+
+func (onDemandIssuer *OnDemandCertificateIssuer) mint(ctx context.Context, host string) (*tls.Certificate, error) {
+	var material ServerCertificateMaterial
+	var issueErr error
+	if onDemandIssuer.configuration.CacheDirectoryPath == "" {
+		material, issueErr = onDemandIssuer.issuer.createServerCertificate(ctx, onDemandIssuer.authority, []string{host})
+	} else {
+		fileName := strings.ReplaceAll(host, ":", "_")
+		onDemandIssuer.persistenceLock.Lock()
+		material, issueErr = onDemandIssuer.issuer.IssueServerCertificate(ctx, onDemandIssuer.authority, ServerCertificateRequest{
+			Hosts:                 []string{host},
+			CertificateOutputPath: filepath.Join(onDemandIssuer.configuration.CacheDirectoryPath, fileName+".pem"),
+			PrivateKeyOutputPath:  filepath.Join(onDemandIssuer.configuration.CacheDirectoryPath, fileName+".key"),
+		})
+		onDemandIssuer.persistenceLock.Unlock()
+	}
+	if issueErr != nil {
+		return nil, fmt.Errorf("issue certificate for %s: %w", host, issueErr)
+	}
+	certificate, parseErr := tls.X509KeyPair(material.CertificateBytes, material.PrivateKeyBytes)
+	if parseErr != nil {
+		return nil, fmt.Errorf("parse certificate for %s: %w", host, parseErr)
+	}
+	return &certificate, nil
+}
+
+func normalizeOnDemandHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// isOnDemandHostname accepts the hostnames ValidateCertificateHost accepts, minus addresses and wildcards, which keeps
+// minted names valid in certificates and safe as cache file names.
+func isOnDemandHostname(host string) bool {
+	return net.ParseIP(host) == nil && !strings.Contains(host, wildcardLabel) && ValidateCertificateHost(host) == nil
+}
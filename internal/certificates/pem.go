@@ -1,7 +1,7 @@
 package certificates
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -23,17 +23,30 @@ func parseCertificateFromPEM(pemBytes []byte) (*x509.Certificate, error) {
 	return certificate, nil
 }
 
-func parseRSAPrivateKeyFromPEM(pemBytes []byte) (*rsa.PrivateKey, error) {
+// parsePrivateKeyFromPEM reads the PKCS#8 keys written by this package and the PKCS#1 RSA keys of earlier versions. SEC 1
+// EC keys are accepted for user-supplied files, such as a CA key placed in the certificate directory after conversion
+// with openssl ec.
+func parsePrivateKeyFromPEM(pemBytes []byte) (crypto.Signer, error) {
 	pemBlock, _ := pem.Decode(pemBytes)
 	if pemBlock == nil {
 		return nil, errors.New("invalid private key pem encoding")
 	}
-	if pemBlock.Type != privateKeyPemBlockType {
+	switch pemBlock.Type {
+	case privateKeyPemBlockType:
+		return x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
+	case ecPrivateKeyPemBlockType:
+		return x509.ParseECPrivateKey(pemBlock.Bytes)
+	case pkcs8PrivateKeyPemBlockType:
+		parsedKey, err := x509.ParsePKCS8PrivateKey(pemBlock.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, isSigner := parsedKey.(crypto.Signer)
+		if !isSigner {
+			return nil, fmt.Errorf("unsupported private key type %T", parsedKey)
+		}
+		return privateKey, nil
+	default:
 		return nil, fmt.Errorf("unexpected pem block type %s", pemBlock.Type)
 	}
-	privateKey, err := x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
-	if err != nil {
-		return nil, err
-	}
-	return privateKey, nil
 }
@@ -0,0 +1,350 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"unicode/utf16"
+)
+
+const (
+	pkcs12Version        = 3
+	pkcs12Iterations     = 10000
+	pkcs12SaltLength     = 16
+	pkcs12MACKeyID       = 3
+	pkcs12HashBlockBytes = 64
+)
+
+var (
+	oidPKCS7Data              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS12CertBag          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS12ShroudedKeyBag   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidPKCS9X509Certificate   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPKCS9FriendlyName      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidPKCS9LocalKeyID        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	errEmptyPKCS12Password    = errors.New("pkcs12 bundles need a non-empty password")
+	asn1NullParameters        = asn1.RawValue{Tag: asn1.TagNull}
+	pkcs12ExplicitContentTag0 = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true}
+)
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type pkcs12SafeBag struct {
+	BagID      asn1.ObjectIdentifier
+	BagValue   asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID     asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type pkcs12CertBag struct {
+	CertID    asn1.ObjectIdentifier
+	CertValue asn1.RawValue
+}
+
+type pkcs12AlgorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs12EncryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkcs12AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pkcs12PBES2Parameters struct {
+	KeyDerivationFunction pkcs12AlgorithmIdentifier
+	EncryptionScheme      pkcs12AlgorithmIdentifier
+}
+
+type pkcs12PBKDF2Parameters struct {
+	Salt           []byte
+	IterationCount int
+	PRF            pkcs12AlgorithmIdentifier
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkcs12AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12MACData struct {
+	MAC        pkcs12DigestInfo
+	MACSalt    []byte
+	Iterations int
+}
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MACData  pkcs12MACData
+}
+
+// EncodePKCS12 bundles a private key, its certificate, and the issuing chain into a password-protected PKCS#12 file.
+// The key is encrypted with PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) and the bundle is authenticated with an
+// HMAC-SHA256 MAC, which current OpenSSL, macOS, Windows, and Java releases all import.
+func EncodePKCS12(privateKey crypto.Signer, certificate *x509.Certificate, chain []*x509.Certificate, friendlyName string, password string, randomnessSource io.Reader) ([]byte, error) {
+	if password == "" {
+		return nil, errEmptyPKCS12Password
+	}
+	localKeyID := sha1.Sum(certificate.Raw)
+	keyAttributes, attributesErr := pkcs12BagAttributes(friendlyName, localKeyID[:])
+	if attributesErr != nil {
+		return nil, attributesErr
+	}
+
+	certificateBags := make([]pkcs12SafeBag, 0, len(chain)+1)
+	for certificateIndex, bagCertificate := range append([]*x509.Certificate{certificate}, chain...) {
+		certificateBag, certificateBagErr := pkcs12CertificateBag(bagCertificate)
+		if certificateBagErr != nil {
+			return nil, certificateBagErr
+		}
+		if certificateIndex == 0 {
+			certificateBag.Attributes = keyAttributes
+		}
+		certificateBags = append(certificateBags, certificateBag)
+	}
+
+	encryptedKey, encryptErr := pkcs12EncryptPrivateKey(privateKey, password, randomnessSource)
+	if encryptErr != nil {
+		return nil, encryptErr
+	}
+	keyBag := pkcs12SafeBag{BagID: oidPKCS12ShroudedKeyBag, BagValue: pkcs12ExplicitContent(encryptedKey), Attributes: keyAttributes}
+
+	certificateContents, certificateContentsErr := pkcs12DataContentInfo(certificateBags)
+	if certificateContentsErr != nil {
+		return nil, certificateContentsErr
+	}
+	keyContents, keyContentsErr := pkcs12DataContentInfo([]pkcs12SafeBag{keyBag})
+	if keyContentsErr != nil {
+		return nil, keyContentsErr
+	}
+	authenticatedSafe, authenticatedSafeErr := asn1.Marshal([]pkcs12ContentInfo{certificateContents, keyContents})
+	if authenticatedSafeErr != nil {
+		return nil, fmt.Errorf("encode pkcs12 contents: %w", authenticatedSafeErr)
+	}
+
+	macSalt := make([]byte, pkcs12SaltLength)
+	if _, saltErr := io.ReadFull(randomnessSource, macSalt); saltErr != nil {
+		return nil, fmt.Errorf("generate pkcs12 mac salt: %w", saltErr)
+	}
+	macKey := pkcs12DeriveKey(pkcs12BMPPassword(password), macSalt, pkcs12MACKeyID, pkcs12Iterations, sha256.Size)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(authenticatedSafe)
+
+	authSafe, authSafeErr := pkcs12OctetStringContent(authenticatedSafe)
+	if authSafeErr != nil {
+		return nil, authSafeErr
+	}
+	pfx := pkcs12PFX{
+		Version:  pkcs12Version,
+		AuthSafe: pkcs12ContentInfo{ContentType: oidPKCS7Data, Content: authSafe},
+		MACData: pkcs12MACData{
+			MAC:        pkcs12DigestInfo{Algorithm: pkcs12AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1NullParameters}, Digest: mac.Sum(nil)},
+			MACSalt:    macSalt,
+			Iterations: pkcs12Iterations,
+		},
+	}
+	encoded, encodeErr := asn1.Marshal(pfx)
+	if encodeErr != nil {
+		return nil, fmt.Errorf("encode pkcs12 bundle: %w", encodeErr)
+	}
+	return encoded, nil
+}
+
+func pkcs12BagAttributes(friendlyName string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	localKeyIDValue, localKeyIDErr := asn1.Marshal(localKeyID)
+	if localKeyIDErr != nil {
+		return nil, fmt.Errorf("encode pkcs12 key id: %w", localKeyIDErr)
+	}
+	attributes := []pkcs12Attribute{{ID: oidPKCS9LocalKeyID, Values: asn1.RawValue{FullBytes: pkcs12Set(localKeyIDValue)}}}
+	if friendlyName != "" {
+		friendlyNameValue, friendlyNameErr := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: pkcs12BMPString(friendlyName)})
+		if friendlyNameErr != nil {
+			return nil, fmt.Errorf("encode pkcs12 friendly name: %w", friendlyNameErr)
+		}
+		attributes = append(attributes, pkcs12Attribute{ID: oidPKCS9FriendlyName, Values: asn1.RawValue{FullBytes: pkcs12Set(friendlyNameValue)}})
+	}
+	return attributes, nil
+}
+
+func pkcs12CertificateBag(certificate *x509.Certificate) (pkcs12SafeBag, error) {
+	certificateValue, certificateValueErr := asn1.Marshal(certificate.Raw)
+	if certificateValueErr != nil {
+		return pkcs12SafeBag{}, fmt.Errorf("encode pkcs12 certificate: %w", certificateValueErr)
+	}
+	certificateBag, certificateBagErr := asn1.Marshal(pkcs12CertBag{CertID: oidPKCS9X509Certificate, CertValue: pkcs12ExplicitContent(certificateValue)})
+	if certificateBagErr != nil {
+		return pkcs12SafeBag{}, fmt.Errorf("encode pkcs12 certificate bag: %w", certificateBagErr)
+	}
+	return pkcs12SafeBag{BagID: oidPKCS12CertBag, BagValue: pkcs12ExplicitContent(certificateBag)}, nil
+}
+
+func pkcs12EncryptPrivateKey(privateKey crypto.Signer, password string, randomnessSource io.Reader) ([]byte, error) {
+	privateKeyDer, marshalErr := x509.MarshalPKCS8PrivateKey(privateKey)
+	if marshalErr != nil {
+		return nil, fmt.Errorf("encode pkcs12 private key: %w", marshalErr)
+	}
+	salt := make([]byte, pkcs12SaltLength)
+	initializationVector := make([]byte, aes.BlockSize)
+	if _, saltErr := io.ReadFull(randomnessSource, salt); saltErr != nil {
+		return nil, fmt.Errorf("generate pkcs12 key salt: %w", saltErr)
+	}
+	if _, ivErr := io.ReadFull(randomnessSource, initializationVector); ivErr != nil {
+		return nil, fmt.Errorf("generate pkcs12 key iv: %w", ivErr)
+	}
+	encryptionKey, deriveErr := pbkdf2.Key(sha256.New, password, salt, pkcs12Iterations, 32)
+	if deriveErr != nil {
+		return nil, fmt.Errorf("derive pkcs12 key: %w", deriveErr)
+	}
+	blockCipher, cipherErr := aes.NewCipher(encryptionKey)
+	if cipherErr != nil {
+		return nil, fmt.Errorf("create pkcs12 cipher: %w", cipherErr)
+	}
+	paddingLength := aes.BlockSize - len(privateKeyDer)%aes.BlockSize
+	plaintext := append(append([]byte{}, privateKeyDer...), make([]byte, paddingLength)...)
+	for paddingIndex := len(privateKeyDer); paddingIndex < len(plaintext); paddingIndex++ {
+		plaintext[paddingIndex] = byte(paddingLength)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(blockCipher, initializationVector).CryptBlocks(ciphertext, plaintext)
+
+	pbkdf2Parameters, pbkdf2Err := asn1.Marshal(pkcs12PBKDF2Parameters{
+		Salt:           salt,
+		IterationCount: pkcs12Iterations,
+		PRF:            pkcs12AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1NullParameters},
+	})
+	if pbkdf2Err != nil {
+		return nil, fmt.Errorf("encode pkcs12 kdf parameters: %w", pbkdf2Err)
+	}
+	ivParameter, ivParameterErr := asn1.Marshal(initializationVector)
+	if ivParameterErr != nil {
+		return nil, fmt.Errorf("encode pkcs12 iv: %w", ivParameterErr)
+	}
+	pbes2Parameters, pbes2Err := asn1.Marshal(pkcs12PBES2Parameters{
+		KeyDerivationFunction: pkcs12AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: pbkdf2Parameters}},
+		EncryptionScheme:      pkcs12AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParameter}},
+	})
+	if pbes2Err != nil {
+		return nil, fmt.Errorf("encode pkcs12 encryption parameters: %w", pbes2Err)
+	}
+	encryptedKey, encryptedKeyErr := asn1.Marshal(pkcs12EncryptedPrivateKeyInfo{
+		EncryptionAlgorithm: pkcs12AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: pbes2Parameters}},
+		EncryptedData:       ciphertext,
+	})
+	if encryptedKeyErr != nil {
+		return nil, fmt.Errorf("encode pkcs12 encrypted key: %w", encryptedKeyErr)
+	}
+	return encryptedKey, nil
+}
+
+func pkcs12DataContentInfo(safeBags []pkcs12SafeBag) (pkcs12ContentInfo, error) {
+	safeContents, safeContentsErr := asn1.Marshal(safeBags)
+	if safeContentsErr != nil {
+		return pkcs12ContentInfo{}, fmt.Errorf("encode pkcs12 safe contents: %w", safeContentsErr)
+	}
+	content, contentErr := pkcs12OctetStringContent(safeContents)
+	if contentErr != nil {
+		return pkcs12ContentInfo{}, contentErr
+	}
+	return pkcs12ContentInfo{ContentType: oidPKCS7Data, Content: content}, nil
+}
+
+func pkcs12OctetStringContent(data []byte) (asn1.RawValue, error) {
+	octetString, marshalErr := asn1.Marshal(data)
+	if marshalErr != nil {
+		return asn1.RawValue{}, fmt.Errorf("encode pkcs12 data: %w", marshalErr)
+	}
+	return pkcs12ExplicitContent(octetString), nil
+}
+
+func pkcs12ExplicitContent(encoded []byte) asn1.RawValue {
+	content := pkcs12ExplicitContentTag0
+	content.Bytes = encoded
+	return content
+}
+
+func pkcs12Set(encodedElement []byte) []byte {
+	encodedSet, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: encodedElement})
+	return encodedSet
+}
+
+func pkcs12BMPString(value string) []byte {
+	encoded := make([]byte, 0, 2*len(value))
+	for _, codeUnit := range utf16.Encode([]rune(value)) {
+		encoded = append(encoded, byte(codeUnit>>8), byte(codeUnit))
+	}
+	return encoded
+}
+
+// pkcs12BMPPassword is the password form the PKCS#12 key derivation expects: a BMPString with a trailing zero.
+func pkcs12BMPPassword(password string) []byte {
+	return append(pkcs12BMPString(password), 0, 0)
+}
+
+// pkcs12DeriveKey implements the PKCS#12 key derivation of RFC 7292 appendix B.2 over SHA-256.
+func pkcs12DeriveKey(password []byte, salt []byte, keyID byte, iterations int, keyLength int) []byte {
+	blockSize := pkcs12HashBlockBytes
+	diversifier := make([]byte, blockSize)
+	for diversifierIndex := range diversifier {
+		diversifier[diversifierIndex] = keyID
+	}
+	repeatToBlocks := func(value []byte) []byte {
+		if len(value) == 0 {
+			return nil
+		}
+		repeated := make([]byte, blockSize*((len(value)+blockSize-1)/blockSize))
+		for repeatedIndex := range repeated {
+			repeated[repeatedIndex] = value[repeatedIndex%len(value)]
+		}
+		return repeated
+	}
+	input := append(repeatToBlocks(salt), repeatToBlocks(password)...)
+
+	derivedKey := make([]byte, 0, keyLength+sha256.Size)
+	one := big.NewInt(1)
+	for len(derivedKey) < keyLength {
+		digest := sha256.Sum256(append(append([]byte{}, diversifier...), input...))
+		hashed := digest[:]
+		for iteration := 1; iteration < iterations; iteration++ {
+			nextDigest := sha256.Sum256(hashed)
+			hashed = nextDigest[:]
+		}
+		derivedKey = append(derivedKey, hashed...)
+
+		adjustment := new(big.Int).SetBytes(repeatToBlocks(hashed)[:blockSize])
+		adjustment.Add(adjustment, one)
+		for blockStart := 0; blockStart < len(input); blockStart += blockSize {
+			block := new(big.Int).SetBytes(input[blockStart : blockStart+blockSize])
+			block.Add(block, adjustment)
+			blockBytes := block.Bytes()
+			if len(blockBytes) > blockSize {
+				blockBytes = blockBytes[len(blockBytes)-blockSize:]
+			}
+			copy(input[blockStart:blockStart+blockSize], make([]byte, blockSize))
+			copy(input[blockStart+blockSize-len(blockBytes):blockStart+blockSize], blockBytes)
+		}
+	}
+	return derivedKey[:keyLength]
+}
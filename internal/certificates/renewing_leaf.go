@@ -0,0 +1,83 @@
+package certificates
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// renewalRetryInterval spaces out renewal attempts after one fails, so handshakes keep using the current certificate
+// instead of starting a renewal on every connection.
+const renewalRetryInterval = time.Minute
+
+// RenewingLeafCertificate serves the development leaf certificate and re-issues it in the background once it enters
+// the issuer's renewal window, so a long-running server never presents an expired certificate.
+type RenewingLeafCertificate struct {
+	issuer         ServerCertificateIssuer
+	authority      CertificateAuthorityMaterial
+	request        ServerCertificateRequest
+	observer       func(certificate *tls.Certificate, err error)
+	certificate    atomic.Pointer[tls.Certificate]
+	renewing       atomic.Bool
+	nextRenewalTry atomic.Int64
+}
+
+// NewRenewingLeafCertificate issues or loads the leaf certificate for request. Observer, when set, is told about every
+// renewal that succeeded or failed while serving.
+func NewRenewingLeafCertificate(ctx context.Context, issuer ServerCertificateIssuer, authority CertificateAuthorityMaterial, request ServerCertificateRequest, observer func(certificate *tls.Certificate, err error)) (*RenewingLeafCertificate, error) {
+	renewingLeaf := &RenewingLeafCertificate{
+		issuer:    issuer,
+		authority: authority,
+		request:   request,
+		observer:  observer,
+	}
+	certificate, issueErr := renewingLeaf.issue(ctx)
+	if issueErr != nil {
+		return nil, issueErr
+	}
+	renewingLeaf.certificate.Store(certificate)
+	return renewingLeaf, nil
+}
+
+// Certificate returns the leaf certificate in service without blocking. Inside the renewal window it starts a single
+// background renewal and keeps returning the current certificate until the new one is stored; a failed renewal is
+// retried after renewalRetryInterval.
+func (renewingLeaf *RenewingLeafCertificate) Certificate() *tls.Certificate {
+	certificate := renewingLeaf.certificate.Load()
+	now := renewingLeaf.issuer.clock.Now()
+	renewalThreshold := certificate.Leaf.NotAfter.Add(-renewingLeaf.issuer.configuration.CertificateRenewalWindowDuration)
+	if now.Before(renewalThreshold) || now.UnixNano() < renewingLeaf.nextRenewalTry.Load() {
+		return certificate
+	}
+	if renewingLeaf.renewing.CompareAndSwap(false, true) {
+		go renewingLeaf.renew(now)
+	}
+	return certificate
+}
+
+func (renewingLeaf *RenewingLeafCertificate) renew(startedAt time.Time) {
+	defer renewingLeaf.renewing.Store(false)
+	certificate, issueErr := renewingLeaf.issue(context.Background())
+	if issueErr != nil {
+		renewingLeaf.nextRenewalTry.Store(startedAt.Add(renewalRetryInterval).UnixNano())
+	} else {
+		renewingLeaf.certificate.Store(certificate)
+	}
+	if renewingLeaf.observer != nil {
+		renewingLeaf.observer(renewingLeaf.certificate.Load(), issueErr)
+	}
+}
+
+func (renewingLeaf *RenewingLeafCertificate) issue(ctx context.Context) (*tls.Certificate, error) {
+	material, issueErr := renewingLeaf.issuer.IssueServerCertificate(ctx, renewingLeaf.authority, renewingLeaf.request)
+	if issueErr != nil {
+		return nil, fmt.Errorf("issue server certificate: %w", issueErr)
+	}
+	certificate, parseErr := tls.X509KeyPair(material.CertificateBytes, material.PrivateKeyBytes)
+	if parseErr != nil {
+		return nil, fmt.Errorf("parse server certificate: %w", parseErr)
+	}
+	return &certificate, nil
+}
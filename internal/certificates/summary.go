@@ -0,0 +1,61 @@
+package certificates
+
+import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateSummary describes a certificate for display.
+type CertificateSummary struct {
+	Subject           string
+	Issuer            string
+	Hosts             []string
+	NotBefore         time.Time
+	NotAfter          time.Time
+	KeyType           string
+	SHA256Fingerprint string
+	SHA1Fingerprint   string
+	NameConstraints   []string
+}
+
+// SummarizeCertificate extracts the subject, names, validity, key type, fingerprints, and name constraints of a
+// certificate.
+func SummarizeCertificate(certificate *x509.Certificate) CertificateSummary {
+	hosts := append([]string{}, certificate.DNSNames...)
+	for _, address := range certificate.IPAddresses {
+		hosts = append(hosts, address.String())
+	}
+	keyType := string(keyAlgorithmOf(certificate.PublicKey))
+	if publicKey, isRSA := certificate.PublicKey.(*rsa.PublicKey); isRSA {
+		keyType = fmt.Sprintf("%s-%d", KeyAlgorithmRSA, publicKey.N.BitLen())
+	}
+	if keyType == "" {
+		keyType = strings.ToLower(certificate.PublicKeyAlgorithm.String())
+	}
+	sha256Fingerprint := sha256.Sum256(certificate.Raw)
+	sha1Fingerprint := sha1.Sum(certificate.Raw)
+	return CertificateSummary{
+		Subject:           certificate.Subject.String(),
+		Issuer:            certificate.Issuer.String(),
+		Hosts:             hosts,
+		NotBefore:         certificate.NotBefore,
+		NotAfter:          certificate.NotAfter,
+		KeyType:           keyType,
+		SHA256Fingerprint: formatFingerprint(sha256Fingerprint[:]),
+		SHA1Fingerprint:   formatFingerprint(sha1Fingerprint[:]),
+		NameConstraints:   nameConstraintsOf(certificate).Describe(),
+	}
+}
+
+func formatFingerprint(digest []byte) string {
+	octets := make([]string, len(digest))
+	for octetIndex, octet := range digest {
+		octets[octetIndex] = fmt.Sprintf("%02X", octet)
+	}
+	return strings.Join(octets, ":")
+}
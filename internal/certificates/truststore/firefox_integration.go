@@ -74,6 +74,27 @@ func removeFirefoxCertificates(ctx context.Context, commandRunner certificates.C
 	return nil
 }
 
+// firefoxCertificateStatus reports each Firefox profile as trusting the certificate when certutil finds it in the
+// profile database or, without certutil, when the profile trusts the operating system roots.
+func firefoxCertificateStatus(ctx context.Context, commandRunner certificates.CommandRunner, fileSystem certificates.FileSystem, configuration Configuration) []StoreStatus {
+	profiles := discoverFirefoxProfiles(fileSystem, configuration)
+	certutilAvailable := isCertutilAvailable()
+	statuses := make([]StoreStatus, 0, len(profiles))
+	for _, profile := range profiles {
+		status := StoreStatus{Store: storeNameFirefox, Detail: profile}
+		if certutilAvailable {
+			arguments := []string{"-L", "-n", configuration.CertificateCommonName, "-d", "sql:" + profile}
+			status.Installed = commandRunner.Run(ctx, "certutil", arguments) == nil
+		} else {
+			userPreferences, _ := fileSystem.ReadFile(filepath.Join(profile, firefoxUserPreferenceFile))
+			status.Installed = strings.Contains(string(userPreferences), firefoxUserPreferenceLine)
+			status.Detail = profile + " (enterprise roots)"
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
 func discoverFirefoxProfiles(fileSystem certificates.FileSystem, configuration Configuration) []string {
 	candidateDirectories := configuration.FirefoxProfileDirectories
 	if len(candidateDirectories) == 0 {
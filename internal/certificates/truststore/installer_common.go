@@ -9,12 +9,21 @@ const (
 	commandNameSecurity = "security"
 	commandNameCertutil = "certutil"
 	commandNameTrust    = "trust"
+	storeNameFirefox    = "firefox"
 )
 
 // Installer provisions and removes certificates from operating system trust stores.
 type Installer interface {
 	Install(ctx context.Context, certificatePath string) error
 	Uninstall(ctx context.Context) error
+	Status(ctx context.Context, certificatePath string) ([]StoreStatus, error)
+}
+
+// StoreStatus reports whether the certificate is present in one trust store.
+type StoreStatus struct {
+	Store     string
+	Installed bool
+	Detail    string
 }
 
 // Configuration controls installer behavior across platforms.
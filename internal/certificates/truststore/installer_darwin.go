@@ -67,3 +67,15 @@ func (installer *macOSInstaller) Uninstall(ctx context.Context) error {
 	}
 	return nil
 }
+
+// Status looks the authority up by common name in the login keychain.
+func (installer *macOSInstaller) Status(ctx context.Context, certificatePath string) ([]StoreStatus, error) {
+	arguments := []string{"find-certificate", "-c", installer.configuration.CertificateCommonName, installer.configuration.MacOSKeychainPath}
+	keychainStatus := StoreStatus{
+		Store:     "macos keychain",
+		Installed: installer.commandRunner.Run(ctx, commandNameSecurity, arguments) == nil,
+		Detail:    installer.configuration.MacOSKeychainPath,
+	}
+	statuses := []StoreStatus{keychainStatus}
+	return append(statuses, firefoxCertificateStatus(ctx, installer.commandRunner, installer.fileSystem, installer.configuration)...), nil
+}
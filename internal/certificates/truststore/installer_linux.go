@@ -3,6 +3,7 @@
 package truststore
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -91,3 +92,22 @@ func (installer *linuxInstaller) Uninstall(ctx context.Context) error {
 	}
 	return nil
 }
+
+// Status compares the anchor file with the certificate, so an anchor left over from a rotated authority shows as not
+// installed.
+func (installer *linuxInstaller) Status(ctx context.Context, certificatePath string) ([]StoreStatus, error) {
+	certificateBytes, readErr := installer.fileSystem.ReadFile(certificatePath)
+	if readErr != nil {
+		return nil, fmt.Errorf("read certificate: %w", readErr)
+	}
+	systemStatus := StoreStatus{Store: "linux trust anchors", Detail: installer.configuration.LinuxCertificateDestinationPath}
+	if anchorPath := installer.configuration.LinuxCertificateDestinationPath; anchorPath != "" {
+		anchorBytes, anchorErr := installer.fileSystem.ReadFile(anchorPath)
+		systemStatus.Installed = anchorErr == nil && bytes.Equal(anchorBytes, certificateBytes)
+		if anchorErr == nil && !systemStatus.Installed {
+			systemStatus.Detail = anchorPath + " (holds another certificate)"
+		}
+	}
+	statuses := []StoreStatus{systemStatus}
+	return append(statuses, firefoxCertificateStatus(ctx, installer.commandRunner, installer.fileSystem, installer.configuration)...), nil
+}
@@ -61,3 +61,15 @@ func (installer *windowsInstaller) Uninstall(ctx context.Context) error {
 	}
 	return nil
 }
+
+// Status looks the authority up by common name in the current user's certificate store.
+func (installer *windowsInstaller) Status(ctx context.Context, certificatePath string) ([]StoreStatus, error) {
+	arguments := []string{"-user", "-verifystore", installer.configuration.WindowsCertificateStoreName, installer.configuration.CertificateCommonName}
+	storeStatus := StoreStatus{
+		Store:     "windows certificate store",
+		Installed: installer.commandRunner.Run(ctx, commandNameCertutil, arguments) == nil,
+		Detail:    "CurrentUser\\" + installer.configuration.WindowsCertificateStoreName,
+	}
+	statuses := []StoreStatus{storeStatus}
+	return append(statuses, firefoxCertificateStatus(ctx, installer.commandRunner, installer.fileSystem, installer.configuration)...), nil
+}
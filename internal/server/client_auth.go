@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	// ClientAuthModeRequest asks for a client certificate but accepts requests without one or with an untrusted one.
+	ClientAuthModeRequest = "request"
+	// ClientAuthModeRequire rejects requests without a client certificate signed by the client CA bundle.
+	ClientAuthModeRequire = "require"
+	// ClientAuthModeVerifyIfGiven accepts requests without a client certificate and rejects untrusted ones.
+	ClientAuthModeVerifyIfGiven = "verify-if-given"
+
+	clientAuthMappingSeparator          = "="
+	logMessageClientCertificateRejected = "client certificate rejected"
+	logFieldClientCertificate           = "client_cert"
+	logFieldClientCertificateNames      = "client_sans"
+	clientCertificateMissingReason      = "client certificate required"
+	clientCertificateUntrustedReason    = "client certificate not trusted"
+)
+
+var ErrInvalidClientAuth = errors.New("client.auth.invalid")
+
+// ClientAuthPolicies verifies TLS client certificates against a CA bundle. The server-wide mode can be overridden per
+// path prefix; the longest matching prefix wins.
+type ClientAuthPolicies struct {
+	certificatePool *x509.CertPool
+	defaultMode     string
+	routes          []clientAuthRoute
+}
+
+type clientAuthRoute struct {
+	pathPrefix string
+	mode       string
+}
+
+type clientCertificateContextKey struct{}
+
+// clientCertificateDetails exposes the presented client certificate to request header templates as .ClientCert.
+type clientCertificateDetails struct {
+	Present           bool
+	Verified          bool
+	Subject           string
+	CommonName        string
+	Issuer            string
+	SerialNumber      string
+	DNSNames          []string
+	EmailAddresses    []string
+	IPAddresses       []string
+	URIs              []string
+	SANs              string
+	SHA256Fingerprint string
+}
+
+// NewClientAuthPolicies loads the client CA bundle and parses /path=mode overrides. An empty bundle path without
+// overrides disables client certificate verification.
+func NewClientAuthPolicies(bundlePath string, defaultMode string, mappings []string) (ClientAuthPolicies, error) {
+	if bundlePath == "" {
+		if len(mappings) > 0 {
+			return ClientAuthPolicies{}, fmt.Errorf("%w: per-route client auth modes need a client CA bundle", ErrInvalidClientAuth)
+		}
+		return ClientAuthPolicies{}, nil
+	}
+	normalizedDefaultMode, modeErr := parseClientAuthMode(defaultMode)
+	if modeErr != nil {
+		return ClientAuthPolicies{}, modeErr
+	}
+	bundleBytes, readErr := os.ReadFile(bundlePath)
+	if readErr != nil {
+		return ClientAuthPolicies{}, fmt.Errorf("%w: read client CA bundle: %s", ErrInvalidClientAuth, readErr.Error())
+	}
+	certificatePool := x509.NewCertPool()
+	if !certificatePool.AppendCertsFromPEM(bundleBytes) {
+		return ClientAuthPolicies{}, fmt.Errorf("%w: %s contains no PEM certificates", ErrInvalidClientAuth, bundlePath)
+	}
+
+	modeByPathPrefix := map[string]string{}
+	for _, mapping := range mappings {
+		pathPrefix, mode, found := strings.Cut(strings.TrimSpace(mapping), clientAuthMappingSeparator)
+		pathPrefix = strings.TrimSpace(pathPrefix)
+		if !found || !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+			return ClientAuthPolicies{}, fmt.Errorf("%w: mapping %q must be in /path=mode form", ErrInvalidClientAuth, mapping)
+		}
+		normalizedMode, routeModeErr := parseClientAuthMode(mode)
+		if routeModeErr != nil {
+			return ClientAuthPolicies{}, routeModeErr
+		}
+		modeByPathPrefix[pathPrefix] = normalizedMode
+	}
+	routes := make([]clientAuthRoute, 0, len(modeByPathPrefix))
+	for pathPrefix, mode := range modeByPathPrefix {
+		routes = append(routes, clientAuthRoute{pathPrefix: pathPrefix, mode: mode})
+	}
+	sort.SliceStable(routes, func(leftIndex int, rightIndex int) bool {
+		return len(routes[leftIndex].pathPrefix) > len(routes[rightIndex].pathPrefix)
+	})
+	return ClientAuthPolicies{certificatePool: certificatePool, defaultMode: normalizedDefaultMode, routes: routes}, nil
+}
+
+// IsEnabled reports whether a client CA bundle is configured.
+func (policies ClientAuthPolicies) IsEnabled() bool {
+	return policies.certificatePool != nil
+}
+
+func parseClientAuthMode(mode string) (string, error) {
+	normalizedMode := strings.ToLower(strings.TrimSpace(mode))
+	switch normalizedMode {
+	case "":
+		return ClientAuthModeRequire, nil
+	case ClientAuthModeRequest, ClientAuthModeRequire, ClientAuthModeVerifyIfGiven:
+		return normalizedMode, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported mode %q (use %s, %s, or %s)", ErrInvalidClientAuth, mode, ClientAuthModeRequest, ClientAuthModeRequire, ClientAuthModeVerifyIfGiven)
+	}
+}
+
+// configure sets the handshake policy. Without per-route modes the handshake enforces the server-wide mode; with them
+// the handshake only asks for a certificate and clientAuthHandler enforces the mode of each request path.
+func (policies ClientAuthPolicies) configure(tlsConfig *tls.Config) {
+	tlsConfig.ClientCAs = policies.certificatePool
+	tlsConfig.ClientAuth = tls.RequestClientCert
+	if len(policies.routes) > 0 {
+		return
+	}
+	switch policies.defaultMode {
+	case ClientAuthModeRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case ClientAuthModeVerifyIfGiven:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+}
+
+func (policies ClientAuthPolicies) modeFor(requestPath string) string {
+	for _, route := range policies.routes {
+		if strings.HasPrefix(requestPath, route.pathPrefix) {
+			return route.mode
+		}
+	}
+	return policies.defaultMode
+}
+
+func (policies ClientAuthPolicies) inspect(request *http.Request) clientCertificateDetails {
+	if request.TLS == nil || len(request.TLS.PeerCertificates) == 0 {
+		return clientCertificateDetails{}
+	}
+	certificate := request.TLS.PeerCertificates[0]
+	verified := len(request.TLS.VerifiedChains) > 0
+	if !verified {
+		intermediates := x509.NewCertPool()
+		for _, intermediate := range request.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(intermediate)
+		}
+		_, verifyErr := certificate.Verify(x509.VerifyOptions{
+			Roots:         policies.certificatePool,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		verified = verifyErr == nil
+	}
+	details := clientCertificateDetails{
+		Present:        true,
+		Verified:       verified,
+		Subject:        certificate.Subject.String(),
+		CommonName:     certificate.Subject.CommonName,
+		Issuer:         certificate.Issuer.String(),
+		SerialNumber:   certificate.SerialNumber.Text(16),
+		DNSNames:       certificate.DNSNames,
+		EmailAddresses: certificate.EmailAddresses,
+	}
+	for _, address := range certificate.IPAddresses {
+		details.IPAddresses = append(details.IPAddresses, address.String())
+	}
+	for _, uri := range certificate.URIs {
+		details.URIs = append(details.URIs, uri.String())
+	}
+	details.SANs = strings.Join(details.names(), ", ")
+	fingerprint := sha256.Sum256(certificate.Raw)
+	details.SHA256Fingerprint = hex.EncodeToString(fingerprint[:])
+	return details
+}
+
+func (details clientCertificateDetails) names() []string {
+	names := append([]string{}, details.DNSNames...)
+	names = append(names, details.EmailAddresses...)
+	names = append(names, details.IPAddresses...)
+	return append(names, details.URIs...)
+}
+
+func clientCertificateFromContext(ctx context.Context) clientCertificateDetails {
+	details, _ := ctx.Value(clientCertificateContextKey{}).(clientCertificateDetails)
+	return details
+}
+
+// clientAuthHandler enforces the client certificate mode of each request path and records the presented certificate
+// for the request log and proxy request header templates.
+type clientAuthHandler struct {
+	next           http.Handler
+	policies       ClientAuthPolicies
+	loggingService *logging.Service
+}
+
+func newClientAuthHandler(next http.Handler, policies ClientAuthPolicies, loggingService *logging.Service) http.Handler {
+	return &clientAuthHandler{next: next, policies: policies, loggingService: loggingService}
+}
+
+func (handler *clientAuthHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	details := handler.policies.inspect(request)
+	if details.Present {
+		markRequestClientCertificate(request, details)
+	}
+	mode := handler.policies.modeFor(request.URL.Path)
+	rejectionReason := ""
+	switch {
+	case mode == ClientAuthModeRequire && !details.Present:
+		rejectionReason = clientCertificateMissingReason
+	case mode != ClientAuthModeRequest && details.Present && !details.Verified:
+		rejectionReason = clientCertificateUntrustedReason
+	}
+	if rejectionReason != "" {
+		handler.loggingService.Info(
+			logMessageClientCertificateRejected,
+			logging.String(logFieldPath, request.URL.Path),
+			logging.String(logFieldReason, rejectionReason),
+			logging.String(logFieldClientCertificate, details.Subject),
+		)
+		http.Error(responseWriter, "Forbidden: "+rejectionReason, http.StatusForbidden)
+		return
+	}
+	handler.next.ServeHTTP(responseWriter, request.WithContext(context.WithValue(request.Context(), clientCertificateContextKey{}, details)))
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	requestHandlerFaultAdmin = "fault-admin"
+	headerFaultInjected      = "X-Ghttp-Fault"
+)
+
+var errFaultConnectionAborted = errors.New("connection aborted by fault injection")
+
+type faultHandler struct {
+	next          http.Handler
+	faultInjector *FaultInjector
+}
+
+func newFaultHandler(next http.Handler, faultInjector *FaultInjector) http.Handler {
+	return &faultHandler{next: next, faultInjector: faultInjector}
+}
+
+func (handler *faultHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.URL.Path == faultAdminPath {
+		markRequestHandler(request, requestHandlerFaultAdmin)
+		handler.faultInjector.serveAdmin(responseWriter, request)
+		return
+	}
+	rule, selected := handler.faultInjector.selectFault(request)
+	if !selected {
+		handler.next.ServeHTTP(responseWriter, request)
+		return
+	}
+	isWebSocket := isWebSocketUpgrade(request)
+	markRequestFault(request, rule.describe(isWebSocket))
+
+	if rule.delay > 0 {
+		delayTimer := time.NewTimer(rule.delay)
+		defer delayTimer.Stop()
+		select {
+		case <-delayTimer.C:
+		case <-request.Context().Done():
+			return
+		}
+	}
+	switch {
+	case rule.status != 0:
+		responseWriter.Header().Set(headerFaultInjected, rule.name)
+		http.Error(responseWriter, fmt.Sprintf("gHTTP injected fault %s: %d %s", rule.name, rule.status, http.StatusText(rule.status)), rule.status)
+	case isWebSocket && rule.webSocketDropAfter > 0:
+		handler.next.ServeHTTP(&webSocketDropWriter{ResponseWriter: responseWriter, dropAfter: rule.webSocketDropAfter}, request)
+	case !isWebSocket && (rule.truncateAfterBytes >= 0 || rule.abortAfterBytes >= 0):
+		faultWriter := &bodyFaultWriter{ResponseWriter: responseWriter, truncateAfterBytes: rule.truncateAfterBytes, abortAfterBytes: rule.abortAfterBytes}
+		// The reverse proxy panics with http.ErrAbortHandler when its writes fail; once this handler closed the
+		// connection on purpose that is expected and must not cost the request its log line.
+		defer func() {
+			if recovered := recover(); recovered != nil && (recovered != http.ErrAbortHandler || !faultWriter.connectionClosed) {
+				panic(recovered)
+			}
+		}()
+		handler.next.ServeHTTP(faultWriter, request)
+		if rule.abortAfterBytes >= 0 {
+			faultWriter.abort()
+		}
+	default:
+		handler.next.ServeHTTP(responseWriter, request)
+	}
+}
+
+// bodyFaultWriter passes through at most truncateAfterBytes (ending the response cleanly) or abortAfterBytes (then
+// dropping the connection) of the response body.
+type bodyFaultWriter struct {
+	http.ResponseWriter
+	truncateAfterBytes int64
+	abortAfterBytes    int64
+	bytesWritten       int64
+	wroteHeader        bool
+	aborted            bool
+	connectionClosed   bool
+}
+
+func (writer *bodyFaultWriter) WriteHeader(statusCode int) {
+	if writer.wroteHeader {
+		return
+	}
+	writer.wroteHeader = true
+	if writer.truncateAfterBytes >= 0 {
+		writer.Header().Del(headerContentLength)
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *bodyFaultWriter) Write(content []byte) (int, error) {
+	if !writer.wroteHeader {
+		writer.WriteHeader(http.StatusOK)
+	}
+	if writer.aborted {
+		return 0, errFaultConnectionAborted
+	}
+	limit := writer.truncateAfterBytes
+	if writer.abortAfterBytes >= 0 {
+		limit = writer.abortAfterBytes
+	}
+	allowedBytes := min(int64(len(content)), max(limit-writer.bytesWritten, 0))
+	if allowedBytes > 0 {
+		written, writeErr := writer.ResponseWriter.Write(content[:allowedBytes])
+		writer.bytesWritten += int64(written)
+		if writeErr != nil {
+			return written, writeErr
+		}
+	}
+	if allowedBytes == int64(len(content)) {
+		return len(content), nil
+	}
+	if writer.abortAfterBytes >= 0 {
+		writer.abort()
+		return int(allowedBytes), errFaultConnectionAborted
+	}
+	return len(content), nil
+}
+
+func (writer *bodyFaultWriter) Flush() {
+	if responseFlusher, supportsFlush := writer.ResponseWriter.(http.Flusher); supportsFlush {
+		responseFlusher.Flush()
+	}
+}
+
+func (writer *bodyFaultWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+// abort sends whatever was written so far and closes the underlying connection. Connections that cannot be hijacked
+// (HTTP/2) are reset by aborting the handler instead.
+func (writer *bodyFaultWriter) abort() {
+	if writer.aborted {
+		return
+	}
+	writer.aborted = true
+	if !writer.wroteHeader {
+		writer.WriteHeader(http.StatusOK)
+	}
+	writer.Flush()
+	connection, _, hijackErr := http.NewResponseController(writer.ResponseWriter).Hijack()
+	if hijackErr != nil {
+		panic(http.ErrAbortHandler)
+	}
+	_ = connection.Close()
+	writer.connectionClosed = true
+}
+
+// webSocketDropWriter closes hijacked connections once dropAfter has elapsed.
+type webSocketDropWriter struct {
+	http.ResponseWriter
+	dropAfter time.Duration
+}
+
+func (writer *webSocketDropWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+func (writer *webSocketDropWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	connection, readWriter, hijackErr := http.NewResponseController(writer.ResponseWriter).Hijack()
+	if hijackErr != nil {
+		return nil, nil, hijackErr
+	}
+	time.AfterFunc(writer.dropAfter, func() {
+		_ = connection.Close()
+	})
+	return connection, readWriter, nil
+}
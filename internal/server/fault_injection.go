@@ -0,0 +1,282 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	faultAdminPath        = "/__ghttp/faults"
+	faultRuleNamePrefix   = "fault-"
+	faultDefaultPercent   = 100
+	faultKindSeparator    = ","
+	faultKindDelay        = "delay"
+	faultKindStatus       = "status"
+	faultKindTruncate     = "truncate"
+	faultKindAbort        = "abort"
+	faultKindWebSocketEnd = "websocket-drop"
+)
+
+var ErrInvalidFaultFixture = errors.New("fault.fixture.invalid")
+
+// FaultInjector holds the fault rules declared in a fixture file together with their runtime on/off switches.
+type FaultInjector struct {
+	enabled atomic.Bool
+	rules   []*faultRule
+}
+
+type faultFixtureDocument struct {
+	Faults []faultDefinition `json:"faults" yaml:"faults"`
+}
+
+type faultDefinition struct {
+	Name               string   `json:"name" yaml:"name"`
+	Method             string   `json:"method" yaml:"method"`
+	Path               string   `json:"path" yaml:"path"`
+	Enabled            *bool    `json:"enabled" yaml:"enabled"`
+	Percentage         *float64 `json:"percentage" yaml:"percentage"`
+	Status             int      `json:"status" yaml:"status"`
+	Delay              string   `json:"delay" yaml:"delay"`
+	TruncateAfterBytes *int64   `json:"truncate_after_bytes" yaml:"truncate_after_bytes"`
+	AbortAfterBytes    *int64   `json:"abort_after_bytes" yaml:"abort_after_bytes"`
+	WebSocketDropAfter string   `json:"websocket_drop_after" yaml:"websocket_drop_after"`
+}
+
+type faultRule struct {
+	name               string
+	method             string
+	pathPrefix         string
+	enabled            atomic.Bool
+	percentage         float64
+	status             int
+	delay              time.Duration
+	truncateAfterBytes int64
+	abortAfterBytes    int64
+	webSocketDropAfter time.Duration
+}
+
+// faultState is the JSON document served and accepted by the fault admin endpoint.
+type faultState struct {
+	Enabled bool             `json:"enabled"`
+	Rules   []faultRuleState `json:"rules"`
+}
+
+type faultRuleState struct {
+	Name       string  `json:"name"`
+	Method     string  `json:"method,omitempty"`
+	Path       string  `json:"path"`
+	Enabled    bool    `json:"enabled"`
+	Percentage float64 `json:"percentage"`
+}
+
+type faultToggle struct {
+	Rule    string `json:"rule"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// LoadFaultInjector parses a YAML or JSON fault fixture. Faults start enabled.
+func LoadFaultInjector(fixturePath string) (*FaultInjector, error) {
+	fixtureBytes, readErr := os.ReadFile(fixturePath)
+	if readErr != nil {
+		return nil, fmt.Errorf("%w: read %s: %s", ErrInvalidFaultFixture, fixturePath, readErr.Error())
+	}
+	var document faultFixtureDocument
+	if decodeErr := decodeFixtureFile(fixturePath, fixtureBytes, &document, ErrInvalidFaultFixture); decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	faultInjector := &FaultInjector{rules: make([]*faultRule, 0, len(document.Faults))}
+	ruleNames := map[string]struct{}{}
+	for definitionIndex, definition := range document.Faults {
+		rule, ruleErr := newFaultRule(definition, definitionIndex)
+		if ruleErr != nil {
+			return nil, fmt.Errorf("fault %d (%s): %w", definitionIndex+1, definition.Path, ruleErr)
+		}
+		if _, duplicate := ruleNames[rule.name]; duplicate {
+			return nil, fmt.Errorf("%w: duplicate fault name %s", ErrInvalidFaultFixture, rule.name)
+		}
+		ruleNames[rule.name] = struct{}{}
+		faultInjector.rules = append(faultInjector.rules, rule)
+	}
+	faultInjector.enabled.Store(true)
+	return faultInjector, nil
+}
+
+func newFaultRule(definition faultDefinition, definitionIndex int) (*faultRule, error) {
+	pathPrefix := strings.TrimSpace(definition.Path)
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return nil, fmt.Errorf("%w: path must start with /", ErrInvalidFaultFixture)
+	}
+	rule := &faultRule{
+		name:       strings.TrimSpace(definition.Name),
+		method:     strings.ToUpper(strings.TrimSpace(definition.Method)),
+		pathPrefix: pathPrefix,
+		percentage: faultDefaultPercent,
+		status:     definition.Status,
+	}
+	if rule.name == "" {
+		rule.name = faultRuleNamePrefix + strconv.Itoa(definitionIndex+1)
+	}
+	rule.enabled.Store(definition.Enabled == nil || *definition.Enabled)
+	if definition.Percentage != nil {
+		if *definition.Percentage < 0 || *definition.Percentage > 100 {
+			return nil, fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidFaultFixture)
+		}
+		rule.percentage = *definition.Percentage
+	}
+	if rule.status != 0 && (rule.status < 100 || rule.status > 599) {
+		return nil, fmt.Errorf("%w: unsupported status %d", ErrInvalidFaultFixture, rule.status)
+	}
+
+	var durationErr error
+	if rule.delay, durationErr = parseFaultDuration(definition.Delay); durationErr != nil {
+		return nil, fmt.Errorf("%w: invalid delay %s", ErrInvalidFaultFixture, definition.Delay)
+	}
+	if rule.webSocketDropAfter, durationErr = parseFaultDuration(definition.WebSocketDropAfter); durationErr != nil {
+		return nil, fmt.Errorf("%w: invalid websocket_drop_after %s", ErrInvalidFaultFixture, definition.WebSocketDropAfter)
+	}
+
+	rule.truncateAfterBytes = -1
+	rule.abortAfterBytes = -1
+	if definition.TruncateAfterBytes != nil {
+		if *definition.TruncateAfterBytes < 0 {
+			return nil, fmt.Errorf("%w: truncate_after_bytes must not be negative", ErrInvalidFaultFixture)
+		}
+		rule.truncateAfterBytes = *definition.TruncateAfterBytes
+	}
+	if definition.AbortAfterBytes != nil {
+		if *definition.AbortAfterBytes < 0 {
+			return nil, fmt.Errorf("%w: abort_after_bytes must not be negative", ErrInvalidFaultFixture)
+		}
+		rule.abortAfterBytes = *definition.AbortAfterBytes
+	}
+	if rule.truncateAfterBytes >= 0 && rule.abortAfterBytes >= 0 {
+		return nil, fmt.Errorf("%w: truncate_after_bytes and abort_after_bytes are mutually exclusive", ErrInvalidFaultFixture)
+	}
+	if rule.status != 0 && (rule.truncateAfterBytes >= 0 || rule.abortAfterBytes >= 0) {
+		return nil, fmt.Errorf("%w: status cannot be combined with truncate_after_bytes or abort_after_bytes", ErrInvalidFaultFixture)
+	}
+	if rule.status == 0 && rule.delay == 0 && rule.truncateAfterBytes < 0 && rule.abortAfterBytes < 0 && rule.webSocketDropAfter == 0 {
+		return nil, fmt.Errorf("%w: fault must set status, delay, truncate_after_bytes, abort_after_bytes or websocket_drop_after", ErrInvalidFaultFixture)
+	}
+	return rule, nil
+}
+
+func parseFaultDuration(rawDuration string) (time.Duration, error) {
+	trimmedDuration := strings.TrimSpace(rawDuration)
+	if trimmedDuration == "" {
+		return 0, nil
+	}
+	parsedDuration, parseErr := time.ParseDuration(trimmedDuration)
+	if parseErr != nil {
+		return 0, parseErr
+	}
+	if parsedDuration < 0 {
+		return 0, errors.New("negative duration")
+	}
+	return parsedDuration, nil
+}
+
+// selectFault returns the first enabled rule matching the request, after rolling its percentage.
+func (faultInjector *FaultInjector) selectFault(request *http.Request) (*faultRule, bool) {
+	if !faultInjector.enabled.Load() {
+		return nil, false
+	}
+	for _, rule := range faultInjector.rules {
+		if !rule.enabled.Load() || !strings.HasPrefix(request.URL.Path, rule.pathPrefix) {
+			continue
+		}
+		if rule.method != "" && rule.method != request.Method {
+			continue
+		}
+		if rule.percentage < faultDefaultPercent && rand.Float64()*faultDefaultPercent >= rule.percentage {
+			return nil, false
+		}
+		return rule, true
+	}
+	return nil, false
+}
+
+// describe lists the fault kinds the rule applies to this request, for request logs.
+func (rule *faultRule) describe(isWebSocket bool) string {
+	faultKinds := make([]string, 0, 3)
+	if rule.delay > 0 {
+		faultKinds = append(faultKinds, faultKindDelay+":"+rule.delay.String())
+	}
+	switch {
+	case rule.status != 0:
+		faultKinds = append(faultKinds, faultKindStatus+":"+strconv.Itoa(rule.status))
+	case isWebSocket && rule.webSocketDropAfter > 0:
+		faultKinds = append(faultKinds, faultKindWebSocketEnd+":"+rule.webSocketDropAfter.String())
+	case !isWebSocket && rule.truncateAfterBytes >= 0:
+		faultKinds = append(faultKinds, faultKindTruncate+":"+strconv.FormatInt(rule.truncateAfterBytes, 10))
+	case !isWebSocket && rule.abortAfterBytes >= 0:
+		faultKinds = append(faultKinds, faultKindAbort+":"+strconv.FormatInt(rule.abortAfterBytes, 10))
+	}
+	return rule.name + "(" + strings.Join(faultKinds, faultKindSeparator) + ")"
+}
+
+func (faultInjector *FaultInjector) state() faultState {
+	currentState := faultState{Enabled: faultInjector.enabled.Load(), Rules: make([]faultRuleState, 0, len(faultInjector.rules))}
+	for _, rule := range faultInjector.rules {
+		currentState.Rules = append(currentState.Rules, faultRuleState{
+			Name:       rule.name,
+			Method:     rule.method,
+			Path:       rule.pathPrefix,
+			Enabled:    rule.enabled.Load(),
+			Percentage: rule.percentage,
+		})
+	}
+	return currentState
+}
+
+// serveAdmin reports fault state on GET and toggles all faults, or one named rule, on POST/PUT.
+func (faultInjector *FaultInjector) serveAdmin(responseWriter http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost, http.MethodPut:
+		var toggle faultToggle
+		decoder := json.NewDecoder(request.Body)
+		decoder.DisallowUnknownFields()
+		if decodeErr := decoder.Decode(&toggle); decodeErr != nil || toggle.Enabled == nil {
+			http.Error(responseWriter, `fault toggle must be a JSON object like {"enabled":false} or {"rule":"name","enabled":true}`, http.StatusBadRequest)
+			return
+		}
+		if toggle.Rule == "" {
+			faultInjector.enabled.Store(*toggle.Enabled)
+			break
+		}
+		rule, found := faultInjector.ruleNamed(toggle.Rule)
+		if !found {
+			http.Error(responseWriter, "unknown fault rule "+toggle.Rule, http.StatusNotFound)
+			return
+		}
+		rule.enabled.Store(*toggle.Enabled)
+	default:
+		responseWriter.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(responseWriter, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	responseWriter.Header().Set(headerContentType, "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+	if request.Method != http.MethodHead {
+		_ = json.NewEncoder(responseWriter).Encode(faultInjector.state())
+	}
+}
+
+func (faultInjector *FaultInjector) ruleNamed(ruleName string) (*faultRule, bool) {
+	for _, rule := range faultInjector.rules {
+		if rule.name == ruleName {
+			return rule, true
+		}
+	}
+	return nil, false
+}
@@ -34,7 +34,10 @@ const (
 	logFieldRemote                       = "remote"
 	logFieldDuration                     = "duration"
 	logFieldStatus                       = "status"
+	logFieldHandler                      = "handler"
+	logFieldFault                        = "fault"
 	logFieldTimestamp                    = "timestamp"
+	logFieldRedirect                     = "redirect"
 	logMessageServingHTTP                = "serving http"
 	logMessageServingHTTPS               = "serving https"
 	logMessageShutdownInitiated          = "shutdown initiated"
@@ -51,6 +54,7 @@ type FileServerConfiguration struct {
 	Port                    string
 	DirectoryPath           string
 	ProtocolVersion         string
+	EnableH2C               bool
 	DisableDirectoryListing bool
 	EnableMarkdown          bool
 	BrowseDirectories       bool
@@ -60,13 +64,54 @@ type FileServerConfiguration struct {
 	ProxyRoutes             ProxyRoutes
 	RouteResponsePolicies   RouteResponsePolicies
 	ProxyStreamingPolicies  ProxyStreamingPolicies
+	ProxyBackendTLSPolicies ProxyBackendTLSPolicies
+	ProxyGRPCWebPolicies    ProxyGRPCWebPolicies
+	ProxyFallbackPolicies   ProxyFallbackPolicies
+	ProxyMirrors            ProxyMirrors
+	ProxyRewritePolicies    ProxyRewritePolicies
+	ProxyRequestHeaders     ProxyRequestHeaders
+	ProxyCache              *ProxyCache
+	ProxyWebSocketPing      time.Duration
+	ProxyWebSocketIdle      time.Duration
+	ProxyWebSocketInspector *ProxyWebSocketInspector
+	ReadHeaderTimeout       time.Duration
+	ReadTimeout             time.Duration
+	WriteTimeout            time.Duration
+	IdleTimeout             time.Duration
+	MaxHeaderBytes          int
+	RequestLimits           RequestLimits
+	MockRoutes              *MockRoutes
+	RESTStores              *RESTStores
+	FaultInjector           *FaultInjector
+	ProxyTrafficRecorder    *ProxyTrafficRecorder
+	ProxyTrafficReplayer    *ProxyTrafficReplayer
+	HTTPListener            *HTTPListenerConfiguration
+}
+
+// HTTPListenerConfiguration adds a cleartext HTTP listener next to the HTTPS one. Both share the request handlers unless
+// RedirectToHTTPS sends clients to the HTTPS port.
+type HTTPListenerConfiguration struct {
+	Port            string
+	RedirectToHTTPS bool
+	// RedirectExcludedPaths lists path prefixes that are served over HTTP even when RedirectToHTTPS is set.
+	RedirectExcludedPaths []string
 }
 
 // TLSConfiguration describes transport layer security configuration.
 type TLSConfiguration struct {
-	CertificatePath   string
-	PrivateKeyPath    string
-	LoadedCertificate *tls.Certificate
+	// CertificatePath and PrivateKeyPath are watched while serving; changed files, or a SIGHUP, swap in the new pair
+	// when it loads and is currently valid.
+	CertificatePath string
+	PrivateKeyPath  string
+	// DefaultCertificate, when set, replaces the certificate files and is consulted during each handshake, so callers
+	// can renew the certificate while the server runs. It must not block.
+	DefaultCertificate func() *tls.Certificate
+	// GetCertificate, when set, is consulted during each handshake; a nil certificate falls back to the default one.
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// ClientAuth, when enabled, asks clients for certificates and verifies them against the client CA bundle.
+	ClientAuth ClientAuthPolicies
+	// ProtocolPolicy restricts TLS versions, cipher suites, and curves; the zero value keeps the crypto/tls defaults.
+	ProtocolPolicy TLSProtocolPolicy
 }
 
 // FileServer serves files over HTTP or HTTPS.
@@ -86,7 +131,6 @@ func (fileServer FileServer) Serve(ctx context.Context, configuration FileServer
 		return errors.New("logging service not configured")
 	}
 	listeningAddress := net.JoinHostPort(configuration.BindAddress, configuration.Port)
-	displayAddress := fileServer.servingAddressFormatter.FormatHostAndPortForLogging(configuration.BindAddress, configuration.Port)
 	fileHandler := fileServer.buildFileHandler(configuration)
 	wrappedHandler := fileServer.wrapWithHeaders(fileHandler, configuration.ProtocolVersion)
 	if !configuration.RouteResponsePolicies.IsEmpty() {
@@ -101,72 +145,94 @@ func (fileServer FileServer) Serve(ctx context.Context, configuration FileServer
 		return fmt.Errorf("normalize logging type: %w", normalizeErr)
 	}
 	loggingType = normalizedLoggingType
-	loggingHandler := fileServer.wrapWithLogging(wrappedHandler, loggingType)
-
-	server := &http.Server{
-		Addr:              listeningAddress,
-		Handler:           loggingHandler,
-		ReadHeaderTimeout: 15 * time.Second,
+	if !configuration.RequestLimits.IsEmpty() || configuration.ReadTimeout > 0 || configuration.WriteTimeout > 0 {
+		wrappedHandler = newRequestLimitHandler(wrappedHandler, configuration.RequestLimits, fileServer.loggingService)
 	}
-
-	if configuration.ProtocolVersion == httpProtocolVersionOneZero {
-		server.DisableGeneralOptionsHandler = true
-		server.SetKeepAlivesEnabled(false)
+	if configuration.TLS != nil && configuration.TLS.ClientAuth.IsEnabled() {
+		wrappedHandler = newClientAuthHandler(wrappedHandler, configuration.TLS.ClientAuth, fileServer.loggingService)
 	}
+	loggingHandler := fileServer.wrapWithLogging(wrappedHandler, loggingType)
 
-	certificateConfigured, configureErr := fileServer.configureTLS(server, configuration.TLS)
+	server := newHTTPServer(listeningAddress, loggingHandler, configuration)
+	certificateConfigured, certificateReloader, configureErr := fileServer.configureTLS(server, configuration.TLS)
 	if configureErr != nil {
 		return fmt.Errorf("configure tls: %w", configureErr)
 	}
+	if certificateReloader != nil {
+		certificateReloader.watch(ctx)
+	}
+	listeners := []servingListener{{server: server, port: configuration.Port, secure: certificateConfigured}}
+	if certificateConfigured && configuration.HTTPListener != nil {
+		httpHandler := wrappedHandler
+		if configuration.HTTPListener.RedirectToHTTPS {
+			httpHandler = newHTTPSRedirectHandler(wrappedHandler, configuration.Port, configuration.HTTPListener.RedirectExcludedPaths)
+		}
+		listeners = append(listeners, servingListener{
+			server:   newHTTPServer(net.JoinHostPort(configuration.BindAddress, configuration.HTTPListener.Port), fileServer.wrapWithLogging(httpHandler, loggingType), configuration),
+			port:     configuration.HTTPListener.Port,
+			redirect: configuration.HTTPListener.RedirectToHTTPS,
+		})
+	}
 
 	currentTime := time.Now().Format(defaultLogTimeLayout)
 	if loggingType == logging.TypeConsole {
-		startMessage := formatConsoleStartMessage(configuration, certificateConfigured, displayAddress)
+		startMessage := formatConsoleStartMessage(configuration, listeners, fileServer.servingAddressFormatter)
 		fileServer.loggingService.Info(startMessage)
 	} else {
-		fullURLScheme := "http"
-		activeMessage := logMessageServingHTTP
-		if certificateConfigured {
-			fullURLScheme = "https"
-			activeMessage = logMessageServingHTTPS
-		}
-		fullURL := fileServer.servingAddressFormatter.FormatURLForLogging(fullURLScheme, configuration.BindAddress, configuration.Port)
-		fileServer.loggingService.Info(
-			activeMessage,
-			logging.String(logFieldDirectory, configuration.DirectoryPath),
-			logging.String(logFieldProtocol, configuration.ProtocolVersion),
-			logging.String(logFieldURL, fullURL),
-			logging.String(logFieldTimestamp, currentTime),
-		)
-	}
-
-	serverErrors := make(chan error, 1)
-	go func() {
-		var serveErr error
-		if certificateConfigured {
-			serveErr = server.ListenAndServeTLS("", "")
-		} else {
-			serveErr = server.ListenAndServe()
+		for _, listener := range listeners {
+			fullURLScheme := "http"
+			activeMessage := logMessageServingHTTP
+			if listener.secure {
+				fullURLScheme = "https"
+				activeMessage = logMessageServingHTTPS
+			}
+			fullURL := fileServer.servingAddressFormatter.FormatURLForLogging(fullURLScheme, configuration.BindAddress, listener.port)
+			startFields := []logging.Field{
+				logging.String(logFieldDirectory, configuration.DirectoryPath),
+				logging.String(logFieldProtocol, configuration.ProtocolVersion),
+				logging.String(logFieldURL, fullURL),
+				logging.String(logFieldTimestamp, currentTime),
+			}
+			if listener.redirect {
+				startFields = append(startFields, logging.String(logFieldRedirect, fileServer.servingAddressFormatter.FormatURLForLogging("https", configuration.BindAddress, configuration.Port)))
+			}
+			fileServer.loggingService.Info(activeMessage, startFields...)
 		}
-		serverErrors <- serveErr
-	}()
+	}
+
+	serverErrors := make(chan listenerError, len(listeners))
+	for _, listener := range listeners {
+		go func() {
+			var serveErr error
+			if listener.secure {
+				serveErr = listener.server.ListenAndServeTLS("", "")
+			} else {
+				serveErr = listener.server.ListenAndServe()
+			}
+			serverErrors <- listenerError{port: listener.port, err: serveErr}
+		}()
+	}
 
 	select {
 	case <-ctx.Done():
 		fileServer.loggingService.Info(logMessageShutdownInitiated)
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
 		defer cancel()
-		shutdownErr := server.Shutdown(shutdownCtx)
+		shutdownErr := shutdownListeners(shutdownCtx, listeners)
 		if shutdownErr != nil {
 			fileServer.loggingService.Error(logMessageShutdownFailed, shutdownErr)
 			return fmt.Errorf("shutdown server: %w", shutdownErr)
 		}
 		fileServer.loggingService.Info(logMessageShutdownCompleted)
 		return nil
-	case serveErr := <-serverErrors:
+	case failure := <-serverErrors:
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+		defer cancel()
+		_ = shutdownListeners(shutdownCtx, listeners)
+		serveErr := failure.err
 		if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
 			if isAddressInUse(serveErr) {
-				friendlyMessage := formatAddressInUseMessage(configuration)
+				friendlyMessage := formatAddressInUseMessage(configuration.BindAddress, failure.port)
 				fileServer.loggingService.Error(friendlyMessage, serveErr)
 				return fmt.Errorf("address in use: %s", friendlyMessage)
 			}
@@ -177,6 +243,54 @@ func (fileServer FileServer) Serve(ctx context.Context, configuration FileServer
 	}
 }
 
+// servingListener is one of the listeners started by Serve.
+type servingListener struct {
+	server   *http.Server
+	port     string
+	secure   bool
+	redirect bool
+}
+
+type listenerError struct {
+	port string
+	err  error
+}
+
+func newHTTPServer(listeningAddress string, handler http.Handler, configuration FileServerConfiguration) *http.Server {
+	server := &http.Server{
+		Addr:              listeningAddress,
+		Handler:           handler,
+		ReadHeaderTimeout: configuration.ReadHeaderTimeout,
+		ReadTimeout:       configuration.ReadTimeout,
+		WriteTimeout:      configuration.WriteTimeout,
+		IdleTimeout:       configuration.IdleTimeout,
+		MaxHeaderBytes:    configuration.MaxHeaderBytes,
+	}
+
+	server.Protocols = new(http.Protocols)
+	server.Protocols.SetHTTP1(true)
+	if configuration.ProtocolVersion == httpProtocolVersionOneZero {
+		server.DisableGeneralOptionsHandler = true
+		server.SetKeepAlivesEnabled(false)
+		return server
+	}
+	// TLS listeners offer HTTP/2 through ALPN for HTTP/1.1 and HTTP/2 alike, unless the TLS protocol policy rules it
+	// out. Cleartext HTTP/2 is only accepted with h2c.
+	server.Protocols.SetHTTP2(configuration.TLS == nil || configuration.TLS.ProtocolPolicy.http2Incompatibility() == nil)
+	server.Protocols.SetUnencryptedHTTP2(configuration.EnableH2C)
+	return server
+}
+
+func shutdownListeners(ctx context.Context, listeners []servingListener) error {
+	var shutdownErrors []error
+	for _, listener := range listeners {
+		if shutdownErr := listener.server.Shutdown(ctx); shutdownErr != nil {
+			shutdownErrors = append(shutdownErrors, shutdownErr)
+		}
+	}
+	return errors.Join(shutdownErrors...)
+}
+
 func (fileServer FileServer) buildFileHandler(configuration FileServerConfiguration) http.Handler {
 	fileSystem := http.Dir(configuration.DirectoryPath)
 	baseHandler := http.FileServer(fileSystem)
@@ -193,7 +307,16 @@ func (fileServer FileServer) buildFileHandler(configuration FileServerConfigurat
 		handler = newInitialFileHandler(handler, configuration.InitialFileRelativePath)
 	}
 	if !configuration.ProxyRoutes.IsEmpty() {
-		handler = newProxyHandler(handler, configuration.ProxyRoutes, configuration.ProxyStreamingPolicies)
+		handler = newProxyHandler(handler, configuration, fileServer.loggingService)
+	}
+	if configuration.RESTStores != nil {
+		handler = newRESTHandler(handler, configuration.RESTStores, fileServer.loggingService)
+	}
+	if configuration.MockRoutes != nil {
+		handler = newMockHandler(handler, configuration.MockRoutes, fileServer.loggingService)
+	}
+	if configuration.FaultInjector != nil {
+		handler = newFaultHandler(handler, configuration.FaultInjector)
 	}
 	return handler
 }
@@ -216,14 +339,25 @@ func (fileServer FileServer) wrapWithLogging(handler http.Handler, loggingType s
 	case logging.TypeConsole:
 		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
 			recordedWriter := newStatusRecorder(responseWriter)
+			request, logDetails := withRequestLogDetails(request)
 			startTime := time.Now()
 			handler.ServeHTTP(recordedWriter, request)
 			message := formatConsoleRequestLog(request, recordedWriter.statusCode, recordedWriter.bytesWritten, startTime)
+			if logDetails.handlerName != "" {
+				message += " " + logDetails.handlerName
+			}
+			if logDetails.fault != "" {
+				message += " fault=" + logDetails.fault
+			}
+			if logDetails.clientCertificate.Present {
+				message += fmt.Sprintf(" client_cert=%q", logDetails.clientCertificate.Subject)
+			}
 			fileServer.loggingService.Info(message)
 		})
 	default:
 		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
 			recordedWriter := newStatusRecorder(responseWriter)
+			request, logDetails := withRequestLogDetails(request)
 			startTime := time.Now()
 			fileServer.loggingService.Info(
 				logMessageRequestStarted,
@@ -234,31 +368,58 @@ func (fileServer FileServer) wrapWithLogging(handler http.Handler, loggingType s
 			)
 			handler.ServeHTTP(recordedWriter, request)
 			duration := time.Since(startTime)
-			fileServer.loggingService.Info(
-				logMessageRequestCompleted,
+			completionFields := []logging.Field{
 				logging.String(logFieldMethod, request.Method),
 				logging.String(logFieldPath, request.URL.Path),
+				logging.String(logFieldProtocol, request.Proto),
 				logging.Int(logFieldStatus, recordedWriter.statusCode),
 				logging.Duration(logFieldDuration, duration),
 				logging.String(logFieldRemote, request.RemoteAddr),
-			)
+			}
+			if logDetails.handlerName != "" {
+				completionFields = append(completionFields, logging.String(logFieldHandler, logDetails.handlerName))
+			}
+			if logDetails.fault != "" {
+				completionFields = append(completionFields, logging.String(logFieldFault, logDetails.fault))
+			}
+			if request.TLS != nil {
+				completionFields = append(completionFields,
+					logging.String(logFieldTLSVersion, tls.VersionName(request.TLS.Version)),
+					logging.String(logFieldTLSCipherSuite, tls.CipherSuiteName(request.TLS.CipherSuite)),
+				)
+			}
+			if logDetails.clientCertificate.Present {
+				completionFields = append(completionFields,
+					logging.String(logFieldClientCertificate, logDetails.clientCertificate.Subject),
+					logging.Strings(logFieldClientCertificateNames, logDetails.clientCertificate.names()),
+				)
+			}
+			fileServer.loggingService.Info(logMessageRequestCompleted, completionFields...)
 		})
 	}
 }
 
-func formatConsoleStartMessage(configuration FileServerConfiguration, certificateConfigured bool, displayAddress string) string {
+func formatConsoleStartMessage(configuration FileServerConfiguration, listeners []servingListener, servingAddressFormatter serverdetails.ServingAddressFormatter) string {
 	bindAddress := configuration.BindAddress
 	if strings.TrimSpace(bindAddress) == "" {
 		bindAddress = "0.0.0.0"
 	}
-	port := configuration.Port
-	scheme := "http"
-	schemeLabel := "HTTP"
-	if certificateConfigured {
-		scheme = "https"
-		schemeLabel = "HTTPS"
+	descriptions := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		scheme := "http"
+		schemeLabel := "HTTP"
+		if listener.secure {
+			scheme = "https"
+			schemeLabel = "HTTPS"
+		}
+		displayAddress := servingAddressFormatter.FormatHostAndPortForLogging(configuration.BindAddress, listener.port)
+		description := fmt.Sprintf("%s on %s port %s (%s://%s/)", schemeLabel, bindAddress, listener.port, scheme, displayAddress)
+		if listener.redirect {
+			description += " redirecting to HTTPS"
+		}
+		descriptions = append(descriptions, description)
 	}
-	return fmt.Sprintf("Serving %s on %s port %s (%s://%s/) ...", schemeLabel, bindAddress, port, scheme, displayAddress)
+	return fmt.Sprintf("Serving %s ...", strings.Join(descriptions, " and "))
 }
 
 func formatConsoleRequestLog(request *http.Request, statusCode int, bytesWritten int, startTime time.Time) string {
@@ -279,23 +440,70 @@ func formatConsoleRequestLog(request *http.Request, statusCode int, bytesWritten
 	return fmt.Sprintf("%s - - [%s] \"%s\" %d %s", clientAddress, timestamp, requestLine, statusCode, sizeField)
 }
 
-func (fileServer FileServer) configureTLS(server *http.Server, configuration *TLSConfiguration) (bool, error) {
+// configureTLS installs the TLS configuration on server. Certificates loaded from files come with the reloader that
+// keeps them current.
+func (fileServer FileServer) configureTLS(server *http.Server, configuration *TLSConfiguration) (bool, *certificateFileReloader, error) {
 	if configuration == nil {
-		return false, nil
+		return false, nil, nil
+	}
+	defaultCertificate := configuration.DefaultCertificate
+	var certificateReloader *certificateFileReloader
+	if defaultCertificate == nil {
+		if configuration.CertificatePath == "" || configuration.PrivateKeyPath == "" {
+			return false, nil, errors.New("both certificate and private key paths must be provided")
+		}
+		reloader, reloaderErr := newCertificateFileReloader(configuration.CertificatePath, configuration.PrivateKeyPath, fileServer.loggingService)
+		if reloaderErr != nil {
+			return false, nil, reloaderErr
+		}
+		certificateReloader = reloader
+		defaultCertificate = reloader.currentCertificate
+	}
+	server.TLSConfig = buildTLSConfig(defaultCertificate, configuration.GetCertificate)
+	if !configuration.ProtocolPolicy.IsEmpty() {
+		configuration.ProtocolPolicy.configure(server.TLSConfig)
 	}
-	if configuration.LoadedCertificate != nil {
-		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*configuration.LoadedCertificate}}
-		return true, nil
+	if configuration.ClientAuth.IsEnabled() {
+		configuration.ClientAuth.configure(server.TLSConfig)
 	}
-	if configuration.CertificatePath == "" || configuration.PrivateKeyPath == "" {
-		return false, errors.New("both certificate and private key paths must be provided")
+	return true, certificateReloader, nil
+}
+
+// buildTLSConfig serves the default certificate unless getCertificate supplies one. Both are resolved inside the
+// GetCertificate callback on every handshake, so a replaced default certificate takes effect for the next connection.
+func buildTLSConfig(defaultCertificate func() *tls.Certificate, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if getCertificate != nil {
+				certificate, err := getCertificate(clientHello)
+				if err != nil || certificate != nil {
+					return certificate, err
+				}
+			}
+			return defaultCertificate(), nil
+		},
 	}
-	certificate, err := tls.LoadX509KeyPair(configuration.CertificatePath, configuration.PrivateKeyPath)
-	if err != nil {
-		return false, err
+}
+
+type requestLogDetailsContextKey struct{}
+
+// requestLogDetails lets inner handlers annotate the request log line written by the logging middleware.
+type requestLogDetails struct {
+	handlerName       string
+	fault             string
+	clientCertificate clientCertificateDetails
+}
+
+func withRequestLogDetails(request *http.Request) (*http.Request, *requestLogDetails) {
+	logDetails := &requestLogDetails{}
+	return request.WithContext(context.WithValue(request.Context(), requestLogDetailsContextKey{}, logDetails)), logDetails
+}
+
+// markRequestHandler records which handler answered the request so the request log can name it.
+func markRequestHandler(request *http.Request, handlerName string) {
+	if logDetails, exists := request.Context().Value(requestLogDetailsContextKey{}).(*requestLogDetails); exists {
+		logDetails.handlerName = handlerName
 	}
-	server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
-	return true, nil
 }
 
 type statusRecorder struct {
@@ -328,7 +536,21 @@ func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
 
 // Hijack implements http.Hijacker to support WebSocket connections through the logging middleware.
 func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return recorder.ResponseWriter.(http.Hijacker).Hijack()
+	return http.NewResponseController(recorder.ResponseWriter).Hijack()
+}
+
+// markRequestFault records the fault injected into the request so the request log can flag it.
+func markRequestFault(request *http.Request, faultDescription string) {
+	if logDetails, exists := request.Context().Value(requestLogDetailsContextKey{}).(*requestLogDetails); exists {
+		logDetails.fault = faultDescription
+	}
+}
+
+// markRequestClientCertificate records the presented client certificate so the request log can name it.
+func markRequestClientCertificate(request *http.Request, details clientCertificateDetails) {
+	if logDetails, exists := request.Context().Value(requestLogDetailsContextKey{}).(*requestLogDetails); exists {
+		logDetails.clientCertificate = details
+	}
 }
 
 func newStatusRecorder(responseWriter http.ResponseWriter) *statusRecorder {
@@ -336,12 +558,11 @@ func newStatusRecorder(responseWriter http.ResponseWriter) *statusRecorder {
 	return recorder
 }
 
-func formatAddressInUseMessage(configuration FileServerConfiguration) string {
-	bindAddress := configuration.BindAddress
+func formatAddressInUseMessage(bindAddress string, port string) string {
 	if strings.TrimSpace(bindAddress) == "" {
 		bindAddress = "0.0.0.0"
 	}
-	return fmt.Sprintf("Address already in use: %s:%s", bindAddress, configuration.Port)
+	return fmt.Sprintf("Address already in use: %s:%s", bindAddress, port)
 }
 
 func isAddressInUse(err error) bool {
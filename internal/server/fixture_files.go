@@ -0,0 +1,39 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	fixtureExtensionJSON = ".json"
+	fixtureExtensionYAML = ".yaml"
+	fixtureExtensionYML  = ".yml"
+)
+
+// decodeFixtureFile decodes a JSON or YAML fixture, chosen by file extension, rejecting unknown fields. Errors wrap
+// invalidFixtureErr.
+func decodeFixtureFile(fixturePath string, fixtureBytes []byte, document any, invalidFixtureErr error) error {
+	switch strings.ToLower(filepath.Ext(fixturePath)) {
+	case fixtureExtensionJSON:
+		decoder := json.NewDecoder(bytes.NewReader(fixtureBytes))
+		decoder.DisallowUnknownFields()
+		if decodeErr := decoder.Decode(document); decodeErr != nil {
+			return fmt.Errorf("%w: parse %s: %s", invalidFixtureErr, fixturePath, decodeErr.Error())
+		}
+	case fixtureExtensionYAML, fixtureExtensionYML:
+		decoder := yaml.NewDecoder(bytes.NewReader(fixtureBytes))
+		decoder.KnownFields(true)
+		if decodeErr := decoder.Decode(document); decodeErr != nil {
+			return fmt.Errorf("%w: parse %s: %s", invalidFixtureErr, fixturePath, decodeErr.Error())
+		}
+	default:
+		return fmt.Errorf("%w: %s must have a .json, .yaml or .yml extension", invalidFixtureErr, fixturePath)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultHTTPSPort            = "443"
+	requestHandlerHTTPSRedirect = "https-redirect"
+)
+
+// httpsRedirectHandler answers cleartext requests with a redirect to the same path and query on the HTTPS port. GET and
+// HEAD requests get 301; other methods get 308 so clients repeat the method and body. Excluded path prefixes reach the
+// shared handlers instead.
+type httpsRedirectHandler struct {
+	next          http.Handler
+	httpsPort     string
+	excludedPaths []string
+}
+
+func newHTTPSRedirectHandler(next http.Handler, httpsPort string, excludedPaths []string) http.Handler {
+	return &httpsRedirectHandler{next: next, httpsPort: httpsPort, excludedPaths: excludedPaths}
+}
+
+func (handler *httpsRedirectHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	for _, excludedPath := range handler.excludedPaths {
+		if strings.HasPrefix(request.URL.Path, excludedPath) {
+			handler.next.ServeHTTP(responseWriter, request)
+			return
+		}
+	}
+	host := request.Host
+	if hostname, _, splitErr := net.SplitHostPort(host); splitErr == nil {
+		host = hostname
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	if handler.httpsPort != defaultHTTPSPort {
+		host = net.JoinHostPort(host, handler.httpsPort)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	statusCode := http.StatusMovedPermanently
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		statusCode = http.StatusPermanentRedirect
+	}
+	markRequestHandler(request, requestHandlerHTTPSRedirect)
+	http.Redirect(responseWriter, request, "https://"+host+request.URL.RequestURI(), statusCode)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	requestHandlerMock           = "mock"
+	logMessageMockFixtureLoaded  = "mock fixture reloaded"
+	logMessageMockFixtureInvalid = "mock fixture reload failed"
+	logMessageMockResponseFailed = "mock response failed"
+	logFieldFixture              = "fixture"
+)
+
+type mockHandler struct {
+	next           http.Handler
+	mockRoutes     *MockRoutes
+	loggingService *logging.Service
+}
+
+func newMockHandler(next http.Handler, mockRoutes *MockRoutes, loggingService *logging.Service) http.Handler {
+	return &mockHandler{next: next, mockRoutes: mockRoutes, loggingService: loggingService}
+}
+
+func (handler *mockHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	reloaded, reloadErr := handler.mockRoutes.reloadIfChanged()
+	if reloadErr != nil {
+		handler.loggingService.Error(logMessageMockFixtureInvalid, reloadErr, logging.String(logFieldFixture, handler.mockRoutes.fixturePath))
+	} else if reloaded {
+		handler.loggingService.Info(logMessageMockFixtureLoaded, logging.String(logFieldFixture, handler.mockRoutes.fixturePath))
+	}
+
+	route, parameters, matched := handler.mockRoutes.match(request.Method, request.URL.Path)
+	if !matched {
+		handler.next.ServeHTTP(responseWriter, request)
+		return
+	}
+	markRequestHandler(request, requestHandlerMock)
+
+	templateData := mockTemplateData{Method: request.Method, Path: request.URL.Path, Params: parameters, Query: map[string]string{}}
+	for queryName, queryValues := range request.URL.Query() {
+		templateData.Query[queryName] = queryValues[0]
+	}
+	responseBody, renderErr := route.renderBody(templateData)
+	if renderErr != nil {
+		handler.loggingService.Error(logMessageMockResponseFailed, renderErr, logging.String(logFieldPath, request.URL.Path))
+		http.Error(responseWriter, "mock response failed", http.StatusInternalServerError)
+		return
+	}
+	for headerName, headerTemplate := range route.headerTemplates {
+		headerValue, headerErr := executeMockTemplate(headerTemplate, templateData)
+		if headerErr != nil {
+			handler.loggingService.Error(logMessageMockResponseFailed, headerErr, logging.String(logFieldPath, request.URL.Path))
+			http.Error(responseWriter, "mock response failed", http.StatusInternalServerError)
+			return
+		}
+		responseWriter.Header().Set(headerName, string(headerValue))
+	}
+	if route.bodyFilePath != "" && responseWriter.Header().Get(headerContentType) == "" {
+		if contentType := mime.TypeByExtension(filepath.Ext(route.bodyFilePath)); contentType != "" {
+			responseWriter.Header().Set(headerContentType, contentType)
+		}
+	}
+
+	if route.delay > 0 {
+		delayTimer := time.NewTimer(route.delay)
+		defer delayTimer.Stop()
+		select {
+		case <-delayTimer.C:
+		case <-request.Context().Done():
+			return
+		}
+	}
+	responseWriter.WriteHeader(route.status)
+	if request.Method != http.MethodHead {
+		_, _ = responseWriter.Write(responseBody)
+	}
+}
+
+func (route mockRoute) renderBody(templateData mockTemplateData) ([]byte, error) {
+	bodyTemplate := route.bodyTemplate
+	if route.bodyFilePath != "" {
+		bodyFileBytes, readErr := os.ReadFile(route.bodyFilePath)
+		if readErr != nil {
+			return nil, readErr
+		}
+		parsedTemplate, parseErr := template.New(filepath.Base(route.bodyFilePath)).Option(mockTemplateOption).Parse(string(bodyFileBytes))
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		bodyTemplate = parsedTemplate
+	}
+	return executeMockTemplate(bodyTemplate, templateData)
+}
+
+func executeMockTemplate(mockTemplate *template.Template, templateData mockTemplateData) ([]byte, error) {
+	var rendered bytes.Buffer
+	if executeErr := mockTemplate.Execute(&rendered, templateData); executeErr != nil {
+		return nil, executeErr
+	}
+	return rendered.Bytes(), nil
+}
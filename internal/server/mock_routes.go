@@ -0,0 +1,275 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	mockPathSeparator   = "/"
+	mockParameterPrefix = ":"
+	mockWildcardSegment = "*"
+	mockMethodAny       = "*"
+	mockTemplateOption  = "missingkey=zero"
+)
+
+var ErrInvalidMockFixture = errors.New("mock.fixture.invalid")
+
+// MockRoutes holds the mock API routes declared in a fixture file and reloads them when the file changes.
+type MockRoutes struct {
+	fixturePath   string
+	directoryPath string
+	mutex         sync.Mutex
+	routes        []mockRoute
+	fixtureState  mockFixtureState
+	failedState   mockFixtureState
+}
+
+type mockFixtureState struct {
+	modificationTime time.Time
+	size             int64
+}
+
+type mockFixtureDocument struct {
+	Mocks []mockDefinition `json:"mocks" yaml:"mocks"`
+}
+
+type mockDefinition struct {
+	Method   string            `json:"method" yaml:"method"`
+	Path     string            `json:"path" yaml:"path"`
+	Status   int               `json:"status" yaml:"status"`
+	Headers  map[string]string `json:"headers" yaml:"headers"`
+	Body     string            `json:"body" yaml:"body"`
+	BodyFile string            `json:"body_file" yaml:"body_file"`
+	Delay    string            `json:"delay" yaml:"delay"`
+}
+
+type mockRoute struct {
+	method          string
+	pathSegments    []string
+	status          int
+	headerTemplates map[string]*template.Template
+	bodyTemplate    *template.Template
+	bodyFilePath    string
+	delay           time.Duration
+}
+
+type mockTemplateData struct {
+	Method string
+	Path   string
+	Params map[string]string
+	Query  map[string]string
+}
+
+// LoadMockRoutes parses the fixture file. Body files are resolved relative to directoryPath.
+func LoadMockRoutes(fixturePath string, directoryPath string) (*MockRoutes, error) {
+	mockRoutes := &MockRoutes{fixturePath: fixturePath, directoryPath: directoryPath}
+	fixtureState, statErr := mockRoutes.statFixture()
+	if statErr != nil {
+		return nil, statErr
+	}
+	routes, parseErr := mockRoutes.parseFixture()
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	mockRoutes.routes = routes
+	mockRoutes.fixtureState = fixtureState
+	return mockRoutes, nil
+}
+
+// reloadIfChanged re-reads the fixture when its size or modification time changed. A fixture that fails to parse
+// is reported once and the previously loaded routes stay active.
+func (mockRoutes *MockRoutes) reloadIfChanged() (bool, error) {
+	mockRoutes.mutex.Lock()
+	defer mockRoutes.mutex.Unlock()
+	fixtureState, statErr := mockRoutes.statFixture()
+	if statErr != nil {
+		return mockRoutes.recordReloadFailure(fixtureState, statErr)
+	}
+	if fixtureState == mockRoutes.fixtureState || fixtureState == mockRoutes.failedState {
+		return false, nil
+	}
+	routes, parseErr := mockRoutes.parseFixture()
+	if parseErr != nil {
+		return mockRoutes.recordReloadFailure(fixtureState, parseErr)
+	}
+	mockRoutes.routes = routes
+	mockRoutes.fixtureState = fixtureState
+	mockRoutes.failedState = mockFixtureState{}
+	return true, nil
+}
+
+func (mockRoutes *MockRoutes) recordReloadFailure(fixtureState mockFixtureState, reloadErr error) (bool, error) {
+	if fixtureState == mockRoutes.failedState {
+		return false, nil
+	}
+	mockRoutes.failedState = fixtureState
+	return false, reloadErr
+}
+
+func (mockRoutes *MockRoutes) match(method string, requestPath string) (mockRoute, map[string]string, bool) {
+	mockRoutes.mutex.Lock()
+	routes := mockRoutes.routes
+	mockRoutes.mutex.Unlock()
+	requestSegments := strings.Split(strings.TrimPrefix(requestPath, mockPathSeparator), mockPathSeparator)
+	for _, route := range routes {
+		if route.method != mockMethodAny && route.method != method {
+			continue
+		}
+		if parameters, matched := route.matchPath(requestSegments); matched {
+			return route, parameters, true
+		}
+	}
+	return mockRoute{}, nil, false
+}
+
+func (route mockRoute) matchPath(requestSegments []string) (map[string]string, bool) {
+	parameters := map[string]string{}
+	for segmentIndex, patternSegment := range route.pathSegments {
+		if strings.HasPrefix(patternSegment, mockWildcardSegment) && len(patternSegment) > len(mockWildcardSegment) {
+			parameters[strings.TrimPrefix(patternSegment, mockWildcardSegment)] = strings.Join(requestSegments[min(segmentIndex, len(requestSegments)):], mockPathSeparator)
+			return parameters, true
+		}
+		if segmentIndex >= len(requestSegments) {
+			return nil, false
+		}
+		requestSegment := requestSegments[segmentIndex]
+		switch {
+		case patternSegment == mockWildcardSegment:
+			if requestSegment == "" {
+				return nil, false
+			}
+		case strings.HasPrefix(patternSegment, mockParameterPrefix):
+			if requestSegment == "" {
+				return nil, false
+			}
+			parameters[strings.TrimPrefix(patternSegment, mockParameterPrefix)] = requestSegment
+		case patternSegment != requestSegment:
+			return nil, false
+		}
+	}
+	if len(requestSegments) != len(route.pathSegments) {
+		return nil, false
+	}
+	return parameters, true
+}
+
+func (mockRoutes *MockRoutes) statFixture() (mockFixtureState, error) {
+	fixtureInfo, statErr := os.Stat(mockRoutes.fixturePath)
+	if statErr != nil {
+		return mockFixtureState{}, fmt.Errorf("%w: stat %s: %s", ErrInvalidMockFixture, mockRoutes.fixturePath, statErr.Error())
+	}
+	return mockFixtureState{modificationTime: fixtureInfo.ModTime(), size: fixtureInfo.Size()}, nil
+}
+
+func (mockRoutes *MockRoutes) parseFixture() ([]mockRoute, error) {
+	fixtureBytes, readErr := os.ReadFile(mockRoutes.fixturePath)
+	if readErr != nil {
+		return nil, fmt.Errorf("%w: read %s: %s", ErrInvalidMockFixture, mockRoutes.fixturePath, readErr.Error())
+	}
+	var document mockFixtureDocument
+	if decodeErr := decodeFixtureFile(mockRoutes.fixturePath, fixtureBytes, &document, ErrInvalidMockFixture); decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	routes := make([]mockRoute, 0, len(document.Mocks))
+	for definitionIndex, definition := range document.Mocks {
+		route, routeErr := mockRoutes.newMockRoute(definition)
+		if routeErr != nil {
+			return nil, fmt.Errorf("mock %d (%s %s): %w", definitionIndex+1, definition.Method, definition.Path, routeErr)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func (mockRoutes *MockRoutes) newMockRoute(definition mockDefinition) (mockRoute, error) {
+	method := strings.ToUpper(strings.TrimSpace(definition.Method))
+	if method == "" {
+		method = mockMethodAny
+	}
+	routePath := strings.TrimSpace(definition.Path)
+	if !strings.HasPrefix(routePath, mockPathSeparator) {
+		return mockRoute{}, fmt.Errorf("%w: path must start with /", ErrInvalidMockFixture)
+	}
+	pathSegments := strings.Split(strings.TrimPrefix(routePath, mockPathSeparator), mockPathSeparator)
+	for segmentIndex, pathSegment := range pathSegments {
+		isCatchAll := strings.HasPrefix(pathSegment, mockWildcardSegment) && pathSegment != mockWildcardSegment
+		if isCatchAll && segmentIndex != len(pathSegments)-1 {
+			return mockRoute{}, fmt.Errorf("%w: catch-all segment %s must be last", ErrInvalidMockFixture, pathSegment)
+		}
+		if pathSegment == mockParameterPrefix {
+			return mockRoute{}, fmt.Errorf("%w: path parameter must be named", ErrInvalidMockFixture)
+		}
+	}
+
+	status := definition.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < 100 || status > 599 {
+		return mockRoute{}, fmt.Errorf("%w: unsupported status %d", ErrInvalidMockFixture, status)
+	}
+
+	var delay time.Duration
+	if strings.TrimSpace(definition.Delay) != "" {
+		parsedDelay, delayErr := time.ParseDuration(strings.TrimSpace(definition.Delay))
+		if delayErr != nil || parsedDelay < 0 {
+			return mockRoute{}, fmt.Errorf("%w: invalid delay %s", ErrInvalidMockFixture, definition.Delay)
+		}
+		delay = parsedDelay
+	}
+
+	headerTemplates := make(map[string]*template.Template, len(definition.Headers))
+	for headerName, headerValue := range definition.Headers {
+		headerTemplate, templateErr := template.New(headerName).Option(mockTemplateOption).Parse(headerValue)
+		if templateErr != nil {
+			return mockRoute{}, fmt.Errorf("%w: header %s template: %s", ErrInvalidMockFixture, headerName, templateErr.Error())
+		}
+		headerTemplates[http.CanonicalHeaderKey(headerName)] = headerTemplate
+	}
+
+	route := mockRoute{method: method, pathSegments: pathSegments, status: status, headerTemplates: headerTemplates, delay: delay}
+	if definition.BodyFile != "" {
+		if definition.Body != "" {
+			return mockRoute{}, fmt.Errorf("%w: body and body_file are mutually exclusive", ErrInvalidMockFixture)
+		}
+		bodyFilePath, bodyFileErr := mockRoutes.resolveBodyFile(definition.BodyFile)
+		if bodyFileErr != nil {
+			return mockRoute{}, bodyFileErr
+		}
+		route.bodyFilePath = bodyFilePath
+		return route, nil
+	}
+	bodyTemplate, templateErr := template.New("body").Option(mockTemplateOption).Parse(definition.Body)
+	if templateErr != nil {
+		return mockRoute{}, fmt.Errorf("%w: body template: %s", ErrInvalidMockFixture, templateErr.Error())
+	}
+	route.bodyTemplate = bodyTemplate
+	return route, nil
+}
+
+// resolveBodyFile confines body files to the served directory.
+func (mockRoutes *MockRoutes) resolveBodyFile(bodyFile string) (string, error) {
+	cleanedBodyFile := path.Clean(filepath.ToSlash(bodyFile))
+	if path.IsAbs(cleanedBodyFile) || cleanedBodyFile == ".." || strings.HasPrefix(cleanedBodyFile, "../") {
+		return "", fmt.Errorf("%w: body_file %s must be relative to the served directory", ErrInvalidMockFixture, bodyFile)
+	}
+	bodyFilePath := filepath.Join(mockRoutes.directoryPath, filepath.FromSlash(cleanedBodyFile))
+	bodyFileInfo, statErr := os.Stat(bodyFilePath)
+	if statErr != nil {
+		return "", fmt.Errorf("%w: body_file %s: %s", ErrInvalidMockFixture, bodyFile, statErr.Error())
+	}
+	if bodyFileInfo.IsDir() {
+		return "", fmt.Errorf("%w: body_file %s is a directory", ErrInvalidMockFixture, bodyFile)
+	}
+	return bodyFilePath, nil
+}
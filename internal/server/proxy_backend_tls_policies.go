@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	proxyBackendTLSPolicyMappingSeparator   = "="
+	proxyBackendTLSPolicyOptionSeparator    = ":"
+	proxyBackendTLSOptionCertificateBundle  = "ca"
+	proxyBackendTLSOptionInsecureSkipVerify = "insecure_skip_verify"
+	proxyBackendTLSOptionServerName         = "server_name"
+	proxyBackendTLSOptionClientCertificate  = "client_certificate"
+	proxyBackendTLSOptionClientPrivateKey   = "client_key"
+)
+
+var ErrInvalidProxyBackendTLSPolicy = errors.New("proxy.backend.tls.policy.invalid")
+
+// ProxyBackendTLSPolicies resolves the TLS client configuration used when proxying to https backends.
+type ProxyBackendTLSPolicies struct {
+	policies      []proxyBackendTLSPolicy
+	defaultConfig *tls.Config
+}
+
+type proxyBackendTLSPolicy struct {
+	pathPrefix string
+	config     *tls.Config
+}
+
+// NewProxyBackendTLSPolicies parses /path=option:value mappings. The default certificate authority bundle, when
+// provided, is trusted in addition to the system roots for every route.
+func NewProxyBackendTLSPolicies(mappings []string, defaultCertificateAuthorityPEM []byte) (ProxyBackendTLSPolicies, error) {
+	defaultRootCertificates, rootErr := buildProxyBackendRootCertificates(defaultCertificateAuthorityPEM)
+	if rootErr != nil {
+		return ProxyBackendTLSPolicies{}, rootErr
+	}
+	var defaultConfig *tls.Config
+	if defaultRootCertificates != nil {
+		defaultConfig = &tls.Config{RootCAs: defaultRootCertificates}
+	}
+	if len(mappings) == 0 {
+		return ProxyBackendTLSPolicies{defaultConfig: defaultConfig}, nil
+	}
+
+	optionsByPathPrefix := map[string]map[string]string{}
+	for _, mapping := range mappings {
+		pathPrefix, optionName, optionValue, parseErr := parseProxyBackendTLSPolicyMapping(mapping)
+		if parseErr != nil {
+			return ProxyBackendTLSPolicies{}, parseErr
+		}
+		existingOptions, exists := optionsByPathPrefix[pathPrefix]
+		if !exists {
+			existingOptions = map[string]string{}
+			optionsByPathPrefix[pathPrefix] = existingOptions
+		}
+		existingOptions[optionName] = optionValue
+	}
+
+	policies := make([]proxyBackendTLSPolicy, 0, len(optionsByPathPrefix))
+	for pathPrefix, options := range optionsByPathPrefix {
+		config, configErr := buildProxyBackendTLSConfig(pathPrefix, options, defaultCertificateAuthorityPEM)
+		if configErr != nil {
+			return ProxyBackendTLSPolicies{}, configErr
+		}
+		policies = append(policies, proxyBackendTLSPolicy{pathPrefix: pathPrefix, config: config})
+	}
+	sort.SliceStable(policies, func(leftIndex int, rightIndex int) bool {
+		return len(policies[leftIndex].pathPrefix) > len(policies[rightIndex].pathPrefix)
+	})
+	return ProxyBackendTLSPolicies{policies: policies, defaultConfig: defaultConfig}, nil
+}
+
+// ConfigForRoute returns a copy of the TLS client configuration for the proxy route prefix, or nil when the
+// system defaults apply.
+func (policies ProxyBackendTLSPolicies) ConfigForRoute(routePathPrefix string) *tls.Config {
+	for _, policy := range policies.policies {
+		if strings.HasPrefix(routePathPrefix, policy.pathPrefix) {
+			return policy.config.Clone()
+		}
+	}
+	if policies.defaultConfig == nil {
+		return nil
+	}
+	return policies.defaultConfig.Clone()
+}
+
+// ValidateRoutes rejects policies that no proxy route would pick up in ConfigForRoute, such as a prefix longer than
+// the route it was meant for or a misspelled one, instead of letting those routes fall back to the default.
+func (policies ProxyBackendTLSPolicies) ValidateRoutes(routes ProxyRoutes) error {
+	for _, policy := range policies.policies {
+		selectsRoute := false
+		for _, route := range routes.routes {
+			if strings.HasPrefix(route.pathPrefix, policy.pathPrefix) {
+				selectsRoute = true
+				break
+			}
+		}
+		if !selectsRoute {
+			return fmt.Errorf("%w: path prefix %s does not select any proxy route", ErrInvalidProxyBackendTLSPolicy, policy.pathPrefix)
+		}
+	}
+	return nil
+}
+
+func parseProxyBackendTLSPolicyMapping(mapping string) (string, string, string, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	if trimmedMapping == "" {
+		return "", "", "", fmt.Errorf("%w: empty mapping", ErrInvalidProxyBackendTLSPolicy)
+	}
+	parts := strings.SplitN(trimmedMapping, proxyBackendTLSPolicyMappingSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("%w: mapping must be in /path=option:value form", ErrInvalidProxyBackendTLSPolicy)
+	}
+	pathPrefix := strings.TrimSpace(parts[0])
+	if pathPrefix == "" {
+		return "", "", "", fmt.Errorf("%w: empty path prefix", ErrInvalidProxyBackendTLSPolicy)
+	}
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return "", "", "", fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyBackendTLSPolicy)
+	}
+	optionParts := strings.SplitN(strings.TrimSpace(parts[1]), proxyBackendTLSPolicyOptionSeparator, 2)
+	if len(optionParts) != 2 {
+		return "", "", "", fmt.Errorf("%w: option must be in option:value form", ErrInvalidProxyBackendTLSPolicy)
+	}
+	optionName := strings.ToLower(strings.TrimSpace(optionParts[0]))
+	optionValue := strings.TrimSpace(optionParts[1])
+	switch optionName {
+	case proxyBackendTLSOptionCertificateBundle, proxyBackendTLSOptionInsecureSkipVerify, proxyBackendTLSOptionServerName, proxyBackendTLSOptionClientCertificate, proxyBackendTLSOptionClientPrivateKey:
+	default:
+		return "", "", "", fmt.Errorf("%w: unsupported option %s", ErrInvalidProxyBackendTLSPolicy, optionName)
+	}
+	if optionValue == "" {
+		return "", "", "", fmt.Errorf("%w: empty value for option %s", ErrInvalidProxyBackendTLSPolicy, optionName)
+	}
+	return pathPrefix, optionName, optionValue, nil
+}
+
+func buildProxyBackendTLSConfig(pathPrefix string, options map[string]string, defaultCertificateAuthorityPEM []byte) (*tls.Config, error) {
+	rootCertificates, rootErr := buildProxyBackendRootCertificates(defaultCertificateAuthorityPEM)
+	if rootErr != nil {
+		return nil, rootErr
+	}
+	if bundlePath, exists := options[proxyBackendTLSOptionCertificateBundle]; exists {
+		bundleBytes, readErr := os.ReadFile(bundlePath)
+		if readErr != nil {
+			return nil, fmt.Errorf("%w: read ca bundle for %s: %s", ErrInvalidProxyBackendTLSPolicy, pathPrefix, readErr.Error())
+		}
+		if rootCertificates == nil {
+			rootCertificates, rootErr = x509.SystemCertPool()
+			if rootErr != nil {
+				return nil, fmt.Errorf("%w: load system roots: %s", ErrInvalidProxyBackendTLSPolicy, rootErr.Error())
+			}
+		}
+		if !rootCertificates.AppendCertsFromPEM(bundleBytes) {
+			return nil, fmt.Errorf("%w: no certificates found in ca bundle %s for %s", ErrInvalidProxyBackendTLSPolicy, bundlePath, pathPrefix)
+		}
+	}
+	config := &tls.Config{RootCAs: rootCertificates}
+
+	if insecureValue, exists := options[proxyBackendTLSOptionInsecureSkipVerify]; exists {
+		insecureSkipVerify, parseErr := strconv.ParseBool(insecureValue)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%w: invalid %s value %s for %s", ErrInvalidProxyBackendTLSPolicy, proxyBackendTLSOptionInsecureSkipVerify, insecureValue, pathPrefix)
+		}
+		config.InsecureSkipVerify = insecureSkipVerify
+	}
+	if serverName, exists := options[proxyBackendTLSOptionServerName]; exists {
+		config.ServerName = serverName
+	}
+
+	clientCertificatePath, certificateConfigured := options[proxyBackendTLSOptionClientCertificate]
+	clientPrivateKeyPath, privateKeyConfigured := options[proxyBackendTLSOptionClientPrivateKey]
+	if certificateConfigured != privateKeyConfigured {
+		return nil, fmt.Errorf("%w: %s and %s must be provided together for %s", ErrInvalidProxyBackendTLSPolicy, proxyBackendTLSOptionClientCertificate, proxyBackendTLSOptionClientPrivateKey, pathPrefix)
+	}
+	if certificateConfigured {
+		clientCertificate, loadErr := tls.LoadX509KeyPair(clientCertificatePath, clientPrivateKeyPath)
+		if loadErr != nil {
+			return nil, fmt.Errorf("%w: load client certificate for %s: %s", ErrInvalidProxyBackendTLSPolicy, pathPrefix, loadErr.Error())
+		}
+		config.Certificates = []tls.Certificate{clientCertificate}
+	}
+	return config, nil
+}
+
+func buildProxyBackendRootCertificates(certificateAuthorityPEM []byte) (*x509.CertPool, error) {
+	if len(certificateAuthorityPEM) == 0 {
+		return nil, nil
+	}
+	rootCertificates, systemErr := x509.SystemCertPool()
+	if systemErr != nil {
+		return nil, fmt.Errorf("%w: load system roots: %s", ErrInvalidProxyBackendTLSPolicy, systemErr.Error())
+	}
+	if !rootCertificates.AppendCertsFromPEM(certificateAuthorityPEM) {
+		return nil, fmt.Errorf("%w: no certificates found in default ca bundle", ErrInvalidProxyBackendTLSPolicy)
+	}
+	return rootCertificates, nil
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	proxyCacheModeMemory          = "memory"
+	proxyCacheModeDisk            = "disk"
+	proxyCacheTTLMappingSeparator = "="
+	proxyCacheMaxEntries          = 4096
+	proxyCacheMaxBodyBytes        = 8 << 20
+	proxyCacheFileExtension       = ".json"
+	proxyCacheAdminPath           = "/__ghttp/cache"
+	proxyCacheAdminPrefixQuery    = "prefix"
+	requestHandlerCacheAdmin      = "cache-admin"
+)
+
+var ErrInvalidProxyCache = errors.New("proxy.cache.invalid")
+
+// ProxyCache stores proxied GET responses in memory, optionally persisting them to a directory so they survive
+// restarts.
+type ProxyCache struct {
+	mode          string
+	directoryPath string
+	routeTTLs     []proxyCacheRouteTTL
+	mutex         sync.Mutex
+	entriesByKey  map[string][]*proxyCacheEntry
+	revalidating  map[string]struct{}
+}
+
+type proxyCacheRouteTTL struct {
+	pathPrefix string
+	ttl        time.Duration
+}
+
+// proxyCacheEntry is one stored response variant. It is also the on-disk JSON format.
+type proxyCacheEntry struct {
+	Key                  string            `json:"key"`
+	Vary                 map[string]string `json:"vary,omitempty"`
+	Status               int               `json:"status"`
+	Header               http.Header       `json:"header"`
+	Body                 []byte            `json:"body"`
+	StoredAt             time.Time         `json:"stored_at"`
+	InitialAge           time.Duration     `json:"initial_age"`
+	FreshFor             time.Duration     `json:"fresh_for"`
+	StaleWhileRevalidate time.Duration     `json:"stale_while_revalidate"`
+	StaleIfError         time.Duration     `json:"stale_if_error"`
+}
+
+type proxyCacheAdminState struct {
+	Mode    string `json:"mode"`
+	Entries int    `json:"entries"`
+	Purged  int    `json:"purged,omitempty"`
+}
+
+// NewProxyCache builds a cache in memory or disk mode. ttlMappings (/path=duration) give a freshness lifetime to
+// responses whose backend sent no caching headers.
+func NewProxyCache(mode string, directoryPath string, ttlMappings []string) (*ProxyCache, error) {
+	normalizedMode := strings.ToLower(strings.TrimSpace(mode))
+	if normalizedMode != proxyCacheModeMemory && normalizedMode != proxyCacheModeDisk {
+		return nil, fmt.Errorf("%w: unsupported mode %s", ErrInvalidProxyCache, mode)
+	}
+	proxyCache := &ProxyCache{mode: normalizedMode, entriesByKey: map[string][]*proxyCacheEntry{}, revalidating: map[string]struct{}{}}
+	ttlByPathPrefix := map[string]time.Duration{}
+	for _, mapping := range ttlMappings {
+		routeTTL, parseErr := parseProxyCacheRouteTTL(mapping)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		ttlByPathPrefix[routeTTL.pathPrefix] = routeTTL.ttl
+	}
+	for pathPrefix, ttl := range ttlByPathPrefix {
+		proxyCache.routeTTLs = append(proxyCache.routeTTLs, proxyCacheRouteTTL{pathPrefix: pathPrefix, ttl: ttl})
+	}
+	sort.SliceStable(proxyCache.routeTTLs, func(leftIndex int, rightIndex int) bool {
+		return len(proxyCache.routeTTLs[leftIndex].pathPrefix) > len(proxyCache.routeTTLs[rightIndex].pathPrefix)
+	})
+
+	if normalizedMode == proxyCacheModeMemory {
+		if strings.TrimSpace(directoryPath) != "" {
+			return nil, fmt.Errorf("%w: a cache directory requires disk mode", ErrInvalidProxyCache)
+		}
+		return proxyCache, nil
+	}
+	if strings.TrimSpace(directoryPath) == "" {
+		return nil, fmt.Errorf("%w: disk mode requires a cache directory", ErrInvalidProxyCache)
+	}
+	proxyCache.directoryPath = directoryPath
+	if loadErr := proxyCache.loadFromDisk(); loadErr != nil {
+		return nil, loadErr
+	}
+	return proxyCache, nil
+}
+
+func parseProxyCacheRouteTTL(mapping string) (proxyCacheRouteTTL, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	parts := strings.SplitN(trimmedMapping, proxyCacheTTLMappingSeparator, 2)
+	if len(parts) != 2 {
+		return proxyCacheRouteTTL{}, fmt.Errorf("%w: ttl mapping must be in /path=duration form", ErrInvalidProxyCache)
+	}
+	pathPrefix := strings.TrimSpace(parts[0])
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return proxyCacheRouteTTL{}, fmt.Errorf("%w: ttl path prefix must start with /", ErrInvalidProxyCache)
+	}
+	ttl, parseErr := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if parseErr != nil || ttl <= 0 {
+		return proxyCacheRouteTTL{}, fmt.Errorf("%w: invalid ttl %s", ErrInvalidProxyCache, parts[1])
+	}
+	return proxyCacheRouteTTL{pathPrefix: pathPrefix, ttl: ttl}, nil
+}
+
+func (proxyCache *ProxyCache) defaultTTL(requestPath string) time.Duration {
+	for _, routeTTL := range proxyCache.routeTTLs {
+		if strings.HasPrefix(requestPath, routeTTL.pathPrefix) {
+			return routeTTL.ttl
+		}
+	}
+	return 0
+}
+
+func (entry *proxyCacheEntry) age(now time.Time) time.Duration {
+	return entry.InitialAge + max(now.Sub(entry.StoredAt), 0)
+}
+
+func (entry *proxyCacheEntry) matchesVary(request *http.Request) bool {
+	for headerName, storedValue := range entry.Vary {
+		if strings.Join(request.Header.Values(headerName), ",") != storedValue {
+			return false
+		}
+	}
+	return true
+}
+
+func (proxyCache *ProxyCache) lookup(key string, request *http.Request) *proxyCacheEntry {
+	proxyCache.mutex.Lock()
+	defer proxyCache.mutex.Unlock()
+	for _, entry := range proxyCache.entriesByKey[key] {
+		if entry.matchesVary(request) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// store saves a response variant, replacing the variant with the same Vary values and evicting the oldest entry
+// when the cache is full.
+func (proxyCache *ProxyCache) store(key string, request *http.Request, statusCode int, responseHeaders http.Header, body []byte, freshness proxyCacheFreshness, now time.Time) error {
+	entry := &proxyCacheEntry{
+		Key:                  key,
+		Status:               statusCode,
+		Header:               responseHeaders,
+		Body:                 body,
+		StoredAt:             now,
+		InitialAge:           freshness.initialAge,
+		FreshFor:             freshness.freshFor,
+		StaleWhileRevalidate: freshness.staleWhileRevalidate,
+		StaleIfError:         freshness.staleIfError,
+	}
+	for _, varyValue := range responseHeaders.Values(headerVary) {
+		for _, headerName := range strings.Split(varyValue, ",") {
+			if trimmedHeaderName := http.CanonicalHeaderKey(strings.TrimSpace(headerName)); trimmedHeaderName != "" {
+				if entry.Vary == nil {
+					entry.Vary = map[string]string{}
+				}
+				entry.Vary[trimmedHeaderName] = strings.Join(request.Header.Values(trimmedHeaderName), ",")
+			}
+		}
+	}
+
+	proxyCache.mutex.Lock()
+	defer proxyCache.mutex.Unlock()
+	variants := proxyCache.entriesByKey[key]
+	replaced := false
+	for variantIndex, variant := range variants {
+		if variant.matchesVary(request) {
+			variants[variantIndex] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		if proxyCache.entryCountLocked() >= proxyCacheMaxEntries {
+			if evictErr := proxyCache.evictOldestLocked(); evictErr != nil {
+				return evictErr
+			}
+			variants = proxyCache.entriesByKey[key]
+		}
+		variants = append(variants, entry)
+	}
+	proxyCache.entriesByKey[key] = variants
+	return proxyCache.persistLocked(key)
+}
+
+// refresh applies the headers of a 304 revalidation response to a stored entry and restarts its freshness lifetime.
+// The refreshed entry is returned even when the new headers no longer allow storing it, so it can still answer the
+// request that triggered the revalidation.
+func (proxyCache *ProxyCache) refresh(entry *proxyCacheEntry, request *http.Request, notModifiedHeaders http.Header, defaultTTL time.Duration, now time.Time) (*proxyCacheEntry, error) {
+	refreshedHeaders := entry.Header.Clone()
+	for headerName, headerValues := range notModifiedHeaders {
+		if headerName == headerContentLength || headerName == headerXCache {
+			continue
+		}
+		refreshedHeaders[headerName] = append([]string(nil), headerValues...)
+	}
+	refreshedEntry := *entry
+	refreshedEntry.Header = refreshedHeaders
+	refreshedEntry.StoredAt = now
+	refreshedEntry.InitialAge = 0
+	freshness, storable := responseFreshness(request, entry.Status, refreshedHeaders, defaultTTL, now)
+	if !storable {
+		return &refreshedEntry, proxyCache.purgeKey(entry.Key)
+	}
+	return &refreshedEntry, proxyCache.store(entry.Key, request, entry.Status, refreshedHeaders, entry.Body, freshness, now)
+}
+
+func (proxyCache *ProxyCache) entryCountLocked() int {
+	entryCount := 0
+	for _, variants := range proxyCache.entriesByKey {
+		entryCount += len(variants)
+	}
+	return entryCount
+}
+
+func (proxyCache *ProxyCache) evictOldestLocked() error {
+	oldestKey := ""
+	oldestIndex := -1
+	var oldestStoredAt time.Time
+	for key, variants := range proxyCache.entriesByKey {
+		for variantIndex, variant := range variants {
+			if oldestIndex < 0 || variant.StoredAt.Before(oldestStoredAt) {
+				oldestKey, oldestIndex, oldestStoredAt = key, variantIndex, variant.StoredAt
+			}
+		}
+	}
+	if oldestIndex < 0 {
+		return nil
+	}
+	variants := proxyCache.entriesByKey[oldestKey]
+	proxyCache.entriesByKey[oldestKey] = append(variants[:oldestIndex:oldestIndex], variants[oldestIndex+1:]...)
+	return proxyCache.persistLocked(oldestKey)
+}
+
+func (proxyCache *ProxyCache) purgeKey(key string) error {
+	proxyCache.mutex.Lock()
+	defer proxyCache.mutex.Unlock()
+	delete(proxyCache.entriesByKey, key)
+	return proxyCache.persistLocked(key)
+}
+
+// purge removes every entry whose key starts with pathPrefix and reports how many were removed.
+func (proxyCache *ProxyCache) purge(pathPrefix string) (int, error) {
+	proxyCache.mutex.Lock()
+	defer proxyCache.mutex.Unlock()
+	purgedCount := 0
+	for key, variants := range proxyCache.entriesByKey {
+		if !strings.HasPrefix(key, pathPrefix) {
+			continue
+		}
+		purgedCount += len(variants)
+		delete(proxyCache.entriesByKey, key)
+		if persistErr := proxyCache.persistLocked(key); persistErr != nil {
+			return purgedCount, persistErr
+		}
+	}
+	return purgedCount, nil
+}
+
+// persistLocked mirrors the variants of one key to disk: one file per key, removed when no variant is left.
+func (proxyCache *ProxyCache) persistLocked(key string) error {
+	if proxyCache.directoryPath == "" {
+		return nil
+	}
+	keyHash := sha256.Sum256([]byte(key))
+	filePath := filepath.Join(proxyCache.directoryPath, hex.EncodeToString(keyHash[:])+proxyCacheFileExtension)
+	variants := proxyCache.entriesByKey[key]
+	if len(variants) == 0 {
+		delete(proxyCache.entriesByKey, key)
+		if removeErr := os.Remove(filePath); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			return fmt.Errorf("remove cache file: %w", removeErr)
+		}
+		return nil
+	}
+	encodedVariants, encodeErr := json.Marshal(variants)
+	if encodeErr != nil {
+		return fmt.Errorf("encode cache entry: %w", encodeErr)
+	}
+	temporaryFile, createErr := os.CreateTemp(proxyCache.directoryPath, ".entry-*")
+	if createErr != nil {
+		return fmt.Errorf("write cache entry: %w", createErr)
+	}
+	_, writeErr := temporaryFile.Write(encodedVariants)
+	closeErr := temporaryFile.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(temporaryFile.Name())
+		return fmt.Errorf("write cache entry: %w", errors.Join(writeErr, closeErr))
+	}
+	if renameErr := os.Rename(temporaryFile.Name(), filePath); renameErr != nil {
+		_ = os.Remove(temporaryFile.Name())
+		return fmt.Errorf("write cache entry: %w", renameErr)
+	}
+	return nil
+}
+
+// loadFromDisk restores persisted entries. Files that cannot be decoded are discarded, since a cache can always be
+// refilled from the backend.
+func (proxyCache *ProxyCache) loadFromDisk() error {
+	if mkdirErr := os.MkdirAll(proxyCache.directoryPath, 0o755); mkdirErr != nil {
+		return fmt.Errorf("%w: create cache directory %s: %s", ErrInvalidProxyCache, proxyCache.directoryPath, mkdirErr.Error())
+	}
+	directoryEntries, readDirErr := os.ReadDir(proxyCache.directoryPath)
+	if readDirErr != nil {
+		return fmt.Errorf("%w: read cache directory %s: %s", ErrInvalidProxyCache, proxyCache.directoryPath, readDirErr.Error())
+	}
+	for _, directoryEntry := range directoryEntries {
+		if directoryEntry.IsDir() || filepath.Ext(directoryEntry.Name()) != proxyCacheFileExtension {
+			continue
+		}
+		filePath := filepath.Join(proxyCache.directoryPath, directoryEntry.Name())
+		encodedVariants, readErr := os.ReadFile(filePath)
+		var variants []*proxyCacheEntry
+		if readErr != nil || json.Unmarshal(encodedVariants, &variants) != nil || len(variants) == 0 {
+			_ = os.Remove(filePath)
+			continue
+		}
+		proxyCache.entriesByKey[variants[0].Key] = variants
+	}
+	return nil
+}
+
+// serveAdmin reports cache size on GET and purges entries on DELETE, optionally limited to ?prefix=/path.
+func (proxyCache *ProxyCache) serveAdmin(responseWriter http.ResponseWriter, request *http.Request) {
+	adminState := proxyCacheAdminState{Mode: proxyCache.mode}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodDelete, http.MethodPost:
+		purgedCount, purgeErr := proxyCache.purge(request.URL.Query().Get(proxyCacheAdminPrefixQuery))
+		if purgeErr != nil {
+			http.Error(responseWriter, "cache purge failed: "+purgeErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		adminState.Purged = purgedCount
+	default:
+		responseWriter.Header().Set("Allow", "GET, HEAD, DELETE, POST")
+		http.Error(responseWriter, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	proxyCache.mutex.Lock()
+	adminState.Entries = proxyCache.entryCountLocked()
+	proxyCache.mutex.Unlock()
+	responseWriter.Header().Set(headerContentType, "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+	if request.Method != http.MethodHead {
+		_ = json.NewEncoder(responseWriter).Encode(adminState)
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerCacheControl    = "Cache-Control"
+	headerExpires         = "Expires"
+	headerDate            = "Date"
+	headerAge             = "Age"
+	headerVary            = "Vary"
+	headerETag            = "ETag"
+	headerLastModified    = "Last-Modified"
+	headerAuthorization   = "Authorization"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+
+	cacheDirectiveNoStore              = "no-store"
+	cacheDirectiveNoCache              = "no-cache"
+	cacheDirectivePrivate              = "private"
+	cacheDirectivePublic               = "public"
+	cacheDirectiveMaxAge               = "max-age"
+	cacheDirectiveSharedMaxAge         = "s-maxage"
+	cacheDirectiveStaleWhileRevalidate = "stale-while-revalidate"
+	cacheDirectiveStaleIfError         = "stale-if-error"
+	varyAny                            = "*"
+)
+
+// cacheableStatusCodes are the statuses a shared cache may store without explicit freshness rules (RFC 9110 15.1).
+var cacheableStatusCodes = map[int]struct{}{
+	http.StatusOK:                   {},
+	http.StatusNonAuthoritativeInfo: {},
+	http.StatusNoContent:            {},
+	http.StatusMultipleChoices:      {},
+	http.StatusMovedPermanently:     {},
+	http.StatusPermanentRedirect:    {},
+	http.StatusNotFound:             {},
+	http.StatusMethodNotAllowed:     {},
+	http.StatusGone:                 {},
+	http.StatusRequestURITooLong:    {},
+	http.StatusNotImplemented:       {},
+}
+
+type cacheControlDirectives map[string]string
+
+func parseCacheControl(headerValues []string) cacheControlDirectives {
+	directives := cacheControlDirectives{}
+	for _, headerValue := range headerValues {
+		for _, directive := range strings.Split(headerValue, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
+
+func (directives cacheControlDirectives) has(name string) bool {
+	_, exists := directives[name]
+	return exists
+}
+
+// seconds returns a non-negative delta-seconds directive value.
+func (directives cacheControlDirectives) seconds(name string) (time.Duration, bool) {
+	rawValue, exists := directives[name]
+	if !exists {
+		return 0, false
+	}
+	parsedSeconds, parseErr := strconv.ParseInt(rawValue, 10, 64)
+	if parseErr != nil || parsedSeconds < 0 {
+		return 0, false
+	}
+	return time.Duration(parsedSeconds) * time.Second, true
+}
+
+// proxyCacheFreshness is what the cache needs to know to store and later serve a response.
+type proxyCacheFreshness struct {
+	freshFor             time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	initialAge           time.Duration
+}
+
+// responseFreshness decides whether a response may be stored and for how long. defaultTTL applies only when the
+// backend sent no freshness information at all.
+func responseFreshness(request *http.Request, statusCode int, responseHeaders http.Header, defaultTTL time.Duration, now time.Time) (proxyCacheFreshness, bool) {
+	if _, cacheable := cacheableStatusCodes[statusCode]; !cacheable {
+		return proxyCacheFreshness{}, false
+	}
+	if responseHeaders.Get(headerVary) == varyAny || len(responseHeaders.Values(headerSetCookie)) > 0 {
+		return proxyCacheFreshness{}, false
+	}
+	if mediaType, _, _ := mime.ParseMediaType(responseHeaders.Get(headerContentType)); strings.EqualFold(mediaType, mediaTypeEventStream) {
+		return proxyCacheFreshness{}, false
+	}
+	directives := parseCacheControl(responseHeaders.Values(headerCacheControl))
+	if directives.has(cacheDirectiveNoStore) || directives.has(cacheDirectivePrivate) || directives.has(cacheDirectiveNoCache) {
+		return proxyCacheFreshness{}, false
+	}
+	sharedMaxAge, hasSharedMaxAge := directives.seconds(cacheDirectiveSharedMaxAge)
+	if request.Header.Get(headerAuthorization) != "" && !directives.has(cacheDirectivePublic) && !hasSharedMaxAge {
+		return proxyCacheFreshness{}, false
+	}
+
+	freshness := proxyCacheFreshness{}
+	freshness.staleWhileRevalidate, _ = directives.seconds(cacheDirectiveStaleWhileRevalidate)
+	freshness.staleIfError, _ = directives.seconds(cacheDirectiveStaleIfError)
+	if ageSeconds, parseErr := strconv.ParseInt(strings.TrimSpace(responseHeaders.Get(headerAge)), 10, 64); parseErr == nil && ageSeconds > 0 {
+		freshness.initialAge = time.Duration(ageSeconds) * time.Second
+	}
+	maxAge, hasMaxAge := directives.seconds(cacheDirectiveMaxAge)
+	expiresValue := responseHeaders.Get(headerExpires)
+	switch {
+	case hasSharedMaxAge:
+		freshness.freshFor = sharedMaxAge
+	case hasMaxAge:
+		freshness.freshFor = maxAge
+	case expiresValue != "":
+		expiresAt, expiresErr := http.ParseTime(expiresValue)
+		if expiresErr == nil {
+			responseDate := now
+			if parsedDate, dateErr := http.ParseTime(responseHeaders.Get(headerDate)); dateErr == nil {
+				responseDate = parsedDate
+			}
+			freshness.freshFor = max(expiresAt.Sub(responseDate), 0)
+		}
+	default:
+		freshness.freshFor = defaultTTL
+	}
+	if freshness.freshFor <= 0 && freshness.staleWhileRevalidate <= 0 && freshness.staleIfError <= 0 {
+		return proxyCacheFreshness{}, false
+	}
+	return freshness, true
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	headerXCache    = "X-Cache"
+	proxyCacheHit   = "HIT"
+	proxyCacheMiss  = "MISS"
+	proxyCacheStale = "STALE"
+
+	logMessageProxyCacheStoreFailed = "proxy cache store failed"
+)
+
+// serve answers GET and HEAD requests from the cache when it can and otherwise forwards them to next, storing
+// cacheable responses on the way back. Stale entries are revalidated in the background within stale-while-revalidate
+// and served instead of backend errors within stale-if-error.
+func (proxyCache *ProxyCache) serve(responseWriter http.ResponseWriter, request *http.Request, next http.Handler, loggingService *logging.Service) {
+	if proxyCache == nil || (request.Method != http.MethodGet && request.Method != http.MethodHead) {
+		next.ServeHTTP(responseWriter, request)
+		return
+	}
+	requestDirectives := parseCacheControl(request.Header.Values(headerCacheControl))
+	if requestDirectives.has(cacheDirectiveNoStore) {
+		responseWriter.Header().Set(headerXCache, proxyCacheMiss)
+		next.ServeHTTP(responseWriter, request)
+		return
+	}
+
+	defaultTTL := proxyCache.defaultTTL(request.URL.Path)
+	entry := proxyCache.lookup(request.URL.RequestURI(), request)
+	if entry == nil {
+		proxyCache.fetch(responseWriter, request, next, defaultTTL, loggingService)
+		return
+	}
+	now := time.Now()
+	entryAge := entry.age(now)
+	if !requestDirectives.has(cacheDirectiveNoCache) {
+		switch {
+		case entryAge < entry.FreshFor:
+			writeProxyCacheEntry(responseWriter, request, entry, proxyCacheHit, now)
+			return
+		case entryAge < entry.FreshFor+entry.StaleWhileRevalidate:
+			writeProxyCacheEntry(responseWriter, request, entry, proxyCacheStale, now)
+			proxyCache.revalidateInBackground(request, next, entry, defaultTTL, loggingService)
+			return
+		}
+	}
+	proxyCache.revalidate(responseWriter, request, next, entry, defaultTTL, loggingService)
+}
+
+// fetch streams a cache miss to the client while keeping a copy of the body to store.
+func (proxyCache *ProxyCache) fetch(responseWriter http.ResponseWriter, request *http.Request, next http.Handler, defaultTTL time.Duration, loggingService *logging.Service) {
+	responseWriter.Header().Set(headerXCache, proxyCacheMiss)
+	captureWriter := &proxyCacheCaptureWriter{ResponseWriter: responseWriter}
+	next.ServeHTTP(captureWriter, request)
+	if captureWriter.statusCode == 0 || captureWriter.overflowed {
+		return
+	}
+	proxyCache.storeResponse(request, captureWriter.statusCode, captureWriter.header, captureWriter.body.Bytes(), defaultTTL, loggingService)
+}
+
+// revalidate asks the backend about a stale entry before answering; the backend response is buffered so the entry can
+// still be served if the backend fails within stale-if-error.
+func (proxyCache *ProxyCache) revalidate(responseWriter http.ResponseWriter, request *http.Request, next http.Handler, entry *proxyCacheEntry, defaultTTL time.Duration, loggingService *logging.Service) {
+	revalidationRequest, conditional := conditionalRevalidationRequest(request, entry)
+	bufferWriter := serveBuffered(next, revalidationRequest)
+	now := time.Now()
+	switch {
+	case conditional && bufferWriter.statusCode == http.StatusNotModified:
+		refreshedEntry, refreshErr := proxyCache.refresh(entry, request, bufferWriter.header, defaultTTL, now)
+		if refreshErr != nil {
+			loggingService.Error(logMessageProxyCacheStoreFailed, refreshErr, logging.String(logFieldPath, request.URL.Path))
+		}
+		writeProxyCacheEntry(responseWriter, request, refreshedEntry, proxyCacheHit, now)
+	case bufferWriter.statusCode >= http.StatusInternalServerError && entry.age(now) < entry.FreshFor+entry.StaleIfError:
+		writeProxyCacheEntry(responseWriter, request, entry, proxyCacheStale, now)
+	default:
+		proxyCache.storeResponse(request, bufferWriter.statusCode, bufferWriter.header, bufferWriter.body.Bytes(), defaultTTL, loggingService)
+		copyResponseHeaders(responseWriter.Header(), bufferWriter.header)
+		responseWriter.Header().Set(headerXCache, proxyCacheMiss)
+		responseWriter.WriteHeader(bufferWriter.statusCode)
+		_, _ = responseWriter.Write(bufferWriter.body.Bytes())
+	}
+}
+
+// revalidateInBackground refreshes an entry served stale within stale-while-revalidate. Only one revalidation per key
+// runs at a time.
+func (proxyCache *ProxyCache) revalidateInBackground(request *http.Request, next http.Handler, entry *proxyCacheEntry, defaultTTL time.Duration, loggingService *logging.Service) {
+	proxyCache.mutex.Lock()
+	if _, running := proxyCache.revalidating[entry.Key]; running {
+		proxyCache.mutex.Unlock()
+		return
+	}
+	proxyCache.revalidating[entry.Key] = struct{}{}
+	proxyCache.mutex.Unlock()
+
+	backgroundRequest := request.Clone(context.WithoutCancel(request.Context()))
+	backgroundRequest.Body = http.NoBody
+	go func() {
+		defer func() {
+			proxyCache.mutex.Lock()
+			delete(proxyCache.revalidating, entry.Key)
+			proxyCache.mutex.Unlock()
+		}()
+		revalidationRequest, conditional := conditionalRevalidationRequest(backgroundRequest, entry)
+		bufferWriter := serveBuffered(next, revalidationRequest)
+		if conditional && bufferWriter.statusCode == http.StatusNotModified {
+			if _, refreshErr := proxyCache.refresh(entry, backgroundRequest, bufferWriter.header, defaultTTL, time.Now()); refreshErr != nil {
+				loggingService.Error(logMessageProxyCacheStoreFailed, refreshErr, logging.String(logFieldPath, backgroundRequest.URL.Path))
+			}
+			return
+		}
+		proxyCache.storeResponse(backgroundRequest, bufferWriter.statusCode, bufferWriter.header, bufferWriter.body.Bytes(), defaultTTL, loggingService)
+	}()
+}
+
+// storeResponse keeps a GET response when its status and headers allow it. Responses to HEAD carry no body and are
+// never stored.
+func (proxyCache *ProxyCache) storeResponse(request *http.Request, statusCode int, responseHeaders http.Header, body []byte, defaultTTL time.Duration, loggingService *logging.Service) {
+	if request.Method != http.MethodGet || len(body) > proxyCacheMaxBodyBytes {
+		return
+	}
+	now := time.Now()
+	freshness, storable := responseFreshness(request, statusCode, responseHeaders, defaultTTL, now)
+	if !storable {
+		return
+	}
+	storedHeaders := responseHeaders.Clone()
+	storedHeaders.Del(headerXCache)
+	storedBody := bytes.Clone(body)
+	if storeErr := proxyCache.store(request.URL.RequestURI(), request, statusCode, storedHeaders, storedBody, freshness, now); storeErr != nil {
+		loggingService.Error(logMessageProxyCacheStoreFailed, storeErr, logging.String(logFieldPath, request.URL.Path))
+	}
+}
+
+// conditionalRevalidationRequest adds the entry validators to the request unless the client sent its own, in which
+// case a 304 belongs to the client and is passed through.
+func conditionalRevalidationRequest(request *http.Request, entry *proxyCacheEntry) (*http.Request, bool) {
+	if request.Header.Get(headerIfNoneMatch) != "" || request.Header.Get(headerIfModifiedSince) != "" {
+		return request, false
+	}
+	entityTag := entry.Header.Get(headerETag)
+	lastModified := entry.Header.Get(headerLastModified)
+	if entityTag == "" && lastModified == "" {
+		return request, false
+	}
+	conditionalRequest := request.Clone(request.Context())
+	if entityTag != "" {
+		conditionalRequest.Header.Set(headerIfNoneMatch, entityTag)
+	}
+	if lastModified != "" {
+		conditionalRequest.Header.Set(headerIfModifiedSince, lastModified)
+	}
+	return conditionalRequest, true
+}
+
+func writeProxyCacheEntry(responseWriter http.ResponseWriter, request *http.Request, entry *proxyCacheEntry, cacheStatus string, now time.Time) {
+	copyResponseHeaders(responseWriter.Header(), entry.Header)
+	responseWriter.Header().Set(headerAge, strconv.FormatInt(int64(entry.age(now)/time.Second), 10))
+	responseWriter.Header().Set(headerXCache, cacheStatus)
+	responseWriter.WriteHeader(entry.Status)
+	if request.Method != http.MethodHead {
+		_, _ = responseWriter.Write(entry.Body)
+	}
+}
+
+func copyResponseHeaders(destination http.Header, source http.Header) {
+	for headerName, headerValues := range source {
+		destination[headerName] = append([]string(nil), headerValues...)
+	}
+}
+
+// proxyCacheCaptureWriter passes a response through to the client and keeps a copy of it, giving up on the copy once
+// the body exceeds the per-entry limit.
+type proxyCacheCaptureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	header     http.Header
+	body       bytes.Buffer
+	overflowed bool
+}
+
+func (writer *proxyCacheCaptureWriter) WriteHeader(statusCode int) {
+	if writer.statusCode == 0 && statusCode >= http.StatusOK {
+		writer.statusCode = statusCode
+		writer.header = writer.ResponseWriter.Header().Clone()
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *proxyCacheCaptureWriter) Write(payload []byte) (int, error) {
+	if writer.statusCode == 0 {
+		writer.WriteHeader(http.StatusOK)
+	}
+	if !writer.overflowed {
+		if writer.body.Len()+len(payload) > proxyCacheMaxBodyBytes {
+			writer.overflowed = true
+			writer.body = bytes.Buffer{}
+		} else {
+			writer.body.Write(payload)
+		}
+	}
+	return writer.ResponseWriter.Write(payload)
+}
+
+func (writer *proxyCacheCaptureWriter) Flush() {
+	_ = http.NewResponseController(writer.ResponseWriter).Flush()
+}
+
+func (writer *proxyCacheCaptureWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+// proxyCacheBufferWriter collects a whole backend response before the cache decides what to send to the client.
+type proxyCacheBufferWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func serveBuffered(next http.Handler, request *http.Request) *proxyCacheBufferWriter {
+	bufferWriter := &proxyCacheBufferWriter{header: http.Header{}}
+	next.ServeHTTP(bufferWriter, request)
+	if bufferWriter.statusCode == 0 {
+		bufferWriter.statusCode = http.StatusOK
+	}
+	return bufferWriter
+}
+
+func (writer *proxyCacheBufferWriter) Header() http.Header {
+	return writer.header
+}
+
+func (writer *proxyCacheBufferWriter) WriteHeader(statusCode int) {
+	if writer.statusCode == 0 && statusCode >= http.StatusOK {
+		writer.statusCode = statusCode
+	}
+}
+
+func (writer *proxyCacheBufferWriter) Write(payload []byte) (int, error) {
+	if writer.statusCode == 0 {
+		writer.statusCode = http.StatusOK
+	}
+	return writer.body.Write(payload)
+}
+
+func (writer *proxyCacheBufferWriter) Flush() {}
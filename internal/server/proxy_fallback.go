@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+)
+
+// serveWithFallback runs primary and, when it answers with one of the fallback status codes, discards that answer
+// and runs fallback instead.
+func serveWithFallback(responseWriter http.ResponseWriter, request *http.Request, primary http.Handler, fallback http.Handler, fallbackStatusCodes ...int) {
+	fallbackWriter := &fallbackResponseWriter{
+		ResponseWriter:      responseWriter,
+		header:              http.Header{},
+		fallbackStatusCodes: fallbackStatusCodes,
+	}
+	primary.ServeHTTP(fallbackWriter, request)
+	if fallbackWriter.intercepted {
+		fallback.ServeHTTP(responseWriter, request)
+	}
+}
+
+// fallbackResponseWriter holds back headers until the status code is known so that a fallback response can replace
+// the primary one without leaking any of its headers or body.
+type fallbackResponseWriter struct {
+	http.ResponseWriter
+	header              http.Header
+	fallbackStatusCodes []int
+	wroteHeader         bool
+	intercepted         bool
+}
+
+func (writer *fallbackResponseWriter) Header() http.Header {
+	if writer.wroteHeader && !writer.intercepted {
+		return writer.ResponseWriter.Header()
+	}
+	return writer.header
+}
+
+func (writer *fallbackResponseWriter) WriteHeader(statusCode int) {
+	if writer.wroteHeader {
+		return
+	}
+	writer.wroteHeader = true
+	for _, fallbackStatusCode := range writer.fallbackStatusCodes {
+		if statusCode == fallbackStatusCode {
+			writer.intercepted = true
+			return
+		}
+	}
+	committedHeader := writer.ResponseWriter.Header()
+	for headerName, headerValues := range writer.header {
+		committedHeader[headerName] = headerValues
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *fallbackResponseWriter) Write(content []byte) (int, error) {
+	if !writer.wroteHeader {
+		writer.WriteHeader(http.StatusOK)
+	}
+	if writer.intercepted {
+		return len(content), nil
+	}
+	return writer.ResponseWriter.Write(content)
+}
+
+func (writer *fallbackResponseWriter) Flush() {
+	if !writer.wroteHeader || writer.intercepted {
+		return
+	}
+	responseFlusher, supportsFlush := writer.ResponseWriter.(http.Flusher)
+	if supportsFlush {
+		responseFlusher.Flush()
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	proxyFallbackPolicyMappingSeparator = "="
+	proxyFallbackModeProxy              = "proxy"
+	proxyFallbackModeStaticFirst        = "static-first"
+	proxyFallbackModeProxyFirst         = "proxy-first"
+)
+
+var ErrInvalidProxyFallbackPolicy = errors.New("proxy.fallback.policy.invalid")
+
+type proxyFallbackMode int
+
+const (
+	proxyFallbackProxyOnly proxyFallbackMode = iota
+	proxyFallbackStaticFirst
+	proxyFallbackProxyFirst
+)
+
+// ProxyFallbackPolicies decides, per proxied path, whether the local file pipeline is tried before or after the
+// backend.
+type ProxyFallbackPolicies struct {
+	policies []proxyFallbackPolicy
+}
+
+type proxyFallbackPolicy struct {
+	pathPrefix string
+	mode       proxyFallbackMode
+}
+
+func NewProxyFallbackPolicies(mappings []string) (ProxyFallbackPolicies, error) {
+	if len(mappings) == 0 {
+		return ProxyFallbackPolicies{}, nil
+	}
+	policyByPathPrefix := map[string]proxyFallbackPolicy{}
+	for _, mapping := range mappings {
+		parsedPolicy, parseErr := parseProxyFallbackPolicy(mapping)
+		if parseErr != nil {
+			return ProxyFallbackPolicies{}, parseErr
+		}
+		policyByPathPrefix[parsedPolicy.pathPrefix] = parsedPolicy
+	}
+
+	policies := make([]proxyFallbackPolicy, 0, len(policyByPathPrefix))
+	for _, policy := range policyByPathPrefix {
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(leftIndex int, rightIndex int) bool {
+		return len(policies[leftIndex].pathPrefix) > len(policies[rightIndex].pathPrefix)
+	})
+	return ProxyFallbackPolicies{policies: policies}, nil
+}
+
+func (policies ProxyFallbackPolicies) IsEmpty() bool {
+	return len(policies.policies) == 0
+}
+
+func (policies ProxyFallbackPolicies) modeFor(requestPath string) proxyFallbackMode {
+	for _, policy := range policies.policies {
+		if strings.HasPrefix(requestPath, policy.pathPrefix) {
+			return policy.mode
+		}
+	}
+	return proxyFallbackProxyOnly
+}
+
+func parseProxyFallbackPolicy(mapping string) (proxyFallbackPolicy, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	if trimmedMapping == "" {
+		return proxyFallbackPolicy{}, fmt.Errorf("%w: empty mapping", ErrInvalidProxyFallbackPolicy)
+	}
+	parts := strings.SplitN(trimmedMapping, proxyFallbackPolicyMappingSeparator, 2)
+	if len(parts) != 2 {
+		return proxyFallbackPolicy{}, fmt.Errorf("%w: mapping must be in /path=proxy|static-first|proxy-first form", ErrInvalidProxyFallbackPolicy)
+	}
+
+	pathPrefix := strings.TrimSpace(parts[0])
+	if pathPrefix == "" {
+		return proxyFallbackPolicy{}, fmt.Errorf("%w: empty path prefix", ErrInvalidProxyFallbackPolicy)
+	}
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return proxyFallbackPolicy{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyFallbackPolicy)
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(parts[1]))
+	switch mode {
+	case proxyFallbackModeProxy:
+		return proxyFallbackPolicy{pathPrefix: pathPrefix, mode: proxyFallbackProxyOnly}, nil
+	case proxyFallbackModeStaticFirst:
+		return proxyFallbackPolicy{pathPrefix: pathPrefix, mode: proxyFallbackStaticFirst}, nil
+	case proxyFallbackModeProxyFirst:
+		return proxyFallbackPolicy{pathPrefix: pathPrefix, mode: proxyFallbackProxyFirst}, nil
+	default:
+		return proxyFallbackPolicy{}, fmt.Errorf("%w: unsupported mode %s", ErrInvalidProxyFallbackPolicy, mode)
+	}
+}
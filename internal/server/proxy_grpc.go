@@ -0,0 +1,186 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	headerContentType          = "Content-Type"
+	headerContentLength        = "Content-Length"
+	headerTE                   = "Te"
+	headerTrailer              = "Trailer"
+	headerGRPCStatus           = "Grpc-Status"
+	headerGRPCMessage          = "Grpc-Message"
+	valueTrailers              = "trailers"
+	contentTypeGRPC            = "application/grpc"
+	contentTypeGRPCWeb         = "application/grpc-web"
+	contentTypeGRPCWebText     = "application/grpc-web-text"
+	grpcStatusUnavailable      = "14"
+	grpcWebTrailerFrameFlag    = 0x80
+	grpcWebFrameHeaderLength   = 5
+	grpcWebResponseReadBufSize = 32 * 1024
+)
+
+type grpcWebFormatContextKey struct{}
+
+// grpcWebFormat records how the browser encoded a gRPC-web request so the response can be encoded the same way.
+type grpcWebFormat struct {
+	contentType string
+	textEncoded bool
+}
+
+func isGRPCRequest(request *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(request.Header.Get(headerContentType)), contentTypeGRPC)
+}
+
+func isGRPCWebRequest(request *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(request.Header.Get(headerContentType)), contentTypeGRPCWeb)
+}
+
+// translateGRPCWebRequest rewrites a gRPC-web request into a native gRPC request for an HTTP/2 backend.
+func translateGRPCWebRequest(request *http.Request) *http.Request {
+	requestContentType := strings.ToLower(request.Header.Get(headerContentType))
+	format := grpcWebFormat{contentType: contentTypeGRPCWeb, textEncoded: strings.HasPrefix(requestContentType, contentTypeGRPCWebText)}
+	subtype := strings.TrimPrefix(requestContentType, contentTypeGRPCWeb)
+	if format.textEncoded {
+		format.contentType = contentTypeGRPCWebText
+		subtype = strings.TrimPrefix(requestContentType, contentTypeGRPCWebText)
+	}
+
+	translatedRequest := request.Clone(context.WithValue(request.Context(), grpcWebFormatContextKey{}, format))
+	translatedRequest.Header.Set(headerContentType, contentTypeGRPC+subtype)
+	translatedRequest.Header.Set(headerTE, valueTrailers)
+	translatedRequest.Header.Del(headerContentLength)
+	translatedRequest.ContentLength = -1
+	if format.textEncoded {
+		translatedRequest.Body = struct {
+			io.Reader
+			io.Closer
+		}{Reader: base64.NewDecoder(base64.StdEncoding, request.Body), Closer: request.Body}
+	}
+	return translatedRequest
+}
+
+// translateGRPCWebResponse converts a native gRPC response back to gRPC-web, moving trailers into a trailer frame
+// at the end of the body.
+func translateGRPCWebResponse(response *http.Response) error {
+	format, translated := response.Request.Context().Value(grpcWebFormatContextKey{}).(grpcWebFormat)
+	if !translated {
+		return nil
+	}
+	responseContentType := strings.ToLower(response.Header.Get(headerContentType))
+	if strings.HasPrefix(responseContentType, contentTypeGRPC) {
+		response.Header.Set(headerContentType, format.contentType+strings.TrimPrefix(responseContentType, contentTypeGRPC))
+	}
+	response.Header.Del(headerContentLength)
+	response.Header.Del(headerTrailer)
+	response.ContentLength = -1
+	response.Trailer = nil
+	response.Body = &grpcWebResponseBody{
+		source:      response.Body,
+		response:    response,
+		textEncoded: format.textEncoded,
+		readBuffer:  make([]byte, grpcWebResponseReadBufSize),
+	}
+	return nil
+}
+
+type grpcWebResponseBody struct {
+	source      io.ReadCloser
+	response    *http.Response
+	textEncoded bool
+	readBuffer  []byte
+	pending     bytes.Buffer
+	sourceDone  bool
+}
+
+func (body *grpcWebResponseBody) Read(destination []byte) (int, error) {
+	for body.pending.Len() == 0 {
+		if body.sourceDone {
+			return 0, io.EOF
+		}
+		readCount, readErr := body.source.Read(body.readBuffer)
+		if readCount > 0 {
+			body.appendPending(body.readBuffer[:readCount])
+		}
+		if readErr == io.EOF {
+			body.sourceDone = true
+			body.appendPending(buildGRPCWebTrailerFrame(body.response.Trailer))
+			body.response.Trailer = nil
+		} else if readErr != nil {
+			return 0, readErr
+		}
+	}
+	return body.pending.Read(destination)
+}
+
+func (body *grpcWebResponseBody) Close() error {
+	return body.source.Close()
+}
+
+func (body *grpcWebResponseBody) appendPending(content []byte) {
+	if len(content) == 0 {
+		return
+	}
+	if body.textEncoded {
+		body.pending.WriteString(base64.StdEncoding.EncodeToString(content))
+		return
+	}
+	body.pending.Write(content)
+}
+
+func buildGRPCWebTrailerFrame(trailer http.Header) []byte {
+	if len(trailer) == 0 {
+		return nil
+	}
+	trailerKeys := make([]string, 0, len(trailer))
+	for trailerKey := range trailer {
+		trailerKeys = append(trailerKeys, trailerKey)
+	}
+	sort.Strings(trailerKeys)
+	var trailerBlock bytes.Buffer
+	for _, trailerKey := range trailerKeys {
+		for _, trailerValue := range trailer[trailerKey] {
+			fmt.Fprintf(&trailerBlock, "%s: %s\r\n", strings.ToLower(trailerKey), trailerValue)
+		}
+	}
+	frame := make([]byte, grpcWebFrameHeaderLength, grpcWebFrameHeaderLength+trailerBlock.Len())
+	frame[0] = grpcWebTrailerFrameFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(trailerBlock.Len()))
+	return append(frame, trailerBlock.Bytes()...)
+}
+
+// writeGRPCUnavailable reports a backend failure as a trailers-only gRPC response so clients see grpc-status
+// instead of a bare HTTP error.
+func writeGRPCUnavailable(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	responseContentType := contentTypeGRPC
+	if format, translated := request.Context().Value(grpcWebFormatContextKey{}).(grpcWebFormat); translated {
+		responseContentType = format.contentType
+	}
+	responseWriter.Header().Set(headerContentType, responseContentType)
+	responseWriter.Header().Set(headerGRPCStatus, grpcStatusUnavailable)
+	responseWriter.Header().Set(headerGRPCMessage, encodeGRPCMessage("Bad Gateway: "+err.Error()))
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message value as required by the gRPC HTTP/2 protocol.
+func encodeGRPCMessage(message string) string {
+	var encodedMessage strings.Builder
+	for index := 0; index < len(message); index++ {
+		character := message[index]
+		if character < ' ' || character > '~' || character == '%' {
+			fmt.Fprintf(&encodedMessage, "%%%02X", character)
+			continue
+		}
+		encodedMessage.WriteByte(character)
+	}
+	return encodedMessage.String()
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	proxyGRPCWebPolicyMappingSeparator = "="
+	proxyGRPCWebModeEnabled            = "enabled"
+	proxyGRPCWebModeDisabled           = "disabled"
+)
+
+var ErrInvalidProxyGRPCWebPolicy = errors.New("proxy.grpc_web.policy.invalid")
+
+// ProxyGRPCWebPolicies selects the proxy paths whose gRPC-web requests are translated to native gRPC.
+type ProxyGRPCWebPolicies struct {
+	policies []proxyGRPCWebPolicy
+}
+
+type proxyGRPCWebPolicy struct {
+	pathPrefix string
+	enabled    bool
+}
+
+func NewProxyGRPCWebPolicies(mappings []string) (ProxyGRPCWebPolicies, error) {
+	if len(mappings) == 0 {
+		return ProxyGRPCWebPolicies{}, nil
+	}
+	policyByPathPrefix := map[string]proxyGRPCWebPolicy{}
+	for _, mapping := range mappings {
+		parsedPolicy, parseErr := parseProxyGRPCWebPolicy(mapping)
+		if parseErr != nil {
+			return ProxyGRPCWebPolicies{}, parseErr
+		}
+		policyByPathPrefix[parsedPolicy.pathPrefix] = parsedPolicy
+	}
+
+	policies := make([]proxyGRPCWebPolicy, 0, len(policyByPathPrefix))
+	for _, policy := range policyByPathPrefix {
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(leftIndex int, rightIndex int) bool {
+		return len(policies[leftIndex].pathPrefix) > len(policies[rightIndex].pathPrefix)
+	})
+	return ProxyGRPCWebPolicies{policies: policies}, nil
+}
+
+func (policies ProxyGRPCWebPolicies) IsEmpty() bool {
+	return len(policies.policies) == 0
+}
+
+func (policies ProxyGRPCWebPolicies) IsEnabled(requestPath string) bool {
+	for _, policy := range policies.policies {
+		if strings.HasPrefix(requestPath, policy.pathPrefix) {
+			return policy.enabled
+		}
+	}
+	return false
+}
+
+func parseProxyGRPCWebPolicy(mapping string) (proxyGRPCWebPolicy, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	if trimmedMapping == "" {
+		return proxyGRPCWebPolicy{}, fmt.Errorf("%w: empty mapping", ErrInvalidProxyGRPCWebPolicy)
+	}
+	parts := strings.SplitN(trimmedMapping, proxyGRPCWebPolicyMappingSeparator, 2)
+	if len(parts) != 2 {
+		return proxyGRPCWebPolicy{}, fmt.Errorf("%w: mapping must be in /path=enabled|disabled form", ErrInvalidProxyGRPCWebPolicy)
+	}
+
+	pathPrefix := strings.TrimSpace(parts[0])
+	if pathPrefix == "" {
+		return proxyGRPCWebPolicy{}, fmt.Errorf("%w: empty path prefix", ErrInvalidProxyGRPCWebPolicy)
+	}
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return proxyGRPCWebPolicy{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyGRPCWebPolicy)
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(parts[1]))
+	switch mode {
+	case proxyGRPCWebModeEnabled:
+		return proxyGRPCWebPolicy{pathPrefix: pathPrefix, enabled: true}, nil
+	case proxyGRPCWebModeDisabled:
+		return proxyGRPCWebPolicy{pathPrefix: pathPrefix, enabled: false}, nil
+	default:
+		return proxyGRPCWebPolicy{}, fmt.Errorf("%w: unsupported mode %s", ErrInvalidProxyGRPCWebPolicy, mode)
+	}
+}
@@ -1,15 +1,16 @@
 package server
 
 import (
-	"bufio"
+	"context"
 	"crypto/tls"
-	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
 )
 
 const (
@@ -18,57 +19,142 @@ const (
 	headerUpgrade    = "Upgrade"
 	valueUpgrade     = "upgrade"
 	valueWebSocket   = "websocket"
+
+	networkTCP         = "tcp"
+	networkUnix        = "unix"
+	backendDialTimeout = 10 * time.Second
 )
 
 type proxyHandler struct {
 	next                   http.Handler
 	routes                 []proxyRouteHandler
 	proxyStreamingPolicies ProxyStreamingPolicies
+	proxyGRPCWebPolicies   ProxyGRPCWebPolicies
+	proxyFallbackPolicies  ProxyFallbackPolicies
+	proxyMirrors           ProxyMirrors
+	proxyRewritePolicies   ProxyRewritePolicies
+	proxyRequestHeaders    ProxyRequestHeaders
+	proxyCache             *ProxyCache
+	webSocketKeepalive     webSocketKeepalive
+	webSocketInspector     *ProxyWebSocketInspector
+	loggingService         *logging.Service
 }
 
 type proxyRouteHandler struct {
-	pathPrefix      string
-	backendURL      *url.URL
-	defaultProxy    *httputil.ReverseProxy
-	unbufferedProxy *httputil.ReverseProxy
+	pathPrefix         string
+	backendURL         *url.URL
+	defaultProxy       *httputil.ReverseProxy
+	unbufferedProxy    *httputil.ReverseProxy
+	webSocketTransport *http.Transport
 }
 
-func newProxyHandler(next http.Handler, proxyRoutes ProxyRoutes, proxyStreamingPolicies ProxyStreamingPolicies) http.Handler {
-	routeHandlers := make([]proxyRouteHandler, 0, len(proxyRoutes.routes))
-	for _, route := range proxyRoutes.routes {
-		routeHandlers = append(routeHandlers, newProxyRouteHandler(route))
+func newProxyHandler(next http.Handler, configuration FileServerConfiguration, loggingService *logging.Service) http.Handler {
+	routeHandlers := make([]proxyRouteHandler, 0, len(configuration.ProxyRoutes.routes))
+	for _, route := range configuration.ProxyRoutes.routes {
+		backendTLSConfig := configuration.ProxyBackendTLSPolicies.ConfigForRoute(route.pathPrefix)
+		transport := newRouteTransport(route, backendTLSConfig)
+		if !configuration.ProxyMirrors.IsEmpty() {
+			transport = configuration.ProxyMirrors.wrapTransport(transport, loggingService)
+		}
+		if configuration.ProxyTrafficReplayer != nil {
+			transport = configuration.ProxyTrafficReplayer.wrapTransport(transport)
+		}
+		if configuration.ProxyTrafficRecorder != nil {
+			transport = configuration.ProxyTrafficRecorder.wrapTransport(transport, loggingService)
+		}
+		routeHandlers = append(routeHandlers, newProxyRouteHandler(route, transport, newWebSocketTransport(route, backendTLSConfig)))
 	}
 	return &proxyHandler{
 		next:                   next,
 		routes:                 routeHandlers,
-		proxyStreamingPolicies: proxyStreamingPolicies,
+		proxyStreamingPolicies: configuration.ProxyStreamingPolicies,
+		proxyGRPCWebPolicies:   configuration.ProxyGRPCWebPolicies,
+		proxyFallbackPolicies:  configuration.ProxyFallbackPolicies,
+		proxyMirrors:           configuration.ProxyMirrors,
+		proxyRewritePolicies:   configuration.ProxyRewritePolicies,
+		proxyRequestHeaders:    configuration.ProxyRequestHeaders,
+		proxyCache:             configuration.ProxyCache,
+		webSocketKeepalive: webSocketKeepalive{
+			pingInterval: configuration.ProxyWebSocketPing,
+			idleTimeout:  configuration.ProxyWebSocketIdle,
+		},
+		webSocketInspector: configuration.ProxyWebSocketInspector,
+		loggingService:     loggingService,
 	}
 }
 
-func newProxyRouteHandler(route proxyRoute) proxyRouteHandler {
-	defaultProxy := newRouteReverseProxy(route.backendURL, 0)
-	unbufferedProxy := newRouteReverseProxy(route.backendURL, -1)
+func newProxyRouteHandler(route proxyRoute, transport http.RoundTripper, webSocketTransport *http.Transport) proxyRouteHandler {
+	defaultProxy := newRouteReverseProxy(route.backendURL, transport, 0)
+	unbufferedProxy := newRouteReverseProxy(route.backendURL, transport, -1)
 	return proxyRouteHandler{
-		pathPrefix:      route.pathPrefix,
-		backendURL:      route.backendURL,
-		defaultProxy:    defaultProxy,
-		unbufferedProxy: unbufferedProxy,
+		pathPrefix:         route.pathPrefix,
+		backendURL:         route.backendURL,
+		defaultProxy:       defaultProxy,
+		unbufferedProxy:    unbufferedProxy,
+		webSocketTransport: webSocketTransport,
 	}
 }
 
 func (handler *proxyHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if handler.proxyCache != nil && request.URL.Path == proxyCacheAdminPath {
+		markRequestHandler(request, requestHandlerCacheAdmin)
+		handler.proxyCache.serveAdmin(responseWriter, request)
+		return
+	}
+	if handler.webSocketInspector != nil && (request.URL.Path == webSocketInspectorAdminPath || request.URL.Path == webSocketInspectorFramesPath) {
+		markRequestHandler(request, requestHandlerWebSocketInspector)
+		handler.webSocketInspector.serveAdmin(responseWriter, request)
+		return
+	}
 	routeHandler, matched := handler.matchRoute(request.URL.Path)
 	if !matched {
 		handler.next.ServeHTTP(responseWriter, request)
 		return
 	}
+	request = handler.proxyRequestHeaders.apply(request)
 
-	if routeHandler.isWebSocketUpgrade(request) {
-		routeHandler.handleWebSocket(responseWriter, request)
+	if isWebSocketUpgrade(request) {
+		frameLogger := handler.webSocketInspector.frameLogger(request.URL.Path, handler.loggingService)
+		routeHandler.handleWebSocket(responseWriter, handler.mountRequest(request), handler.webSocketKeepalive, frameLogger, handler.loggingService)
 		return
 	}
 
-	routeHandler.resolveHTTPProxy(request.URL.Path, handler.proxyStreamingPolicies).ServeHTTP(responseWriter, request)
+	proxyBackendHandler := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		request = handler.proxyMirrors.withPendingMirror(request)
+		if isGRPCWebRequest(request) && handler.proxyGRPCWebPolicies.IsEnabled(request.URL.Path) {
+			request = translateGRPCWebRequest(request)
+		}
+		reverseProxy := routeHandler.resolveHTTPProxy(request, handler.proxyStreamingPolicies)
+		if heartbeatInterval, isSSE := handler.proxyStreamingPolicies.sseHeartbeatInterval(request.URL.Path); isSSE {
+			serveServerSentEvents(responseWriter, handler.mountRequest(request), reverseProxy, heartbeatInterval, handler.loggingService)
+			return
+		}
+		reverseProxy.ServeHTTP(responseWriter, handler.mountRequest(request))
+	})
+	backendHandler := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		handler.proxyCache.serve(responseWriter, request, proxyBackendHandler, handler.loggingService)
+	})
+	switch handler.proxyFallbackPolicies.modeFor(request.URL.Path) {
+	case proxyFallbackStaticFirst:
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			backendHandler.ServeHTTP(responseWriter, request)
+			return
+		}
+		serveWithFallback(responseWriter, request, handler.next, backendHandler, http.StatusNotFound)
+	case proxyFallbackProxyFirst:
+		serveWithFallback(responseWriter, request, backendHandler, handler.next, http.StatusNotFound, http.StatusBadGateway)
+	default:
+		backendHandler.ServeHTTP(responseWriter, request)
+	}
+}
+
+// mountRequest strips the mount prefix for routes with a rewrite policy; policies are matched on the client path.
+func (handler *proxyHandler) mountRequest(request *http.Request) *http.Request {
+	rewritePolicy, mounted := handler.proxyRewritePolicies.policyFor(request.URL.Path)
+	if !mounted {
+		return request
+	}
+	return withProxyRewrite(request, rewritePolicy)
 }
 
 func (handler *proxyHandler) matchRoute(requestPath string) (*proxyRouteHandler, bool) {
@@ -81,107 +167,21 @@ func (handler *proxyHandler) matchRoute(requestPath string) (*proxyRouteHandler,
 	return nil, false
 }
 
-func (routeHandler *proxyRouteHandler) isWebSocketUpgrade(request *http.Request) bool {
+func isWebSocketUpgrade(request *http.Request) bool {
 	connectionHeader := strings.ToLower(request.Header.Get(headerConnection))
 	upgradeHeader := strings.ToLower(request.Header.Get(headerUpgrade))
 	return strings.Contains(connectionHeader, valueUpgrade) && upgradeHeader == valueWebSocket
 }
 
-func (routeHandler *proxyRouteHandler) resolveHTTPProxy(requestPath string, streamingPolicies ProxyStreamingPolicies) *httputil.ReverseProxy {
-	if streamingPolicies.IsUnbuffered(requestPath) {
+// resolveHTTPProxy picks the flush behavior for the request; gRPC traffic streams unbuffered unless a streaming
+// policy for the path says otherwise.
+func (routeHandler *proxyRouteHandler) resolveHTTPProxy(request *http.Request, streamingPolicies ProxyStreamingPolicies) *httputil.ReverseProxy {
+	if streamingPolicies.isUnbufferedWithDefault(request.URL.Path, isGRPCRequest(request)) {
 		return routeHandler.unbufferedProxy
 	}
 	return routeHandler.defaultProxy
 }
 
-func (routeHandler *proxyRouteHandler) handleWebSocket(responseWriter http.ResponseWriter, request *http.Request) {
-	backendHost := routeHandler.backendURL.Host
-	scheme := "ws"
-	useTLS := strings.EqualFold(routeHandler.backendURL.Scheme, proxySchemeHTTPS)
-	if useTLS {
-		scheme = "wss"
-	}
-
-	var backendConnection net.Conn
-	var dialErr error
-
-	if useTLS {
-		dialer := &net.Dialer{Timeout: 10 * time.Second}
-		backendConnection, dialErr = tls.DialWithDialer(dialer, "tcp", backendHost, &tls.Config{
-			ServerName: hostWithoutPort(backendHost),
-		})
-	} else {
-		backendConnection, dialErr = net.DialTimeout("tcp", backendHost, 10*time.Second)
-	}
-
-	if dialErr != nil {
-		http.Error(responseWriter, "Bad Gateway: failed to connect to backend", http.StatusBadGateway)
-		return
-	}
-	defer backendConnection.Close()
-
-	hijacker, supportsHijacker := responseWriter.(http.Hijacker)
-	if !supportsHijacker {
-		http.Error(responseWriter, "WebSocket hijacking not supported", http.StatusInternalServerError)
-		return
-	}
-
-	clientConnection, clientBuffer, hijackErr := hijacker.Hijack()
-	if hijackErr != nil {
-		http.Error(responseWriter, "Failed to hijack connection", http.StatusInternalServerError)
-		return
-	}
-	defer clientConnection.Close()
-
-	backendURL := &url.URL{
-		Scheme:   scheme,
-		Host:     backendHost,
-		Path:     request.URL.Path,
-		RawQuery: request.URL.RawQuery,
-	}
-
-	upgradeRequest := &http.Request{
-		Method:     request.Method,
-		URL:        backendURL,
-		Proto:      request.Proto,
-		ProtoMajor: request.ProtoMajor,
-		ProtoMinor: request.ProtoMinor,
-		Header:     cloneHeaders(request.Header),
-		Host:       backendHost,
-	}
-	upgradeRequest.Header.Set(headerConnection, headerUpgrade)
-	upgradeRequest.Header.Set(headerUpgrade, valueWebSocket)
-	upgradeRequest.Header.Set(headerHost, backendHost)
-
-	if err := upgradeRequest.Write(backendConnection); err != nil {
-		return
-	}
-
-	backendReader := bufio.NewReader(backendConnection)
-	backendResponse, readErr := http.ReadResponse(backendReader, upgradeRequest)
-	if readErr != nil {
-		return
-	}
-
-	if err := backendResponse.Write(clientConnection); err != nil {
-		return
-	}
-
-	completionSignals := make(chan struct{}, 2)
-
-	go func() {
-		copyWithBuffer(backendConnection, clientBuffer)
-		completionSignals <- struct{}{}
-	}()
-
-	go func() {
-		copyWithBuffer(clientConnection, backendReader)
-		completionSignals <- struct{}{}
-	}()
-
-	<-completionSignals
-}
-
 func cloneHeaders(src http.Header) http.Header {
 	dst := make(http.Header, len(src))
 	for key, values := range src {
@@ -190,23 +190,38 @@ func cloneHeaders(src http.Header) http.Header {
 	return dst
 }
 
-func copyWithBuffer(dst io.Writer, src io.Reader) {
-	buf := make([]byte, 32*1024)
-	io.CopyBuffer(dst, src, buf)
-}
-
-func hostWithoutPort(hostPort string) string {
-	host, _, err := net.SplitHostPort(hostPort)
-	if err != nil {
-		return hostPort
+func newRouteTransport(route proxyRoute, backendTLSConfig *tls.Config) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = backendTLSConfig
+	if route.backendSocketPath != "" {
+		dialer := &net.Dialer{Timeout: backendDialTimeout}
+		transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, networkUnix, route.backendSocketPath)
+		}
+	}
+	if route.backendHTTP2Cleartext {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
 	}
-	return host
+	return transport
 }
 
-func newRouteReverseProxy(backendURL *url.URL, flushInterval time.Duration) *httputil.ReverseProxy {
+func newRouteReverseProxy(backendURL *url.URL, transport http.RoundTripper, flushInterval time.Duration) *httputil.ReverseProxy {
 	reverseProxy := httputil.NewSingleHostReverseProxy(backendURL)
+	reverseProxy.Transport = transport
 	reverseProxy.FlushInterval = flushInterval
+	rewriteBody := flushInterval >= 0
+	reverseProxy.ModifyResponse = func(response *http.Response) error {
+		if translateErr := translateGRPCWebResponse(response); translateErr != nil {
+			return translateErr
+		}
+		return rewriteMountedResponse(response, rewriteBody)
+	}
 	reverseProxy.ErrorHandler = func(responseWriter http.ResponseWriter, request *http.Request, err error) {
+		if isGRPCRequest(request) {
+			writeGRPCUnavailable(responseWriter, request, err)
+			return
+		}
 		http.Error(responseWriter, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
 	}
 	return reverseProxy
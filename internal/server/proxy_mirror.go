@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	proxyMirrorMappingSeparator = "="
+	proxyMirrorRequestTimeout   = 30 * time.Second
+
+	logMessageProxyMirrorCompleted = "proxy mirror completed"
+	logMessageProxyMirrorFailed    = "proxy mirror failed"
+	logMessageProxyMirrorSkipped   = "proxy mirror skipped"
+	logFieldMirror                 = "mirror"
+	logFieldReason                 = "reason"
+
+	proxyMirrorSkipReasonBodyLimit   = "request body exceeds mirror limit"
+	proxyMirrorSkipReasonBodyRead    = "request body could not be buffered"
+	proxyMirrorSkipReasonConcurrency = "mirror concurrency limit reached"
+)
+
+var ErrInvalidProxyMirror = errors.New("proxy.mirror.invalid")
+
+type proxyMirrorContextKey struct{}
+
+// ProxyMirrors sends an asynchronous copy of proxied requests to per-route shadow backends. Mirror responses are
+// discarded; only their status and latency are logged.
+type ProxyMirrors struct {
+	mirrors        []proxyMirror
+	bodyLimitBytes int64
+	slots          chan struct{}
+}
+
+type proxyMirror struct {
+	pathPrefix string
+	mirrorURL  *url.URL
+	client     *http.Client
+}
+
+// NewProxyMirrors parses /path=http://shadow mappings. At most maxConcurrent mirror requests are in flight; further
+// copies are dropped. Request bodies larger than bodyLimitBytes are not mirrored.
+func NewProxyMirrors(mappings []string, maxConcurrent int, bodyLimitBytes int64) (ProxyMirrors, error) {
+	if len(mappings) == 0 {
+		return ProxyMirrors{}, nil
+	}
+	if maxConcurrent < 1 {
+		return ProxyMirrors{}, fmt.Errorf("%w: concurrency must be at least 1", ErrInvalidProxyMirror)
+	}
+	if bodyLimitBytes < 0 {
+		return ProxyMirrors{}, fmt.Errorf("%w: body limit must not be negative", ErrInvalidProxyMirror)
+	}
+	mirrorByPathPrefix := map[string]proxyMirror{}
+	for _, mapping := range mappings {
+		parsedMirror, parseErr := parseProxyMirror(mapping)
+		if parseErr != nil {
+			return ProxyMirrors{}, parseErr
+		}
+		mirrorByPathPrefix[parsedMirror.pathPrefix] = parsedMirror
+	}
+
+	mirrors := make([]proxyMirror, 0, len(mirrorByPathPrefix))
+	for _, mirror := range mirrorByPathPrefix {
+		mirrors = append(mirrors, mirror)
+	}
+	sort.SliceStable(mirrors, func(leftIndex int, rightIndex int) bool {
+		return len(mirrors[leftIndex].pathPrefix) > len(mirrors[rightIndex].pathPrefix)
+	})
+	return ProxyMirrors{mirrors: mirrors, bodyLimitBytes: bodyLimitBytes, slots: make(chan struct{}, maxConcurrent)}, nil
+}
+
+func (mirrors ProxyMirrors) IsEmpty() bool {
+	return len(mirrors.mirrors) == 0
+}
+
+func (mirrors ProxyMirrors) mirrorFor(requestPath string) (proxyMirror, bool) {
+	for _, mirror := range mirrors.mirrors {
+		if strings.HasPrefix(requestPath, mirror.pathPrefix) {
+			return mirror, true
+		}
+	}
+	return proxyMirror{}, false
+}
+
+// withPendingMirror marks a request bound for the primary backend so the mirroring transport copies it. Requests
+// answered before reaching the backend (static fallback hits, cache hits, replayed exchanges) are never copied.
+func (mirrors ProxyMirrors) withPendingMirror(request *http.Request) *http.Request {
+	if _, matched := mirrors.mirrorFor(request.URL.Path); !matched {
+		return request
+	}
+	clientURL := *request.URL
+	return request.WithContext(context.WithValue(request.Context(), proxyMirrorContextKey{}, &clientURL))
+}
+
+// wrapTransport returns a transport that mirrors the requests marked by withPendingMirror as they are sent to the
+// primary backend.
+func (mirrors ProxyMirrors) wrapTransport(transport http.RoundTripper, loggingService *logging.Service) http.RoundTripper {
+	return &mirroringTransport{mirrors: mirrors, transport: transport, loggingService: loggingService}
+}
+
+type mirroringTransport struct {
+	mirrors        ProxyMirrors
+	transport      http.RoundTripper
+	loggingService *logging.Service
+}
+
+func (transport *mirroringTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if clientURL, pending := request.Context().Value(proxyMirrorContextKey{}).(*url.URL); pending {
+		transport.mirrors.mirror(request, clientURL, transport.loggingService)
+	}
+	return transport.transport.RoundTrip(request)
+}
+
+// mirror buffers the outgoing backend request body (restoring it for the primary backend) and sends a copy to the
+// shadow backend matching the client URL in the background.
+func (mirrors ProxyMirrors) mirror(request *http.Request, clientURL *url.URL, loggingService *logging.Service) {
+	mirror, matched := mirrors.mirrorFor(clientURL.Path)
+	if !matched {
+		return
+	}
+	mirrorTarget := mirror.targetURL(clientURL)
+	requestBody, bodyErr := mirrors.bufferRequestBody(request)
+	if bodyErr != nil {
+		loggingService.Info(logMessageProxyMirrorSkipped, logging.String(logFieldPath, clientURL.Path), logging.String(logFieldMirror, mirrorTarget.String()), logging.String(logFieldReason, bodyErr.Error()))
+		return
+	}
+	select {
+	case mirrors.slots <- struct{}{}:
+	default:
+		loggingService.Info(logMessageProxyMirrorSkipped, logging.String(logFieldPath, clientURL.Path), logging.String(logFieldMirror, mirrorTarget.String()), logging.String(logFieldReason, proxyMirrorSkipReasonConcurrency))
+		return
+	}
+
+	mirrorRequest, requestErr := http.NewRequestWithContext(context.WithoutCancel(request.Context()), request.Method, mirrorTarget.String(), bytes.NewReader(requestBody))
+	if requestErr != nil {
+		<-mirrors.slots
+		loggingService.Error(logMessageProxyMirrorFailed, requestErr, logging.String(logFieldPath, clientURL.Path), logging.String(logFieldMirror, mirrorTarget.String()))
+		return
+	}
+	mirrorRequest.Header = cloneHeaders(request.Header)
+	mirrorRequest.Header.Del(headerContentLength)
+	if len(requestBody) == 0 {
+		mirrorRequest.Body = http.NoBody
+	}
+	go func() {
+		defer func() { <-mirrors.slots }()
+		startTime := time.Now()
+		mirrorResponse, mirrorErr := mirror.client.Do(mirrorRequest)
+		if mirrorErr != nil {
+			loggingService.Error(logMessageProxyMirrorFailed, mirrorErr, logging.String(logFieldPath, clientURL.Path), logging.String(logFieldMirror, mirrorTarget.String()), logging.Duration(logFieldDuration, time.Since(startTime)))
+			return
+		}
+		_, _ = io.Copy(io.Discard, mirrorResponse.Body)
+		_ = mirrorResponse.Body.Close()
+		loggingService.Info(logMessageProxyMirrorCompleted, logging.String(logFieldPath, clientURL.Path), logging.String(logFieldMirror, mirrorTarget.String()), logging.Int(logFieldStatus, mirrorResponse.StatusCode), logging.Duration(logFieldDuration, time.Since(startTime)))
+	}()
+}
+
+// bufferRequestBody reads at most bodyLimitBytes+1 bytes. The primary request always keeps its full body, whether or
+// not the copy fits the limit.
+func (mirrors ProxyMirrors) bufferRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	originalBody := request.Body
+	bufferedBody, readErr := io.ReadAll(io.LimitReader(originalBody, mirrors.bodyLimitBytes+1))
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{Reader: io.MultiReader(bytes.NewReader(bufferedBody), originalBody), Closer: originalBody}
+	if readErr != nil {
+		return nil, errors.New(proxyMirrorSkipReasonBodyRead)
+	}
+	if int64(len(bufferedBody)) > mirrors.bodyLimitBytes {
+		return nil, errors.New(proxyMirrorSkipReasonBodyLimit)
+	}
+	return bufferedBody, nil
+}
+
+func (mirror proxyMirror) targetURL(requestURL *url.URL) *url.URL {
+	return &url.URL{
+		Scheme:   mirror.mirrorURL.Scheme,
+		Host:     mirror.mirrorURL.Host,
+		Path:     strings.TrimSuffix(mirror.mirrorURL.Path, proxyPathPrefixStart) + requestURL.Path,
+		RawQuery: requestURL.RawQuery,
+	}
+}
+
+func parseProxyMirror(mapping string) (proxyMirror, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	if trimmedMapping == "" {
+		return proxyMirror{}, fmt.Errorf("%w: empty mapping", ErrInvalidProxyMirror)
+	}
+	parts := strings.SplitN(trimmedMapping, proxyMirrorMappingSeparator, 2)
+	if len(parts) != 2 {
+		return proxyMirror{}, fmt.Errorf("%w: mapping must be in /path=http://shadow form", ErrInvalidProxyMirror)
+	}
+
+	pathPrefix := strings.TrimSpace(parts[0])
+	if pathPrefix == "" {
+		return proxyMirror{}, fmt.Errorf("%w: empty path prefix", ErrInvalidProxyMirror)
+	}
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return proxyMirror{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyMirror)
+	}
+	mirrorURL, parseErr := parseProxyBackendURL(strings.TrimSpace(parts[1]))
+	if parseErr != nil {
+		return proxyMirror{}, fmt.Errorf("%w: %s", ErrInvalidProxyMirror, parseErr.Error())
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if strings.EqualFold(mirrorURL.Scheme, proxySchemeH2C) {
+		mirrorURL.Scheme = proxySchemeHTTP
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return proxyMirror{
+		pathPrefix: pathPrefix,
+		mirrorURL:  mirrorURL,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   proxyMirrorRequestTimeout,
+			CheckRedirect: func(request *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
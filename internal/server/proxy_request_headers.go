@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	proxyRequestHeaderMappingSeparator = "="
+	proxyRequestHeaderValueSeparator   = ":"
+)
+
+var ErrInvalidProxyRequestHeader = errors.New("proxy.request.header.invalid")
+
+// ProxyRequestHeaders sets templated request headers on proxied requests per path prefix. Headers of the same name
+// sent by the client are always dropped, so a backend can trust them.
+type ProxyRequestHeaders struct {
+	policies []proxyRequestHeaderPolicy
+}
+
+type proxyRequestHeaderPolicy struct {
+	pathPrefix      string
+	headerTemplates map[string]*template.Template
+}
+
+type proxyRequestHeaderTemplateData struct {
+	Method     string
+	Path       string
+	RemoteAddr string
+	ClientCert clientCertificateDetails
+}
+
+// NewProxyRequestHeaders parses /path=Header-Name:template mappings. Templates use text/template syntax over .Method,
+// .Path, .RemoteAddr, and .ClientCert.
+func NewProxyRequestHeaders(mappings []string) (ProxyRequestHeaders, error) {
+	templatesByPathPrefix := map[string]map[string]*template.Template{}
+	for _, mapping := range mappings {
+		pathPrefix, headerAssignment, found := strings.Cut(strings.TrimSpace(mapping), proxyRequestHeaderMappingSeparator)
+		pathPrefix = strings.TrimSpace(pathPrefix)
+		if !found || !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+			return ProxyRequestHeaders{}, fmt.Errorf("%w: mapping %q must be in /path=Header-Name:template form", ErrInvalidProxyRequestHeader, mapping)
+		}
+		headerName, headerTemplateText, found := strings.Cut(headerAssignment, proxyRequestHeaderValueSeparator)
+		headerName = http.CanonicalHeaderKey(strings.TrimSpace(headerName))
+		if !found || headerName == "" || strings.ContainsAny(headerName, " \t") {
+			return ProxyRequestHeaders{}, fmt.Errorf("%w: mapping %q must be in /path=Header-Name:template form", ErrInvalidProxyRequestHeader, mapping)
+		}
+		headerTemplate, parseErr := template.New(headerName).Option(mockTemplateOption).Parse(strings.TrimSpace(headerTemplateText))
+		if parseErr != nil {
+			return ProxyRequestHeaders{}, fmt.Errorf("%w: header %s template: %s", ErrInvalidProxyRequestHeader, headerName, parseErr.Error())
+		}
+		if templatesByPathPrefix[pathPrefix] == nil {
+			templatesByPathPrefix[pathPrefix] = map[string]*template.Template{}
+		}
+		templatesByPathPrefix[pathPrefix][headerName] = headerTemplate
+	}
+
+	policies := make([]proxyRequestHeaderPolicy, 0, len(templatesByPathPrefix))
+	for pathPrefix, headerTemplates := range templatesByPathPrefix {
+		policies = append(policies, proxyRequestHeaderPolicy{pathPrefix: pathPrefix, headerTemplates: headerTemplates})
+	}
+	sort.SliceStable(policies, func(leftIndex int, rightIndex int) bool {
+		return len(policies[leftIndex].pathPrefix) < len(policies[rightIndex].pathPrefix)
+	})
+	return ProxyRequestHeaders{policies: policies}, nil
+}
+
+// IsEmpty reports whether no request header templates are configured.
+func (headers ProxyRequestHeaders) IsEmpty() bool {
+	return len(headers.policies) == 0
+}
+
+// apply returns the request with every configured header removed and the templates of matching prefixes rendered in
+// their place; longer prefixes win. Headers that render empty or fail to render are left unset.
+func (headers ProxyRequestHeaders) apply(request *http.Request) *http.Request {
+	if headers.IsEmpty() {
+		return request
+	}
+	templateData := proxyRequestHeaderTemplateData{
+		Method:     request.Method,
+		Path:       request.URL.Path,
+		RemoteAddr: request.RemoteAddr,
+		ClientCert: clientCertificateFromContext(request.Context()),
+	}
+	updatedRequest := request.Clone(request.Context())
+	renderedHeaders := map[string]string{}
+	for _, policy := range headers.policies {
+		for headerName, headerTemplate := range policy.headerTemplates {
+			updatedRequest.Header.Del(headerName)
+			if !strings.HasPrefix(request.URL.Path, policy.pathPrefix) {
+				continue
+			}
+			var rendered bytes.Buffer
+			if executeErr := headerTemplate.Execute(&rendered, templateData); executeErr != nil {
+				delete(renderedHeaders, headerName)
+				continue
+			}
+			renderedHeaders[headerName] = strings.Join(strings.Fields(rendered.String()), " ")
+		}
+	}
+	for headerName, headerValue := range renderedHeaders {
+		if headerValue != "" {
+			updatedRequest.Header.Set(headerName, headerValue)
+		}
+	}
+	return updatedRequest
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerLocation        = "Location"
+	headerSetCookie       = "Set-Cookie"
+	headerContentEncoding = "Content-Encoding"
+	headerForwardedPrefix = "X-Forwarded-Prefix"
+	contentEncodingGzip   = "gzip"
+	proxyRewriteMaxBody   = 16 << 20
+)
+
+var (
+	htmlURLAttributePattern       = regexp.MustCompile(`(?i)(\s(?:href|src|action|formaction|poster|data)\s*=\s*)(["'])(/[^"']*)`)
+	htmlSrcsetPattern             = regexp.MustCompile(`(?i)(\ssrcset\s*=\s*)(["'])([^"']*)`)
+	cssURLPattern                 = regexp.MustCompile(`(?i)(url\(\s*["']?|@import\s+["'])(/[^)"'\s]*)`)
+	jsPathLiteralPattern          = regexp.MustCompile("([\"'`])(/[^/\"'`\\s][^\"'`\\s]*)")
+	setCookiePathPattern          = regexp.MustCompile(`(?i)(;\s*path=)(/[^;]*)`)
+	proxyRewriteContentKindByType = map[string]string{
+		"text/html":              proxyRewriteContentHTML,
+		"application/xhtml+xml":  proxyRewriteContentHTML,
+		"text/css":               proxyRewriteContentCSS,
+		"text/javascript":        proxyRewriteContentJS,
+		"application/javascript": proxyRewriteContentJS,
+	}
+)
+
+type proxyRewriteContextKey struct{}
+
+// withProxyRewrite strips the mount prefix from the forwarded request and remembers the policy so the response can be
+// rewritten.
+func withProxyRewrite(request *http.Request, policy proxyRewritePolicy) *http.Request {
+	rewrittenRequest := request.WithContext(context.WithValue(request.Context(), proxyRewriteContextKey{}, policy))
+	rewrittenURL := *request.URL
+	rewrittenURL.Path = stripMountPrefix(request.URL.Path, policy.mountPrefix)
+	if request.URL.RawPath != "" {
+		rewrittenURL.RawPath = stripMountPrefix(request.URL.RawPath, policy.mountPrefix)
+	}
+	rewrittenRequest.URL = &rewrittenURL
+	rewrittenRequest.Header = request.Header.Clone()
+	rewrittenRequest.Header.Set(headerForwardedPrefix, policy.mountPrefix)
+	return rewrittenRequest
+}
+
+func stripMountPrefix(requestPath string, mountPrefix string) string {
+	strippedPath := strings.TrimPrefix(requestPath, mountPrefix)
+	if !strings.HasPrefix(strippedPath, proxyPathPrefixStart) {
+		strippedPath = proxyPathPrefixStart + strippedPath
+	}
+	return strippedPath
+}
+
+// rewriteMountedResponse adds the mount prefix to redirect and cookie paths and, when the route buffers responses, to
+// URLs in HTML, CSS and JavaScript bodies. Paths that already start with the prefix are kept, and bodies larger than
+// proxyRewriteMaxBody, compressed or not, pass through unchanged.
+func rewriteMountedResponse(response *http.Response, rewriteBody bool) error {
+	policy, mounted := response.Request.Context().Value(proxyRewriteContextKey{}).(proxyRewritePolicy)
+	if !mounted {
+		return nil
+	}
+	if location := response.Header.Get(headerLocation); location != "" {
+		response.Header.Set(headerLocation, rewriteLocation(location, response.Request.URL, policy.mountPrefix))
+	}
+	for cookieIndex, setCookie := range response.Header.Values(headerSetCookie) {
+		response.Header[headerSetCookie][cookieIndex] = setCookiePathPattern.ReplaceAllStringFunc(setCookie, func(match string) string {
+			submatches := setCookiePathPattern.FindStringSubmatch(match)
+			if isMountedPath(submatches[2], policy.mountPrefix) {
+				return match
+			}
+			return submatches[1] + policy.mountPrefix + submatches[2]
+		})
+	}
+	if !rewriteBody || response.Body == nil || response.Body == http.NoBody {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get(headerContentType))
+	contentKind := proxyRewriteContentKindByType[strings.ToLower(mediaType)]
+	if !policy.rewritesContent(contentKind) {
+		return nil
+	}
+	contentEncoding := strings.ToLower(strings.TrimSpace(response.Header.Get(headerContentEncoding)))
+	if contentEncoding != "" && contentEncoding != contentEncodingGzip {
+		return nil
+	}
+
+	encodedBody, readErr := io.ReadAll(io.LimitReader(response.Body, proxyRewriteMaxBody+1))
+	if readErr != nil {
+		return readErr
+	}
+	if len(encodedBody) > proxyRewriteMaxBody {
+		response.Body = struct {
+			io.Reader
+			io.Closer
+		}{Reader: io.MultiReader(bytes.NewReader(encodedBody), response.Body), Closer: response.Body}
+		return nil
+	}
+	_ = response.Body.Close()
+	decodedBody := encodedBody
+	if contentEncoding == contentEncodingGzip {
+		gzipReader, gzipErr := gzip.NewReader(bytes.NewReader(encodedBody))
+		if gzipErr != nil {
+			return gzipErr
+		}
+		if decodedBody, readErr = io.ReadAll(io.LimitReader(gzipReader, proxyRewriteMaxBody+1)); readErr != nil {
+			return readErr
+		}
+		if len(decodedBody) > proxyRewriteMaxBody {
+			response.Body = io.NopCloser(bytes.NewReader(encodedBody))
+			return nil
+		}
+	}
+
+	rewrittenBody := rewriteMountedContent(string(decodedBody), contentKind, policy.mountPrefix)
+	responseBody := []byte(rewrittenBody)
+	if contentEncoding == contentEncodingGzip {
+		var compressedBody bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressedBody)
+		_, _ = gzipWriter.Write(responseBody)
+		_ = gzipWriter.Close()
+		responseBody = compressedBody.Bytes()
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+	response.ContentLength = int64(len(responseBody))
+	response.Header.Set(headerContentLength, strconv.Itoa(len(responseBody)))
+	return nil
+}
+
+// isMountedPath reports paths that already carry the mount prefix, as produced by backends that honor
+// X-Forwarded-Prefix; they are left alone so the prefix is not added twice.
+func isMountedPath(requestPath string, mountPrefix string) bool {
+	return requestPath == mountPrefix || strings.HasPrefix(requestPath, mountPrefix+proxyPathPrefixStart)
+}
+
+func rewriteMountedContent(content string, contentKind string, mountPrefix string) string {
+	prefixPath := func(matchedPath string) string {
+		if strings.HasPrefix(matchedPath, "//") || isMountedPath(matchedPath, mountPrefix) {
+			return matchedPath
+		}
+		return mountPrefix + matchedPath
+	}
+	switch contentKind {
+	case proxyRewriteContentHTML:
+		content = htmlURLAttributePattern.ReplaceAllStringFunc(content, func(match string) string {
+			submatches := htmlURLAttributePattern.FindStringSubmatch(match)
+			return submatches[1] + submatches[2] + prefixPath(submatches[3])
+		})
+		content = htmlSrcsetPattern.ReplaceAllStringFunc(content, func(match string) string {
+			submatches := htmlSrcsetPattern.FindStringSubmatch(match)
+			candidates := strings.Split(submatches[3], ",")
+			for candidateIndex, candidate := range candidates {
+				trimmedCandidate := strings.TrimLeft(candidate, " \t\n")
+				if strings.HasPrefix(trimmedCandidate, proxyPathPrefixStart) {
+					candidates[candidateIndex] = candidate[:len(candidate)-len(trimmedCandidate)] + prefixPath(trimmedCandidate)
+				}
+			}
+			return submatches[1] + submatches[2] + strings.Join(candidates, ",")
+		})
+		return rewriteCSSURLs(content, prefixPath)
+	case proxyRewriteContentCSS:
+		return rewriteCSSURLs(content, prefixPath)
+	default:
+		return jsPathLiteralPattern.ReplaceAllStringFunc(content, func(match string) string {
+			return match[:1] + prefixPath(match[1:])
+		})
+	}
+}
+
+// rewriteCSSURLs handles stylesheets and inline style blocks or attributes inside HTML.
+func rewriteCSSURLs(content string, prefixPath func(string) string) string {
+	return cssURLPattern.ReplaceAllStringFunc(content, func(match string) string {
+		submatches := cssURLPattern.FindStringSubmatch(match)
+		return submatches[1] + prefixPath(submatches[2])
+	})
+}
+
+// rewriteLocation mounts path-absolute redirects and redirects that point back at the backend host. Redirects already
+// under the mount prefix only lose the backend host.
+func rewriteLocation(location string, backendURL *url.URL, mountPrefix string) string {
+	parsedLocation, parseErr := url.Parse(location)
+	if parseErr != nil {
+		return location
+	}
+	if parsedLocation.Host != "" {
+		if !strings.EqualFold(parsedLocation.Host, backendURL.Host) {
+			return location
+		}
+		parsedLocation.Scheme = ""
+		parsedLocation.Host = ""
+	}
+	if !strings.HasPrefix(parsedLocation.Path, proxyPathPrefixStart) {
+		return location
+	}
+	if isMountedPath(parsedLocation.Path, mountPrefix) {
+		return parsedLocation.String()
+	}
+	parsedLocation.Path = mountPrefix + parsedLocation.Path
+	if parsedLocation.RawPath != "" {
+		parsedLocation.RawPath = mountPrefix + parsedLocation.RawPath
+	}
+	return parsedLocation.String()
+}
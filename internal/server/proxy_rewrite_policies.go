@@ -0,0 +1,119 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	proxyRewritePolicyMappingSeparator = "="
+	proxyRewriteContentSeparator       = "+"
+	proxyRewriteContentHTML            = "html"
+	proxyRewriteContentCSS             = "css"
+	proxyRewriteContentJS              = "js"
+)
+
+var ErrInvalidProxyRewritePolicy = errors.New("proxy.rewrite.policy.invalid")
+
+// ProxyRewritePolicies mounts backends that assume they live at / under a sub-path: the mount prefix is stripped from
+// forwarded requests and added back to URLs in responses.
+type ProxyRewritePolicies struct {
+	policies []proxyRewritePolicy
+}
+
+type proxyRewritePolicy struct {
+	pathPrefix  string
+	mountPrefix string
+	rewriteHTML bool
+	rewriteCSS  bool
+	rewriteJS   bool
+}
+
+func NewProxyRewritePolicies(mappings []string) (ProxyRewritePolicies, error) {
+	if len(mappings) == 0 {
+		return ProxyRewritePolicies{}, nil
+	}
+	policyByPathPrefix := map[string]proxyRewritePolicy{}
+	for _, mapping := range mappings {
+		parsedPolicy, parseErr := parseProxyRewritePolicy(mapping)
+		if parseErr != nil {
+			return ProxyRewritePolicies{}, parseErr
+		}
+		policyByPathPrefix[parsedPolicy.pathPrefix] = parsedPolicy
+	}
+
+	policies := make([]proxyRewritePolicy, 0, len(policyByPathPrefix))
+	for _, policy := range policyByPathPrefix {
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(leftIndex int, rightIndex int) bool {
+		return len(policies[leftIndex].pathPrefix) > len(policies[rightIndex].pathPrefix)
+	})
+	return ProxyRewritePolicies{policies: policies}, nil
+}
+
+func (policies ProxyRewritePolicies) IsEmpty() bool {
+	return len(policies.policies) == 0
+}
+
+func (policies ProxyRewritePolicies) policyFor(requestPath string) (proxyRewritePolicy, bool) {
+	for _, policy := range policies.policies {
+		if strings.HasPrefix(requestPath, policy.pathPrefix) {
+			return policy, true
+		}
+	}
+	return proxyRewritePolicy{}, false
+}
+
+func (policy proxyRewritePolicy) rewritesContent(contentKind string) bool {
+	switch contentKind {
+	case proxyRewriteContentHTML:
+		return policy.rewriteHTML
+	case proxyRewriteContentCSS:
+		return policy.rewriteCSS
+	case proxyRewriteContentJS:
+		return policy.rewriteJS
+	default:
+		return false
+	}
+}
+
+func parseProxyRewritePolicy(mapping string) (proxyRewritePolicy, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	if trimmedMapping == "" {
+		return proxyRewritePolicy{}, fmt.Errorf("%w: empty mapping", ErrInvalidProxyRewritePolicy)
+	}
+	parts := strings.SplitN(trimmedMapping, proxyRewritePolicyMappingSeparator, 2)
+	if len(parts) != 2 {
+		return proxyRewritePolicy{}, fmt.Errorf("%w: mapping must be in /path=html[+css][+js] form", ErrInvalidProxyRewritePolicy)
+	}
+
+	pathPrefix := strings.TrimSpace(parts[0])
+	if pathPrefix == "" {
+		return proxyRewritePolicy{}, fmt.Errorf("%w: empty path prefix", ErrInvalidProxyRewritePolicy)
+	}
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return proxyRewritePolicy{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyRewritePolicy)
+	}
+	mountPrefix := strings.TrimRight(pathPrefix, proxyPathPrefixStart)
+	if mountPrefix == "" {
+		return proxyRewritePolicy{}, fmt.Errorf("%w: cannot mount at the root path", ErrInvalidProxyRewritePolicy)
+	}
+
+	policy := proxyRewritePolicy{pathPrefix: pathPrefix, mountPrefix: mountPrefix}
+	for _, contentKind := range strings.Split(parts[1], proxyRewriteContentSeparator) {
+		switch strings.ToLower(strings.TrimSpace(contentKind)) {
+		case proxyRewriteContentHTML:
+			policy.rewriteHTML = true
+		case proxyRewriteContentCSS:
+			policy.rewriteCSS = true
+		case proxyRewriteContentJS:
+			policy.rewriteJS = true
+		default:
+			return proxyRewritePolicy{}, fmt.Errorf("%w: unsupported content kind %s", ErrInvalidProxyRewritePolicy, contentKind)
+		}
+	}
+	return policy, nil
+}
@@ -13,6 +13,10 @@ const (
 	proxyPathPrefixStart  = "/"
 	proxySchemeHTTP       = "http"
 	proxySchemeHTTPS      = "https"
+	proxySchemeH2C        = "h2c"
+	proxySchemeUnix       = "unix"
+	proxySchemeHTTPUnix   = "http+unix"
+	unixSocketBackendHost = "localhost"
 )
 
 var (
@@ -25,8 +29,10 @@ type ProxyRoutes struct {
 }
 
 type proxyRoute struct {
-	pathPrefix string
-	backendURL *url.URL
+	pathPrefix            string
+	backendURL            *url.URL
+	backendSocketPath     string
+	backendHTTP2Cleartext bool
 }
 
 func NewProxyRoutes(routeMappings []string) (ProxyRoutes, error) {
@@ -90,13 +96,29 @@ func newProxyRoute(pathPrefix string, backendURL string) (proxyRoute, error) {
 	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
 		return proxyRoute{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyRoute)
 	}
+	if isUnixSocketBackendURL(backendURL) {
+		parsedURL, socketPath, parseErr := parseUnixSocketBackendURL(backendURL)
+		if parseErr != nil {
+			return proxyRoute{}, parseErr
+		}
+		return proxyRoute{
+			pathPrefix:        pathPrefix,
+			backendURL:        parsedURL,
+			backendSocketPath: socketPath,
+		}, nil
+	}
 	parsedURL, parseErr := parseProxyBackendURL(backendURL)
 	if parseErr != nil {
 		return proxyRoute{}, parseErr
 	}
+	backendHTTP2Cleartext := strings.EqualFold(parsedURL.Scheme, proxySchemeH2C)
+	if backendHTTP2Cleartext {
+		parsedURL.Scheme = proxySchemeHTTP
+	}
 	return proxyRoute{
-		pathPrefix: pathPrefix,
-		backendURL: parsedURL,
+		pathPrefix:            pathPrefix,
+		backendURL:            parsedURL,
+		backendHTTP2Cleartext: backendHTTP2Cleartext,
 	}, nil
 }
 
@@ -108,8 +130,8 @@ func parseProxyBackendURL(backendURL string) (*url.URL, error) {
 	if parseErr != nil {
 		return nil, fmt.Errorf("%w: parse backend url: %s", ErrInvalidProxyRoute, parseErr.Error())
 	}
-	if !strings.EqualFold(parsedURL.Scheme, proxySchemeHTTP) && !strings.EqualFold(parsedURL.Scheme, proxySchemeHTTPS) {
-		return nil, fmt.Errorf("%w: backend url must use http or https", ErrInvalidProxyRoute)
+	if !strings.EqualFold(parsedURL.Scheme, proxySchemeHTTP) && !strings.EqualFold(parsedURL.Scheme, proxySchemeHTTPS) && !strings.EqualFold(parsedURL.Scheme, proxySchemeH2C) {
+		return nil, fmt.Errorf("%w: backend url must use http, https, h2c, unix or http+unix", ErrInvalidProxyRoute)
 	}
 	if parsedURL.Host == "" {
 		return nil, fmt.Errorf("%w: backend url must include host", ErrInvalidProxyRoute)
@@ -120,3 +142,47 @@ func parseProxyBackendURL(backendURL string) (*url.URL, error) {
 	}
 	return parsedURL, nil
 }
+
+func isUnixSocketBackendURL(backendURL string) bool {
+	lowerBackendURL := strings.ToLower(strings.TrimSpace(backendURL))
+	return strings.HasPrefix(lowerBackendURL, proxySchemeUnix+"://") || strings.HasPrefix(lowerBackendURL, proxySchemeHTTPUnix+"://")
+}
+
+// parseUnixSocketBackendURL accepts unix:///path/to.sock, which forwards request paths unchanged, and
+// http+unix://%2Fpath%2Fto.sock/base, where the percent-encoded host names the socket and the path is the
+// backend base path. The returned URL addresses the backend over plain HTTP on the socket.
+func parseUnixSocketBackendURL(backendURL string) (*url.URL, string, error) {
+	trimmedBackendURL := strings.TrimSpace(backendURL)
+	schemeSeparatorIndex := strings.Index(trimmedBackendURL, "://")
+	scheme := strings.ToLower(trimmedBackendURL[:schemeSeparatorIndex])
+	remainder := trimmedBackendURL[schemeSeparatorIndex+len("://"):]
+
+	socketPath := ""
+	basePath := ""
+	switch scheme {
+	case proxySchemeUnix:
+		if !strings.HasPrefix(remainder, proxyPathPrefixStart) {
+			return nil, "", fmt.Errorf("%w: unix backend url must be in unix:///path/to.sock form", ErrInvalidProxyRoute)
+		}
+		socketPath = remainder
+	default:
+		encodedSocketPath, encodedBasePath, _ := strings.Cut(remainder, proxyPathPrefixStart)
+		decodedSocketPath, unescapeErr := url.PathUnescape(encodedSocketPath)
+		if unescapeErr != nil {
+			return nil, "", fmt.Errorf("%w: parse unix socket path: %s", ErrInvalidProxyRoute, unescapeErr.Error())
+		}
+		socketPath = decodedSocketPath
+		if encodedBasePath != "" {
+			basePath = proxyPathPrefixStart + encodedBasePath
+		}
+	}
+	if strings.ContainsAny(socketPath, "?#") || strings.TrimSpace(socketPath) == "" {
+		return nil, "", fmt.Errorf("%w: backend url must include a unix socket path", ErrInvalidProxyRoute)
+	}
+
+	parsedURL, parseErr := url.Parse(proxySchemeHTTP + "://" + unixSocketBackendHost + basePath)
+	if parseErr != nil {
+		return nil, "", fmt.Errorf("%w: parse backend url: %s", ErrInvalidProxyRoute, parseErr.Error())
+	}
+	return parsedURL, socketPath, nil
+}
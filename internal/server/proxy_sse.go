@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	mediaTypeEventStream      = "text/event-stream"
+	headerAcceptEncoding      = "Accept-Encoding"
+	headerLastEventID         = "Last-Event-ID"
+	headerAccelBuffering      = "X-Accel-Buffering"
+	contentEncodingIdentity   = "identity"
+	sseHeartbeatComment       = ": heartbeat\n\n"
+	sseHeartbeatChecksPerIdle = 4
+
+	logMessageProxySSEEnded   = "proxy sse stream ended"
+	logFieldEvents            = "events"
+	logFieldHeartbeats        = "heartbeats"
+	logFieldLastEventID       = "last_event_id"
+	sseEndReasonClientGone    = "client disconnected"
+	sseEndReasonBackendClosed = "backend closed"
+)
+
+// serveServerSentEvents proxies a request on an sse streaming route. Event-stream responses get heartbeat comments
+// while the backend is idle, and a client that goes away cancels the backend request; other responses pass through.
+func serveServerSentEvents(responseWriter http.ResponseWriter, request *http.Request, reverseProxy *httputil.ReverseProxy, heartbeatInterval time.Duration, loggingService *logging.Service) {
+	backendContext, cancelBackend := context.WithCancel(request.Context())
+	defer cancelBackend()
+	backendRequest := request.WithContext(backendContext)
+	backendRequest.Header = request.Header.Clone()
+	backendRequest.Header.Set(headerAcceptEncoding, contentEncodingIdentity)
+
+	startTime := time.Now()
+	eventWriter := &serverSentEventsWriter{
+		ResponseWriter:  responseWriter,
+		cancelBackend:   cancelBackend,
+		lastWriteAt:     startTime,
+		lineEmpty:       true,
+		atEventBoundary: true,
+	}
+	stopHeartbeats := make(chan struct{})
+	heartbeatsStopped := make(chan struct{})
+	go func() {
+		defer close(heartbeatsStopped)
+		heartbeatTicker := time.NewTicker(max(heartbeatInterval/sseHeartbeatChecksPerIdle, time.Millisecond))
+		defer heartbeatTicker.Stop()
+		for {
+			select {
+			case <-stopHeartbeats:
+				return
+			case tickTime := <-heartbeatTicker.C:
+				eventWriter.sendHeartbeatIfIdle(tickTime, heartbeatInterval)
+			}
+		}
+	}()
+	// The reverse proxy aborts the handler with a panic when the client disappears mid-stream, so the stream summary
+	// is written from a deferred function.
+	defer func() {
+		close(stopHeartbeats)
+		<-heartbeatsStopped
+		eventWriter.mutex.Lock()
+		defer eventWriter.mutex.Unlock()
+		if !eventWriter.streaming {
+			return
+		}
+		endReason := sseEndReasonBackendClosed
+		if eventWriter.writeErr != nil || request.Context().Err() != nil {
+			endReason = sseEndReasonClientGone
+		}
+		loggingService.Info(
+			logMessageProxySSEEnded,
+			logging.String(logFieldPath, request.URL.Path),
+			logging.Duration(logFieldDuration, time.Since(startTime)),
+			logging.Int(logFieldEvents, eventWriter.eventCount),
+			logging.Int(logFieldHeartbeats, eventWriter.heartbeatCount),
+			logging.String(logFieldLastEventID, request.Header.Get(headerLastEventID)),
+			logging.String(logFieldReason, endReason),
+		)
+	}()
+	reverseProxy.ServeHTTP(eventWriter, backendRequest)
+}
+
+// serverSentEventsWriter serializes backend writes and heartbeats, and follows the event framing so heartbeats are
+// only inserted between events.
+type serverSentEventsWriter struct {
+	http.ResponseWriter
+	mutex           sync.Mutex
+	cancelBackend   context.CancelFunc
+	wroteHeader     bool
+	streaming       bool
+	lastWriteAt     time.Time
+	lineEmpty       bool
+	lineIsComment   bool
+	eventHasFields  bool
+	atEventBoundary bool
+	eventCount      int
+	heartbeatCount  int
+	writeErr        error
+}
+
+func (writer *serverSentEventsWriter) WriteHeader(statusCode int) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.writeHeaderLocked(statusCode)
+}
+
+func (writer *serverSentEventsWriter) writeHeaderLocked(statusCode int) {
+	if statusCode < http.StatusOK {
+		writer.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if writer.wroteHeader {
+		return
+	}
+	writer.wroteHeader = true
+	mediaType, _, _ := mime.ParseMediaType(writer.Header().Get(headerContentType))
+	if strings.EqualFold(mediaType, mediaTypeEventStream) {
+		writer.streaming = true
+		writer.Header().Del(headerContentLength)
+		writer.Header().Set(headerAccelBuffering, "no")
+		if writer.Header().Get(headerCacheControl) == "" {
+			writer.Header().Set(headerCacheControl, cacheDirectiveNoCache)
+		}
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *serverSentEventsWriter) Write(payload []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if !writer.wroteHeader {
+		writer.writeHeaderLocked(http.StatusOK)
+	}
+	writtenBytes, writeErr := writer.ResponseWriter.Write(payload)
+	if writer.streaming {
+		writer.trackEvents(payload[:writtenBytes])
+		writer.lastWriteAt = time.Now()
+	}
+	if writeErr != nil {
+		writer.writeErr = writeErr
+	}
+	return writtenBytes, writeErr
+}
+
+func (writer *serverSentEventsWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	_ = http.NewResponseController(writer.ResponseWriter).Flush()
+}
+
+// trackEvents counts dispatched events (blank-line terminated blocks with at least one field line; comment-only
+// blocks are not events) and records whether the stream currently sits between events.
+func (writer *serverSentEventsWriter) trackEvents(payload []byte) {
+	for _, payloadByte := range payload {
+		switch payloadByte {
+		case '\r':
+			continue
+		case '\n':
+			if writer.lineEmpty {
+				if writer.eventHasFields {
+					writer.eventCount++
+				}
+				writer.eventHasFields = false
+				writer.atEventBoundary = true
+				continue
+			}
+			if !writer.lineIsComment {
+				writer.eventHasFields = true
+			}
+			writer.lineEmpty = true
+		default:
+			if writer.lineEmpty {
+				writer.lineIsComment = payloadByte == ':'
+			}
+			writer.lineEmpty = false
+			writer.atEventBoundary = false
+		}
+	}
+}
+
+// sendHeartbeatIfIdle writes a comment when nothing was written for the heartbeat interval. A failed write means the
+// client is gone, so the backend request is cancelled.
+func (writer *serverSentEventsWriter) sendHeartbeatIfIdle(now time.Time, heartbeatInterval time.Duration) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if !writer.streaming || !writer.atEventBoundary || writer.writeErr != nil || now.Sub(writer.lastWriteAt) < heartbeatInterval {
+		return
+	}
+	writer.lastWriteAt = now
+	_, writeErr := writer.ResponseWriter.Write([]byte(sseHeartbeatComment))
+	if writeErr == nil {
+		writeErr = http.NewResponseController(writer.ResponseWriter).Flush()
+	}
+	if writeErr != nil {
+		writer.writeErr = writeErr
+		writer.cancelBackend()
+		return
+	}
+	writer.heartbeatCount++
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 const (
 	proxyStreamingPolicyMappingSeparator = "="
 	proxyStreamingModeBuffered           = "buffered"
 	proxyStreamingModeUnbuffered         = "unbuffered"
+	proxyStreamingModeSSE                = "sse"
+	proxyStreamingOptionSeparator        = ":"
+	defaultSSEHeartbeatInterval          = 15 * time.Second
 )
 
 var ErrInvalidProxyStreamingPolicy = errors.New("proxy.streaming.policy.invalid")
@@ -22,6 +26,7 @@ type ProxyStreamingPolicies struct {
 type proxyStreamingPolicy struct {
 	pathPrefix   string
 	unbufferedIO bool
+	sseHeartbeat time.Duration
 }
 
 func NewProxyStreamingPolicies(mappings []string) (ProxyStreamingPolicies, error) {
@@ -48,12 +53,28 @@ func NewProxyStreamingPolicies(mappings []string) (ProxyStreamingPolicies, error
 }
 
 func (policies ProxyStreamingPolicies) IsUnbuffered(requestPath string) bool {
+	return policies.isUnbufferedWithDefault(requestPath, false)
+}
+
+// isUnbufferedWithDefault applies the matching policy, falling back to defaultUnbuffered when no policy matches.
+func (policies ProxyStreamingPolicies) isUnbufferedWithDefault(requestPath string, defaultUnbuffered bool) bool {
 	for _, policy := range policies.policies {
 		if strings.HasPrefix(requestPath, policy.pathPrefix) {
 			return policy.unbufferedIO
 		}
 	}
-	return false
+	return defaultUnbuffered
+}
+
+// sseHeartbeatInterval reports whether the matching policy is in sse mode and how long a stream may stay idle before
+// a heartbeat comment is sent.
+func (policies ProxyStreamingPolicies) sseHeartbeatInterval(requestPath string) (time.Duration, bool) {
+	for _, policy := range policies.policies {
+		if strings.HasPrefix(requestPath, policy.pathPrefix) {
+			return policy.sseHeartbeat, policy.sseHeartbeat > 0
+		}
+	}
+	return 0, false
 }
 
 func parseProxyStreamingPolicy(mapping string) (proxyStreamingPolicy, error) {
@@ -63,7 +84,7 @@ func parseProxyStreamingPolicy(mapping string) (proxyStreamingPolicy, error) {
 	}
 	parts := strings.SplitN(trimmedMapping, proxyStreamingPolicyMappingSeparator, 2)
 	if len(parts) != 2 {
-		return proxyStreamingPolicy{}, fmt.Errorf("%w: mapping must be in /path=unbuffered|buffered form", ErrInvalidProxyStreamingPolicy)
+		return proxyStreamingPolicy{}, fmt.Errorf("%w: mapping must be in /path=unbuffered|buffered|sse[:interval] form", ErrInvalidProxyStreamingPolicy)
 	}
 
 	pathPrefix := strings.TrimSpace(parts[0])
@@ -74,12 +95,25 @@ func parseProxyStreamingPolicy(mapping string) (proxyStreamingPolicy, error) {
 		return proxyStreamingPolicy{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyStreamingPolicy)
 	}
 
-	mode := strings.ToLower(strings.TrimSpace(parts[1]))
+	mode, heartbeatOption, hasHeartbeatOption := strings.Cut(strings.ToLower(strings.TrimSpace(parts[1])), proxyStreamingOptionSeparator)
+	if hasHeartbeatOption && mode != proxyStreamingModeSSE {
+		return proxyStreamingPolicy{}, fmt.Errorf("%w: only sse mode accepts an interval", ErrInvalidProxyStreamingPolicy)
+	}
 	switch mode {
 	case proxyStreamingModeBuffered:
 		return proxyStreamingPolicy{pathPrefix: pathPrefix, unbufferedIO: false}, nil
 	case proxyStreamingModeUnbuffered:
 		return proxyStreamingPolicy{pathPrefix: pathPrefix, unbufferedIO: true}, nil
+	case proxyStreamingModeSSE:
+		heartbeatInterval := defaultSSEHeartbeatInterval
+		if hasHeartbeatOption {
+			parsedInterval, parseErr := time.ParseDuration(strings.TrimSpace(heartbeatOption))
+			if parseErr != nil || parsedInterval <= 0 {
+				return proxyStreamingPolicy{}, fmt.Errorf("%w: invalid sse heartbeat interval %s", ErrInvalidProxyStreamingPolicy, heartbeatOption)
+			}
+			heartbeatInterval = parsedInterval
+		}
+		return proxyStreamingPolicy{pathPrefix: pathPrefix, unbufferedIO: true, sseHeartbeat: heartbeatInterval}, nil
 	default:
 		return proxyStreamingPolicy{}, fmt.Errorf("%w: unsupported mode %s", ErrInvalidProxyStreamingPolicy, mode)
 	}
@@ -0,0 +1,420 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	proxyRecordingFileExtension    = ".json"
+	proxyRecordingTimeLayout       = "20060102T150405.000000000Z"
+	proxyRecordingRedactedValue    = "REDACTED"
+	proxyRecordingMaxBody          = 1 << 20
+	proxyReplayMatchMethod         = "method"
+	proxyReplayMatchPath           = "path"
+	proxyReplayMatchQuery          = "query"
+	proxyReplayMatchBody           = "body"
+	proxyReplayMissFail            = "fail"
+	proxyReplayMissPassthrough     = "passthrough"
+	logMessageProxyRecordingFailed = "proxy recording failed"
+)
+
+var (
+	ErrInvalidProxyRecording = errors.New("proxy.recording.invalid")
+	ErrInvalidProxyReplay    = errors.New("proxy.replay.invalid")
+	errProxyReplayMiss       = errors.New("no recorded exchange matches the request")
+)
+
+// proxyExchange is the on-disk JSON-per-exchange format shared by recording and replay.
+type proxyExchange struct {
+	RecordedAt string                `json:"recorded_at"`
+	Request    proxyExchangeRequest  `json:"request"`
+	Response   proxyExchangeResponse `json:"response"`
+}
+
+type proxyExchangeRequest struct {
+	Method     string      `json:"method"`
+	Host       string      `json:"host"`
+	Path       string      `json:"path"`
+	Query      string      `json:"query"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"body_base64,omitempty"`
+	BodySHA256 string      `json:"body_sha256"`
+}
+
+type proxyExchangeResponse struct {
+	Status     int         `json:"status"`
+	Headers    http.Header `json:"headers"`
+	Trailers   http.Header `json:"trailers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"body_base64,omitempty"`
+	// Truncated marks a body cut at proxyRecordingMaxBody; Streamed marks an event-stream or gRPC response, which is
+	// recorded when its headers arrive and without a body.
+	Truncated bool `json:"truncated,omitempty"`
+	Streamed  bool `json:"streamed,omitempty"`
+}
+
+// ProxyTrafficRecorder writes every proxied request/response pair to a directory, one JSON file per exchange.
+type ProxyTrafficRecorder struct {
+	directoryPath   string
+	redactedHeaders map[string]struct{}
+	sequence        atomic.Uint64
+}
+
+// NewProxyTrafficRecorder creates the recording directory when needed. Values of redactedHeaders are replaced
+// before anything is written to disk.
+func NewProxyTrafficRecorder(directoryPath string, redactedHeaders []string) (*ProxyTrafficRecorder, error) {
+	if strings.TrimSpace(directoryPath) == "" {
+		return nil, fmt.Errorf("%w: empty recording directory", ErrInvalidProxyRecording)
+	}
+	if mkdirErr := os.MkdirAll(directoryPath, 0o755); mkdirErr != nil {
+		return nil, fmt.Errorf("%w: create recording directory %s: %s", ErrInvalidProxyRecording, directoryPath, mkdirErr.Error())
+	}
+	redactedHeaderSet := make(map[string]struct{}, len(redactedHeaders))
+	for _, headerName := range redactedHeaders {
+		trimmedHeaderName := strings.TrimSpace(headerName)
+		if trimmedHeaderName == "" {
+			return nil, fmt.Errorf("%w: empty redacted header name", ErrInvalidProxyRecording)
+		}
+		redactedHeaderSet[http.CanonicalHeaderKey(trimmedHeaderName)] = struct{}{}
+	}
+	return &ProxyTrafficRecorder{directoryPath: directoryPath, redactedHeaders: redactedHeaderSet}, nil
+}
+
+func (recorder *ProxyTrafficRecorder) wrapTransport(transport http.RoundTripper, loggingService *logging.Service) http.RoundTripper {
+	return &recordingTransport{recorder: recorder, transport: transport, loggingService: loggingService}
+}
+
+type recordingTransport struct {
+	recorder       *ProxyTrafficRecorder
+	transport      http.RoundTripper
+	loggingService *logging.Service
+}
+
+// RoundTrip records the exchange. gRPC request bodies may stream for the life of the call, so they are passed
+// through unread and recorded as empty.
+func (transport *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if !isGRPCRequest(request) {
+		var readErr error
+		if requestBody, readErr = readAndRestoreRequestBody(request); readErr != nil {
+			return nil, readErr
+		}
+	}
+	response, roundTripErr := transport.transport.RoundTrip(request)
+	if roundTripErr != nil {
+		return nil, roundTripErr
+	}
+	exchange := proxyExchange{
+		RecordedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Request: proxyExchangeRequest{
+			Method:     request.Method,
+			Host:       request.URL.Host,
+			Path:       request.URL.Path,
+			Query:      request.URL.RawQuery,
+			Headers:    transport.recorder.redactHeaders(request.Header),
+			BodySHA256: hashRequestBody(requestBody),
+		},
+		Response: proxyExchangeResponse{
+			Status:  response.StatusCode,
+			Headers: transport.recorder.redactHeaders(response.Header),
+		},
+	}
+	exchange.Request.Body, exchange.Request.BodyBase64 = encodeExchangeBody(requestBody)
+	recordingBody := &recordingResponseBody{
+		source:    response.Body,
+		response:  response,
+		exchange:  exchange,
+		transport: transport,
+	}
+	if isStreamedResponse(response) {
+		recordingBody.exchange.Response.Streamed = true
+		recordingBody.writeExchange()
+		return response, nil
+	}
+	response.Body = recordingBody
+	return response, nil
+}
+
+// isStreamedResponse reports event streams and gRPC responses, whose bodies can stay open indefinitely.
+func isStreamedResponse(response *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get(headerContentType))
+	return strings.EqualFold(mediaType, mediaTypeEventStream) || strings.HasPrefix(strings.ToLower(mediaType), contentTypeGRPC)
+}
+
+// recordingResponseBody captures the response body as the proxy streams it and writes the exchange once the body
+// has been fully read or closed, or as soon as it outgrows proxyRecordingMaxBody, so a long-lived response neither
+// grows the capture without bound nor goes unrecorded.
+type recordingResponseBody struct {
+	source    io.ReadCloser
+	response  *http.Response
+	exchange  proxyExchange
+	transport *recordingTransport
+	captured  bytes.Buffer
+	writeOnce sync.Once
+}
+
+func (body *recordingResponseBody) Read(destination []byte) (int, error) {
+	readCount, readErr := body.source.Read(destination)
+	if capturedCount := body.captured.Len(); capturedCount+readCount > proxyRecordingMaxBody {
+		body.captured.Write(destination[:max(proxyRecordingMaxBody-capturedCount, 0)])
+		body.exchange.Response.Truncated = true
+		body.writeExchange()
+	} else {
+		body.captured.Write(destination[:readCount])
+	}
+	if readErr == io.EOF {
+		body.writeExchange()
+	}
+	return readCount, readErr
+}
+
+func (body *recordingResponseBody) Close() error {
+	body.writeExchange()
+	return body.source.Close()
+}
+
+func (body *recordingResponseBody) writeExchange() {
+	body.writeOnce.Do(func() {
+		body.exchange.Response.Body, body.exchange.Response.BodyBase64 = encodeExchangeBody(body.captured.Bytes())
+		if len(body.response.Trailer) > 0 && !body.exchange.Response.Streamed {
+			body.exchange.Response.Trailers = body.transport.recorder.redactHeaders(body.response.Trailer)
+		}
+		if writeErr := body.transport.recorder.write(body.exchange); writeErr != nil {
+			body.transport.loggingService.Error(logMessageProxyRecordingFailed, writeErr, logging.String(logFieldPath, body.exchange.Request.Path))
+		}
+	})
+}
+
+func (recorder *ProxyTrafficRecorder) write(exchange proxyExchange) error {
+	sequence := recorder.sequence.Add(1)
+	fileName := fmt.Sprintf("%s-%06d-%s-%s%s", time.Now().UTC().Format(proxyRecordingTimeLayout), sequence, exchange.Request.Method, sanitizeRecordingPath(exchange.Request.Path), proxyRecordingFileExtension)
+	exchangeBytes, marshalErr := json.MarshalIndent(exchange, "", "  ")
+	if marshalErr != nil {
+		return fmt.Errorf("encode recorded exchange: %w", marshalErr)
+	}
+	if writeErr := os.WriteFile(filepath.Join(recorder.directoryPath, fileName), exchangeBytes, 0o600); writeErr != nil {
+		return fmt.Errorf("write recorded exchange: %w", writeErr)
+	}
+	return nil
+}
+
+func (recorder *ProxyTrafficRecorder) redactHeaders(headers http.Header) http.Header {
+	redactedHeaders := headers.Clone()
+	for headerName := range redactedHeaders {
+		if _, redacted := recorder.redactedHeaders[http.CanonicalHeaderKey(headerName)]; redacted {
+			redactedHeaders[headerName] = []string{proxyRecordingRedactedValue}
+		}
+	}
+	return redactedHeaders
+}
+
+// ProxyTrafficReplayer answers proxied requests from a directory of recorded exchanges.
+type ProxyTrafficReplayer struct {
+	matchFields     map[string]struct{}
+	passthroughMiss bool
+	mutex           sync.Mutex
+	exchangesByKey  map[string][]proxyExchange
+	servedByKey     map[string]int
+}
+
+// NewProxyTrafficReplayer loads every recording in directoryPath. matchFields selects which of method, path,
+// query and body identify a request; missMode is fail or passthrough.
+func NewProxyTrafficReplayer(directoryPath string, matchFields []string, missMode string) (*ProxyTrafficReplayer, error) {
+	matchFieldSet := map[string]struct{}{}
+	for _, matchField := range matchFields {
+		normalizedMatchField := strings.ToLower(strings.TrimSpace(matchField))
+		switch normalizedMatchField {
+		case proxyReplayMatchMethod, proxyReplayMatchPath, proxyReplayMatchQuery, proxyReplayMatchBody:
+			matchFieldSet[normalizedMatchField] = struct{}{}
+		default:
+			return nil, fmt.Errorf("%w: unsupported match field %s", ErrInvalidProxyReplay, matchField)
+		}
+	}
+	if len(matchFieldSet) == 0 {
+		return nil, fmt.Errorf("%w: at least one match field is required", ErrInvalidProxyReplay)
+	}
+	normalizedMissMode := strings.ToLower(strings.TrimSpace(missMode))
+	if normalizedMissMode != proxyReplayMissFail && normalizedMissMode != proxyReplayMissPassthrough {
+		return nil, fmt.Errorf("%w: unsupported miss mode %s", ErrInvalidProxyReplay, missMode)
+	}
+
+	directoryEntries, readDirErr := os.ReadDir(directoryPath)
+	if readDirErr != nil {
+		return nil, fmt.Errorf("%w: read replay directory %s: %s", ErrInvalidProxyReplay, directoryPath, readDirErr.Error())
+	}
+	fileNames := make([]string, 0, len(directoryEntries))
+	for _, directoryEntry := range directoryEntries {
+		if !directoryEntry.IsDir() && strings.EqualFold(filepath.Ext(directoryEntry.Name()), proxyRecordingFileExtension) {
+			fileNames = append(fileNames, directoryEntry.Name())
+		}
+	}
+	sort.Strings(fileNames)
+
+	replayer := &ProxyTrafficReplayer{
+		matchFields:     matchFieldSet,
+		passthroughMiss: normalizedMissMode == proxyReplayMissPassthrough,
+		exchangesByKey:  map[string][]proxyExchange{},
+		servedByKey:     map[string]int{},
+	}
+	for _, fileName := range fileNames {
+		exchangeBytes, readErr := os.ReadFile(filepath.Join(directoryPath, fileName))
+		if readErr != nil {
+			return nil, fmt.Errorf("%w: read recording %s: %s", ErrInvalidProxyReplay, fileName, readErr.Error())
+		}
+		var exchange proxyExchange
+		if decodeErr := json.Unmarshal(exchangeBytes, &exchange); decodeErr != nil {
+			return nil, fmt.Errorf("%w: parse recording %s: %s", ErrInvalidProxyReplay, fileName, decodeErr.Error())
+		}
+		if exchange.Response.Status < 100 || exchange.Response.Status > 599 {
+			return nil, fmt.Errorf("%w: recording %s has invalid status %d", ErrInvalidProxyReplay, fileName, exchange.Response.Status)
+		}
+		if exchange.Response.Body != "" && len(exchange.Response.BodyBase64) > 0 {
+			return nil, fmt.Errorf("%w: recording %s has both body and body_base64", ErrInvalidProxyReplay, fileName)
+		}
+		matchKey := replayer.matchKey(exchange.Request.Method, exchange.Request.Path, exchange.Request.Query, exchange.Request.BodySHA256)
+		replayer.exchangesByKey[matchKey] = append(replayer.exchangesByKey[matchKey], exchange)
+	}
+	return replayer, nil
+}
+
+func (replayer *ProxyTrafficReplayer) wrapTransport(transport http.RoundTripper) http.RoundTripper {
+	return &replayTransport{replayer: replayer, transport: transport}
+}
+
+type replayTransport struct {
+	replayer  *ProxyTrafficReplayer
+	transport http.RoundTripper
+}
+
+// RoundTrip answers from the recordings. The request body is only read when it is part of the match.
+func (transport *replayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var bodySHA256 string
+	if _, matchesBody := transport.replayer.matchFields[proxyReplayMatchBody]; matchesBody {
+		requestBody, readErr := readAndRestoreRequestBody(request)
+		if readErr != nil {
+			return nil, readErr
+		}
+		bodySHA256 = hashRequestBody(requestBody)
+	}
+	matchKey := transport.replayer.matchKey(request.Method, request.URL.Path, request.URL.RawQuery, bodySHA256)
+	exchange, found := transport.replayer.next(matchKey)
+	if !found {
+		if transport.replayer.passthroughMiss {
+			return transport.transport.RoundTrip(request)
+		}
+		return nil, fmt.Errorf("%w: %s %s", errProxyReplayMiss, request.Method, request.URL.RequestURI())
+	}
+	responseBody := []byte(exchange.Response.Body)
+	if len(exchange.Response.BodyBase64) > 0 {
+		responseBody = exchange.Response.BodyBase64
+	}
+	responseHeaders := exchange.Response.Headers.Clone()
+	if responseHeaders == nil {
+		responseHeaders = http.Header{}
+	}
+	responseHeaders.Del(headerContentLength)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Response.Status, http.StatusText(exchange.Response.Status)),
+		StatusCode:    exchange.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        responseHeaders,
+		Trailer:       exchange.Response.Trailers.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(responseBody)),
+		ContentLength: int64(len(responseBody)),
+		Request:       request,
+	}, nil
+}
+
+// next returns the recorded exchanges for a key in recording order, repeating the last one once all were served.
+func (replayer *ProxyTrafficReplayer) next(matchKey string) (proxyExchange, bool) {
+	replayer.mutex.Lock()
+	defer replayer.mutex.Unlock()
+	exchanges := replayer.exchangesByKey[matchKey]
+	if len(exchanges) == 0 {
+		return proxyExchange{}, false
+	}
+	servedCount := replayer.servedByKey[matchKey]
+	replayer.servedByKey[matchKey] = servedCount + 1
+	return exchanges[min(servedCount, len(exchanges)-1)], true
+}
+
+func (replayer *ProxyTrafficReplayer) matchKey(method string, requestPath string, rawQuery string, bodySHA256 string) string {
+	keyParts := make([]string, 0, 4)
+	for _, matchField := range []string{proxyReplayMatchMethod, proxyReplayMatchPath, proxyReplayMatchQuery, proxyReplayMatchBody} {
+		if _, enabled := replayer.matchFields[matchField]; !enabled {
+			continue
+		}
+		switch matchField {
+		case proxyReplayMatchMethod:
+			keyParts = append(keyParts, strings.ToUpper(method))
+		case proxyReplayMatchPath:
+			keyParts = append(keyParts, requestPath)
+		case proxyReplayMatchQuery:
+			keyParts = append(keyParts, rawQuery)
+		case proxyReplayMatchBody:
+			keyParts = append(keyParts, bodySHA256)
+		}
+	}
+	return strings.Join(keyParts, "\n")
+}
+
+func readAndRestoreRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	requestBody, readErr := io.ReadAll(request.Body)
+	_ = request.Body.Close()
+	if readErr != nil {
+		return nil, fmt.Errorf("read proxied request body: %w", readErr)
+	}
+	request.Body = io.NopCloser(bytes.NewReader(requestBody))
+	request.ContentLength = int64(len(requestBody))
+	return requestBody, nil
+}
+
+func hashRequestBody(requestBody []byte) string {
+	bodyDigest := sha256.Sum256(requestBody)
+	return hex.EncodeToString(bodyDigest[:])
+}
+
+// encodeExchangeBody keeps UTF-8 bodies readable in recordings and falls back to base64 for binary content.
+func encodeExchangeBody(body []byte) (string, []byte) {
+	if utf8.Valid(body) {
+		return string(body), nil
+	}
+	return "", body
+}
+
+func sanitizeRecordingPath(requestPath string) string {
+	sanitizedPath := strings.Map(func(character rune) rune {
+		if character >= 'a' && character <= 'z' || character >= 'A' && character <= 'Z' || character >= '0' && character <= '9' || character == '-' || character == '.' {
+			return character
+		}
+		return '_'
+	}, strings.Trim(requestPath, "/"))
+	if sanitizedPath == "" {
+		return "root"
+	}
+	return sanitizedPath[:min(len(sanitizedPath), 80)]
+}
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	headerWebSocketProtocol = "Sec-WebSocket-Protocol"
+	headerForwardedFor      = "X-Forwarded-For"
+
+	webSocketOpcodeContinuation = 0x0
+	webSocketOpcodeText         = 0x1
+	webSocketOpcodeClose        = 0x8
+	webSocketOpcodePing         = 0x9
+	webSocketOpcodePong         = 0xA
+	webSocketFinalBit           = 0x80
+	webSocketCompressedBit      = 0x40
+	webSocketMaskBit            = 0x80
+	webSocketOpcodeMask         = 0x0F
+	webSocketLengthMask         = 0x7F
+	webSocketLength16           = 126
+	webSocketLength64           = 127
+	webSocketMaskKeyLength      = 4
+	webSocketMaxHeaderLength    = 14
+	webSocketCopyBufferLength   = 32 * 1024
+	webSocketKeepalivePayload   = "ghttp-keepalive"
+	webSocketCloseGracePeriod   = 5 * time.Second
+	webSocketKeepaliveChecks    = 4
+
+	logMessageProxyWebSocketClosed = "proxy websocket closed"
+	logFieldBytesIn                = "bytes_in"
+	logFieldBytesOut               = "bytes_out"
+	webSocketEndReasonClient       = "client closed"
+	webSocketEndReasonBackend      = "backend closed"
+	webSocketEndReasonIdle         = "idle timeout"
+)
+
+// webSocketHopHeaders are connection-scoped headers that are not forwarded with the upgrade request.
+var webSocketHopHeaders = []string{
+	headerConnection,
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	headerUpgrade,
+}
+
+var errWebSocketSubprotocol = errors.New("backend selected a subprotocol the client did not offer")
+
+// webSocketKeepalive configures proxy pings sent to both peers of an idle tunnel and the idle period after which the
+// tunnel is closed. Zero disables either behavior.
+type webSocketKeepalive struct {
+	pingInterval time.Duration
+	idleTimeout  time.Duration
+}
+
+// newWebSocketTransport builds the HTTP/1.1-only transport used for upgrades; it shares the route's TLS options and
+// Unix socket dialing with the regular proxy transport.
+func newWebSocketTransport(route proxyRoute, backendTLSConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if backendTLSConfig != nil {
+		transport.TLSClientConfig = backendTLSConfig.Clone()
+	}
+	transport.ForceAttemptHTTP2 = false
+	transport.DisableCompression = true
+	transport.Protocols = new(http.Protocols)
+	transport.Protocols.SetHTTP1(true)
+	if route.backendSocketPath != "" {
+		dialer := &net.Dialer{Timeout: backendDialTimeout}
+		transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, networkUnix, route.backendSocketPath)
+		}
+	}
+	return transport
+}
+
+// handleWebSocket sends the upgrade through the route transport and, once the backend switches protocols, relays
+// frames between the hijacked client connection and the backend connection. Responses other than 101 are passed
+// through unchanged.
+func (routeHandler *proxyRouteHandler) handleWebSocket(responseWriter http.ResponseWriter, request *http.Request, keepalive webSocketKeepalive, frameLogger *webSocketFrameLogger, loggingService *logging.Service) {
+	upgradeRequest := request.Clone(request.Context())
+	upgradeRequest.RequestURI = ""
+	upgradeRequest.Body = http.NoBody
+	upgradeRequest.ContentLength = 0
+	routeHandler.defaultProxy.Director(upgradeRequest)
+	upgradeRequest.Host = ""
+	for _, hopHeader := range webSocketHopHeaders {
+		upgradeRequest.Header.Del(hopHeader)
+	}
+	upgradeRequest.Header.Set(headerConnection, headerUpgrade)
+	upgradeRequest.Header.Set(headerUpgrade, valueWebSocket)
+	if clientHost, _, splitErr := net.SplitHostPort(request.RemoteAddr); splitErr == nil {
+		if priorForwardedFor := request.Header.Get(headerForwardedFor); priorForwardedFor != "" {
+			clientHost = priorForwardedFor + ", " + clientHost
+		}
+		upgradeRequest.Header.Set(headerForwardedFor, clientHost)
+	}
+
+	backendResponse, roundTripErr := routeHandler.webSocketTransport.RoundTrip(upgradeRequest)
+	if roundTripErr != nil {
+		http.Error(responseWriter, "Bad Gateway: failed to connect to backend", http.StatusBadGateway)
+		return
+	}
+	if backendResponse.StatusCode != http.StatusSwitchingProtocols {
+		defer backendResponse.Body.Close()
+		copyResponseHeaders(responseWriter.Header(), backendResponse.Header)
+		responseWriter.WriteHeader(backendResponse.StatusCode)
+		_, _ = io.Copy(responseWriter, backendResponse.Body)
+		return
+	}
+	backendConnection, switched := backendResponse.Body.(io.ReadWriteCloser)
+	if !switched || !strings.EqualFold(backendResponse.Header.Get(headerUpgrade), valueWebSocket) {
+		_ = backendResponse.Body.Close()
+		http.Error(responseWriter, "Bad Gateway: backend did not switch to WebSocket", http.StatusBadGateway)
+		return
+	}
+	defer backendConnection.Close()
+	if subprotocolErr := validateWebSocketSubprotocol(request, backendResponse); subprotocolErr != nil {
+		http.Error(responseWriter, "Bad Gateway: "+subprotocolErr.Error(), http.StatusBadGateway)
+		return
+	}
+
+	clientConnection, clientBuffer, hijackErr := http.NewResponseController(responseWriter).Hijack()
+	if hijackErr != nil {
+		http.Error(responseWriter, "WebSocket hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	defer clientConnection.Close()
+	// Server read and write timeouts are meant for requests, not for the long-lived tunnel.
+	_ = clientConnection.SetDeadline(time.Time{})
+	_, _ = fmt.Fprintf(clientBuffer, "HTTP/1.1 %d %s\r\n", http.StatusSwitchingProtocols, http.StatusText(http.StatusSwitchingProtocols))
+	_ = backendResponse.Header.Write(clientBuffer)
+	_, _ = clientBuffer.WriteString("\r\n")
+	if flushErr := clientBuffer.Flush(); flushErr != nil {
+		return
+	}
+
+	startTime := time.Now()
+	tunnel := &webSocketTunnel{clientConnection: clientConnection, backendConnection: backendConnection, frameLogger: frameLogger}
+	endReason := tunnel.run(clientBuffer.Reader, keepalive)
+	loggingService.Info(
+		logMessageProxyWebSocketClosed,
+		logging.String(logFieldPath, request.URL.Path),
+		logging.Duration(logFieldDuration, time.Since(startTime)),
+		logging.Int(logFieldBytesIn, int(tunnel.bytesFromClient.Load())),
+		logging.Int(logFieldBytesOut, int(tunnel.bytesFromBackend.Load())),
+		logging.String(logFieldReason, endReason),
+	)
+}
+
+func validateWebSocketSubprotocol(request *http.Request, backendResponse *http.Response) error {
+	selectedSubprotocol := strings.TrimSpace(backendResponse.Header.Get(headerWebSocketProtocol))
+	if selectedSubprotocol == "" {
+		return nil
+	}
+	for _, offeredValue := range request.Header.Values(headerWebSocketProtocol) {
+		for _, offeredSubprotocol := range strings.Split(offeredValue, ",") {
+			if strings.TrimSpace(offeredSubprotocol) == selectedSubprotocol {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s", errWebSocketSubprotocol, selectedSubprotocol)
+}
+
+// webSocketTunnel relays frames in both directions. Frames are forwarded untouched (extensions such as
+// permessage-deflate pass through), but relaying whole frames lets keepalive pings be written between them.
+type webSocketTunnel struct {
+	clientConnection  net.Conn
+	backendConnection io.ReadWriteCloser
+	frameLogger       *webSocketFrameLogger
+	clientWriteMutex  sync.Mutex
+	backendWriteMutex sync.Mutex
+	lastActivity      atomic.Int64
+	bytesFromClient   atomic.Int64
+	bytesFromBackend  atomic.Int64
+	idleClosed        atomic.Bool
+	closeOnce         sync.Once
+}
+
+type webSocketRelayResult struct {
+	fromClient bool
+	sawClose   bool
+}
+
+// run relays until one side goes away. After a Close frame the other direction gets a grace period to finish the
+// closing handshake. A Close from the backend also half-closes the client connection where it supports it; the backend
+// connection is the transport's upgrade body, which cannot be half-closed, so a client Close only starts the grace
+// period.
+func (tunnel *webSocketTunnel) run(clientReader io.Reader, keepalive webSocketKeepalive) string {
+	tunnel.touch()
+	relayResults := make(chan webSocketRelayResult, 2)
+	go func() {
+		sawClose := tunnel.relayFrames(webSocketDirectionIn, clientReader, tunnel.backendConnection, &tunnel.backendWriteMutex, &tunnel.bytesFromClient)
+		relayResults <- webSocketRelayResult{fromClient: true, sawClose: sawClose}
+	}()
+	go func() {
+		sawClose := tunnel.relayFrames(webSocketDirectionOut, tunnel.backendConnection, tunnel.clientConnection, &tunnel.clientWriteMutex, &tunnel.bytesFromBackend)
+		if sawClose {
+			closeWrite(tunnel.clientConnection)
+		}
+		relayResults <- webSocketRelayResult{fromClient: false, sawClose: sawClose}
+	}()
+	stopKeepalive := make(chan struct{})
+	keepaliveStopped := make(chan struct{})
+	go tunnel.keepalive(keepalive, stopKeepalive, keepaliveStopped)
+
+	firstResult := <-relayResults
+	if firstResult.sawClose {
+		select {
+		case <-relayResults:
+		case <-time.After(webSocketCloseGracePeriod):
+		}
+	}
+	tunnel.close()
+	close(stopKeepalive)
+	<-keepaliveStopped
+
+	switch {
+	case tunnel.idleClosed.Load():
+		return webSocketEndReasonIdle
+	case firstResult.fromClient:
+		return webSocketEndReasonClient
+	default:
+		return webSocketEndReasonBackend
+	}
+}
+
+// relayFrames copies frames from source to destination until the source ends or forwards a Close frame. Pongs that
+// answer the proxy's own keepalive pings are dropped and do not count as activity for the idle timeout. With a frame
+// logger the start of each payload is captured on the way through and unmasked for the logger.
+func (tunnel *webSocketTunnel) relayFrames(direction string, source io.Reader, destination io.Writer, destinationMutex *sync.Mutex, byteCounter *atomic.Int64) bool {
+	frameHeader := make([]byte, webSocketMaxHeaderLength)
+	copyBuffer := make([]byte, webSocketCopyBufferLength)
+	payloadCapture := &webSocketPayloadCapture{limit: tunnel.frameLogger.captureLimit()}
+	for {
+		if _, readErr := io.ReadFull(source, frameHeader[:2]); readErr != nil {
+			return false
+		}
+		headerLength := 2
+		payloadLength := int64(frameHeader[1] & webSocketLengthMask)
+		switch payloadLength {
+		case webSocketLength16:
+			if _, readErr := io.ReadFull(source, frameHeader[2:4]); readErr != nil {
+				return false
+			}
+			payloadLength = int64(binary.BigEndian.Uint16(frameHeader[2:4]))
+			headerLength = 4
+		case webSocketLength64:
+			if _, readErr := io.ReadFull(source, frameHeader[2:10]); readErr != nil {
+				return false
+			}
+			payloadLength = int64(binary.BigEndian.Uint64(frameHeader[2:10]) & (1<<63 - 1))
+			headerLength = 10
+		}
+		masked := frameHeader[1]&webSocketMaskBit != 0
+		if masked {
+			if _, readErr := io.ReadFull(source, frameHeader[headerLength:headerLength+webSocketMaskKeyLength]); readErr != nil {
+				return false
+			}
+			headerLength += webSocketMaskKeyLength
+		}
+		byteCounter.Add(int64(headerLength) + payloadLength)
+		opcode := frameHeader[0] & webSocketOpcodeMask
+
+		if opcode == webSocketOpcodePong && payloadLength == int64(len(webSocketKeepalivePayload)) {
+			pongPayload := make([]byte, payloadLength)
+			if _, readErr := io.ReadFull(source, pongPayload); readErr != nil {
+				return false
+			}
+			unmaskedPayload := append([]byte(nil), pongPayload...)
+			if masked {
+				maskKey := frameHeader[headerLength-webSocketMaskKeyLength : headerLength]
+				for payloadIndex := range unmaskedPayload {
+					unmaskedPayload[payloadIndex] ^= maskKey[payloadIndex%webSocketMaskKeyLength]
+				}
+			}
+			if string(unmaskedPayload) == webSocketKeepalivePayload {
+				continue
+			}
+			tunnel.touch()
+			destinationMutex.Lock()
+			_, writeErr := destination.Write(append(frameHeader[:headerLength:headerLength], pongPayload...))
+			destinationMutex.Unlock()
+			if writeErr != nil {
+				return false
+			}
+			tunnel.frameLogger.record(direction, frameHeader[0], payloadLength, unmaskedPayload)
+			continue
+		}
+
+		tunnel.touch()
+		payloadCapture.reset()
+		payloadReader := io.TeeReader(io.LimitReader(source, payloadLength), payloadCapture)
+		destinationMutex.Lock()
+		_, writeErr := destination.Write(frameHeader[:headerLength])
+		if writeErr == nil {
+			_, writeErr = io.CopyBuffer(destination, payloadReader, copyBuffer)
+		}
+		destinationMutex.Unlock()
+		if writeErr != nil {
+			return false
+		}
+		if masked {
+			payloadCapture.unmask(frameHeader[headerLength-webSocketMaskKeyLength : headerLength])
+		}
+		tunnel.frameLogger.record(direction, frameHeader[0], payloadLength, payloadCapture.captured)
+		if opcode == webSocketOpcodeClose {
+			return true
+		}
+	}
+}
+
+// keepalive pings both peers while the tunnel is idle and closes it once nothing has arrived for the idle timeout.
+func (tunnel *webSocketTunnel) keepalive(keepalive webSocketKeepalive, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	checkInterval := min(nonZeroDuration(keepalive.pingInterval), nonZeroDuration(keepalive.idleTimeout)) / webSocketKeepaliveChecks
+	if checkInterval <= 0 {
+		<-stop
+		return
+	}
+	checkTicker := time.NewTicker(checkInterval)
+	defer checkTicker.Stop()
+	var lastPingAt time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case tickTime := <-checkTicker.C:
+			idleFor := tickTime.Sub(time.Unix(0, tunnel.lastActivity.Load()))
+			if keepalive.idleTimeout > 0 && idleFor >= keepalive.idleTimeout {
+				tunnel.idleClosed.Store(true)
+				tunnel.close()
+				<-stop
+				return
+			}
+			if keepalive.pingInterval > 0 && idleFor >= keepalive.pingInterval && tickTime.Sub(lastPingAt) >= keepalive.pingInterval {
+				lastPingAt = tickTime
+				tunnel.sendKeepalivePings()
+			}
+		}
+	}
+}
+
+// sendKeepalivePings writes an unmasked ping to the client and a masked ping to the backend, as the proxy acts as
+// the server on one side and the client on the other.
+func (tunnel *webSocketTunnel) sendKeepalivePings() {
+	clientPing := append([]byte{webSocketFinalBit | webSocketOpcodePing, byte(len(webSocketKeepalivePayload))}, webSocketKeepalivePayload...)
+	tunnel.clientWriteMutex.Lock()
+	_, _ = tunnel.clientConnection.Write(clientPing)
+	tunnel.clientWriteMutex.Unlock()
+
+	maskKey := make([]byte, webSocketMaskKeyLength)
+	_, _ = rand.Read(maskKey)
+	backendPing := append([]byte{webSocketFinalBit | webSocketOpcodePing, webSocketMaskBit | byte(len(webSocketKeepalivePayload))}, maskKey...)
+	for payloadIndex := range len(webSocketKeepalivePayload) {
+		backendPing = append(backendPing, webSocketKeepalivePayload[payloadIndex]^maskKey[payloadIndex%webSocketMaskKeyLength])
+	}
+	tunnel.backendWriteMutex.Lock()
+	_, _ = tunnel.backendConnection.Write(backendPing)
+	tunnel.backendWriteMutex.Unlock()
+}
+
+// webSocketPayloadCapture keeps the first limit bytes of a frame payload for the frame logger.
+type webSocketPayloadCapture struct {
+	limit    int
+	captured []byte
+}
+
+func (payloadCapture *webSocketPayloadCapture) Write(payload []byte) (int, error) {
+	if remaining := payloadCapture.limit - len(payloadCapture.captured); remaining > 0 {
+		payloadCapture.captured = append(payloadCapture.captured, payload[:min(remaining, len(payload))]...)
+	}
+	return len(payload), nil
+}
+
+func (payloadCapture *webSocketPayloadCapture) reset() {
+	payloadCapture.captured = payloadCapture.captured[:0]
+}
+
+func (payloadCapture *webSocketPayloadCapture) unmask(maskKey []byte) {
+	for payloadIndex := range payloadCapture.captured {
+		payloadCapture.captured[payloadIndex] ^= maskKey[payloadIndex%webSocketMaskKeyLength]
+	}
+}
+
+func (tunnel *webSocketTunnel) touch() {
+	tunnel.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (tunnel *webSocketTunnel) close() {
+	tunnel.closeOnce.Do(func() {
+		_ = tunnel.clientConnection.Close()
+		_ = tunnel.backendConnection.Close()
+	})
+}
+
+// closeWrite half-closes connections that support it so the peer sees end of stream while the other direction keeps
+// flowing.
+func closeWrite(connection any) {
+	if halfCloser, supportsHalfClose := connection.(interface{ CloseWrite() error }); supportsHalfClose {
+		_ = halfCloser.CloseWrite()
+	}
+}
+
+func nonZeroDuration(duration time.Duration) time.Duration {
+	if duration <= 0 {
+		return time.Duration(1<<63 - 1)
+	}
+	return duration
+}
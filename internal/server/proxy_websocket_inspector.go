@@ -0,0 +1,399 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	proxyWebSocketInspectionSeparator = "="
+	proxyWebSocketInspectionOption    = ":"
+	proxyWebSocketInspectionFrames    = "frames"
+	proxyWebSocketInspectionPayload   = "payload"
+	defaultWebSocketPayloadLimit      = 256
+	webSocketInspectCaptureLimit      = 64 << 10
+	webSocketInspectRecentFrames      = 200
+	webSocketInspectSubscriberBuffer  = 64
+	webSocketRedactedText             = "[REDACTED]"
+	webSocketTruncatedSuffix          = "…"
+	webSocketCloseCodeLength          = 2
+	webSocketDirectionIn              = "in"
+	webSocketDirectionOut             = "out"
+	webSocketInspectorAdminPath       = "/__ghttp/websocket"
+	webSocketInspectorFramesPath      = "/__ghttp/websocket/frames"
+	requestHandlerWebSocketInspector  = "websocket-inspector"
+
+	logMessageProxyWebSocketFrame = "proxy websocket frame"
+	logFieldDirection             = "direction"
+	logFieldOpcode                = "opcode"
+	logFieldSize                  = "size"
+	logFieldFinal                 = "fin"
+	logFieldCompressed            = "compressed"
+	logFieldPayload               = "payload"
+)
+
+var ErrInvalidProxyWebSocketInspection = errors.New("proxy.websocket.inspection.invalid")
+
+var webSocketOpcodeNames = map[byte]string{
+	0x0: "continuation",
+	0x1: "text",
+	0x2: "binary",
+	0x8: "close",
+	0x9: "ping",
+	0xA: "pong",
+}
+
+// ProxyWebSocketInspector logs frames relayed on inspected WebSocket routes, keeps the most recent ones for the debug
+// page, and streams new frames to its live viewers.
+type ProxyWebSocketInspector struct {
+	policies       []webSocketInspectionPolicy
+	redactPatterns []*regexp.Regexp
+	mutex          sync.Mutex
+	recentFrames   []webSocketFrameRecord
+	subscribers    map[chan webSocketFrameRecord]struct{}
+}
+
+type webSocketInspectionPolicy struct {
+	pathPrefix   string
+	payloadLimit int
+}
+
+// webSocketFrameRecord is one inspected frame as logged and as sent to the debug page.
+type webSocketFrameRecord struct {
+	Time       time.Time `json:"time"`
+	Path       string    `json:"path"`
+	Direction  string    `json:"direction"`
+	Opcode     string    `json:"opcode"`
+	Size       int64     `json:"size"`
+	Final      bool      `json:"fin"`
+	Compressed bool      `json:"compressed,omitempty"`
+	Payload    string    `json:"payload,omitempty"`
+}
+
+// NewProxyWebSocketInspector parses /path=frames|payload[:bytes] mappings. frames logs frame metadata only; payload
+// also logs text payloads up to the byte limit after applying the redaction patterns.
+func NewProxyWebSocketInspector(mappings []string, redactPatterns []string) (*ProxyWebSocketInspector, error) {
+	policyByPathPrefix := map[string]webSocketInspectionPolicy{}
+	for _, mapping := range mappings {
+		policy, parseErr := parseWebSocketInspectionPolicy(mapping)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		policyByPathPrefix[policy.pathPrefix] = policy
+	}
+	if len(policyByPathPrefix) == 0 {
+		return nil, fmt.Errorf("%w: at least one mapping is required", ErrInvalidProxyWebSocketInspection)
+	}
+	inspector := &ProxyWebSocketInspector{subscribers: map[chan webSocketFrameRecord]struct{}{}}
+	for _, policy := range policyByPathPrefix {
+		inspector.policies = append(inspector.policies, policy)
+	}
+	sort.SliceStable(inspector.policies, func(leftIndex int, rightIndex int) bool {
+		return len(inspector.policies[leftIndex].pathPrefix) > len(inspector.policies[rightIndex].pathPrefix)
+	})
+	for _, redactPattern := range redactPatterns {
+		if strings.TrimSpace(redactPattern) == "" {
+			continue
+		}
+		compiledPattern, compileErr := regexp.Compile(redactPattern)
+		if compileErr != nil {
+			return nil, fmt.Errorf("%w: invalid redaction pattern %s: %v", ErrInvalidProxyWebSocketInspection, redactPattern, compileErr)
+		}
+		inspector.redactPatterns = append(inspector.redactPatterns, compiledPattern)
+	}
+	return inspector, nil
+}
+
+func parseWebSocketInspectionPolicy(mapping string) (webSocketInspectionPolicy, error) {
+	pathPrefix, mode, found := strings.Cut(strings.TrimSpace(mapping), proxyWebSocketInspectionSeparator)
+	if !found {
+		return webSocketInspectionPolicy{}, fmt.Errorf("%w: mapping must be in /path=frames|payload[:bytes] form", ErrInvalidProxyWebSocketInspection)
+	}
+	pathPrefix = strings.TrimSpace(pathPrefix)
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return webSocketInspectionPolicy{}, fmt.Errorf("%w: path prefix must start with /", ErrInvalidProxyWebSocketInspection)
+	}
+	modeName, limitOption, hasLimit := strings.Cut(strings.ToLower(strings.TrimSpace(mode)), proxyWebSocketInspectionOption)
+	switch {
+	case modeName == proxyWebSocketInspectionFrames && !hasLimit:
+		return webSocketInspectionPolicy{pathPrefix: pathPrefix}, nil
+	case modeName == proxyWebSocketInspectionPayload && !hasLimit:
+		return webSocketInspectionPolicy{pathPrefix: pathPrefix, payloadLimit: defaultWebSocketPayloadLimit}, nil
+	case modeName == proxyWebSocketInspectionPayload:
+		payloadLimit, parseErr := strconv.Atoi(strings.TrimSpace(limitOption))
+		if parseErr != nil || payloadLimit <= 0 || payloadLimit > webSocketInspectCaptureLimit {
+			return webSocketInspectionPolicy{}, fmt.Errorf("%w: payload limit must be between 1 and %d bytes, got %s", ErrInvalidProxyWebSocketInspection, webSocketInspectCaptureLimit, limitOption)
+		}
+		return webSocketInspectionPolicy{pathPrefix: pathPrefix, payloadLimit: payloadLimit}, nil
+	default:
+		return webSocketInspectionPolicy{}, fmt.Errorf("%w: unsupported mode %s", ErrInvalidProxyWebSocketInspection, mode)
+	}
+}
+
+// frameLogger returns the frame logger for a tunnel on requestPath, or nil when the path is not inspected.
+func (inspector *ProxyWebSocketInspector) frameLogger(requestPath string, loggingService *logging.Service) *webSocketFrameLogger {
+	if inspector == nil {
+		return nil
+	}
+	for _, policy := range inspector.policies {
+		if strings.HasPrefix(requestPath, policy.pathPrefix) {
+			return &webSocketFrameLogger{inspector: inspector, policy: policy, requestPath: requestPath, loggingService: loggingService}
+		}
+	}
+	return nil
+}
+
+func (inspector *ProxyWebSocketInspector) publish(record webSocketFrameRecord) {
+	inspector.mutex.Lock()
+	defer inspector.mutex.Unlock()
+	if len(inspector.recentFrames) == webSocketInspectRecentFrames {
+		copy(inspector.recentFrames, inspector.recentFrames[1:])
+		inspector.recentFrames = inspector.recentFrames[:webSocketInspectRecentFrames-1]
+	}
+	inspector.recentFrames = append(inspector.recentFrames, record)
+	for subscriber := range inspector.subscribers {
+		select {
+		case subscriber <- record:
+		default:
+		}
+	}
+}
+
+// redact replaces every match of the configured patterns.
+func (inspector *ProxyWebSocketInspector) redact(payload string) string {
+	for _, redactPattern := range inspector.redactPatterns {
+		payload = redactPattern.ReplaceAllLiteralString(payload, webSocketRedactedText)
+	}
+	return payload
+}
+
+// serveAdmin serves the debug page and its frame feed: the feed is JSON with the recent frames, or an event stream
+// that replays them and then follows new frames when the client asks for text/event-stream.
+func (inspector *ProxyWebSocketInspector) serveAdmin(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		responseWriter.Header().Set("Allow", "GET, HEAD")
+		http.Error(responseWriter, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if request.URL.Path == webSocketInspectorAdminPath {
+		responseWriter.Header().Set(headerContentType, "text/html; charset=utf-8")
+		responseWriter.Header().Set(headerCacheControl, cacheDirectiveNoStore)
+		_, _ = responseWriter.Write([]byte(webSocketInspectorPage))
+		return
+	}
+	if !strings.Contains(request.Header.Get("Accept"), mediaTypeEventStream) {
+		inspector.mutex.Lock()
+		recentFrames := append([]webSocketFrameRecord{}, inspector.recentFrames...)
+		inspector.mutex.Unlock()
+		responseWriter.Header().Set(headerContentType, "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+		if request.Method != http.MethodHead {
+			_ = json.NewEncoder(responseWriter).Encode(recentFrames)
+		}
+		return
+	}
+	inspector.streamFrames(responseWriter, request)
+}
+
+func (inspector *ProxyWebSocketInspector) streamFrames(responseWriter http.ResponseWriter, request *http.Request) {
+	subscriber := make(chan webSocketFrameRecord, webSocketInspectSubscriberBuffer)
+	inspector.mutex.Lock()
+	recentFrames := append([]webSocketFrameRecord{}, inspector.recentFrames...)
+	inspector.subscribers[subscriber] = struct{}{}
+	inspector.mutex.Unlock()
+	defer func() {
+		inspector.mutex.Lock()
+		delete(inspector.subscribers, subscriber)
+		inspector.mutex.Unlock()
+	}()
+
+	responseController := http.NewResponseController(responseWriter)
+	responseWriter.Header().Set(headerContentType, mediaTypeEventStream)
+	responseWriter.Header().Set(headerCacheControl, cacheDirectiveNoCache)
+	responseWriter.WriteHeader(http.StatusOK)
+	for _, record := range recentFrames {
+		writeWebSocketFrameEvent(responseWriter, record)
+	}
+	if responseController.Flush() != nil {
+		return
+	}
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case record := <-subscriber:
+			writeWebSocketFrameEvent(responseWriter, record)
+			if responseController.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeWebSocketFrameEvent(responseWriter http.ResponseWriter, record webSocketFrameRecord) {
+	encodedRecord, _ := json.Marshal(record)
+	_, _ = fmt.Fprintf(responseWriter, "data: %s\n\n", encodedRecord)
+}
+
+// webSocketFrameLogger records the frames of one tunnel. Continuation frames take the opcode of the message they
+// continue so text messages split across frames keep their payloads.
+type webSocketFrameLogger struct {
+	inspector      *ProxyWebSocketInspector
+	policy         webSocketInspectionPolicy
+	requestPath    string
+	loggingService *logging.Service
+	mutex          sync.Mutex
+	messageOpcode  map[string]byte
+}
+
+// captureLimit is how much of a payload the relay keeps for the logger; JSON is parsed from the captured bytes
+// before the logged text is cut to the policy limit.
+func (frameLogger *webSocketFrameLogger) captureLimit() int {
+	if frameLogger == nil || frameLogger.policy.payloadLimit == 0 {
+		return 0
+	}
+	return webSocketInspectCaptureLimit
+}
+
+func (frameLogger *webSocketFrameLogger) record(direction string, frameFlags byte, payloadLength int64, capturedPayload []byte) {
+	if frameLogger == nil {
+		return
+	}
+	opcode := frameFlags & webSocketOpcodeMask
+	final := frameFlags&webSocketFinalBit != 0
+	compressed := frameFlags&webSocketCompressedBit != 0
+	messageOpcode := opcode
+	frameLogger.mutex.Lock()
+	if frameLogger.messageOpcode == nil {
+		frameLogger.messageOpcode = map[string]byte{}
+	}
+	if opcode == webSocketOpcodeContinuation {
+		messageOpcode = frameLogger.messageOpcode[direction]
+	} else if opcode < webSocketOpcodeClose {
+		frameLogger.messageOpcode[direction] = opcode
+	}
+	frameLogger.mutex.Unlock()
+
+	record := webSocketFrameRecord{
+		Time:       time.Now(),
+		Path:       frameLogger.requestPath,
+		Direction:  direction,
+		Opcode:     webSocketOpcodeName(opcode),
+		Size:       payloadLength,
+		Final:      final,
+		Compressed: compressed,
+	}
+	switch {
+	case frameLogger.policy.payloadLimit == 0 || compressed:
+	case opcode == webSocketOpcodeClose && len(capturedPayload) >= webSocketCloseCodeLength:
+		record.Payload = strconv.Itoa(int(binary.BigEndian.Uint16(capturedPayload))) + " " + frameLogger.describePayload(capturedPayload[webSocketCloseCodeLength:], false)
+		record.Payload = strings.TrimSuffix(record.Payload, " ")
+	case messageOpcode == webSocketOpcodeText:
+		record.Payload = frameLogger.describePayload(capturedPayload, int64(len(capturedPayload)) < payloadLength)
+	}
+	frameLogger.inspector.publish(record)
+	logFields := []logging.Field{
+		logging.String(logFieldPath, record.Path),
+		logging.String(logFieldDirection, record.Direction),
+		logging.String(logFieldOpcode, record.Opcode),
+		logging.Int(logFieldSize, int(record.Size)),
+	}
+	if !record.Final {
+		logFields = append(logFields, logging.String(logFieldFinal, strconv.FormatBool(record.Final)))
+	}
+	if record.Compressed {
+		logFields = append(logFields, logging.String(logFieldCompressed, strconv.FormatBool(record.Compressed)))
+	}
+	if record.Payload != "" {
+		logFields = append(logFields, logging.String(logFieldPayload, record.Payload))
+	}
+	frameLogger.loggingService.Info(logMessageProxyWebSocketFrame, logFields...)
+}
+
+// describePayload redacts the captured text, pretty-prints it when it is JSON, and cuts it to the policy limit.
+func (frameLogger *webSocketFrameLogger) describePayload(capturedPayload []byte, partial bool) string {
+	payloadText := frameLogger.inspector.redact(string(capturedPayload))
+	if !partial && json.Valid([]byte(payloadText)) {
+		var indentedPayload bytes.Buffer
+		if json.Indent(&indentedPayload, []byte(payloadText), "", "  ") == nil {
+			payloadText = indentedPayload.String()
+		}
+	}
+	if len(payloadText) <= frameLogger.policy.payloadLimit {
+		if partial {
+			return payloadText + webSocketTruncatedSuffix
+		}
+		return payloadText
+	}
+	cutIndex := frameLogger.policy.payloadLimit
+	for cutIndex > 0 && !utf8.RuneStart(payloadText[cutIndex]) {
+		cutIndex--
+	}
+	return payloadText[:cutIndex] + webSocketTruncatedSuffix
+}
+
+func webSocketOpcodeName(opcode byte) string {
+	if opcodeName, known := webSocketOpcodeNames[opcode]; known {
+		return opcodeName
+	}
+	return "0x" + strconv.FormatUint(uint64(opcode), 16)
+}
+
+const webSocketInspectorPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gHTTP WebSocket frames</title>
+<style>
+body { font-family: ui-monospace, monospace; margin: 1rem; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.25rem 0.5rem; text-align: left; vertical-align: top; }
+td.payload { white-space: pre-wrap; word-break: break-all; }
+tr.in td.direction { color: #0a5; }
+tr.out td.direction { color: #05a; }
+</style>
+</head>
+<body>
+<h1>WebSocket frames</h1>
+<p id="status">connecting…</p>
+<table>
+<thead><tr><th>time</th><th>path</th><th>direction</th><th>opcode</th><th>size</th><th>payload</th></tr></thead>
+<tbody id="frames"></tbody>
+</table>
+<script>
+const frames = document.getElementById("frames");
+const status = document.getElementById("status");
+const source = new EventSource("/__ghttp/websocket/frames");
+source.onopen = () => { status.textContent = "live"; };
+source.onerror = () => { status.textContent = "disconnected, retrying…"; };
+source.onmessage = (message) => {
+  const frame = JSON.parse(message.data);
+  const row = document.createElement("tr");
+  row.className = frame.direction;
+  const flags = (frame.fin ? "" : " (partial)") + (frame.compressed ? " (compressed)" : "");
+  for (const [className, text] of [["", new Date(frame.time).toLocaleTimeString()], ["", frame.path], ["direction", frame.direction === "in" ? "client → backend" : "backend → client"], ["", frame.opcode + flags], ["", frame.size], ["payload", frame.payload || ""]]) {
+    const cell = document.createElement("td");
+    cell.className = className;
+    cell.textContent = text;
+    row.appendChild(cell);
+  }
+  frames.prepend(row);
+  while (frames.rows.length > 500) { frames.deleteRow(frames.rows.length - 1); }
+};
+</script>
+</body>
+</html>
+`
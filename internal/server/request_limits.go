@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	requestLimitMappingSeparator = "="
+	requestLimitOptionSeparator  = ":"
+	requestLimitOptionMaxBody    = "max_body_bytes"
+	requestLimitOptionWrite      = "write_timeout"
+)
+
+var ErrInvalidRequestLimit = errors.New("request.limit.invalid")
+
+// RequestLimits holds the request body size limit and per-route overrides of the body limit and the server's response
+// write timeout. Zero means unlimited for both.
+type RequestLimits struct {
+	maxBodyBytes int64
+	routes       []requestLimitRoute
+}
+
+type requestLimitRoute struct {
+	pathPrefix      string
+	maxBodyBytes    int64
+	hasMaxBodyBytes bool
+	writeTimeout    time.Duration
+	hasWriteTimeout bool
+}
+
+// NewRequestLimits combines the global body limit with /path=max_body_bytes:N and /path=write_timeout:duration
+// overrides. Each option is resolved separately from the longest prefix that sets it.
+func NewRequestLimits(maxBodyBytes int64, mappings []string) (RequestLimits, error) {
+	if maxBodyBytes < 0 {
+		return RequestLimits{}, fmt.Errorf("%w: max body bytes must not be negative", ErrInvalidRequestLimit)
+	}
+	routeByPathPrefix := map[string]requestLimitRoute{}
+	for _, mapping := range mappings {
+		pathPrefix, optionName, optionValue, parseErr := parseRequestLimitMapping(mapping)
+		if parseErr != nil {
+			return RequestLimits{}, parseErr
+		}
+		route := routeByPathPrefix[pathPrefix]
+		route.pathPrefix = pathPrefix
+		switch optionName {
+		case requestLimitOptionMaxBody:
+			parsedBytes, bytesErr := strconv.ParseInt(optionValue, 10, 64)
+			if bytesErr != nil || parsedBytes < 0 {
+				return RequestLimits{}, fmt.Errorf("%w: invalid %s %s", ErrInvalidRequestLimit, optionName, optionValue)
+			}
+			route.maxBodyBytes, route.hasMaxBodyBytes = parsedBytes, true
+		default:
+			parsedTimeout, timeoutErr := time.ParseDuration(optionValue)
+			if timeoutErr != nil || parsedTimeout < 0 {
+				return RequestLimits{}, fmt.Errorf("%w: invalid %s %s", ErrInvalidRequestLimit, optionName, optionValue)
+			}
+			route.writeTimeout, route.hasWriteTimeout = parsedTimeout, true
+		}
+		routeByPathPrefix[pathPrefix] = route
+	}
+
+	routes := make([]requestLimitRoute, 0, len(routeByPathPrefix))
+	for _, route := range routeByPathPrefix {
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(leftIndex int, rightIndex int) bool {
+		return len(routes[leftIndex].pathPrefix) > len(routes[rightIndex].pathPrefix)
+	})
+	return RequestLimits{maxBodyBytes: maxBodyBytes, routes: routes}, nil
+}
+
+// IsEmpty reports whether no body limit and no route write timeout is configured.
+func (limits RequestLimits) IsEmpty() bool {
+	return limits.maxBodyBytes == 0 && len(limits.routes) == 0
+}
+
+func (limits RequestLimits) maxBodyBytesFor(requestPath string) int64 {
+	for _, route := range limits.routes {
+		if route.hasMaxBodyBytes && strings.HasPrefix(requestPath, route.pathPrefix) {
+			return route.maxBodyBytes
+		}
+	}
+	return limits.maxBodyBytes
+}
+
+// writeTimeoutOverride returns the write timeout of the route, and false when the server-wide timeout applies.
+func (limits RequestLimits) writeTimeoutOverride(requestPath string) (time.Duration, bool) {
+	for _, route := range limits.routes {
+		if route.hasWriteTimeout && strings.HasPrefix(requestPath, route.pathPrefix) {
+			return route.writeTimeout, true
+		}
+	}
+	return 0, false
+}
+
+func parseRequestLimitMapping(mapping string) (string, string, string, error) {
+	trimmedMapping := strings.TrimSpace(mapping)
+	if trimmedMapping == "" {
+		return "", "", "", fmt.Errorf("%w: empty mapping", ErrInvalidRequestLimit)
+	}
+	pathPrefix, option, found := strings.Cut(trimmedMapping, requestLimitMappingSeparator)
+	if !found {
+		return "", "", "", fmt.Errorf("%w: mapping must be in /path=option:value form", ErrInvalidRequestLimit)
+	}
+	pathPrefix = strings.TrimSpace(pathPrefix)
+	if !strings.HasPrefix(pathPrefix, proxyPathPrefixStart) {
+		return "", "", "", fmt.Errorf("%w: path prefix must start with /", ErrInvalidRequestLimit)
+	}
+	optionName, optionValue, found := strings.Cut(strings.TrimSpace(option), requestLimitOptionSeparator)
+	if !found {
+		return "", "", "", fmt.Errorf("%w: option must be in option:value form", ErrInvalidRequestLimit)
+	}
+	optionName = strings.ToLower(strings.TrimSpace(optionName))
+	if optionName != requestLimitOptionMaxBody && optionName != requestLimitOptionWrite {
+		return "", "", "", fmt.Errorf("%w: unsupported option %s", ErrInvalidRequestLimit, optionName)
+	}
+	return pathPrefix, optionName, strings.TrimSpace(optionValue), nil
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	logMessageRequestBodyTooLarge  = "request body too large"
+	logMessageRequestBodyTimedOut  = "request body read timed out"
+	logMessageResponseWriteTimeout = "response write timed out"
+	logFieldLimit                  = "limit"
+	logFieldContentLength          = "content_length"
+)
+
+// requestLimitHandler enforces the request body limit and the route write timeouts. Requests whose body turns out to
+// be too large or too slow are answered with 413 or 408 instead of whatever the wrapped handler makes of the failed
+// read.
+type requestLimitHandler struct {
+	next           http.Handler
+	limits         RequestLimits
+	loggingService *logging.Service
+}
+
+func newRequestLimitHandler(next http.Handler, limits RequestLimits, loggingService *logging.Service) http.Handler {
+	return &requestLimitHandler{next: next, limits: limits, loggingService: loggingService}
+}
+
+func (handler *requestLimitHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	maxBodyBytes := handler.limits.maxBodyBytesFor(request.URL.Path)
+	if maxBodyBytes > 0 && request.ContentLength > maxBodyBytes {
+		handler.loggingService.Info(
+			logMessageRequestBodyTooLarge,
+			logging.String(logFieldPath, request.URL.Path),
+			logging.Int(logFieldLimit, int(maxBodyBytes)),
+			logging.Int(logFieldContentLength, int(request.ContentLength)),
+		)
+		writeRequestLimitError(responseWriter, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if writeTimeout, overridden := handler.limits.writeTimeoutOverride(request.URL.Path); overridden {
+		writeDeadline := time.Time{}
+		if writeTimeout > 0 {
+			writeDeadline = time.Now().Add(writeTimeout)
+		}
+		_ = http.NewResponseController(responseWriter).SetWriteDeadline(writeDeadline)
+	}
+
+	startTime := time.Now()
+	limitedBody := &limitedRequestBody{body: request.Body, limit: maxBodyBytes, remainingBytes: maxBodyBytes}
+	if request.Body != nil && request.Body != http.NoBody {
+		request.Body = limitedBody
+	}
+	limitWriter := &requestLimitWriter{ResponseWriter: responseWriter, requestBody: limitedBody}
+	// Deferred so that handlers aborting with http.ErrAbortHandler after a failed write are still reported.
+	defer handler.logViolation(limitWriter, request.URL.Path, maxBodyBytes, startTime)
+	handler.next.ServeHTTP(limitWriter, request)
+}
+
+func (handler *requestLimitHandler) logViolation(limitWriter *requestLimitWriter, requestPath string, maxBodyBytes int64, startTime time.Time) {
+	switch {
+	case limitWriter.replacedStatus == http.StatusRequestEntityTooLarge:
+		handler.loggingService.Info(
+			logMessageRequestBodyTooLarge,
+			logging.String(logFieldPath, requestPath),
+			logging.Int(logFieldLimit, int(maxBodyBytes)),
+		)
+	case limitWriter.replacedStatus == http.StatusRequestTimeout:
+		handler.loggingService.Info(
+			logMessageRequestBodyTimedOut,
+			logging.String(logFieldPath, requestPath),
+			logging.Duration(logFieldDuration, time.Since(startTime)),
+		)
+	case limitWriter.writeTimedOut.Load():
+		handler.loggingService.Info(
+			logMessageResponseWriteTimeout,
+			logging.String(logFieldPath, requestPath),
+			logging.Duration(logFieldDuration, time.Since(startTime)),
+		)
+	}
+}
+
+func writeRequestLimitError(responseWriter http.ResponseWriter, statusCode int) {
+	responseWriter.Header().Set(headerConnection, connectionCloseValue)
+	http.Error(responseWriter, http.StatusText(statusCode), statusCode)
+}
+
+// limitedRequestBody fails reads past the byte limit and remembers whether the body was cut off or timed out.
+type limitedRequestBody struct {
+	body           io.ReadCloser
+	limit          int64
+	remainingBytes int64
+	mutex          sync.Mutex
+	exceeded       bool
+	timedOut       bool
+}
+
+func (body *limitedRequestBody) Read(buffer []byte) (int, error) {
+	if body.limit > 0 {
+		if body.remainingBytes <= 0 {
+			// One more byte tells a body that ends exactly at the limit apart from a larger one.
+			var probe [1]byte
+			probeCount, probeErr := body.body.Read(probe[:])
+			if probeCount == 0 {
+				return 0, body.observe(probeErr)
+			}
+			body.mutex.Lock()
+			body.exceeded = true
+			body.mutex.Unlock()
+			return 0, &http.MaxBytesError{Limit: body.limit}
+		}
+		buffer = buffer[:min(int64(len(buffer)), body.remainingBytes)]
+	}
+	readCount, readErr := body.body.Read(buffer)
+	body.remainingBytes -= int64(readCount)
+	return readCount, body.observe(readErr)
+}
+
+func (body *limitedRequestBody) observe(readErr error) error {
+	var networkErr net.Error
+	if readErr != nil && (errors.Is(readErr, os.ErrDeadlineExceeded) || (errors.As(readErr, &networkErr) && networkErr.Timeout())) {
+		body.mutex.Lock()
+		body.timedOut = true
+		body.mutex.Unlock()
+	}
+	return readErr
+}
+
+func (body *limitedRequestBody) Close() error {
+	return body.body.Close()
+}
+
+func (body *limitedRequestBody) violation() int {
+	body.mutex.Lock()
+	defer body.mutex.Unlock()
+	switch {
+	case body.exceeded:
+		return http.StatusRequestEntityTooLarge
+	case body.timedOut:
+		return http.StatusRequestTimeout
+	default:
+		return 0
+	}
+}
+
+// requestLimitWriter swaps the wrapped handler's response for 413 or 408 when the request body failed before the
+// response started, and notes writes that ran into the write deadline.
+type requestLimitWriter struct {
+	http.ResponseWriter
+	requestBody    *limitedRequestBody
+	wroteHeader    bool
+	replacedStatus int
+	writeTimedOut  atomic.Bool
+}
+
+func (writer *requestLimitWriter) WriteHeader(statusCode int) {
+	if writer.wroteHeader {
+		return
+	}
+	if statusCode >= http.StatusOK {
+		writer.wroteHeader = true
+		if violationStatus := writer.requestBody.violation(); violationStatus != 0 {
+			writer.replacedStatus = violationStatus
+			writeRequestLimitError(writer.ResponseWriter, violationStatus)
+			return
+		}
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *requestLimitWriter) Write(payload []byte) (int, error) {
+	if !writer.wroteHeader {
+		writer.WriteHeader(http.StatusOK)
+	}
+	if writer.replacedStatus != 0 {
+		return len(payload), nil
+	}
+	writtenBytes, writeErr := writer.ResponseWriter.Write(payload)
+	if writeErr != nil && errors.Is(writeErr, os.ErrDeadlineExceeded) {
+		writer.writeTimedOut.Store(true)
+	}
+	return writtenBytes, writeErr
+}
+
+func (writer *requestLimitWriter) Flush() {
+	if writer.replacedStatus != 0 {
+		return
+	}
+	if flushErr := http.NewResponseController(writer.ResponseWriter).Flush(); flushErr != nil && errors.Is(flushErr, os.ErrDeadlineExceeded) {
+		writer.writeTimedOut.Store(true)
+	}
+}
+
+func (writer *requestLimitWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(writer.ResponseWriter).Hijack()
+}
+
+func (writer *requestLimitWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
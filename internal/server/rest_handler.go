@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	requestHandlerREST           = "rest"
+	logMessageRESTStoreReloaded  = "rest store reloaded"
+	logMessageRESTStoreInvalid   = "rest store reload failed"
+	logMessageRESTStoreSaved     = "rest store saved"
+	logMessageRESTStoreSaveError = "rest store save failed"
+	logFieldStoreFile            = "file"
+	logFieldCollection           = "collection"
+	headerTotalCount             = "X-Total-Count"
+	headerLink                   = "Link"
+	restContentType              = "application/json; charset=utf-8"
+	restAllowDocument            = "GET, HEAD"
+	restAllowCollection          = "GET, HEAD, POST"
+	restAllowSingular            = "GET, HEAD, PUT, PATCH"
+	restAllowItem                = "GET, HEAD, PUT, PATCH, DELETE"
+)
+
+type restHandler struct {
+	next           http.Handler
+	restStores     *RESTStores
+	loggingService *logging.Service
+}
+
+// restResponse is computed under the store lock and written after it is released; bodies are never mutated once
+// they are part of a document, so encoding them later is safe.
+type restResponse struct {
+	statusCode int
+	body       any
+	header     http.Header
+}
+
+func newRESTHandler(next http.Handler, restStores *RESTStores, loggingService *logging.Service) http.Handler {
+	return &restHandler{next: next, restStores: restStores, loggingService: loggingService}
+}
+
+func (handler *restHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	store, resourceSegments, matched := handler.restStores.match(request.URL.Path)
+	if !matched {
+		handler.next.ServeHTTP(responseWriter, request)
+		return
+	}
+	markRequestHandler(request, requestHandlerREST)
+
+	response := handler.respond(store, resourceSegments, request)
+	for headerName, headerValues := range response.header {
+		responseWriter.Header()[headerName] = headerValues
+	}
+	if response.body == nil {
+		responseWriter.WriteHeader(response.statusCode)
+		return
+	}
+	responseWriter.Header().Set(headerContentType, restContentType)
+	responseWriter.WriteHeader(response.statusCode)
+	if request.Method != http.MethodHead {
+		encoder := json.NewEncoder(responseWriter)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(response.body)
+	}
+}
+
+// respond decodes the request body before taking the store lock, so a slow upload never blocks other requests.
+func (handler *restHandler) respond(store *restStore, resourceSegments []string, request *http.Request) restResponse {
+	body := readRESTRequestBody(request)
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	reloaded, reloadErr := store.reloadIfChanged()
+	if reloadErr != nil {
+		handler.loggingService.Error(logMessageRESTStoreInvalid, reloadErr, logging.String(logFieldStoreFile, store.filePath))
+	} else if reloaded {
+		handler.loggingService.Info(logMessageRESTStoreReloaded, logging.String(logFieldStoreFile, store.filePath))
+	}
+
+	switch len(resourceSegments) {
+	case 0:
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			return restMethodNotAllowed(restAllowDocument)
+		}
+		return restResponse{statusCode: http.StatusOK, body: store.document}
+	case 1:
+		return handler.respondCollection(store, resourceSegments[0], request, body)
+	case 2:
+		return handler.respondItem(store, resourceSegments[0], resourceSegments[1], request, body)
+	default:
+		return restError(http.StatusNotFound, "not found")
+	}
+}
+
+func (handler *restHandler) respondCollection(store *restStore, collectionName string, request *http.Request, body restRequestBody) restResponse {
+	value, exists := store.document[collectionName]
+	if !exists {
+		return restError(http.StatusNotFound, "unknown collection "+collectionName)
+	}
+	items, isCollection := value.([]any)
+	if !isCollection {
+		return handler.respondSingular(store, collectionName, value, request, body)
+	}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		listQuery, queryErr := parseRESTListQuery(request.URL.Query())
+		if queryErr != nil {
+			return restError(http.StatusBadRequest, queryErr.Error())
+		}
+		listedItems, totalCount := listQuery.apply(items)
+		response := restResponse{statusCode: http.StatusOK, body: listedItems, header: http.Header{}}
+		response.header.Set(headerTotalCount, strconv.Itoa(totalCount))
+		if pageLinks := listQuery.links(request.URL, totalCount); pageLinks != "" {
+			response.header.Set(headerLink, pageLinks)
+		}
+		return response
+	case http.MethodPost:
+		item, decodeErr := body.object()
+		if decodeErr != nil {
+			return restError(http.StatusBadRequest, decodeErr.Error())
+		}
+		insertedItem, insertErr := store.insertItem(collectionName, item)
+		if errors.Is(insertErr, errRESTDuplicateID) {
+			return restError(http.StatusConflict, insertErr.Error())
+		}
+		if insertErr != nil {
+			return handler.saveFailed(store, collectionName, insertErr)
+		}
+		handler.logSaved(store, collectionName, request)
+		response := restResponse{statusCode: http.StatusCreated, body: insertedItem, header: http.Header{}}
+		response.header.Set(headerLocation, store.mountPath+restPathSeparator+collectionName+restPathSeparator+url.PathEscape(fmt.Sprint(insertedItem[restIDField])))
+		return response
+	default:
+		return restMethodNotAllowed(restAllowCollection)
+	}
+}
+
+// respondSingular serves a top-level value that is not a collection, such as a profile object or a settings flag.
+func (handler *restHandler) respondSingular(store *restStore, resourceName string, value any, request *http.Request, body restRequestBody) restResponse {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		return restResponse{statusCode: http.StatusOK, body: value}
+	case http.MethodPut, http.MethodPatch:
+		if body.err != nil {
+			return restError(http.StatusBadRequest, "request body must be JSON")
+		}
+		replacement := body.value
+		if request.Method == http.MethodPatch {
+			existingObject, existingIsObject := value.(map[string]any)
+			patch, patchIsObject := replacement.(map[string]any)
+			if !existingIsObject || !patchIsObject {
+				return restError(http.StatusBadRequest, "PATCH needs a JSON object on both sides")
+			}
+			replacement = mergeRESTObject(existingObject, patch)
+		}
+		if !isValidRESTValue(replacement) {
+			return restError(http.StatusBadRequest, "an array replacing "+resourceName+" must only hold objects")
+		}
+		if saveErr := store.save(store.withValue(resourceName, replacement)); saveErr != nil {
+			return handler.saveFailed(store, resourceName, saveErr)
+		}
+		handler.logSaved(store, resourceName, request)
+		return restResponse{statusCode: http.StatusOK, body: replacement}
+	default:
+		return restMethodNotAllowed(restAllowSingular)
+	}
+}
+
+func (handler *restHandler) respondItem(store *restStore, collectionName string, itemID string, request *http.Request, body restRequestBody) restResponse {
+	items, isCollection := store.collection(collectionName)
+	if !isCollection {
+		return restError(http.StatusNotFound, "unknown collection "+collectionName)
+	}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		if item, _ := findRESTItem(items, itemID); item != nil {
+			return restResponse{statusCode: http.StatusOK, body: item}
+		}
+		return restError(http.StatusNotFound, "item "+itemID+" not found")
+	case http.MethodPut, http.MethodPatch:
+		item, decodeErr := body.object()
+		if decodeErr != nil {
+			return restError(http.StatusBadRequest, decodeErr.Error())
+		}
+		updatedItem, updateErr := store.updateItem(collectionName, itemID, item, request.Method == http.MethodPatch)
+		if errors.Is(updateErr, errRESTItemNotFound) {
+			return restError(http.StatusNotFound, "item "+itemID+" not found")
+		}
+		if updateErr != nil {
+			return handler.saveFailed(store, collectionName, updateErr)
+		}
+		handler.logSaved(store, collectionName, request)
+		return restResponse{statusCode: http.StatusOK, body: updatedItem}
+	case http.MethodDelete:
+		deleteErr := store.deleteItem(collectionName, itemID)
+		if errors.Is(deleteErr, errRESTItemNotFound) {
+			return restError(http.StatusNotFound, "item "+itemID+" not found")
+		}
+		if deleteErr != nil {
+			return handler.saveFailed(store, collectionName, deleteErr)
+		}
+		handler.logSaved(store, collectionName, request)
+		return restResponse{statusCode: http.StatusNoContent}
+	default:
+		return restMethodNotAllowed(restAllowItem)
+	}
+}
+
+func (handler *restHandler) logSaved(store *restStore, collectionName string, request *http.Request) {
+	handler.loggingService.Info(
+		logMessageRESTStoreSaved,
+		logging.String(logFieldStoreFile, store.filePath),
+		logging.String(logFieldCollection, collectionName),
+		logging.String(logFieldMethod, request.Method),
+	)
+}
+
+func (handler *restHandler) saveFailed(store *restStore, collectionName string, saveErr error) restResponse {
+	handler.loggingService.Error(
+		logMessageRESTStoreSaveError,
+		saveErr,
+		logging.String(logFieldStoreFile, store.filePath),
+		logging.String(logFieldCollection, collectionName),
+	)
+	return restError(http.StatusInternalServerError, "rest store save failed")
+}
+
+// restRequestBody is the JSON body of a write request, decoded before the store lock is taken.
+type restRequestBody struct {
+	value any
+	err   error
+}
+
+func readRESTRequestBody(request *http.Request) restRequestBody {
+	if request.Method != http.MethodPost && request.Method != http.MethodPut && request.Method != http.MethodPatch {
+		return restRequestBody{}
+	}
+	var value any
+	decoder := json.NewDecoder(request.Body)
+	decoder.UseNumber()
+	decodeErr := decoder.Decode(&value)
+	return restRequestBody{value: value, err: decodeErr}
+}
+
+func (body restRequestBody) object() (map[string]any, error) {
+	item, isObject := body.value.(map[string]any)
+	if body.err != nil || !isObject {
+		return nil, errors.New("request body must be a JSON object")
+	}
+	return item, nil
+}
+
+func restError(statusCode int, message string) restResponse {
+	return restResponse{statusCode: statusCode, body: map[string]string{"error": message}}
+}
+
+func restMethodNotAllowed(allowedMethods string) restResponse {
+	response := restError(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+	response.header = http.Header{"Allow": []string{allowedMethods}}
+	return response
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	restQueryPage         = "_page"
+	restQueryLimit        = "_limit"
+	restQueryStart        = "_start"
+	restQueryEnd          = "_end"
+	restQuerySort         = "_sort"
+	restQueryOrder        = "_order"
+	restQuerySearch       = "q"
+	restQueryReserved     = "_"
+	restOperatorNotEqual  = "_ne"
+	restOperatorLike      = "_like"
+	restOperatorAtLeast   = "_gte"
+	restOperatorAtMost    = "_lte"
+	restOrderDescending   = "desc"
+	restFieldSeparator    = "."
+	restListSeparator     = ","
+	restDefaultPageLimit  = 10
+	restLinkRelationFirst = "first"
+	restLinkRelationPrev  = "prev"
+	restLinkRelationNext  = "next"
+	restLinkRelationLast  = "last"
+)
+
+// restListQuery is the json-server style query of a collection listing: field filters (field=value, field_ne,
+// field_like, field_gte, field_lte, with dotted paths into nested objects), full-text q, _sort/_order, and either
+// _page/_limit or _start/_end/_limit slicing.
+type restListQuery struct {
+	filters    []restFilter
+	search     string
+	sortFields []string
+	descending []bool
+	page       int
+	limit      int
+	start      int
+	end        int
+}
+
+type restFilter struct {
+	fieldPath []string
+	operator  string
+	values    []string
+	patterns  []*regexp.Regexp
+}
+
+func parseRESTListQuery(query url.Values) (restListQuery, error) {
+	listQuery := restListQuery{page: -1, limit: -1, start: -1, end: -1}
+	for parameterName, target := range map[string]*int{
+		restQueryPage:  &listQuery.page,
+		restQueryLimit: &listQuery.limit,
+		restQueryStart: &listQuery.start,
+		restQueryEnd:   &listQuery.end,
+	} {
+		if !query.Has(parameterName) {
+			continue
+		}
+		parsedValue, parseErr := strconv.Atoi(query.Get(parameterName))
+		if parseErr != nil || parsedValue < 0 || (parameterName == restQueryPage && parsedValue == 0) {
+			return restListQuery{}, fmt.Errorf("invalid %s %q", parameterName, query.Get(parameterName))
+		}
+		*target = parsedValue
+	}
+	if listQuery.page > 0 && listQuery.page > math.MaxInt/listQuery.pageLimit() {
+		return restListQuery{}, fmt.Errorf("invalid %s %q", restQueryPage, query.Get(restQueryPage))
+	}
+	if listQuery.start > 0 && listQuery.limit > 0 && listQuery.start > math.MaxInt-listQuery.limit {
+		return restListQuery{}, fmt.Errorf("invalid %s %q", restQueryStart, query.Get(restQueryStart))
+	}
+	listQuery.search = strings.ToLower(query.Get(restQuerySearch))
+	if sortValue := query.Get(restQuerySort); sortValue != "" {
+		orders := strings.Split(query.Get(restQueryOrder), restListSeparator)
+		for sortIndex, sortField := range strings.Split(sortValue, restListSeparator) {
+			listQuery.sortFields = append(listQuery.sortFields, strings.TrimSpace(sortField))
+			listQuery.descending = append(listQuery.descending, sortIndex < len(orders) && strings.EqualFold(strings.TrimSpace(orders[sortIndex]), restOrderDescending))
+		}
+	}
+
+	for parameterName, parameterValues := range query {
+		if parameterName == restQuerySearch || strings.HasPrefix(parameterName, restQueryReserved) {
+			continue
+		}
+		filter := restFilter{values: parameterValues}
+		fieldName := parameterName
+		for _, operator := range []string{restOperatorNotEqual, restOperatorLike, restOperatorAtLeast, restOperatorAtMost} {
+			if trimmedName, hasOperator := strings.CutSuffix(parameterName, operator); hasOperator && trimmedName != "" {
+				fieldName, filter.operator = trimmedName, operator
+				break
+			}
+		}
+		filter.fieldPath = strings.Split(fieldName, restFieldSeparator)
+		if filter.operator == restOperatorLike {
+			for _, parameterValue := range parameterValues {
+				pattern, compileErr := regexp.Compile("(?i)" + parameterValue)
+				if compileErr != nil {
+					return restListQuery{}, fmt.Errorf("invalid %s pattern %q", parameterName, parameterValue)
+				}
+				filter.patterns = append(filter.patterns, pattern)
+			}
+		}
+		listQuery.filters = append(listQuery.filters, filter)
+	}
+	return listQuery, nil
+}
+
+// apply filters, sorts, and slices the items, returning the slice and the number of items before slicing.
+func (listQuery restListQuery) apply(items []any) ([]any, int) {
+	matchingItems := make([]any, 0, len(items))
+	for _, item := range items {
+		if itemObject, isObject := item.(map[string]any); isObject && listQuery.matches(itemObject) {
+			matchingItems = append(matchingItems, item)
+		}
+	}
+	if len(listQuery.sortFields) > 0 {
+		sort.SliceStable(matchingItems, func(leftIndex int, rightIndex int) bool {
+			for sortIndex, sortField := range listQuery.sortFields {
+				fieldPath := strings.Split(sortField, restFieldSeparator)
+				leftValue, leftFound := restFieldValue(matchingItems[leftIndex], fieldPath)
+				rightValue, rightFound := restFieldValue(matchingItems[rightIndex], fieldPath)
+				if leftFound != rightFound {
+					return leftFound
+				}
+				comparison := compareRESTText(restValueText(leftValue), restValueText(rightValue))
+				if comparison != 0 {
+					return (comparison < 0) != listQuery.descending[sortIndex]
+				}
+			}
+			return false
+		})
+	}
+
+	totalCount := len(matchingItems)
+	start, end := 0, totalCount
+	switch {
+	case listQuery.page > 0:
+		limit := listQuery.pageLimit()
+		start, end = (listQuery.page-1)*limit, listQuery.page*limit
+	case listQuery.start >= 0 || listQuery.end >= 0 || listQuery.limit >= 0:
+		start = max(listQuery.start, 0)
+		if listQuery.limit >= 0 {
+			end = start + listQuery.limit
+		} else if listQuery.end >= 0 {
+			end = listQuery.end
+		}
+	}
+	start = min(max(start, 0), totalCount)
+	end = min(max(end, start), totalCount)
+	return matchingItems[start:end], totalCount
+}
+
+func (listQuery restListQuery) pageLimit() int {
+	if listQuery.limit > 0 {
+		return listQuery.limit
+	}
+	return restDefaultPageLimit
+}
+
+// links builds the Link header for paged listings, pointing at the first, previous, next, and last pages.
+func (listQuery restListQuery) links(requestURL *url.URL, totalCount int) string {
+	if listQuery.page <= 0 {
+		return ""
+	}
+	lastPage := max(1, (totalCount+listQuery.pageLimit()-1)/listQuery.pageLimit())
+	pageLink := func(page int, relation string) string {
+		pageQuery := requestURL.Query()
+		pageQuery.Set(restQueryPage, strconv.Itoa(page))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, requestURL.Path, pageQuery.Encode(), relation)
+	}
+	pageLinks := []string{pageLink(1, restLinkRelationFirst)}
+	if listQuery.page > 1 {
+		pageLinks = append(pageLinks, pageLink(min(listQuery.page-1, lastPage), restLinkRelationPrev))
+	}
+	if listQuery.page < lastPage {
+		pageLinks = append(pageLinks, pageLink(listQuery.page+1, restLinkRelationNext))
+	}
+	pageLinks = append(pageLinks, pageLink(lastPage, restLinkRelationLast))
+	return strings.Join(pageLinks, ", ")
+}
+
+func (listQuery restListQuery) matches(item map[string]any) bool {
+	for _, filter := range listQuery.filters {
+		fieldValue, found := restFieldValue(item, filter.fieldPath)
+		if !filter.matches(restValueText(fieldValue), found) {
+			return false
+		}
+	}
+	return listQuery.search == "" || restValueContains(item, listQuery.search)
+}
+
+func (filter restFilter) matches(fieldText string, found bool) bool {
+	switch filter.operator {
+	case restOperatorNotEqual:
+		return !found || !containsString(filter.values, fieldText)
+	case restOperatorLike:
+		for _, pattern := range filter.patterns {
+			if found && pattern.MatchString(fieldText) {
+				return true
+			}
+		}
+		return false
+	case restOperatorAtLeast:
+		return found && compareRESTText(fieldText, filter.values[0]) >= 0
+	case restOperatorAtMost:
+		return found && compareRESTText(fieldText, filter.values[0]) <= 0
+	default:
+		return found && containsString(filter.values, fieldText)
+	}
+}
+
+func containsString(values []string, candidate string) bool {
+	for _, value := range values {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func restFieldValue(item any, fieldPath []string) (any, bool) {
+	var current any = item
+	for _, fieldName := range fieldPath {
+		currentObject, isObject := current.(map[string]any)
+		if !isObject {
+			return nil, false
+		}
+		fieldValue, found := currentObject[fieldName]
+		if !found {
+			return nil, false
+		}
+		current = fieldValue
+	}
+	return current, true
+}
+
+// restValueText renders a JSON value the way it is compared with query strings: strings as is, scalars in their
+// JSON form, and objects and arrays as compact JSON.
+func restValueText(value any) string {
+	if text, isString := value.(string); isString {
+		return text
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// compareRESTText compares numerically when both sides are numbers and lexically otherwise.
+func compareRESTText(left string, right string) int {
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case leftNumber < rightNumber:
+			return -1
+		case leftNumber > rightNumber:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(left, right)
+}
+
+func restValueContains(value any, search string) bool {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		for _, fieldValue := range typedValue {
+			if restValueContains(fieldValue, search) {
+				return true
+			}
+		}
+		return false
+	case []any:
+		for _, element := range typedValue {
+			if restValueContains(element, search) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	default:
+		return strings.Contains(strings.ToLower(restValueText(typedValue)), search)
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	restMappingSeparator = "="
+	restPathSeparator    = "/"
+	restIDField          = "id"
+	restFileExtension    = ".json"
+	restFileIndent       = "  "
+)
+
+var (
+	ErrInvalidRESTStore = errors.New("rest.store.invalid")
+	errRESTItemNotFound = errors.New("rest item not found")
+	errRESTDuplicateID  = errors.New("rest item id already exists")
+)
+
+// RESTStores holds the JSON files exposed as REST collections, ordered by longest mount path.
+type RESTStores struct {
+	stores []*restStore
+}
+
+// restStore keeps the decoded document of one JSON file. Collections are top-level arrays of objects; any other
+// top-level value is a singular resource. Changes are written to a temporary file that is renamed over the original,
+// and edits made to the file by someone else are picked up on the next request.
+type restStore struct {
+	mountPath   string
+	filePath    string
+	mutex       sync.Mutex
+	document    map[string]any
+	fileState   restFileState
+	failedState restFileState
+}
+
+type restFileState struct {
+	modificationTime time.Time
+	size             int64
+}
+
+// NewRESTStores parses /mount=file.json mappings. Files are resolved inside directoryPath, must not escape it, and
+// must exist.
+func NewRESTStores(mappings []string, directoryPath string) (*RESTStores, error) {
+	restStores := &RESTStores{}
+	seenMountPaths := map[string]struct{}{}
+	for _, mapping := range mappings {
+		mountPath, fileName, found := strings.Cut(strings.TrimSpace(mapping), restMappingSeparator)
+		mountPath = strings.TrimRight(strings.TrimSpace(mountPath), restPathSeparator)
+		fileName = strings.TrimSpace(fileName)
+		if !found || fileName == "" {
+			return nil, fmt.Errorf("%w: mapping %q must be in /path=file.json form", ErrInvalidRESTStore, mapping)
+		}
+		if !strings.HasPrefix(mountPath, restPathSeparator) {
+			return nil, fmt.Errorf("%w: mount path %q must start with / and not be the root", ErrInvalidRESTStore, mountPath)
+		}
+		if _, duplicate := seenMountPaths[mountPath]; duplicate {
+			return nil, fmt.Errorf("%w: mount path %s is mapped more than once", ErrInvalidRESTStore, mountPath)
+		}
+		seenMountPaths[mountPath] = struct{}{}
+		if !strings.EqualFold(filepath.Ext(fileName), restFileExtension) {
+			return nil, fmt.Errorf("%w: %s must have a .json extension", ErrInvalidRESTStore, fileName)
+		}
+		if !filepath.IsLocal(filepath.FromSlash(fileName)) {
+			return nil, fmt.Errorf("%w: %s must be a relative path inside the served directory", ErrInvalidRESTStore, fileName)
+		}
+		store := &restStore{mountPath: mountPath, filePath: filepath.Join(directoryPath, filepath.FromSlash(fileName))}
+		document, fileState, loadErr := store.load()
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		store.document = document
+		store.fileState = fileState
+		restStores.stores = append(restStores.stores, store)
+	}
+	sort.SliceStable(restStores.stores, func(leftIndex int, rightIndex int) bool {
+		return len(restStores.stores[leftIndex].mountPath) > len(restStores.stores[rightIndex].mountPath)
+	})
+	return restStores, nil
+}
+
+// match returns the store mounted at the request path and the path segments below the mount.
+func (restStores *RESTStores) match(requestPath string) (*restStore, []string, bool) {
+	for _, store := range restStores.stores {
+		if requestPath != store.mountPath && !strings.HasPrefix(requestPath, store.mountPath+restPathSeparator) {
+			continue
+		}
+		resourceSegments := []string{}
+		for _, segment := range strings.Split(strings.TrimPrefix(requestPath, store.mountPath), restPathSeparator) {
+			if segment != "" {
+				resourceSegments = append(resourceSegments, segment)
+			}
+		}
+		return store, resourceSegments, true
+	}
+	return nil, nil, false
+}
+
+func (store *restStore) load() (map[string]any, restFileState, error) {
+	fileInfo, statErr := os.Stat(store.filePath)
+	if statErr != nil {
+		return nil, restFileState{}, fmt.Errorf("%w: %s", ErrInvalidRESTStore, statErr.Error())
+	}
+	fileState := restFileState{modificationTime: fileInfo.ModTime(), size: fileInfo.Size()}
+	fileBytes, readErr := os.ReadFile(store.filePath)
+	if readErr != nil {
+		return nil, fileState, fmt.Errorf("%w: %s", ErrInvalidRESTStore, readErr.Error())
+	}
+	var document map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(fileBytes))
+	decoder.UseNumber()
+	if decodeErr := decoder.Decode(&document); decodeErr != nil || document == nil {
+		return nil, fileState, fmt.Errorf("%w: %s must hold a JSON object of collections", ErrInvalidRESTStore, store.filePath)
+	}
+	for collectionName, collectionValue := range document {
+		if !isValidRESTValue(collectionValue) {
+			return nil, fileState, fmt.Errorf("%w: collection %s in %s must only hold objects", ErrInvalidRESTStore, collectionName, store.filePath)
+		}
+	}
+	return document, fileState, nil
+}
+
+// isValidRESTValue reports whether a top-level value can be stored: arrays are collections and must only hold
+// objects, anything else is a singular resource.
+func isValidRESTValue(value any) bool {
+	items, isCollection := value.([]any)
+	if !isCollection {
+		return true
+	}
+	for _, item := range items {
+		if _, isObject := item.(map[string]any); !isObject {
+			return false
+		}
+	}
+	return true
+}
+
+// reloadIfChanged re-reads the file when its size or modification time changed. A file that fails to parse is
+// reported once and the previously loaded document stays active.
+func (store *restStore) reloadIfChanged() (bool, error) {
+	if fileInfo, statErr := os.Stat(store.filePath); statErr == nil {
+		fileState := restFileState{modificationTime: fileInfo.ModTime(), size: fileInfo.Size()}
+		if fileState == store.fileState || fileState == store.failedState {
+			return false, nil
+		}
+	}
+	document, fileState, loadErr := store.load()
+	if loadErr != nil {
+		if fileState == store.failedState {
+			return false, nil
+		}
+		store.failedState = fileState
+		return false, loadErr
+	}
+	store.document = document
+	store.fileState = fileState
+	store.failedState = restFileState{}
+	return true, nil
+}
+
+// save writes the document next to the file and renames it into place, so readers and file watchers only ever see
+// a complete file. The in-memory document changes only once the write succeeded.
+func (store *restStore) save(document map[string]any) error {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", restFileIndent)
+	if encodeErr := encoder.Encode(document); encodeErr != nil {
+		return fmt.Errorf("encode rest store: %w", encodeErr)
+	}
+	fileMode := os.FileMode(0o644)
+	if fileInfo, statErr := os.Stat(store.filePath); statErr == nil {
+		fileMode = fileInfo.Mode().Perm()
+	}
+	temporaryFile, createErr := os.CreateTemp(filepath.Dir(store.filePath), "."+filepath.Base(store.filePath)+"-*")
+	if createErr != nil {
+		return fmt.Errorf("write rest store: %w", createErr)
+	}
+	_, writeErr := temporaryFile.Write(encoded.Bytes())
+	chmodErr := temporaryFile.Chmod(fileMode)
+	syncErr := temporaryFile.Sync()
+	closeErr := temporaryFile.Close()
+	if writeErr != nil || chmodErr != nil || syncErr != nil || closeErr != nil {
+		_ = os.Remove(temporaryFile.Name())
+		return fmt.Errorf("write rest store: %w", errors.Join(writeErr, chmodErr, syncErr, closeErr))
+	}
+	if renameErr := os.Rename(temporaryFile.Name(), store.filePath); renameErr != nil {
+		_ = os.Remove(temporaryFile.Name())
+		return fmt.Errorf("write rest store: %w", renameErr)
+	}
+	store.document = document
+	if fileInfo, statErr := os.Stat(store.filePath); statErr == nil {
+		store.fileState = restFileState{modificationTime: fileInfo.ModTime(), size: fileInfo.Size()}
+	}
+	return nil
+}
+
+// withValue returns a shallow copy of the document with one top-level value replaced, leaving the loaded document
+// untouched until it is saved.
+func (store *restStore) withValue(name string, value any) map[string]any {
+	document := make(map[string]any, len(store.document)+1)
+	for existingName, existingValue := range store.document {
+		document[existingName] = existingValue
+	}
+	document[name] = value
+	return document
+}
+
+func (store *restStore) collection(collectionName string) ([]any, bool) {
+	items, isCollection := store.document[collectionName].([]any)
+	return items, isCollection
+}
+
+func findRESTItem(items []any, itemID string) (map[string]any, int) {
+	for itemIndex, item := range items {
+		itemObject, isObject := item.(map[string]any)
+		if !isObject {
+			continue
+		}
+		if identifier, hasID := itemObject[restIDField]; hasID && fmt.Sprint(identifier) == itemID {
+			return itemObject, itemIndex
+		}
+	}
+	return nil, -1
+}
+
+// insertItem appends an item to a collection. Items without an id get the next integer id when every existing id is
+// an integer, and a random hexadecimal id otherwise.
+func (store *restStore) insertItem(collectionName string, item map[string]any) (map[string]any, error) {
+	items, _ := store.collection(collectionName)
+	if identifier, hasID := item[restIDField]; hasID {
+		if existingItem, _ := findRESTItem(items, fmt.Sprint(identifier)); existingItem != nil {
+			return nil, errRESTDuplicateID
+		}
+	} else {
+		item[restIDField] = nextRESTItemID(items)
+	}
+	updatedItems := append(append(make([]any, 0, len(items)+1), items...), item)
+	if saveErr := store.save(store.withValue(collectionName, updatedItems)); saveErr != nil {
+		return nil, saveErr
+	}
+	return item, nil
+}
+
+// updateItem replaces an item, or merges the top-level fields of patch into it, keeping its id.
+func (store *restStore) updateItem(collectionName string, itemID string, patch map[string]any, merge bool) (map[string]any, error) {
+	items, _ := store.collection(collectionName)
+	existingItem, itemIndex := findRESTItem(items, itemID)
+	if existingItem == nil {
+		return nil, errRESTItemNotFound
+	}
+	updatedItem := patch
+	if merge {
+		updatedItem = mergeRESTObject(existingItem, patch)
+	}
+	updatedItem[restIDField] = existingItem[restIDField]
+	updatedItems := append(make([]any, 0, len(items)), items...)
+	updatedItems[itemIndex] = updatedItem
+	if saveErr := store.save(store.withValue(collectionName, updatedItems)); saveErr != nil {
+		return nil, saveErr
+	}
+	return updatedItem, nil
+}
+
+func (store *restStore) deleteItem(collectionName string, itemID string) error {
+	items, _ := store.collection(collectionName)
+	if existingItem, itemIndex := findRESTItem(items, itemID); existingItem != nil {
+		updatedItems := append(append(make([]any, 0, len(items)-1), items[:itemIndex]...), items[itemIndex+1:]...)
+		return store.save(store.withValue(collectionName, updatedItems))
+	}
+	return errRESTItemNotFound
+}
+
+func mergeRESTObject(existingObject map[string]any, patch map[string]any) map[string]any {
+	mergedObject := make(map[string]any, len(existingObject)+len(patch))
+	for fieldName, fieldValue := range existingObject {
+		mergedObject[fieldName] = fieldValue
+	}
+	for fieldName, fieldValue := range patch {
+		mergedObject[fieldName] = fieldValue
+	}
+	return mergedObject
+}
+
+func nextRESTItemID(items []any) any {
+	var highestID int64
+	for _, item := range items {
+		itemObject, _ := item.(map[string]any)
+		identifier, parseErr := strconv.ParseInt(fmt.Sprint(itemObject[restIDField]), 10, 64)
+		if parseErr != nil {
+			randomBytes := make([]byte, 8)
+			_, _ = rand.Read(randomBytes)
+			return hex.EncodeToString(randomBytes)
+		}
+		highestID = max(highestID, identifier)
+	}
+	return json.Number(strconv.FormatInt(highestID+1, 10))
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/tyemirov/ghttp/pkg/logging"
+)
+
+const (
+	logMessageTLSCertificateReloaded = "tls certificate reloaded"
+	logMessageTLSCertificateInvalid  = "tls certificate reload failed"
+	logFieldCertificate              = "certificate"
+	logFieldPrivateKey               = "private_key"
+	logFieldTrigger                  = "trigger"
+	certificateReloadTriggerChange   = "file change"
+	certificateReloadTriggerSignal   = "SIGHUP"
+	certificateReloadPollInterval    = time.Second
+)
+
+// certificateFileReloader serves the certificate and key pair loaded from disk and swaps in new material when either
+// file changes or a reload is forced. Material that fails to load keeps the previous certificate in service. Handshakes
+// only read the certificate pointer; the files are checked by the watch goroutine, which performs every reload.
+type certificateFileReloader struct {
+	certificatePath string
+	privateKeyPath  string
+	loggingService  *logging.Service
+	certificate     atomic.Pointer[tls.Certificate]
+	filesState      certificateFilesState
+	failedState     certificateFilesState
+	failed          bool
+}
+
+type certificateFilesState struct {
+	certificateModificationTime time.Time
+	certificateSize             int64
+	privateKeyModificationTime  time.Time
+	privateKeySize              int64
+}
+
+func newCertificateFileReloader(certificatePath string, privateKeyPath string, loggingService *logging.Service) (*certificateFileReloader, error) {
+	filesState, statErr := statCertificateFiles(certificatePath, privateKeyPath)
+	if statErr != nil {
+		return nil, statErr
+	}
+	certificate, loadErr := tls.LoadX509KeyPair(certificatePath, privateKeyPath)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	reloader := &certificateFileReloader{
+		certificatePath: certificatePath,
+		privateKeyPath:  privateKeyPath,
+		loggingService:  loggingService,
+		filesState:      filesState,
+	}
+	reloader.certificate.Store(&certificate)
+	return reloader, nil
+}
+
+// currentCertificate returns the certificate in service.
+func (reloader *certificateFileReloader) currentCertificate() *tls.Certificate {
+	return reloader.certificate.Load()
+}
+
+// watch polls the files every certificateReloadPollInterval and forces a reload on every SIGHUP until ctx ends.
+func (reloader *certificateFileReloader) watch(ctx context.Context) {
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGHUP)
+	pollTicker := time.NewTicker(certificateReloadPollInterval)
+	go func() {
+		defer signal.Stop(signalChannel)
+		defer pollTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pollTicker.C:
+				reloader.reload(false)
+			case <-signalChannel:
+				reloader.reload(true)
+			}
+		}
+	}()
+}
+
+// reload re-reads the pair when the files changed, or unconditionally when forced. A failure is logged once per file
+// state so a half-written pair does not flood the log while it is being replaced. Only the watch goroutine calls it.
+func (reloader *certificateFileReloader) reload(forced bool) {
+	filesState, statErr := statCertificateFiles(reloader.certificatePath, reloader.privateKeyPath)
+	if statErr == nil && !forced && (filesState == reloader.filesState || (reloader.failed && filesState == reloader.failedState)) {
+		return
+	}
+	trigger := certificateReloadTriggerChange
+	if forced {
+		trigger = certificateReloadTriggerSignal
+	}
+	logFields := []logging.Field{
+		logging.String(logFieldCertificate, reloader.certificatePath),
+		logging.String(logFieldPrivateKey, reloader.privateKeyPath),
+		logging.String(logFieldTrigger, trigger),
+	}
+	if statErr != nil {
+		reloader.recordReloadFailure(filesState, statErr, forced, logFields)
+		return
+	}
+	certificate, loadErr := loadValidCertificate(reloader.certificatePath, reloader.privateKeyPath, time.Now())
+	if loadErr != nil {
+		reloader.recordReloadFailure(filesState, loadErr, forced, logFields)
+		return
+	}
+	reloader.certificate.Store(certificate)
+	reloader.filesState = filesState
+	reloader.failedState = certificateFilesState{}
+	reloader.failed = false
+	reloader.loggingService.Info(logMessageTLSCertificateReloaded, logFields...)
+}
+
+func (reloader *certificateFileReloader) recordReloadFailure(filesState certificateFilesState, reloadErr error, forced bool, logFields []logging.Field) {
+	if reloader.failed && filesState == reloader.failedState && !forced {
+		return
+	}
+	reloader.failedState = filesState
+	reloader.failed = true
+	reloader.loggingService.Error(logMessageTLSCertificateInvalid, reloadErr, logFields...)
+}
+
+// loadValidCertificate loads the pair and rejects a certificate that does not match its key, cannot be parsed, or is
+// outside its validity period at now.
+func loadValidCertificate(certificatePath string, privateKeyPath string, now time.Time) (*tls.Certificate, error) {
+	certificate, loadErr := tls.LoadX509KeyPair(certificatePath, privateKeyPath)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if certificate.Leaf == nil {
+		return nil, errors.New("certificate has no leaf")
+	}
+	if now.Before(certificate.Leaf.NotBefore) || now.After(certificate.Leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate is only valid from %s to %s", certificate.Leaf.NotBefore.UTC().Format(time.RFC3339), certificate.Leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return &certificate, nil
+}
+
+func statCertificateFiles(certificatePath string, privateKeyPath string) (certificateFilesState, error) {
+	certificateInfo, certificateErr := os.Stat(certificatePath)
+	if certificateErr != nil {
+		return certificateFilesState{}, certificateErr
+	}
+	privateKeyInfo, privateKeyErr := os.Stat(privateKeyPath)
+	if privateKeyErr != nil {
+		return certificateFilesState{}, privateKeyErr
+	}
+	return certificateFilesState{
+		certificateModificationTime: certificateInfo.ModTime(),
+		certificateSize:             certificateInfo.Size(),
+		privateKeyModificationTime:  privateKeyInfo.ModTime(),
+		privateKeySize:              privateKeyInfo.Size(),
+	}, nil
+}
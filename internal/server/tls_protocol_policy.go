@@ -0,0 +1,265 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// TLSPresetModern accepts TLS 1.3 only.
+	TLSPresetModern = "modern"
+	// TLSPresetIntermediate accepts TLS 1.2 with forward-secret AEAD cipher suites and TLS 1.3.
+	TLSPresetIntermediate = "intermediate"
+	// TLSPresetOld accepts TLS 1.0 through 1.3 with the legacy cipher suites old clients need.
+	TLSPresetOld = "old"
+
+	logFieldTLSVersion     = "tls_version"
+	logFieldTLSCipherSuite = "tls_cipher"
+)
+
+var ErrInvalidTLSProtocolPolicy = errors.New("tls.protocol.invalid")
+
+// TLSProtocolPolicy restricts the TLS versions, TLS 1.0–1.2 cipher suites, and key exchange groups a listener
+// accepts. The zero value keeps the crypto/tls defaults.
+type TLSProtocolPolicy struct {
+	minVersion       uint16
+	maxVersion       uint16
+	cipherSuites     []uint16
+	curvePreferences []tls.CurveID
+}
+
+var tlsVersionsByName = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurvesByName = map[string]tls.CurveID{
+	"x25519":         tls.X25519,
+	"x25519mlkem768": tls.X25519MLKEM768,
+	"p256":           tls.CurveP256,
+	"secp256r1":      tls.CurveP256,
+	"prime256v1":     tls.CurveP256,
+	"p384":           tls.CurveP384,
+	"secp384r1":      tls.CurveP384,
+	"p521":           tls.CurveP521,
+	"secp521r1":      tls.CurveP521,
+}
+
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsPresets follow the Mozilla server-side TLS recommendations, limited to what crypto/tls implements.
+var tlsPresets = map[string]TLSProtocolPolicy{
+	TLSPresetModern: {
+		minVersion:       tls.VersionTLS13,
+		curvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLSPresetIntermediate: {
+		minVersion:       tls.VersionTLS12,
+		cipherSuites:     intermediateCipherSuites,
+		curvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLSPresetOld: {
+		minVersion: tls.VersionTLS10,
+		cipherSuites: append(slices.Clone(intermediateCipherSuites),
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+		),
+		curvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+}
+
+// NewTLSProtocolPolicy starts from the named preset, when given, and lets explicit versions, cipher suites, and
+// curves replace the preset's values; preset cipher suites outside the resulting version range are dropped. Versions
+// are written 1.0 through 1.3 (a TLS or TLSv prefix is accepted), cipher suites use their IANA names, and curves
+// accept X25519, X25519MLKEM768, and P-256, P-384, or P-521 with their SEC aliases. Combinations that cannot complete
+// a handshake are rejected, as is a TLS 1.0–1.2 cipher suite list without the AES-128-GCM suite HTTP/2 requires when
+// http2Offered is set.
+func NewTLSProtocolPolicy(preset string, minVersion string, maxVersion string, cipherSuites []string, curves []string, http2Offered bool) (TLSProtocolPolicy, error) {
+	policy := TLSProtocolPolicy{}
+	normalizedPreset := strings.ToLower(strings.TrimSpace(preset))
+	if normalizedPreset != "" {
+		presetPolicy, known := tlsPresets[normalizedPreset]
+		if !known {
+			return TLSProtocolPolicy{}, fmt.Errorf("%w: unknown preset %q (use %s, %s, or %s)", ErrInvalidTLSProtocolPolicy, preset, TLSPresetModern, TLSPresetIntermediate, TLSPresetOld)
+		}
+		policy = presetPolicy
+	}
+	if strings.TrimSpace(minVersion) != "" {
+		parsedVersion, parseErr := parseTLSVersion(minVersion)
+		if parseErr != nil {
+			return TLSProtocolPolicy{}, parseErr
+		}
+		policy.minVersion = parsedVersion
+	}
+	if strings.TrimSpace(maxVersion) != "" {
+		parsedVersion, parseErr := parseTLSVersion(maxVersion)
+		if parseErr != nil {
+			return TLSProtocolPolicy{}, parseErr
+		}
+		policy.maxVersion = parsedVersion
+	}
+	if len(cipherSuites) > 0 {
+		parsedSuites, parseErr := parseTLSCipherSuites(cipherSuites)
+		if parseErr != nil {
+			return TLSProtocolPolicy{}, parseErr
+		}
+		policy.cipherSuites = parsedSuites
+	}
+	if len(curves) > 0 {
+		parsedCurves, parseErr := parseTLSCurves(curves)
+		if parseErr != nil {
+			return TLSProtocolPolicy{}, parseErr
+		}
+		policy.curvePreferences = parsedCurves
+	}
+	if len(cipherSuites) == 0 && len(policy.cipherSuites) > 0 {
+		effectiveMinVersion, effectiveMaxVersion := policy.effectiveVersions()
+		policy.cipherSuites = slices.DeleteFunc(slices.Clone(policy.cipherSuites), func(cipherSuite uint16) bool {
+			return !cipherSuiteSupportsVersions(cipherSuite, effectiveMinVersion, effectiveMaxVersion)
+		})
+		if len(policy.cipherSuites) == 0 {
+			policy.cipherSuites = nil
+		}
+	}
+	if validateErr := policy.validate(http2Offered); validateErr != nil {
+		return TLSProtocolPolicy{}, validateErr
+	}
+	return policy, nil
+}
+
+// IsEmpty reports whether the policy keeps every crypto/tls default.
+func (policy TLSProtocolPolicy) IsEmpty() bool {
+	return policy.minVersion == 0 && policy.maxVersion == 0 && len(policy.cipherSuites) == 0 && len(policy.curvePreferences) == 0
+}
+
+func (policy TLSProtocolPolicy) configure(tlsConfig *tls.Config) {
+	tlsConfig.MinVersion = policy.minVersion
+	tlsConfig.MaxVersion = policy.maxVersion
+	tlsConfig.CipherSuites = policy.cipherSuites
+	tlsConfig.CurvePreferences = policy.curvePreferences
+}
+
+// effectiveVersions resolves unset bounds to the crypto/tls server defaults of TLS 1.2 and TLS 1.3.
+func (policy TLSProtocolPolicy) effectiveVersions() (uint16, uint16) {
+	minVersion, maxVersion := policy.minVersion, policy.maxVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	if maxVersion == 0 {
+		maxVersion = tls.VersionTLS13
+	}
+	return minVersion, maxVersion
+}
+
+func (policy TLSProtocolPolicy) validate(http2Offered bool) error {
+	minVersion, maxVersion := policy.effectiveVersions()
+	if minVersion > maxVersion {
+		return fmt.Errorf("%w: minimum version %s is above maximum version %s", ErrInvalidTLSProtocolPolicy, tls.VersionName(minVersion), tls.VersionName(maxVersion))
+	}
+	if len(policy.cipherSuites) > 0 {
+		if minVersion == tls.VersionTLS13 {
+			return fmt.Errorf("%w: cipher suites only apply to TLS 1.2 and earlier, but the minimum version is TLS 1.3", ErrInvalidTLSProtocolPolicy)
+		}
+		for _, cipherSuite := range policy.cipherSuites {
+			if !cipherSuiteSupportsVersions(cipherSuite, minVersion, maxVersion) {
+				return fmt.Errorf("%w: cipher suite %s is not available between %s and %s", ErrInvalidTLSProtocolPolicy, tls.CipherSuiteName(cipherSuite), tls.VersionName(minVersion), tls.VersionName(maxVersion))
+			}
+		}
+	}
+	if !http2Offered {
+		return nil
+	}
+	return policy.http2Incompatibility()
+}
+
+// http2Incompatibility explains why HTTP/2 cannot be negotiated under the policy, or returns nil when it can.
+func (policy TLSProtocolPolicy) http2Incompatibility() error {
+	_, maxVersion := policy.effectiveVersions()
+	if maxVersion < tls.VersionTLS12 {
+		return fmt.Errorf("%w: HTTP/2 requires TLS 1.2 or later, but the maximum version is %s", ErrInvalidTLSProtocolPolicy, tls.VersionName(maxVersion))
+	}
+	if len(policy.cipherSuites) > 0 && !slices.Contains(policy.cipherSuites, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) && !slices.Contains(policy.cipherSuites, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256) {
+		return fmt.Errorf("%w: HTTP/2 requires TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 in the cipher suites", ErrInvalidTLSProtocolPolicy)
+	}
+	return nil
+}
+
+func parseTLSVersion(value string) (uint16, error) {
+	normalizedValue := strings.ToLower(strings.TrimSpace(value))
+	normalizedValue = strings.TrimPrefix(strings.TrimPrefix(normalizedValue, "tls"), "v")
+	version, known := tlsVersionsByName[strings.TrimSpace(normalizedValue)]
+	if !known {
+		return 0, fmt.Errorf("%w: unknown TLS version %q (use 1.0, 1.1, 1.2, or 1.3)", ErrInvalidTLSProtocolPolicy, value)
+	}
+	return version, nil
+}
+
+func parseTLSCipherSuites(names []string) ([]uint16, error) {
+	knownSuites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	parsedSuites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		suiteIndex := slices.IndexFunc(knownSuites, func(suite *tls.CipherSuite) bool {
+			return strings.EqualFold(suite.Name, strings.TrimSpace(name))
+		})
+		if suiteIndex < 0 {
+			return nil, fmt.Errorf("%w: unknown cipher suite %q", ErrInvalidTLSProtocolPolicy, name)
+		}
+		suite := knownSuites[suiteIndex]
+		if slices.Equal(suite.SupportedVersions, []uint16{tls.VersionTLS13}) {
+			return nil, fmt.Errorf("%w: %s is a TLS 1.3 cipher suite, which crypto/tls does not let servers configure", ErrInvalidTLSProtocolPolicy, suite.Name)
+		}
+		if !slices.Contains(parsedSuites, suite.ID) {
+			parsedSuites = append(parsedSuites, suite.ID)
+		}
+	}
+	return parsedSuites, nil
+}
+
+func parseTLSCurves(names []string) ([]tls.CurveID, error) {
+	parsedCurves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		normalizedName := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		curve, known := tlsCurvesByName[strings.TrimPrefix(normalizedName, "curve")]
+		if !known {
+			return nil, fmt.Errorf("%w: unknown curve %q (use X25519, X25519MLKEM768, P-256, P-384, or P-521)", ErrInvalidTLSProtocolPolicy, name)
+		}
+		if !slices.Contains(parsedCurves, curve) {
+			parsedCurves = append(parsedCurves, curve)
+		}
+	}
+	return parsedCurves, nil
+}
+
+func cipherSuiteSupportsVersions(cipherSuiteID uint16, minVersion uint16, maxVersion uint16) bool {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.ID != cipherSuiteID {
+			continue
+		}
+		return slices.ContainsFunc(suite.SupportedVersions, func(version uint16) bool {
+			return version >= minVersion && version <= maxVersion
+		})
+	}
+	return false
+}
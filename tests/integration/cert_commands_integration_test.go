@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func exerciseCertCommandFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	homeDirectory := testingT.TempDir()
+	firefoxProfileDirectory := resolveFirefoxProfileDirectory(homeDirectory)
+	if makeErr := os.MkdirAll(firefoxProfileDirectory, 0o755); makeErr != nil {
+		testingT.Fatalf("create firefox profile directory: %v", makeErr)
+	}
+	if writeErr := os.WriteFile(filepath.Join(firefoxProfileDirectory, "cert9.db"), []byte("db"), 0o644); writeErr != nil {
+		testingT.Fatalf("write cert9.db: %v", writeErr)
+	}
+	certificateDirectory := filepath.Join(testingT.TempDir(), "cert-command-certs")
+	outputDirectory := filepath.Join(testingT.TempDir(), "issued")
+	exportDirectory := filepath.Join(testingT.TempDir(), "exported")
+	trustLogPath := filepath.Join(testingT.TempDir(), "cert-trust.log")
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              homeDirectory,
+		"PATH":                              tools.trustAndCertutilPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory,
+		"GHTTP_TEST_TRUST_LOG_FILE":         trustLogPath,
+	}
+	runCert := func(expectedExitCode int, arguments ...string) string {
+		testingT.Helper()
+		return runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, append([]string{"cert"}, arguments...), environment, expectedExitCode)
+	}
+	readCertificate := func(certificatePath string) *x509.Certificate {
+		testingT.Helper()
+		certificateBytes, readErr := os.ReadFile(certificatePath)
+		pemBlock, _ := pem.Decode(certificateBytes)
+		if readErr != nil || pemBlock == nil {
+			testingT.Fatalf("read certificate %s: %v", certificatePath, readErr)
+		}
+		certificate, parseErr := x509.ParseCertificate(pemBlock.Bytes)
+		if parseErr != nil {
+			testingT.Fatalf("parse certificate %s: %v", certificatePath, parseErr)
+		}
+		return certificate
+	}
+
+	if statusOutput := runCert(0, "status"); !strings.Contains(statusOutput, "not found in "+certificateDirectory) {
+		testingT.Fatalf("expected status to report a missing authority, got:\n%s", statusOutput)
+	}
+	runCert(1, "trust")
+	runCert(1, "export")
+
+	issueOutput := runCert(0, "issue", "--host", "api.test", "--host", "10.0.0.5", "--output-directory", outputDirectory, "--https-key-algorithm", "ecdsa-p256")
+	if !strings.Contains(issueOutput, "certificate authority created") || !strings.Contains(issueOutput, "certificate issued") {
+		testingT.Fatalf("expected issue to create the authority and the certificate, got:\n%s", issueOutput)
+	}
+	issuedCertificate := readCertificate(filepath.Join(outputDirectory, "api.test.pem"))
+	authorityCertificate := readCertificate(filepath.Join(certificateDirectory, "ca.pem"))
+	if issuedCertificate.PublicKeyAlgorithm != x509.ECDSA || len(issuedCertificate.DNSNames) != 1 || issuedCertificate.DNSNames[0] != "api.test" || len(issuedCertificate.IPAddresses) != 1 || issuedCertificate.IPAddresses[0].String() != "10.0.0.5" {
+		testingT.Fatalf("unexpected issued certificate: key=%s dns=%v ip=%v", issuedCertificate.PublicKeyAlgorithm, issuedCertificate.DNSNames, issuedCertificate.IPAddresses)
+	}
+	if signatureErr := issuedCertificate.CheckSignatureFrom(authorityCertificate); signatureErr != nil {
+		testingT.Fatalf("expected the issued certificate to chain to the authority: %v", signatureErr)
+	}
+	if keyInfo, statErr := os.Stat(filepath.Join(outputDirectory, "api.test.key")); statErr != nil || keyInfo.Mode().Perm() != 0o600 {
+		testingT.Fatalf("expected a private key readable by the owner only, got %v", statErr)
+	}
+	if namedOutput := runCert(0, "issue", "--host", "*.api.test", "--output-directory", outputDirectory, "--validity", "48h", "--https-key-algorithm", "ecdsa-p256"); strings.Contains(namedOutput, "certificate authority created") {
+		testingT.Fatalf("expected the existing authority to be reused, got:\n%s", namedOutput)
+	}
+	if wildcardCertificate := readCertificate(filepath.Join(outputDirectory, "_wildcard.api.test.pem")); wildcardCertificate.NotAfter.Sub(wildcardCertificate.NotBefore).Hours() > 50 {
+		testingT.Fatalf("expected --validity to bound the certificate lifetime, got %s", wildcardCertificate.NotAfter.Sub(wildcardCertificate.NotBefore))
+	}
+	if mismatchOutput := runCert(1, "issue", "--host", "b.test", "--output-directory", outputDirectory); !strings.Contains(mismatchOutput, "certificates.authority.mismatch") {
+		testingT.Fatalf("expected a different key algorithm to be refused, got:\n%s", mismatchOutput)
+	}
+	if currentAuthority := readCertificate(filepath.Join(certificateDirectory, "ca.pem")); !currentAuthority.Equal(authorityCertificate) {
+		testingT.Fatalf("expected issuing a leaf to keep the authority")
+	}
+	runCert(1, "issue", "--output-directory", outputDirectory)
+	runCert(1, "issue", "--host", "api.test", "--name", "../escape")
+	runCert(1, "issue", "--host", "api.test", "--validity", "0s")
+
+	statusOutput := runCert(0, "status")
+	for _, expectedText := range []string{"Key type:", "ecdsa-p256", "SHA-256:", "Leaf certificate  not issued yet", "linux trust anchors:", "not installed", "firefox:"} {
+		if !strings.Contains(statusOutput, expectedText) {
+			testingT.Fatalf("expected status to contain %q, got:\n%s", expectedText, statusOutput)
+		}
+	}
+	if !regexp.MustCompile(`SHA-256:\s+([0-9A-F]{2}:){31}[0-9A-F]{2}`).MatchString(statusOutput) {
+		testingT.Fatalf("expected a colon separated SHA-256 fingerprint, got:\n%s", statusOutput)
+	}
+
+	runCert(1, "export", "--format", "p12", "--output-directory", exportDirectory, "--password", "secret")
+	runCert(1, "export", "--format", "p12", "--output-directory", exportDirectory, "--leaf-cert", filepath.Join(outputDirectory, "api.test.pem"))
+	runCert(1, "export", "--format", "pfx", "--output-directory", exportDirectory)
+	environment["GHTTP_CERT_EXPORT_PASSWORD"] = "secret"
+	exportOutput := runCert(0, "export", "--format", "pem,der,p12", "--output-directory", exportDirectory, "--leaf-cert", filepath.Join(outputDirectory, "api.test.pem"))
+	delete(environment, "GHTTP_CERT_EXPORT_PASSWORD")
+	if !strings.Contains(exportOutput, "certificate exported") {
+		testingT.Fatalf("expected export to be logged, got:\n%s", exportOutput)
+	}
+	exportedPEM, _ := os.ReadFile(filepath.Join(exportDirectory, "ghttp-ca.pem"))
+	exportedDER, _ := os.ReadFile(filepath.Join(exportDirectory, "ghttp-ca.der"))
+	if !bytes.Equal(exportedDER, authorityCertificate.Raw) || !readCertificate(filepath.Join(exportDirectory, "ghttp-ca.pem")).Equal(authorityCertificate) || len(exportedPEM) == 0 {
+		testingT.Fatalf("expected the exported PEM and DER to hold the authority certificate")
+	}
+	exportedBundle, readBundleErr := os.ReadFile(filepath.Join(exportDirectory, "api.test.p12"))
+	var pfx struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MACData  asn1.RawValue
+	}
+	if readBundleErr != nil {
+		testingT.Fatalf("read exported p12 bundle: %v", readBundleErr)
+	}
+	if _, unmarshalErr := asn1.Unmarshal(exportedBundle, &pfx); unmarshalErr != nil || pfx.Version != 3 || !bytes.Contains(exportedBundle, issuedCertificate.Raw) || !bytes.Contains(exportedBundle, authorityCertificate.Raw) {
+		testingT.Fatalf("expected a PKCS#12 bundle holding the leaf and the authority, got version %d: %v", pfx.Version, unmarshalErr)
+	}
+
+	runCert(0, "trust")
+	if trustLog, _ := os.ReadFile(trustLogPath); !strings.Contains(string(trustLog), "anchor ") {
+		testingT.Fatalf("expected trust to install the authority, got:\n%s", trustLog)
+	}
+	if trustedStatus := runCert(0, "status"); !regexp.MustCompile(`linux trust anchors:\s+installed`).MatchString(trustedStatus) || !regexp.MustCompile(`firefox:\s+installed`).MatchString(trustedStatus) {
+		testingT.Fatalf("expected status to report the installed authority, got:\n%s", trustedStatus)
+	}
+	runCert(0, "untrust")
+	if trustLog, _ := os.ReadFile(trustLogPath); !strings.Contains(string(trustLog), "anchor --remove") {
+		testingT.Fatalf("expected untrust to remove the authority, got:\n%s", trustLog)
+	}
+	if _, statErr := os.Stat(filepath.Join(certificateDirectory, "ca.pem")); statErr != nil {
+		testingT.Fatalf("expected untrust to keep the authority files: %v", statErr)
+	}
+	if untrustedStatus := runCert(0, "status"); !regexp.MustCompile(`linux trust anchors:\s+not installed`).MatchString(untrustedStatus) {
+		testingT.Fatalf("expected status to report the removed authority, got:\n%s", untrustedStatus)
+	}
+}
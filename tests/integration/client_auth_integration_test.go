@@ -0,0 +1,266 @@
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exerciseClientAuthFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	certificateDirectory := filepath.Join(testingT.TempDir(), "client-auth-certs")
+	outputDirectory := filepath.Join(testingT.TempDir(), "client-auth-issued")
+	clientCABundlePath := filepath.Join(certificateDirectory, "ca.pem")
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              testingT.TempDir(),
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory,
+		"GHTTP_TEST_TRUST_LOG_FILE":         filepath.Join(testingT.TempDir(), "client-auth-trust.log"),
+	}
+
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"cert", "issue-client", "--output-directory", outputDirectory}, environment, 1)
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"cert", "issue-client", "--common-name", "alice", "--email", "not an address", "--output-directory", outputDirectory}, environment, 1)
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"cert", "issue-client", "--common-name", "alice", "--name", "../alice", "--output-directory", outputDirectory}, environment, 1)
+	issueOutput := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"cert", "issue-client", "--common-name", "alice", "--email", "alice@example.test", "--output-directory", outputDirectory}, environment, 0)
+	if !strings.Contains(issueOutput, "client certificate issued") {
+		testingT.Fatalf("expected the client certificate to be issued, got:\n%s", issueOutput)
+	}
+	clientCertificate, loadErr := tls.LoadX509KeyPair(filepath.Join(outputDirectory, "alice.pem"), filepath.Join(outputDirectory, "alice.key"))
+	if loadErr != nil {
+		testingT.Fatalf("load issued client certificate: %v", loadErr)
+	}
+	if clientCertificate.Leaf.Subject.CommonName != "alice" || !slices.Equal(clientCertificate.Leaf.EmailAddresses, []string{"alice@example.test"}) || !slices.Equal(clientCertificate.Leaf.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}) {
+		testingT.Fatalf("expected a client authentication certificate for alice, got subject=%s emails=%v usages=%v", clientCertificate.Leaf.Subject, clientCertificate.Leaf.EmailAddresses, clientCertificate.Leaf.ExtKeyUsage)
+	}
+	untrustedCertificate := createSelfSignedClientCertificate(testingT, "mallory")
+
+	newClient := func(certificates ...tls.Certificate) *http.Client {
+		clientTLSConfig := &tls.Config{InsecureSkipVerify: true}
+		if len(certificates) > 0 {
+			// Present the certificate even when its issuer is not among the CAs the server names as acceptable.
+			clientTLSConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &certificates[0], nil
+			}
+		}
+		return &http.Client{
+			Timeout:   browseModeRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: clientTLSConfig},
+		}
+	}
+	fetch := func(client *http.Client, requestURL string, headers map[string]string) (int, string, error) {
+		testingT.Helper()
+		request, requestErr := http.NewRequest(http.MethodGet, requestURL, nil)
+		if requestErr != nil {
+			testingT.Fatalf("create request %s: %v", requestURL, requestErr)
+		}
+		for headerName, headerValue := range headers {
+			request.Header.Set(headerName, headerValue)
+		}
+		response, responseErr := client.Do(request)
+		if responseErr != nil {
+			return 0, "", responseErr
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(body), nil
+	}
+
+	requirePort := allocateFreePort(testingT)
+	requireBaseURL := fmt.Sprintf("https://127.0.0.1:%d", requirePort)
+	requireServer := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(requirePort), "--directory", siteDirectory, "--https", "--https-persist", "--client-ca", clientCABundlePath},
+		environment,
+		requireBaseURL+"/hello.html",
+		newClient(clientCertificate),
+	)
+	if statusCode, _, fetchErr := fetch(newClient(clientCertificate), requireBaseURL+"/hello.html", nil); fetchErr != nil || statusCode != http.StatusOK {
+		testingT.Fatalf("expected the trusted client certificate to be accepted, got %d %v", statusCode, fetchErr)
+	}
+	for _, rejectedClient := range []*http.Client{newClient(), newClient(untrustedCertificate)} {
+		if _, _, fetchErr := fetch(rejectedClient, requireBaseURL+"/hello.html", nil); fetchErr == nil {
+			testingT.Fatalf("expected the handshake to refuse a missing or untrusted client certificate")
+		}
+	}
+	if stopErr := requireServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop require server: %v\n%s", stopErr, requireServer.logBuffer.String())
+	}
+	if !strings.Contains(requireServer.logBuffer.String(), `client_cert="CN=alice"`) {
+		testingT.Fatalf("expected the request log to name the client certificate, got:\n%s", requireServer.logBuffer.String())
+	}
+
+	verifyIfGivenPort := allocateFreePort(testingT)
+	verifyIfGivenBaseURL := fmt.Sprintf("https://127.0.0.1:%d", verifyIfGivenPort)
+	verifyIfGivenServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(verifyIfGivenPort), "--directory", siteDirectory, "--https", "--https-persist", "--client-ca", clientCABundlePath, "--client-auth", "verify-if-given"},
+		environment,
+		verifyIfGivenBaseURL+"/hello.html",
+		true,
+	)
+	if statusCode, _, fetchErr := fetch(newClient(clientCertificate), verifyIfGivenBaseURL+"/hello.html", nil); fetchErr != nil || statusCode != http.StatusOK {
+		testingT.Fatalf("expected verify-if-given to accept the trusted certificate, got %d %v", statusCode, fetchErr)
+	}
+	if _, _, fetchErr := fetch(newClient(untrustedCertificate), verifyIfGivenBaseURL+"/hello.html", nil); fetchErr == nil {
+		testingT.Fatalf("expected verify-if-given to refuse an untrusted client certificate")
+	}
+	if stopErr := verifyIfGivenServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop verify-if-given server: %v\n%s", stopErr, verifyIfGivenServer.logBuffer.String())
+	}
+
+	backendHeaders := make(chan http.Header, 4)
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		backendHeaders <- request.Header.Clone()
+		_, _ = io.WriteString(responseWriter, "backend:"+request.URL.Path)
+	}))
+	testingT.Cleanup(backendServer.Close)
+
+	routePort := allocateFreePort(testingT)
+	routeBaseURL := fmt.Sprintf("https://127.0.0.1:%d", routePort)
+	routeServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(routePort), "--directory", siteDirectory, "--https", "--https-persist", "--logging-type", "JSON",
+			"--client-ca", clientCABundlePath, "--client-auth", "request", "--client-auth-route", "/admin=require",
+			"--proxy", "/api=" + backendServer.URL,
+			"--proxy-request-header", "/api=X-Client-CN:{{.ClientCert.CommonName}}",
+			"--proxy-request-header", "/api=X-Client-Verified:{{if .ClientCert.Present}}{{.ClientCert.Verified}}{{end}}",
+		},
+		environment,
+		routeBaseURL+"/hello.html",
+		true,
+	)
+	for _, routeCase := range []struct {
+		client         *http.Client
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{newClient(), "/hello.html", http.StatusOK, ""},
+		{newClient(untrustedCertificate), "/hello.html", http.StatusOK, ""},
+		{newClient(), "/admin/panel", http.StatusForbidden, "client certificate required"},
+		{newClient(untrustedCertificate), "/admin/panel", http.StatusForbidden, "client certificate not trusted"},
+		{newClient(clientCertificate), "/admin/panel", http.StatusNotFound, ""},
+	} {
+		statusCode, body, fetchErr := fetch(routeCase.client, routeBaseURL+routeCase.path, nil)
+		if fetchErr != nil || statusCode != routeCase.expectedStatus || !strings.Contains(body, routeCase.expectedBody) {
+			testingT.Fatalf("expected %s to answer %d %q, got %d %q %v", routeCase.path, routeCase.expectedStatus, routeCase.expectedBody, statusCode, body, fetchErr)
+		}
+	}
+	for _, proxyCase := range []struct {
+		client           *http.Client
+		expectedCN       string
+		expectedVerified string
+	}{
+		{newClient(clientCertificate), "alice", "true"},
+		{newClient(untrustedCertificate), "mallory", "false"},
+		{newClient(), "", ""},
+	} {
+		statusCode, body, fetchErr := fetch(proxyCase.client, routeBaseURL+"/api/whoami", map[string]string{"X-Client-CN": "spoofed", "X-Client-Verified": "true"})
+		if fetchErr != nil || statusCode != http.StatusOK || body != "backend:/api/whoami" {
+			testingT.Fatalf("expected the proxied request to succeed, got %d %q %v", statusCode, body, fetchErr)
+		}
+		receivedHeaders := <-backendHeaders
+		if receivedHeaders.Get("X-Client-CN") != proxyCase.expectedCN || receivedHeaders.Get("X-Client-Verified") != proxyCase.expectedVerified {
+			testingT.Fatalf("expected the backend to receive CN %q verified %q, got %q %q", proxyCase.expectedCN, proxyCase.expectedVerified, receivedHeaders.Get("X-Client-CN"), receivedHeaders.Get("X-Client-Verified"))
+		}
+	}
+	if stopErr := routeServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop per-route server: %v\n%s", stopErr, routeServer.logBuffer.String())
+	}
+	routeLogs := routeServer.logBuffer.String()
+	if !strings.Contains(routeLogs, `"client_sans":["alice@example.test"]`) || !strings.Contains(routeLogs, "client certificate rejected") {
+		testingT.Fatalf("expected JSON logs with the client certificate SANs and rejections, got:\n%s", routeLogs)
+	}
+
+	tlsPort := allocateFreePort(testingT)
+	tlsBaseURL := fmt.Sprintf("https://127.0.0.1:%d", tlsPort)
+	tlsServer := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(tlsPort), "--directory", siteDirectory, "--client-ca", clientCABundlePath,
+			"--tls-cert", filepath.Join(certificateDirectory, "localhost.pem"), "--tls-key", filepath.Join(certificateDirectory, "localhost.key"),
+		},
+		environment,
+		tlsBaseURL+"/hello.html",
+		newClient(clientCertificate),
+	)
+	if _, _, fetchErr := fetch(newClient(), tlsBaseURL+"/hello.html", nil); fetchErr == nil {
+		testingT.Fatalf("expected --tls-cert serving to require a client certificate")
+	}
+	if stopErr := tlsServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop tls-cert server: %v\n%s", stopErr, tlsServer.logBuffer.String())
+	}
+
+	serveArguments := []string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory}
+	for _, invalidArguments := range [][]string{
+		{"--client-ca", clientCABundlePath},
+		{"--https", "--client-ca", clientCABundlePath, "--client-auth", "sometimes"},
+		{"--https", "--client-ca", clientCABundlePath, "--client-auth-route", "admin=require"},
+		{"--https", "--client-ca", filepath.Join(outputDirectory, "alice.key")},
+		{"--https", "--client-ca", filepath.Join(outputDirectory, "missing.pem")},
+		{"--https", "--client-auth-route", "/admin=require"},
+		{"--proxy-request-header", "/api=X-Client-CN:{{.ClientCert.CommonName}}"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-request-header", "/api=X-Client-CN"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-request-header", "/api=X-Client-CN:{{.ClientCert.CommonName"},
+	} {
+		runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, append(slices.Clone(serveArguments), invalidArguments...), environment, 1)
+	}
+}
+
+func createSelfSignedClientCertificate(testingT *testing.T, commonName string) tls.Certificate {
+	testingT.Helper()
+	privateKey, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		testingT.Fatalf("generate client key: %v", keyErr)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certificateDer, createErr := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if createErr != nil {
+		testingT.Fatalf("create client certificate: %v", createErr)
+	}
+	privateKeyDer, marshalErr := x509.MarshalECPrivateKey(privateKey)
+	if marshalErr != nil {
+		testingT.Fatalf("marshal client key: %v", marshalErr)
+	}
+	certificate, pairErr := tls.X509KeyPair(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDer}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKeyDer}),
+	)
+	if pairErr != nil {
+		testingT.Fatalf("load client certificate: %v", pairErr)
+	}
+	return certificate
+}
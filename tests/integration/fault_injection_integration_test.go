@@ -0,0 +1,319 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const faultFixtureYAML = `faults:
+  - name: flaky
+    method: GET
+    path: /flaky
+    status: 503
+    delay: 30ms
+  - name: never
+    path: /never
+    percentage: 0
+    status: 500
+  - name: truncated
+    path: /truncated
+    truncate_after_bytes: 5
+  - name: aborted
+    path: /aborted
+    abort_after_bytes: 4
+  - name: dropped
+    path: /ws
+    websocket_drop_after: 200ms
+  - name: disabled
+    path: /disabled
+    enabled: false
+    status: 500
+`
+
+const faultRequestLogSettle = 300 * time.Millisecond
+
+type faultAdminState struct {
+	Enabled bool `json:"enabled"`
+	Rules   []struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+	} `json:"rules"`
+}
+
+func exerciseFaultInjectionFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	for fileName, content := range map[string]string{
+		"flaky.txt":     "flaky-content",
+		"never.txt":     "never-content",
+		"truncated.txt": "0123456789",
+		"aborted.txt":   "abcdefghij",
+		"disabled.txt":  "disabled-content",
+	} {
+		writeMockTestFile(testingT, filepath.Join(siteDirectory, fileName), content)
+	}
+	fixtureDirectory := testingT.TempDir()
+	fixturePath := filepath.Join(fixtureDirectory, "faults.yaml")
+	writeMockTestFile(testingT, fixturePath, faultFixtureYAML)
+	webSocketBackendAddress := startWebSocketEchoBackend(testingT)
+
+	faultPort := allocateFreePort(testingT)
+	faultBaseURL := fmt.Sprintf("http://127.0.0.1:%d", faultPort)
+	faultServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(faultPort),
+			"--directory", siteDirectory,
+			"--proxy", "/ws=http://" + webSocketBackendAddress,
+			"--faults", fixturePath,
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		faultBaseURL+"/never.txt",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+
+	startTime := time.Now()
+	flakyStatusCode, flakyHeaders, flakyBody := executeHTTPGet(testingT, httpClient, faultBaseURL, "/flaky.txt")
+	if flakyStatusCode != http.StatusServiceUnavailable || flakyHeaders.Get("X-Ghttp-Fault") != "flaky" || !strings.Contains(flakyBody, "injected fault flaky") || time.Since(startTime) < 30*time.Millisecond {
+		testingT.Fatalf("unexpected status fault response: status=%d headers=%v body=%q", flakyStatusCode, flakyHeaders, flakyBody)
+	}
+	postResponse, postErr := httpClient.Post(faultBaseURL+"/flaky.txt", "text/plain", strings.NewReader("x"))
+	if postErr != nil {
+		testingT.Fatalf("post to fault route: %v", postErr)
+	}
+	_ = postResponse.Body.Close()
+	if postResponse.StatusCode == http.StatusServiceUnavailable {
+		testingT.Fatalf("expected method-scoped fault to skip POST requests")
+	}
+	expectFaultBody(testingT, httpClient, faultBaseURL, "/never.txt", "never-content")
+	expectFaultBody(testingT, httpClient, faultBaseURL, "/disabled.txt", "disabled-content")
+	expectFaultBody(testingT, httpClient, faultBaseURL, "/truncated.txt", "01234")
+	expectAbortedFaultBody(testingT, httpClient, faultBaseURL+"/aborted.txt", "abcd")
+	expectWebSocketDropped(testingT, fmt.Sprintf("127.0.0.1:%d", faultPort), "/ws/stream", 200*time.Millisecond)
+	time.Sleep(faultRequestLogSettle)
+
+	adminState := executeFaultAdminRequest(testingT, httpClient, http.MethodGet, faultBaseURL, "", http.StatusOK)
+	if !adminState.Enabled || len(adminState.Rules) != 6 || adminState.Rules[5].Name != "disabled" || adminState.Rules[5].Enabled {
+		testingT.Fatalf("unexpected fault admin state: %+v", adminState)
+	}
+	executeFaultAdminRequest(testingT, httpClient, http.MethodPost, faultBaseURL, `{"rule":"flaky","enabled":false}`, http.StatusOK)
+	expectFaultBody(testingT, httpClient, faultBaseURL, "/flaky.txt", "flaky-content")
+	executeFaultAdminRequest(testingT, httpClient, http.MethodPut, faultBaseURL, `{"enabled":false}`, http.StatusOK)
+	expectFaultBody(testingT, httpClient, faultBaseURL, "/truncated.txt", "0123456789")
+	executeFaultAdminRequest(testingT, httpClient, http.MethodPost, faultBaseURL, `{"enabled":true}`, http.StatusOK)
+	adminState = executeFaultAdminRequest(testingT, httpClient, http.MethodPost, faultBaseURL, `{"rule":"disabled","enabled":true}`, http.StatusOK)
+	if !adminState.Enabled || adminState.Rules[0].Enabled || !adminState.Rules[5].Enabled {
+		testingT.Fatalf("unexpected fault admin state after toggles: %+v", adminState)
+	}
+	disabledStatusCode, _, _ := executeHTTPGet(testingT, httpClient, faultBaseURL, "/disabled.txt")
+	if disabledStatusCode != http.StatusInternalServerError {
+		testingT.Fatalf("expected re-enabled fault to return 500, got %d", disabledStatusCode)
+	}
+	executeFaultAdminRequest(testingT, httpClient, http.MethodPost, faultBaseURL, `{"rule":"missing","enabled":true}`, http.StatusNotFound)
+	executeFaultAdminRequest(testingT, httpClient, http.MethodPost, faultBaseURL, `{"enabled":"yes"}`, http.StatusBadRequest)
+	executeFaultAdminRequest(testingT, httpClient, http.MethodPost, faultBaseURL, `{}`, http.StatusBadRequest)
+	executeFaultAdminRequest(testingT, httpClient, http.MethodDelete, faultBaseURL, "", http.StatusMethodNotAllowed)
+	headResponse, headErr := httpClient.Head(faultBaseURL + "/__ghttp/faults")
+	if headErr != nil {
+		testingT.Fatalf("head fault admin endpoint: %v", headErr)
+	}
+	_ = headResponse.Body.Close()
+	if headResponse.StatusCode != http.StatusOK {
+		testingT.Fatalf("unexpected fault admin HEAD status %d", headResponse.StatusCode)
+	}
+	if stopErr := faultServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop fault server: %v", stopErr)
+	}
+	faultLogs := faultServer.logBuffer.String()
+	for _, expectedLogFragment := range []string{
+		`"GET /flaky.txt HTTP/1.1" 503`,
+		"fault=flaky(delay:30ms,status:503)",
+		"fault=truncated(truncate:5)",
+		"fault=aborted(abort:4)",
+		"fault=dropped(websocket-drop:200ms)",
+		"fault-admin",
+	} {
+		if !strings.Contains(faultLogs, expectedLogFragment) {
+			testingT.Fatalf("expected fault log fragment %q, got:\n%s", expectedLogFragment, faultLogs)
+		}
+	}
+	if strings.Contains(faultLogs, "fault=never") {
+		testingT.Fatalf("expected zero-percentage fault to never fire, got:\n%s", faultLogs)
+	}
+
+	proxyBackend := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		_, _ = io.WriteString(responseWriter, "proxied-body")
+	}))
+	testingT.Cleanup(proxyBackend.Close)
+	jsonFixturePath := filepath.Join(fixtureDirectory, "faults.json")
+	writeMockTestFile(testingT, jsonFixturePath, `{"faults":[{"path":"/api","abort_after_bytes":3}]}`)
+	jsonPort := allocateFreePort(testingT)
+	jsonBaseURL := fmt.Sprintf("http://127.0.0.1:%d", jsonPort)
+	jsonServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(jsonPort), "--directory", siteDirectory, "--proxy", "/api=" + proxyBackend.URL, "--logging-type", "JSON"},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath, "GHTTP_SERVE_FAULTS": jsonFixturePath},
+		jsonBaseURL+"/never.txt",
+		false,
+	)
+	expectAbortedFaultBody(testingT, httpClient, jsonBaseURL+"/api/data", "pro")
+	// The client sees the aborted connection before the handler returns and writes its request log line.
+	time.Sleep(faultRequestLogSettle)
+	if stopErr := jsonServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop json fault server: %v", stopErr)
+	}
+	if !strings.Contains(jsonServer.logBuffer.String(), `"fault":"fault-1(abort:3)"`) {
+		testingT.Fatalf("expected json request log to mark the injected fault, got:\n%s", jsonServer.logBuffer.String())
+	}
+
+	invalidFixtures := map[string]string{
+		"extension.txt":       "faults: []",
+		"syntax.yaml":         "faults: [",
+		"unknown-field.yaml":  "faults:\n  - path: /a\n    status: 500\n    unknown: true\n",
+		"relative-path.yaml":  "faults:\n  - path: a\n    status: 500\n",
+		"percentage.yaml":     "faults:\n  - path: /a\n    status: 500\n    percentage: 101\n",
+		"status.yaml":         "faults:\n  - path: /a\n    status: 700\n",
+		"delay.yaml":          "faults:\n  - path: /a\n    delay: soon\n",
+		"negative-delay.yaml": "faults:\n  - path: /a\n    delay: -1s\n",
+		"drop.yaml":           "faults:\n  - path: /a\n    websocket_drop_after: later\n",
+		"truncate.yaml":       "faults:\n  - path: /a\n    truncate_after_bytes: -1\n",
+		"abort.yaml":          "faults:\n  - path: /a\n    abort_after_bytes: -1\n",
+		"body-faults.yaml":    "faults:\n  - path: /a\n    truncate_after_bytes: 1\n    abort_after_bytes: 1\n",
+		"status-body.yaml":    "faults:\n  - path: /a\n    status: 500\n    abort_after_bytes: 1\n",
+		"empty-rule.yaml":     "faults:\n  - path: /a\n",
+		"duplicate-name.yaml": "faults:\n  - name: a\n    path: /a\n    status: 500\n  - name: a\n    path: /b\n    status: 500\n",
+	}
+	invalidFixturePaths := []string{filepath.Join(fixtureDirectory, "missing.yaml")}
+	for fileName, content := range invalidFixtures {
+		invalidFixturePath := filepath.Join(fixtureDirectory, fileName)
+		writeMockTestFile(testingT, invalidFixturePath, content)
+		invalidFixturePaths = append(invalidFixturePaths, invalidFixturePath)
+	}
+	for _, invalidFixturePath := range invalidFixturePaths {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--faults", invalidFixturePath},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func expectFaultBody(testingT *testing.T, httpClient *http.Client, baseURL string, requestPath string, expectedBody string) {
+	testingT.Helper()
+	statusCode, _, body := executeHTTPGet(testingT, httpClient, baseURL, requestPath)
+	if statusCode != http.StatusOK || body != expectedBody {
+		testingT.Fatalf("unexpected response for %s: status=%d body=%q", requestPath, statusCode, body)
+	}
+}
+
+func expectAbortedFaultBody(testingT *testing.T, httpClient *http.Client, requestURL string, expectedPrefix string) {
+	testingT.Helper()
+	response, responseErr := httpClient.Get(requestURL)
+	if responseErr != nil {
+		testingT.Fatalf("get %s: %v", requestURL, responseErr)
+	}
+	defer response.Body.Close()
+	body, readErr := io.ReadAll(response.Body)
+	if readErr == nil || !errors.Is(readErr, io.ErrUnexpectedEOF) || string(body) != expectedPrefix {
+		testingT.Fatalf("expected aborted body %q with unexpected EOF, got body=%q err=%v", expectedPrefix, body, readErr)
+	}
+}
+
+func executeFaultAdminRequest(testingT *testing.T, httpClient *http.Client, method string, baseURL string, requestBody string, expectedStatus int) faultAdminState {
+	testingT.Helper()
+	request, requestErr := http.NewRequest(method, baseURL+"/__ghttp/faults", strings.NewReader(requestBody))
+	if requestErr != nil {
+		testingT.Fatalf("build fault admin request: %v", requestErr)
+	}
+	response, responseErr := httpClient.Do(request)
+	if responseErr != nil {
+		testingT.Fatalf("%s fault admin endpoint: %v", method, responseErr)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode != expectedStatus {
+		testingT.Fatalf("unexpected fault admin status for %s %s: %d body=%q", method, requestBody, response.StatusCode, responseBody)
+	}
+	var state faultAdminState
+	if expectedStatus == http.StatusOK {
+		if decodeErr := json.Unmarshal(responseBody, &state); decodeErr != nil {
+			testingT.Fatalf("decode fault admin state: %v", decodeErr)
+		}
+	}
+	return state
+}
+
+func startWebSocketEchoBackend(testingT *testing.T) string {
+	testingT.Helper()
+	backendListener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		testingT.Fatalf("start websocket backend listener: %v", listenErr)
+	}
+	testingT.Cleanup(func() {
+		_ = backendListener.Close()
+	})
+	go func() {
+		for {
+			connection, acceptErr := backendListener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go serveRawWebSocketEcho(connection)
+		}
+	}()
+	return backendListener.Addr().String()
+}
+
+func expectWebSocketDropped(testingT *testing.T, hostPort string, requestPath string, dropAfter time.Duration) {
+	testingT.Helper()
+	connection, dialErr := net.DialTimeout("tcp", hostPort, browseModeRequestTimeout)
+	if dialErr != nil {
+		testingT.Fatalf("dial websocket proxy %s: %v", hostPort, dialErr)
+	}
+	defer connection.Close()
+	startTime := time.Now()
+	handshakeRequest := "GET " + requestPath + " HTTP/1.1\r\nHost: " + hostPort + "\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, writeErr := io.WriteString(connection, handshakeRequest+"still-open\n"); writeErr != nil {
+		testingT.Fatalf("write websocket handshake: %v", writeErr)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(browseModeRequestTimeout))
+	reader := bufio.NewReader(connection)
+	for {
+		headerLine, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			testingT.Fatalf("read websocket handshake response: %v", readErr)
+		}
+		if headerLine == "\r\n" {
+			break
+		}
+	}
+	echoedLine, echoErr := reader.ReadString('\n')
+	if echoErr != nil || echoedLine != "still-open\n" {
+		testingT.Fatalf("expected websocket echo before the drop, got %q err=%v", echoedLine, echoErr)
+	}
+	if _, readErr := reader.ReadByte(); readErr != io.EOF {
+		testingT.Fatalf("expected dropped websocket connection to reach EOF, got %v", readErr)
+	}
+	if elapsed := time.Since(startTime); elapsed < dropAfter {
+		testingT.Fatalf("websocket dropped after %s, expected at least %s", elapsed, dropAfter)
+	}
+}
@@ -270,9 +270,34 @@ func TestGlobalIntegrationCoverageGate(t *testing.T) {
 	exerciseInitialFileFlow(t, repositoryRoot, instrumentedCommandBinary, fixture.landingFilePath, coverageDirectoryPath)
 	exerciseHTTPProxyFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath)
 	exerciseWebSocketProxyFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyBackendTLSFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseUnixSocketProxyFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseGRPCProxyFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyFallbackFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseMockRouteFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyTrafficRecordingFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyMirrorFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseFaultInjectionFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyRewriteFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyCacheFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxySSEFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyWebSocketFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseProxyWebSocketInspectorFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseRequestLimitsFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
+	exerciseRESTStoreFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath)
 	exerciseManualTLSFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath)
 	exerciseAddressInUseFlow(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath)
 	exerciseDynamicHTTPSFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseHTTPSKeyAlgorithmFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseHTTPSPersistFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseCertCommandFlows(t, repositoryRoot, instrumentedCommandBinary, coverageDirectoryPath, tools)
+	exerciseHTTPSOnDemandFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseHTTPSNameConstraintFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseClientAuthFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseHTTPAndHTTPSListenerFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseHTTPProtocolFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath, tools)
+	exerciseTLSCertificateReloadFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath)
+	exerciseTLSProtocolFlows(t, repositoryRoot, instrumentedCommandBinary, fixture.siteDirectory, coverageDirectoryPath)
 
 	coverageProfilePath := filepath.Join(t.TempDir(), "global.coverage.out")
 	writeCoverageProfileFromDirectory(t, repositoryRoot, coverageDirectoryPath, coverageProfilePath)
@@ -334,6 +359,23 @@ func startGHTTPProcessWithArguments(
 	environmentVariables map[string]string,
 	startupProbeURL string,
 	insecureStartupTLS bool,
+) *startedGHTTPServer {
+	testingT.Helper()
+	startupClient := &http.Client{Timeout: browseModeRequestTimeout}
+	if insecureStartupTLS {
+		startupClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return startGHTTPProcessWithStartupClient(testingT, repositoryRoot, serverBinaryPath, arguments, environmentVariables, startupProbeURL, startupClient)
+}
+
+func startGHTTPProcessWithStartupClient(
+	testingT *testing.T,
+	repositoryRoot string,
+	serverBinaryPath string,
+	arguments []string,
+	environmentVariables map[string]string,
+	startupProbeURL string,
+	startupClient *http.Client,
 ) *startedGHTTPServer {
 	testingT.Helper()
 	serverCommand := exec.Command(serverBinaryPath, arguments...)
@@ -358,11 +400,6 @@ func startGHTTPProcessWithArguments(
 		}
 	})
 
-	startupClient := &http.Client{Timeout: browseModeRequestTimeout}
-	if insecureStartupTLS {
-		startupClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	}
-
 	startDeadline := time.Now().Add(browseModeStartupTimeout)
 	for time.Now().Before(startDeadline) {
 		request, requestErr := http.NewRequest(http.MethodGet, startupProbeURL, nil)
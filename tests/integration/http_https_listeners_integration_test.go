@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseHTTPAndHTTPSListenerFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              testingT.TempDir(),
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": filepath.Join(testingT.TempDir(), "listener-certs"),
+		"GHTTP_TEST_TRUST_LOG_FILE":         filepath.Join(testingT.TempDir(), "listener-trust.log"),
+	}
+	client := &http.Client{
+		Timeout: browseModeRequestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	fetch := func(method string, requestURL string) (int, string, string) {
+		testingT.Helper()
+		request, requestErr := http.NewRequest(method, requestURL, nil)
+		if requestErr != nil {
+			testingT.Fatalf("create request %s: %v", requestURL, requestErr)
+		}
+		response, responseErr := client.Do(request)
+		if responseErr != nil {
+			testingT.Fatalf("%s %s: %v", method, requestURL, responseErr)
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, response.Header.Get("Location"), string(body)
+	}
+
+	httpsPort := allocateFreePort(testingT)
+	httpPort := allocateFreePort(testingT)
+	sharedServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(httpsPort), "--directory", siteDirectory, "--https", "--http-port", strconv.Itoa(httpPort)},
+		environment,
+		fmt.Sprintf("https://127.0.0.1:%d/hello.html", httpsPort),
+		true,
+	)
+	if statusCode, _, body := fetch(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/hello.html", httpPort)); statusCode != http.StatusOK || !strings.Contains(body, "ROOT HELLO") {
+		testingT.Fatalf("expected the http listener to share the file handlers, got %d %q", statusCode, body)
+	}
+	if stopErr := sharedServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop shared listener server: %v\n%s", stopErr, sharedServer.logBuffer.String())
+	}
+	expectedStartMessage := fmt.Sprintf("Serving HTTPS on 0.0.0.0 port %d (https://localhost:%d/) and HTTP on 0.0.0.0 port %d (http://localhost:%d/) ...", httpsPort, httpsPort, httpPort, httpPort)
+	if !strings.Contains(sharedServer.logBuffer.String(), expectedStartMessage) {
+		testingT.Fatalf("expected the start message to list both listeners, got:\n%s", sharedServer.logBuffer.String())
+	}
+
+	httpsPort = allocateFreePort(testingT)
+	httpPort = allocateFreePort(testingT)
+	redirectServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(httpsPort), "--directory", siteDirectory, "--https", "--logging-type", "JSON",
+			"--http-port", strconv.Itoa(httpPort), "--https-redirect", "--https-redirect-exclude", "/hello",
+		},
+		environment,
+		fmt.Sprintf("https://127.0.0.1:%d/hello.html", httpsPort),
+		true,
+	)
+	httpBaseURL := fmt.Sprintf("http://127.0.0.1:%d", httpPort)
+	for _, redirectCase := range []struct {
+		method           string
+		requestURL       string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{http.MethodGet, httpBaseURL + "/docs/page.html?lang=en&x=1", http.StatusMovedPermanently, fmt.Sprintf("https://127.0.0.1:%d/docs/page.html?lang=en&x=1", httpsPort)},
+		{http.MethodHead, httpBaseURL + "/", http.StatusMovedPermanently, fmt.Sprintf("https://127.0.0.1:%d/", httpsPort)},
+		{http.MethodPost, fmt.Sprintf("http://localhost:%d/api/items", httpPort), http.StatusPermanentRedirect, fmt.Sprintf("https://localhost:%d/api/items", httpsPort)},
+		{http.MethodGet, httpBaseURL + "/hello.html", http.StatusOK, ""},
+	} {
+		if statusCode, location, _ := fetch(redirectCase.method, redirectCase.requestURL); statusCode != redirectCase.expectedStatus || location != redirectCase.expectedLocation {
+			testingT.Fatalf("expected %s %s to answer %d %q, got %d %q", redirectCase.method, redirectCase.requestURL, redirectCase.expectedStatus, redirectCase.expectedLocation, statusCode, location)
+		}
+	}
+	if stopErr := redirectServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop redirect server: %v\n%s", stopErr, redirectServer.logBuffer.String())
+	}
+	redirectLogs := redirectServer.logBuffer.String()
+	for _, expectedLog := range []string{
+		fmt.Sprintf(`"url":"https://localhost:%d"`, httpsPort),
+		fmt.Sprintf(`"url":"http://localhost:%d","timestamp"`, httpPort),
+		fmt.Sprintf(`"redirect":"https://localhost:%d"`, httpsPort),
+		`"handler":"https-redirect"`,
+	} {
+		if !strings.Contains(redirectLogs, expectedLog) {
+			testingT.Fatalf("expected the JSON logs to contain %s, got:\n%s", expectedLog, redirectLogs)
+		}
+	}
+
+	occupiedListener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		testingT.Fatalf("occupy a port: %v", listenErr)
+	}
+	defer occupiedListener.Close()
+	occupiedPort := strconv.Itoa(occupiedListener.Addr().(*net.TCPAddr).Port)
+	occupiedOutput := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--bind", "127.0.0.1", "--https", "--http-port", occupiedPort}, environment, 1)
+	if !strings.Contains(occupiedOutput, "Address already in use: 127.0.0.1:"+occupiedPort) {
+		testingT.Fatalf("expected the busy http port to be reported, got:\n%s", occupiedOutput)
+	}
+
+	servePort := strconv.Itoa(allocateFreePort(testingT))
+	for _, invalidArguments := range [][]string{
+		{"--http-port", strconv.Itoa(allocateFreePort(testingT))},
+		{"--https", "--https-redirect"},
+		{"--https", "--http-port", servePort},
+		{"--https", "--http-port", "http"},
+		{"--https", "--http-port", strconv.Itoa(allocateFreePort(testingT)), "--https-redirect-exclude", "/health"},
+		{"--https", "--http-port", strconv.Itoa(allocateFreePort(testingT)), "--https-redirect", "--https-redirect-exclude", "health"},
+	} {
+		runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, append([]string{servePort, "--directory", siteDirectory}, invalidArguments...), environment, 1)
+	}
+}
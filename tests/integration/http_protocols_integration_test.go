@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseHTTPProtocolFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              testingT.TempDir(),
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": filepath.Join(testingT.TempDir(), "protocol-certs"),
+		"GHTTP_TEST_TRUST_LOG_FILE":         filepath.Join(testingT.TempDir(), "protocol-trust.log"),
+	}
+	newTLSClient := func() *http.Client {
+		transport := &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}
+		return &http.Client{Timeout: browseModeRequestTimeout, Transport: transport}
+	}
+	newH2CClient := func() *http.Client {
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		return &http.Client{Timeout: browseModeRequestTimeout, Transport: &http.Transport{Protocols: protocols}}
+	}
+	fetch := func(client *http.Client, requestURL string) (string, string) {
+		testingT.Helper()
+		response, responseErr := client.Get(requestURL)
+		if responseErr != nil {
+			testingT.Fatalf("GET %s: %v", requestURL, responseErr)
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		if response.StatusCode != http.StatusOK || !strings.Contains(string(body), "ROOT HELLO") {
+			testingT.Fatalf("expected %s to serve the fixture, got %d %q", requestURL, response.StatusCode, string(body))
+		}
+		negotiatedProtocol := ""
+		if response.TLS != nil {
+			negotiatedProtocol = response.TLS.NegotiatedProtocol
+		}
+		return response.Proto, negotiatedProtocol
+	}
+
+	http2Port := allocateFreePort(testingT)
+	http2URL := fmt.Sprintf("https://127.0.0.1:%d/hello.html", http2Port)
+	http2Server := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(http2Port), "--directory", siteDirectory, "--https", "--https-persist", "--protocol", "HTTP/2", "--logging-type", "JSON"},
+		environment,
+		http2URL,
+		newTLSClient(),
+	)
+	if proto, negotiatedProtocol := fetch(newTLSClient(), http2URL); proto != "HTTP/2.0" || negotiatedProtocol != "h2" {
+		testingT.Fatalf("expected HTTP/2 negotiated over ALPN, got %s (alpn %q)", proto, negotiatedProtocol)
+	}
+	if stopErr := http2Server.stop(); stopErr != nil {
+		testingT.Fatalf("stop HTTP/2 server: %v\n%s", stopErr, http2Server.logBuffer.String())
+	}
+	if !strings.Contains(http2Server.logBuffer.String(), `"protocol":"HTTP/2.0"`) {
+		testingT.Fatalf("expected the JSON request log to record HTTP/2.0, got:\n%s", http2Server.logBuffer.String())
+	}
+
+	http11Port := allocateFreePort(testingT)
+	http11URL := fmt.Sprintf("https://127.0.0.1:%d/hello.html", http11Port)
+	http11Server := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(http11Port), "--directory", siteDirectory, "--https", "--https-persist", "--logging-type", "JSON"},
+		environment,
+		http11URL,
+		newTLSClient(),
+	)
+	if proto, negotiatedProtocol := fetch(newTLSClient(), http11URL); proto != "HTTP/2.0" || negotiatedProtocol != "h2" {
+		testingT.Fatalf("expected the default protocol to offer HTTP/2 over TLS, got %s (alpn %q)", proto, negotiatedProtocol)
+	}
+	if proto, _ := fetch(&http.Client{Timeout: browseModeRequestTimeout, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}, http11URL); proto != "HTTP/1.1" {
+		testingT.Fatalf("expected HTTP/1.1 clients to keep working over TLS, got %s", proto)
+	}
+	if stopErr := http11Server.stop(); stopErr != nil {
+		testingT.Fatalf("stop HTTP/1.1 server: %v\n%s", stopErr, http11Server.logBuffer.String())
+	}
+	if !strings.Contains(http11Server.logBuffer.String(), `"protocol":"HTTP/1.1"`) {
+		testingT.Fatalf("expected the JSON request log to record HTTP/1.1, got:\n%s", http11Server.logBuffer.String())
+	}
+
+	http10Port := allocateFreePort(testingT)
+	http10URL := fmt.Sprintf("https://127.0.0.1:%d/hello.html", http10Port)
+	http10Server := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(http10Port), "--directory", siteDirectory, "--https", "--https-persist", "--protocol", "HTTP/1.0"},
+		environment,
+		http10URL,
+		newTLSClient(),
+	)
+	if proto, negotiatedProtocol := fetch(newTLSClient(), http10URL); proto == "HTTP/2.0" || negotiatedProtocol == "h2" {
+		testingT.Fatalf("expected HTTP/1.0 to keep HTTP/2 off over TLS, got %s (alpn %q)", proto, negotiatedProtocol)
+	}
+	if stopErr := http10Server.stop(); stopErr != nil {
+		testingT.Fatalf("stop HTTP/1.0 server: %v\n%s", stopErr, http10Server.logBuffer.String())
+	}
+
+	h2cPort := allocateFreePort(testingT)
+	h2cURL := fmt.Sprintf("http://127.0.0.1:%d/hello.html", h2cPort)
+	h2cServer := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(h2cPort), "--directory", siteDirectory, "--protocol", "HTTP/2", "--h2c"},
+		environment,
+		h2cURL,
+		newH2CClient(),
+	)
+	if proto, _ := fetch(newH2CClient(), h2cURL); proto != "HTTP/2.0" {
+		testingT.Fatalf("expected cleartext HTTP/2 with prior knowledge, got %s", proto)
+	}
+	if proto, _ := fetch(&http.Client{Timeout: browseModeRequestTimeout}, h2cURL); proto != "HTTP/1.1" {
+		testingT.Fatalf("expected HTTP/1.1 clients to keep working alongside h2c, got %s", proto)
+	}
+	if stopErr := h2cServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop h2c server: %v\n%s", stopErr, h2cServer.logBuffer.String())
+	}
+
+	servePort := strconv.Itoa(allocateFreePort(testingT))
+	for _, invalidCase := range []struct {
+		arguments      []string
+		expectedOutput string
+	}{
+		{[]string{"--protocol", "HTTP/2"}, "HTTP/2 without TLS requires --h2c"},
+		{[]string{"--protocol", "HTTP/1.0", "--h2c"}, "--h2c requires HTTP/1.1 or HTTP/2"},
+		{[]string{"--protocol", "HTTP/3", "--https"}, "unsupported protocol HTTP/3"},
+	} {
+		output := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, append([]string{servePort, "--directory", siteDirectory}, invalidCase.arguments...), environment, 1)
+		if !strings.Contains(output, invalidCase.expectedOutput) {
+			testingT.Fatalf("expected %v to report %q, got:\n%s", invalidCase.arguments, invalidCase.expectedOutput, output)
+		}
+	}
+}
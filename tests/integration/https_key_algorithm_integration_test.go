@@ -0,0 +1,147 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseHTTPSKeyAlgorithmFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	homeDirectory := testingT.TempDir()
+	certificateDirectory := filepath.Join(testingT.TempDir(), "key-algorithm-certs")
+	rootCertificatePath := filepath.Join(certificateDirectory, "ca.pem")
+	rootPrivateKeyPath := filepath.Join(certificateDirectory, "ca.key")
+	leafPrivateKeyPath := filepath.Join(certificateDirectory, "localhost.key")
+	httpsEnvironment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              homeDirectory,
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory,
+		"GHTTP_TEST_TRUST_FAIL_REMOVE":      "1",
+	}
+
+	serveWithKeyAlgorithm := func(keyAlgorithm string) (x509.PublicKeyAlgorithm, []byte) {
+		testingT.Helper()
+		httpsPort := allocateFreePort(testingT)
+		httpsBaseURL := fmt.Sprintf("https://127.0.0.1:%d", httpsPort)
+		httpsEnvironment["GHTTP_HTTPS_KEY_ALGORITHM"] = keyAlgorithm
+		httpsServer := startGHTTPProcessWithArguments(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(httpsPort), "--directory", siteDirectory, "--https"},
+			httpsEnvironment,
+			httpsBaseURL+"/hello.html",
+			true,
+		)
+		httpsClient := &http.Client{Timeout: browseModeRequestTimeout, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		response, responseErr := httpsClient.Get(httpsBaseURL + "/hello.html")
+		if responseErr != nil || response.TLS == nil || len(response.TLS.PeerCertificates) == 0 {
+			testingT.Fatalf("expected an https response with %s keys: %v", keyAlgorithm, responseErr)
+		}
+		_ = response.Body.Close()
+		leafAlgorithm := response.TLS.PeerCertificates[0].PublicKeyAlgorithm
+		if stopErr := httpsServer.stop(); stopErr != nil {
+			testingT.Fatalf("stop %s https server: %v", keyAlgorithm, stopErr)
+		}
+		rootCertificateBytes, readErr := os.ReadFile(rootCertificatePath)
+		if readErr != nil {
+			testingT.Fatalf("read persisted root certificate: %v", readErr)
+		}
+		return leafAlgorithm, rootCertificateBytes
+	}
+	readPEMBlock := func(filePath string) *pem.Block {
+		testingT.Helper()
+		fileBytes, readErr := os.ReadFile(filePath)
+		pemBlock, _ := pem.Decode(fileBytes)
+		if readErr != nil || pemBlock == nil {
+			testingT.Fatalf("read pem file %s: %v", filePath, readErr)
+		}
+		return pemBlock
+	}
+	rewriteSEC1PrivateKey := func(filePath string) string {
+		testingT.Helper()
+		parsedKey, parseErr := x509.ParsePKCS8PrivateKey(readPEMBlock(filePath).Bytes)
+		if parseErr != nil {
+			testingT.Fatalf("parse pkcs8 key %s: %v", filePath, parseErr)
+		}
+		sec1Block := &pem.Block{}
+		switch typedKey := parsedKey.(type) {
+		case *ecdsa.PrivateKey:
+			sec1Block.Type = "EC PRIVATE KEY"
+			sec1Block.Bytes, _ = x509.MarshalECPrivateKey(typedKey)
+		default:
+			testingT.Fatalf("unexpected key type %T in %s", parsedKey, filePath)
+		}
+		if writeErr := os.WriteFile(filePath, pem.EncodeToMemory(sec1Block), 0o600); writeErr != nil {
+			testingT.Fatalf("write SEC 1 key %s: %v", filePath, writeErr)
+		}
+		return sec1Block.Type
+	}
+	rootPublicKeyAlgorithm := func(rootCertificateBytes []byte) x509.PublicKeyAlgorithm {
+		testingT.Helper()
+		pemBlock, _ := pem.Decode(rootCertificateBytes)
+		rootCertificate, parseErr := x509.ParseCertificate(pemBlock.Bytes)
+		if parseErr != nil {
+			testingT.Fatalf("parse root certificate: %v", parseErr)
+		}
+		return rootCertificate.PublicKeyAlgorithm
+	}
+
+	ecdsaLeafAlgorithm, ecdsaRootCertificate := serveWithKeyAlgorithm("ecdsa-p256")
+	if ecdsaLeafAlgorithm != x509.ECDSA || rootPublicKeyAlgorithm(ecdsaRootCertificate) != x509.ECDSA {
+		testingT.Fatalf("expected ECDSA leaf and root certificates, got leaf=%s root=%s", ecdsaLeafAlgorithm, rootPublicKeyAlgorithm(ecdsaRootCertificate))
+	}
+	for _, privateKeyPath := range []string{rootPrivateKeyPath, leafPrivateKeyPath} {
+		if blockType := readPEMBlock(privateKeyPath).Type; blockType != "PRIVATE KEY" {
+			testingT.Fatalf("expected PKCS#8 private keys, got %s in %s", blockType, privateKeyPath)
+		}
+	}
+
+	rewriteSEC1PrivateKey(rootPrivateKeyPath)
+	rewriteSEC1PrivateKey(leafPrivateKeyPath)
+	if reusedLeafAlgorithm, reusedRootCertificate := serveWithKeyAlgorithm("ECDSA-P256"); reusedLeafAlgorithm != x509.ECDSA || !bytes.Equal(reusedRootCertificate, ecdsaRootCertificate) {
+		testingT.Fatalf("expected SEC 1 keys of the configured algorithm to be reused, got leaf=%s", reusedLeafAlgorithm)
+	}
+
+	p384LeafAlgorithm, p384RootCertificate := serveWithKeyAlgorithm("ecdsa-p384")
+	if p384LeafAlgorithm != x509.ECDSA || bytes.Equal(p384RootCertificate, ecdsaRootCertificate) {
+		testingT.Fatalf("expected the authority to rotate to P-384, got leaf=%s", p384LeafAlgorithm)
+	}
+	ed25519LeafAlgorithm, ed25519RootCertificate := serveWithKeyAlgorithm("ed25519")
+	if ed25519LeafAlgorithm != x509.Ed25519 || rootPublicKeyAlgorithm(ed25519RootCertificate) != x509.Ed25519 {
+		testingT.Fatalf("expected Ed25519 leaf and root certificates, got leaf=%s", ed25519LeafAlgorithm)
+	}
+	if _, reusedRootCertificate := serveWithKeyAlgorithm("ed25519"); !bytes.Equal(reusedRootCertificate, ed25519RootCertificate) {
+		testingT.Fatalf("expected the Ed25519 authority to be reused")
+	}
+
+	if rsaLeafAlgorithm, rsaRootCertificate := serveWithKeyAlgorithm(""); rsaLeafAlgorithm != x509.RSA || rootPublicKeyAlgorithm(rsaRootCertificate) != x509.RSA {
+		testingT.Fatalf("expected RSA certificates by default, got leaf=%s", rsaLeafAlgorithm)
+	}
+	if blockType := readPEMBlock(rootPrivateKeyPath).Type; blockType != "PRIVATE KEY" {
+		testingT.Fatalf("expected RSA keys to be stored as PKCS#8, got %s", blockType)
+	}
+
+	invalidOutput := runCommandExpectExitCode(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--https", "--https-key-algorithm", "dsa"},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath, "HOME": homeDirectory, "GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory},
+		1,
+	)
+	if !strings.Contains(invalidOutput, `unsupported key algorithm "dsa"`) {
+		testingT.Fatalf("expected the unsupported algorithm to be reported, got:\n%s", invalidOutput)
+	}
+}
@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseHTTPSNameConstraintFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	certificateDirectory := filepath.Join(testingT.TempDir(), "constrained-certs")
+	outputDirectory := filepath.Join(testingT.TempDir(), "constrained-issued")
+	rootCertificatePath := filepath.Join(certificateDirectory, "ca.pem")
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              testingT.TempDir(),
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory,
+		"GHTTP_TEST_TRUST_LOG_FILE":         filepath.Join(testingT.TempDir(), "constrained-trust.log"),
+	}
+	runCert := func(expectedExitCode int, arguments ...string) string {
+		testingT.Helper()
+		return runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, append([]string{"cert"}, arguments...), environment, expectedExitCode)
+	}
+	readCertificate := func(certificatePath string) (*x509.Certificate, []byte) {
+		testingT.Helper()
+		certificateBytes, readErr := os.ReadFile(certificatePath)
+		pemBlock, _ := pem.Decode(certificateBytes)
+		if readErr != nil || pemBlock == nil {
+			testingT.Fatalf("read certificate %s: %v", certificatePath, readErr)
+		}
+		certificate, parseErr := x509.ParseCertificate(pemBlock.Bytes)
+		if parseErr != nil {
+			testingT.Fatalf("parse certificate %s: %v", certificatePath, parseErr)
+		}
+		return certificate, certificateBytes
+	}
+
+	for _, invalidHost := range []string{"*.localhost", "app.*.test", "app*.test", "bad host.test", "-app.test"} {
+		if invalidOutput := runCert(1, "issue", "--host", invalidHost, "--output-directory", outputDirectory); !strings.Contains(invalidOutput, "certificates.host.invalid") {
+			testingT.Fatalf("expected %q to be rejected as a certificate host, got:\n%s", invalidHost, invalidOutput)
+		}
+	}
+
+	constrainedArguments := []string{"--https-name-constraints", "--https-ca-domain", "example.dev", "--https-ca-domain", "10.0.0.0/7", "--https-ca-domain", "10.1.0.0/16", "--output-directory", outputDirectory}
+	issueOutput := runCert(0, append([]string{"issue", "--host", "*.app.localhost", "--host", "api.example.dev", "--host", "192.168.1.20", "--host", "11.0.0.5"}, constrainedArguments...)...)
+	if !strings.Contains(issueOutput, "certificate authority created") {
+		testingT.Fatalf("expected issue to create the constrained authority, got:\n%s", issueOutput)
+	}
+	authorityCertificate, authorityBytes := readCertificate(rootCertificatePath)
+	if !authorityCertificate.PermittedDNSDomainsCritical || !slices.Equal(authorityCertificate.PermittedDNSDomains, []string{"localhost", "test", "local", "example.dev"}) {
+		testingT.Fatalf("expected a critical name constraint on the development domains, got critical=%t domains=%v", authorityCertificate.PermittedDNSDomainsCritical, authorityCertificate.PermittedDNSDomains)
+	}
+	if len(authorityCertificate.PermittedIPRanges) != 9 || authorityCertificate.PermittedIPRanges[0].String() != "127.0.0.0/8" || authorityCertificate.PermittedIPRanges[8].String() != "10.0.0.0/7" {
+		testingT.Fatalf("expected the private address ranges plus the wider 10.0.0.0/7 range to be permitted, got %v", authorityCertificate.PermittedIPRanges)
+	}
+	wildcardCertificate, _ := readCertificate(filepath.Join(outputDirectory, "_wildcard.app.localhost.pem"))
+	rootPool := x509.NewCertPool()
+	rootPool.AddCert(authorityCertificate)
+	for _, verifiedName := range []string{"web.app.localhost", "api.example.dev", "192.168.1.20", "11.0.0.5"} {
+		if _, verifyErr := wildcardCertificate.Verify(x509.VerifyOptions{DNSName: verifiedName, Roots: rootPool}); verifyErr != nil {
+			testingT.Fatalf("expected the wildcard certificate to verify for %s under the constrained authority: %v", verifiedName, verifyErr)
+		}
+	}
+	if _, verifyErr := wildcardCertificate.Verify(x509.VerifyOptions{DNSName: "deep.web.app.localhost", Roots: rootPool}); verifyErr == nil {
+		testingT.Fatalf("expected the wildcard to cover a single label only")
+	}
+
+	for _, outsideHost := range []string{"evil.com", "8.8.8.8", "*.app.example.com"} {
+		if outsideOutput := runCert(1, append([]string{"issue", "--host", outsideHost}, constrainedArguments...)...); !strings.Contains(outsideOutput, "certificates.host.outside_name_constraints") {
+			testingT.Fatalf("expected %s to be refused by the constrained authority, got:\n%s", outsideHost, outsideOutput)
+		}
+	}
+	if mismatchOutput := runCert(1, "issue", "--host", "api.test", "--output-directory", outputDirectory); !strings.Contains(mismatchOutput, "certificates.authority.mismatch") {
+		testingT.Fatalf("expected issuing without the name constraints to be refused, got:\n%s", mismatchOutput)
+	}
+	if _, currentAuthorityBytes := readCertificate(rootCertificatePath); !bytes.Equal(currentAuthorityBytes, authorityBytes) {
+		testingT.Fatalf("expected issuing leaves to keep the authority")
+	}
+	if statusOutput := runCert(0, "status"); !strings.Contains(statusOutput, "Name constraints:") || !strings.Contains(statusOutput, "example.dev") {
+		testingT.Fatalf("expected status to list the name constraints, got:\n%s", statusOutput)
+	}
+	runCert(1, "issue", "--host", "api.test", "--https-name-constraints", "--https-ca-domain", "bad domain", "--output-directory", outputDirectory)
+
+	environment["GHTTP_HTTPS_NAME_CONSTRAINTS"] = "true"
+	httpsPort := allocateFreePort(testingT)
+	httpsServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(httpsPort), "--directory", siteDirectory, "--https", "--https-host", "localhost,127.0.0.1,preview.dev"},
+		environment,
+		fmt.Sprintf("https://127.0.0.1:%d/hello.html", httpsPort),
+		true,
+	)
+	servingAuthority, servingAuthorityBytes := readCertificate(rootCertificatePath)
+	if bytes.Equal(servingAuthorityBytes, authorityBytes) || !slices.Contains(servingAuthority.PermittedDNSDomains, "preview.dev") || slices.Contains(servingAuthority.PermittedDNSDomains, "example.dev") {
+		testingT.Fatalf("expected serving with other hosts to rotate the constrained authority, got %v", servingAuthority.PermittedDNSDomains)
+	}
+	servingPool := x509.NewCertPool()
+	servingPool.AddCert(servingAuthority)
+	for _, serverName := range []string{"localhost", "preview.dev"} {
+		dialer := &net.Dialer{Timeout: browseModeRequestTimeout}
+		connection, dialErr := tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("127.0.0.1:%d", httpsPort), &tls.Config{ServerName: serverName, RootCAs: servingPool})
+		if dialErr != nil {
+			testingT.Fatalf("expected the constrained chain to verify for %s: %v", serverName, dialErr)
+		}
+		connection.Close()
+	}
+	if stopErr := httpsServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop constrained https server: %v\n%s", stopErr, httpsServer.logBuffer.String())
+	}
+	delete(environment, "GHTTP_HTTPS_NAME_CONSTRAINTS")
+}
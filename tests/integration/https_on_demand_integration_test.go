@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseHTTPSOnDemandFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	homeDirectory := testingT.TempDir()
+	certificateDirectory := filepath.Join(testingT.TempDir(), "on-demand-certs")
+	onDemandDirectory := filepath.Join(certificateDirectory, "on-demand")
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              homeDirectory,
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory,
+		"GHTTP_TEST_TRUST_LOG_FILE":         filepath.Join(testingT.TempDir(), "on-demand-trust.log"),
+	}
+	peerCertificate := func(port int, serverName string) *x509.Certificate {
+		testingT.Helper()
+		dialer := &net.Dialer{Timeout: browseModeRequestTimeout}
+		connection, dialErr := tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("127.0.0.1:%d", port), &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		if dialErr != nil {
+			testingT.Fatalf("tls handshake with server name %q: %v", serverName, dialErr)
+		}
+		defer connection.Close()
+		return connection.ConnectionState().PeerCertificates[0]
+	}
+	serveOnDemand := func(arguments []string, inspect func(port int)) string {
+		testingT.Helper()
+		httpsPort := allocateFreePort(testingT)
+		httpsServer := startGHTTPProcessWithArguments(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(httpsPort), "--directory", siteDirectory, "--https"}, arguments...),
+			environment,
+			fmt.Sprintf("https://127.0.0.1:%d/hello.html", httpsPort),
+			true,
+		)
+		inspect(httpsPort)
+		if stopErr := httpsServer.stop(); stopErr != nil {
+			testingT.Fatalf("stop on-demand https server: %v\n%s", stopErr, httpsServer.logBuffer.String())
+		}
+		return httpsServer.logBuffer.String()
+	}
+
+	memoryLogs := serveOnDemand([]string{"--https-host", "localhost", "--https-on-demand", "*.localhost,private"}, func(port int) {
+		for _, serverName := range []string{"myapp.localhost", "api.myapp.localhost", "MyApp.localhost"} {
+			certificate := peerCertificate(port, serverName)
+			if !slices.Equal(certificate.DNSNames, []string{strings.ToLower(serverName)}) {
+				testingT.Fatalf("expected an on-demand certificate for %s, got %v", serverName, certificate.DNSNames)
+			}
+		}
+		if certificate := peerCertificate(port, "example.com"); !slices.Equal(certificate.DNSNames, []string{"localhost"}) {
+			testingT.Fatalf("expected unmatched names to receive the default certificate, got %v", certificate.DNSNames)
+		}
+		if certificate := peerCertificate(port, "localhost"); !slices.Equal(certificate.DNSNames, []string{"localhost"}) {
+			testingT.Fatalf("expected default hosts to receive the default certificate, got %v", certificate.DNSNames)
+		}
+		if certificate := peerCertificate(port, ""); len(certificate.IPAddresses) != 1 || !certificate.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+			testingT.Fatalf("expected an on-demand certificate for the local address, got %v", certificate.IPAddresses)
+		}
+	})
+	if strings.Count(memoryLogs, "https certificate issued") != 3 || !strings.Contains(memoryLogs, `host="myapp.localhost"`) || !strings.Contains(memoryLogs, `host="127.0.0.1"`) {
+		testingT.Fatalf("expected each on-demand name to be minted once, got:\n%s", memoryLogs)
+	}
+	if _, statErr := os.Stat(onDemandDirectory); !os.IsNotExist(statErr) {
+		testingT.Fatalf("expected in-memory on-demand certificates not to be written, got %v", statErr)
+	}
+
+	boundedLogs := serveOnDemand([]string{"--https-on-demand", "*.localhost", "--https-on-demand-cache-size", "1"}, func(port int) {
+		for _, serverName := range []string{"first.localhost", "second.localhost", "first.localhost"} {
+			if certificate := peerCertificate(port, serverName); !slices.Equal(certificate.DNSNames, []string{serverName}) {
+				testingT.Fatalf("expected an on-demand certificate for %s, got %v", serverName, certificate.DNSNames)
+			}
+		}
+	})
+	if strings.Count(boundedLogs, "https certificate issued") != 3 || strings.Count(boundedLogs, `host="first.localhost"`) != 2 {
+		testingT.Fatalf("expected a one-entry cache to evict and mint first.localhost again, got:\n%s", boundedLogs)
+	}
+
+	environment["GHTTP_HTTPS_ON_DEMAND_HOSTS"] = "preview.test,*.preview.test"
+	serveOnDemand([]string{"--https-persist"}, func(port int) {
+		peerCertificate(port, "preview.test")
+	})
+	if _, statErr := os.Stat(onDemandDirectory); !os.IsNotExist(statErr) {
+		testingT.Fatalf("expected --https-persist alone to keep on-demand certificates in memory, got %v", statErr)
+	}
+	persistentLogs := serveOnDemand([]string{"--https-persist", "--https-on-demand-persist"}, func(port int) {
+		if certificate := peerCertificate(port, "preview.test"); !slices.Equal(certificate.DNSNames, []string{"preview.test"}) {
+			testingT.Fatalf("expected an on-demand certificate for preview.test, got %v", certificate.DNSNames)
+		}
+	})
+	delete(environment, "GHTTP_HTTPS_ON_DEMAND_HOSTS")
+	if !strings.Contains(persistentLogs, `host="preview.test"`) {
+		testingT.Fatalf("expected the persistent run to mint preview.test, got:\n%s", persistentLogs)
+	}
+	if _, statErr := os.Stat(filepath.Join(onDemandDirectory, "preview.test.pem")); statErr != nil {
+		testingT.Fatalf("expected the on-demand certificate to persist: %v", statErr)
+	}
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"https", "uninstall"}, environment, 0)
+	if _, statErr := os.Stat(onDemandDirectory); !os.IsNotExist(statErr) {
+		testingT.Fatalf("expected https uninstall to delete the on-demand certificates, got %v", statErr)
+	}
+
+	for _, invalidPattern := range []string{"bad!host", "10.0.0.0/33", "*.", "*.-bad"} {
+		invalidOutput := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--https", "--https-on-demand", invalidPattern}, environment, 1)
+		if !strings.Contains(invalidOutput, "certificates.on_demand.pattern.invalid") {
+			testingT.Fatalf("expected %q to be rejected, got:\n%s", invalidPattern, invalidOutput)
+		}
+	}
+	invalidSizeOutput := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--https", "--https-on-demand", "*.localhost", "--https-on-demand-cache-size", "0"}, environment, 1)
+	if !strings.Contains(invalidSizeOutput, "--https-on-demand-cache-size must be at least 1") {
+		testingT.Fatalf("expected a zero cache size to be rejected, got:\n%s", invalidSizeOutput)
+	}
+}
@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseHTTPSPersistFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string, tools fakeSystemTools) {
+	testingT.Helper()
+	homeDirectory := testingT.TempDir()
+	certificateDirectory := filepath.Join(testingT.TempDir(), "persistent-certs")
+	trustLogPath := filepath.Join(testingT.TempDir(), "persistent-trust.log")
+	rootCertificatePath := filepath.Join(certificateDirectory, "ca.pem")
+	leafCertificatePath := filepath.Join(certificateDirectory, "localhost.pem")
+	environment := map[string]string{
+		"GOCOVERDIR":                        coverageDirectoryPath,
+		"HOME":                              homeDirectory,
+		"PATH":                              tools.trustOnlyPath + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": certificateDirectory,
+		"GHTTP_TEST_TRUST_LOG_FILE":         trustLogPath,
+	}
+	readTrustLog := func() string {
+		trustLogBytes, _ := os.ReadFile(trustLogPath)
+		return string(trustLogBytes)
+	}
+	readRootCertificate := func() []byte {
+		testingT.Helper()
+		rootCertificateBytes, readErr := os.ReadFile(rootCertificatePath)
+		if readErr != nil {
+			testingT.Fatalf("read persisted root certificate: %v", readErr)
+		}
+		return rootCertificateBytes
+	}
+	servePersistent := func(arguments ...string) string {
+		testingT.Helper()
+		httpsPort := allocateFreePort(testingT)
+		httpsServer := startGHTTPProcessWithArguments(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(httpsPort), "--directory", siteDirectory, "--https"}, arguments...),
+			environment,
+			fmt.Sprintf("https://127.0.0.1:%d/hello.html", httpsPort),
+			true,
+		)
+		if stopErr := httpsServer.stop(); stopErr != nil {
+			testingT.Fatalf("stop persistent https server: %v\n%s", stopErr, httpsServer.logBuffer.String())
+		}
+		return httpsServer.logBuffer.String()
+	}
+
+	installOutput := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"https", "install", "--https-key-algorithm", "ecdsa-p256"}, environment, 0)
+	if !strings.Contains(installOutput, "certificate authority installed") || strings.Count(readTrustLog(), "anchor ") != 1 {
+		testingT.Fatalf("expected https install to install the authority once, got output:\n%s\ntrust log:\n%s", installOutput, readTrustLog())
+	}
+	installedRootCertificate := readRootCertificate()
+
+	reusedLogs := servePersistent("--https-persist", "--https-key-algorithm", "ecdsa-p256")
+	if !strings.Contains(reusedLogs, "certificate authority reused") || !strings.Contains(reusedLogs, "certificate authority kept installed") {
+		testingT.Fatalf("expected the persistent run to reuse the installed authority, got:\n%s", reusedLogs)
+	}
+	if trustLog := readTrustLog(); strings.Count(trustLog, "anchor ") != 1 || strings.Contains(trustLog, "--remove") {
+		testingT.Fatalf("expected the persistent run to leave the trust store alone, got:\n%s", trustLog)
+	}
+	if !bytes.Equal(readRootCertificate(), installedRootCertificate) {
+		testingT.Fatalf("expected the persistent run to keep the installed authority")
+	}
+	if _, statErr := os.Stat(leafCertificatePath); statErr != nil {
+		testingT.Fatalf("expected the leaf certificate to be kept after a persistent run: %v", statErr)
+	}
+
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"cert", "untrust"}, environment, 0)
+	reinstalledLogs := servePersistent("--https-persist", "--https-key-algorithm", "ecdsa-p256")
+	if strings.Contains(reinstalledLogs, "certificate authority reused") || !strings.Contains(reinstalledLogs, "certificate authority installed") {
+		testingT.Fatalf("expected a persistent run to reinstall an untrusted authority, got:\n%s", reinstalledLogs)
+	}
+	if trustLog := readTrustLog(); strings.Count(trustLog, "anchor ") != 3 || strings.Count(trustLog, "anchor --remove") != 1 {
+		testingT.Fatalf("expected the untrusted authority to be installed again, got:\n%s", trustLog)
+	}
+	if !bytes.Equal(readRootCertificate(), installedRootCertificate) {
+		testingT.Fatalf("expected reinstalling to keep the persisted authority")
+	}
+
+	environment["GHTTP_HTTPS_PERSIST_CA"] = "true"
+	rotatedLogs := servePersistent("--https-key-algorithm", "ed25519")
+	if strings.Contains(rotatedLogs, "certificate authority reused") || !strings.Contains(rotatedLogs, "certificate authority installed") {
+		testingT.Fatalf("expected a rotated authority to be installed again, got:\n%s", rotatedLogs)
+	}
+	if trustLog := readTrustLog(); strings.Count(trustLog, "anchor ") != 4 || strings.Count(trustLog, "anchor --remove") != 1 {
+		testingT.Fatalf("expected exactly one additional trust store install after rotation, got:\n%s", trustLog)
+	}
+	if bytes.Equal(readRootCertificate(), installedRootCertificate) {
+		testingT.Fatalf("expected the authority to rotate when the key algorithm changes")
+	}
+	delete(environment, "GHTTP_HTTPS_PERSIST_CA")
+
+	uninstallOutput := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"https", "uninstall"}, environment, 0)
+	if !strings.Contains(uninstallOutput, "certificate authority uninstalled") || !strings.Contains(readTrustLog(), "anchor --remove") {
+		testingT.Fatalf("expected https uninstall to remove the authority, got output:\n%s\ntrust log:\n%s", uninstallOutput, readTrustLog())
+	}
+	for _, removedPath := range []string{rootCertificatePath, leafCertificatePath} {
+		if _, statErr := os.Stat(removedPath); !os.IsNotExist(statErr) {
+			testingT.Fatalf("expected https uninstall to delete %s, got %v", removedPath, statErr)
+		}
+	}
+
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"https", "install", "--https-key-algorithm", "dsa"}, environment, 1)
+	runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, []string{"https", "install", "unexpected"}, environment, 1)
+}
@@ -0,0 +1,223 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mockFixtureYAML = `mocks:
+  - method: GET
+    path: /api/users/:id
+    headers:
+      Content-Type: application/json
+      X-User: "{{.Params.id}}"
+    body: '{"id":"{{.Params.id}}","q":"{{.Query.q}}"}'
+  - method: post
+    path: /api/users
+    status: 201
+    body: created
+  - path: /api/files/*rest
+    body_file: fixtures/user.json
+  - method: GET
+    path: /api/*/ping
+    body: pong
+    delay: 20ms
+  - path: /api/broken-file
+    body_file: fixtures/broken.txt
+  - path: /api/broken-body
+    body: "{{.Params.id.missing}}"
+  - path: /api/broken-header
+    headers:
+      X-Broken: "{{.Missing}}"
+`
+
+func exerciseMockRouteFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	writeMockTestFile(testingT, filepath.Join(siteDirectory, "fixtures", "user.json"), `{"rest":"{{.Params.rest}}"}`)
+	writeMockTestFile(testingT, filepath.Join(siteDirectory, "fixtures", "broken.txt"), "{{")
+	fixtureDirectory := testingT.TempDir()
+	fixturePath := filepath.Join(fixtureDirectory, "mocks.yaml")
+	writeMockTestFile(testingT, fixturePath, mockFixtureYAML)
+
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		_, _ = io.WriteString(responseWriter, "backend:"+request.URL.Path)
+	}))
+	testingT.Cleanup(backendServer.Close)
+
+	proxyPort := allocateFreePort(testingT)
+	proxyBaseURL := fmt.Sprintf("http://127.0.0.1:%d", proxyPort)
+	mockServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(proxyPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + backendServer.URL,
+			"--mocks", fixturePath,
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		proxyBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	userStatusCode, userHeaders, userBody := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/api/users/42?q=search")
+	if userStatusCode != http.StatusOK || userBody != `{"id":"42","q":"search"}` || userHeaders.Get("X-User") != "42" || userHeaders.Get("Content-Type") != "application/json" {
+		testingT.Fatalf("unexpected templated mock response: status=%d headers=%v body=%s", userStatusCode, userHeaders, userBody)
+	}
+	expectations := map[string]struct {
+		expectedStatus int
+		expectedBody   string
+	}{
+		"/api/files/a/b.txt":  {expectedStatus: http.StatusOK, expectedBody: `{"rest":"a/b.txt"}`},
+		"/api/v1/ping":        {expectedStatus: http.StatusOK, expectedBody: "pong"},
+		"/api/users":          {expectedStatus: http.StatusOK, expectedBody: "backend:/api/users"},
+		"/api/users/42/extra": {expectedStatus: http.StatusOK, expectedBody: "backend:/api/users/42/extra"},
+		"/api//ping":          {expectedStatus: http.StatusOK, expectedBody: "backend:/api//ping"},
+		"/api/broken-file":    {expectedStatus: http.StatusInternalServerError, expectedBody: "mock response failed\n"},
+		"/api/broken-body":    {expectedStatus: http.StatusInternalServerError, expectedBody: "mock response failed\n"},
+		"/api/broken-header":  {expectedStatus: http.StatusInternalServerError, expectedBody: "mock response failed\n"},
+	}
+	for requestPath, expectation := range expectations {
+		statusCode, _, body := executeHTTPGet(testingT, httpClient, proxyBaseURL, requestPath)
+		if statusCode != expectation.expectedStatus || body != expectation.expectedBody {
+			testingT.Fatalf("unexpected mock response for %s: status=%d body=%q", requestPath, statusCode, body)
+		}
+	}
+	postResponse, postErr := httpClient.Post(proxyBaseURL+"/api/users", "application/json", strings.NewReader("{}"))
+	if postErr != nil {
+		testingT.Fatalf("post mock route: %v", postErr)
+	}
+	postBody, _ := io.ReadAll(postResponse.Body)
+	_ = postResponse.Body.Close()
+	if postResponse.StatusCode != http.StatusCreated || string(postBody) != "created" {
+		testingT.Fatalf("unexpected mock POST response: status=%d body=%q", postResponse.StatusCode, postBody)
+	}
+	headResponse, headErr := httpClient.Head(proxyBaseURL + "/api/files/report.json")
+	if headErr != nil {
+		testingT.Fatalf("head mock route: %v", headErr)
+	}
+	_ = headResponse.Body.Close()
+	if headResponse.StatusCode != http.StatusOK || headResponse.Header.Get("Content-Type") != "application/json" {
+		testingT.Fatalf("unexpected mock HEAD response: status=%d headers=%v", headResponse.StatusCode, headResponse.Header)
+	}
+
+	writeMockTestFile(testingT, fixturePath, "mocks:\n  - path: /api/users/:id\n    body: reloaded-{{.Params.id}}\n")
+	bumpMockFixtureModificationTime(testingT, fixturePath, time.Minute)
+	reloadedStatusCode, _, reloadedBody := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/api/users/7")
+	if reloadedStatusCode != http.StatusOK || reloadedBody != "reloaded-7" {
+		testingT.Fatalf("expected mock fixture hot reload, got status=%d body=%q", reloadedStatusCode, reloadedBody)
+	}
+	writeMockTestFile(testingT, fixturePath, "mocks: [")
+	bumpMockFixtureModificationTime(testingT, fixturePath, 2*time.Minute)
+	for attempt := 0; attempt < 2; attempt++ {
+		keptStatusCode, _, keptBody := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/api/users/8")
+		if keptStatusCode != http.StatusOK || keptBody != "reloaded-8" {
+			testingT.Fatalf("expected previous mocks to stay active after invalid reload, got status=%d body=%q", keptStatusCode, keptBody)
+		}
+	}
+	if removeErr := os.Remove(fixturePath); removeErr != nil {
+		testingT.Fatalf("remove mock fixture: %v", removeErr)
+	}
+	removedStatusCode, _, removedBody := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/api/users/9")
+	if removedStatusCode != http.StatusOK || removedBody != "reloaded-9" {
+		testingT.Fatalf("expected previous mocks to stay active after fixture removal, got status=%d body=%q", removedStatusCode, removedBody)
+	}
+	if stopErr := mockServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop mock server: %v", stopErr)
+	}
+	serverLogs := mockServer.logBuffer.String()
+	if !strings.Contains(serverLogs, `"GET /api/users/42?q=search HTTP/1.1" 200 24 mock`) || strings.Contains(serverLogs, `"GET /api/users HTTP/1.1" 200 18 mock`) {
+		testingT.Fatalf("expected console request log to mark mock responses only, got:\n%s", serverLogs)
+	}
+	if strings.Count(serverLogs, "mock fixture reload failed") != 2 || !strings.Contains(serverLogs, "mock fixture reloaded") {
+		testingT.Fatalf("expected mock fixture reload logs, got:\n%s", serverLogs)
+	}
+
+	jsonFixturePath := filepath.Join(fixtureDirectory, "mocks.json")
+	writeMockTestFile(testingT, jsonFixturePath, `{"mocks":[{"method":"GET","path":"/status","status":503,"body":"down"}]}`)
+	jsonPort := allocateFreePort(testingT)
+	jsonBaseURL := fmt.Sprintf("http://127.0.0.1:%d", jsonPort)
+	jsonServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(jsonPort), "--directory", siteDirectory, "--logging-type", "JSON"},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath, "GHTTP_SERVE_MOCKS": jsonFixturePath},
+		jsonBaseURL+"/",
+		false,
+	)
+	jsonStatusCode, _, jsonBody := executeHTTPGet(testingT, httpClient, jsonBaseURL, "/status")
+	if jsonStatusCode != http.StatusServiceUnavailable || jsonBody != "down" {
+		testingT.Fatalf("unexpected json fixture mock response: status=%d body=%q", jsonStatusCode, jsonBody)
+	}
+	if stopErr := jsonServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop json mock server: %v", stopErr)
+	}
+	if !strings.Contains(jsonServer.logBuffer.String(), `"handler":"mock"`) {
+		testingT.Fatalf("expected json request log to mark mock responses, got:\n%s", jsonServer.logBuffer.String())
+	}
+
+	invalidFixtures := map[string]string{
+		"extension.txt":       "mocks: []",
+		"syntax.yaml":         "mocks: [",
+		"syntax.json":         `{"mocks":[`,
+		"unknown-field.yaml":  "mocks:\n  - path: /a\n    unknown: true\n",
+		"relative-path.yaml":  "mocks:\n  - path: a\n",
+		"catch-all.yaml":      "mocks:\n  - path: /a/*rest/b\n",
+		"unnamed-param.yaml":  "mocks:\n  - path: /a/:\n",
+		"status.yaml":         "mocks:\n  - path: /a\n    status: 700\n",
+		"delay.yaml":          "mocks:\n  - path: /a\n    delay: soon\n",
+		"negative-delay.yaml": "mocks:\n  - path: /a\n    delay: -1s\n",
+		"exclusive.yaml":      "mocks:\n  - path: /a\n    body: inline\n    body_file: fixtures/user.json\n",
+		"absolute.yaml":       "mocks:\n  - path: /a\n    body_file: /etc/hostname\n",
+		"escape.yaml":         "mocks:\n  - path: /a\n    body_file: ../outside.json\n",
+		"missing-file.yaml":   "mocks:\n  - path: /a\n    body_file: fixtures/missing.json\n",
+		"directory.yaml":      "mocks:\n  - path: /a\n    body_file: fixtures\n",
+		"body-template.yaml":  "mocks:\n  - path: /a\n    body: \"{{\"\n",
+		"header-template.yml": "mocks:\n  - path: /a\n    headers:\n      X-A: \"{{\"\n",
+	}
+	invalidFixturePaths := []string{filepath.Join(fixtureDirectory, "missing.yaml")}
+	for fileName, content := range invalidFixtures {
+		invalidFixturePath := filepath.Join(fixtureDirectory, fileName)
+		writeMockTestFile(testingT, invalidFixturePath, content)
+		invalidFixturePaths = append(invalidFixturePaths, invalidFixturePath)
+	}
+	for _, invalidFixturePath := range invalidFixturePaths {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--mocks", invalidFixturePath},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func writeMockTestFile(testingT *testing.T, filePath string, content string) {
+	testingT.Helper()
+	if mkdirErr := os.MkdirAll(filepath.Dir(filePath), 0o755); mkdirErr != nil {
+		testingT.Fatalf("create mock test directory: %v", mkdirErr)
+	}
+	if writeErr := os.WriteFile(filePath, []byte(content), 0o600); writeErr != nil {
+		testingT.Fatalf("write mock test file: %v", writeErr)
+	}
+}
+
+func bumpMockFixtureModificationTime(testingT *testing.T, fixturePath string, offset time.Duration) {
+	testingT.Helper()
+	modificationTime := time.Now().Add(offset)
+	if chtimesErr := os.Chtimes(fixturePath, modificationTime, modificationTime); chtimesErr != nil {
+		testingT.Fatalf("update mock fixture modification time: %v", chtimesErr)
+	}
+}
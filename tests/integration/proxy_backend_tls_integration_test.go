@@ -0,0 +1,359 @@
+package integration
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCertificateAuthority struct {
+	certificate     *x509.Certificate
+	privateKey      *rsa.PrivateKey
+	certificatePath string
+}
+
+type testIssuedCertificate struct {
+	tlsCertificate  tls.Certificate
+	certificatePath string
+	privateKeyPath  string
+}
+
+func exerciseProxyBackendTLSFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	certificateAuthority := createTestCertificateAuthority(testingT)
+	backendCertificate := certificateAuthority.issueCertificate(testingT, "127.0.0.1", nil, []net.IP{net.ParseIP("127.0.0.1")}, x509.ExtKeyUsageServerAuth)
+	namedBackendCertificate := certificateAuthority.issueCertificate(testingT, "backend.test", []string{"backend.test"}, nil, x509.ExtKeyUsageServerAuth)
+	clientCertificate := certificateAuthority.issueCertificate(testingT, "ghttp-client", nil, nil, x509.ExtKeyUsageClientAuth)
+
+	backendHandler := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		clientName := "anonymous"
+		if request.TLS != nil && len(request.TLS.PeerCertificates) > 0 {
+			clientName = request.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		_, _ = io.WriteString(responseWriter, "tls-backend:"+request.URL.Path+":"+clientName)
+	})
+	backendServer := startTLSBackend(testingT, backendHandler, &tls.Config{Certificates: []tls.Certificate{backendCertificate.tlsCertificate}})
+	namedBackendServer := startTLSBackend(testingT, backendHandler, &tls.Config{Certificates: []tls.Certificate{namedBackendCertificate.tlsCertificate}})
+	mutualBackendServer := startTLSBackend(testingT, backendHandler, &tls.Config{
+		Certificates: []tls.Certificate{backendCertificate.tlsCertificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certificateAuthority.pool(),
+	})
+	webSocketBackendAddress := startTLSWebSocketEchoBackend(testingT, backendCertificate.tlsCertificate)
+
+	emptyCertificateDirectory := testingT.TempDir()
+	proxyPort := allocateFreePort(testingT)
+	proxyBaseURL := fmt.Sprintf("http://127.0.0.1:%d", proxyPort)
+	proxyServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(proxyPort),
+			"--directory", testingT.TempDir(),
+			"--proxy", "/trusted=" + backendServer.URL,
+			"--proxy", "/insecure=" + backendServer.URL,
+			"--proxy", "/untrusted=" + backendServer.URL,
+			"--proxy", "/named=" + namedBackendServer.URL,
+			"--proxy", "/mtls=" + mutualBackendServer.URL,
+			"--proxy", "/wss=https://" + webSocketBackendAddress,
+			"--proxy-backend-tls", "/trusted=ca:" + certificateAuthority.certificatePath,
+			"--proxy-backend-tls", "/insecure=insecure_skip_verify:true",
+			"--proxy-backend-tls", "/named=ca:" + certificateAuthority.certificatePath,
+			"--proxy-backend-tls", "/named=server_name:backend.test",
+			"--proxy-backend-tls", "/mtls=ca:" + certificateAuthority.certificatePath,
+			"--proxy-backend-tls", "/mtls=client_certificate:" + clientCertificate.certificatePath,
+			"--proxy-backend-tls", "/mtls=client_key:" + clientCertificate.privateKeyPath,
+			"--proxy-backend-tls", "/wss=ca:" + certificateAuthority.certificatePath,
+		},
+		map[string]string{
+			"GOCOVERDIR":                        coverageDirectoryPath,
+			"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": emptyCertificateDirectory,
+		},
+		proxyBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	expectedBodies := map[string]string{
+		"/trusted/check":  "tls-backend:/trusted/check:anonymous",
+		"/insecure/check": "tls-backend:/insecure/check:anonymous",
+		"/named/check":    "tls-backend:/named/check:anonymous",
+		"/mtls/check":     "tls-backend:/mtls/check:ghttp-client",
+	}
+	for requestPath, expectedBody := range expectedBodies {
+		statusCode, _, body := executeHTTPGet(testingT, httpClient, proxyBaseURL, requestPath)
+		if statusCode != http.StatusOK || body != expectedBody {
+			testingT.Fatalf("expected %s to proxy over tls with body %q, got status=%d body=%s", requestPath, expectedBody, statusCode, body)
+		}
+	}
+	untrustedStatusCode, _, _ := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/untrusted/check")
+	if untrustedStatusCode != http.StatusBadGateway {
+		testingT.Fatalf("expected untrusted backend certificate to fail with 502, got %d", untrustedStatusCode)
+	}
+	performWebSocketUpgradeRoundTrip(testingT, fmt.Sprintf("127.0.0.1:%d", proxyPort), "/wss")
+	if stopErr := proxyServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop proxy backend tls server: %v", stopErr)
+	}
+
+	developmentCertificateDirectory := testingT.TempDir()
+	certificateAuthorityBytes, readErr := os.ReadFile(certificateAuthority.certificatePath)
+	if readErr != nil {
+		testingT.Fatalf("read test certificate authority: %v", readErr)
+	}
+	if writeErr := os.WriteFile(filepath.Join(developmentCertificateDirectory, "ca.pem"), certificateAuthorityBytes, 0o600); writeErr != nil {
+		testingT.Fatalf("write development certificate authority: %v", writeErr)
+	}
+	developmentPort := allocateFreePort(testingT)
+	developmentBaseURL := fmt.Sprintf("http://127.0.0.1:%d", developmentPort)
+	developmentServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(developmentPort),
+			"--directory", testingT.TempDir(),
+			"--proxy", "/dev=" + backendServer.URL,
+		},
+		map[string]string{
+			"GOCOVERDIR":                        coverageDirectoryPath,
+			"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": developmentCertificateDirectory,
+		},
+		developmentBaseURL+"/",
+		false,
+	)
+	developmentStatusCode, _, developmentBody := executeHTTPGet(testingT, httpClient, developmentBaseURL, "/dev/check")
+	if developmentStatusCode != http.StatusOK || developmentBody != "tls-backend:/dev/check:anonymous" {
+		testingT.Fatalf("expected development certificate authority to be trusted by default, got status=%d body=%s", developmentStatusCode, developmentBody)
+	}
+	if stopErr := developmentServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop development ca proxy server: %v", stopErr)
+	}
+
+	invalidBundlePath := filepath.Join(testingT.TempDir(), "invalid.pem")
+	if writeErr := os.WriteFile(invalidBundlePath, []byte("not a certificate"), 0o600); writeErr != nil {
+		testingT.Fatalf("write invalid bundle: %v", writeErr)
+	}
+	invalidDevelopmentDirectory := testingT.TempDir()
+	if writeErr := os.WriteFile(filepath.Join(invalidDevelopmentDirectory, "ca.pem"), []byte("not a certificate"), 0o600); writeErr != nil {
+		testingT.Fatalf("write invalid development certificate authority: %v", writeErr)
+	}
+	invalidArgumentSets := [][]string{
+		{"--proxy-backend-tls", "/api=insecure_skip_verify:true"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "=ca:" + certificateAuthority.certificatePath},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "api=ca:" + certificateAuthority.certificatePath},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=ca"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=unknown:value"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=server_name:"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=insecure_skip_verify:maybe"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=ca:" + filepath.Join(testingT.TempDir(), "missing.pem")},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=ca:" + invalidBundlePath},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=client_certificate:" + clientCertificate.certificatePath},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", "/api=client_certificate:" + clientCertificate.certificatePath, "--proxy-backend-tls", "/api=client_key:" + invalidBundlePath},
+	}
+	for _, invalidArguments := range invalidArgumentSets {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir()}, invalidArguments...),
+			map[string]string{
+				"GOCOVERDIR":                        coverageDirectoryPath,
+				"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": emptyCertificateDirectory,
+			},
+			1,
+		)
+	}
+	for _, unmatchedPathPrefix := range []string{"/api/v2", "/apl"} {
+		unmatchedOutput := runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir(), "--proxy", "/api=" + backendServer.URL, "--proxy-backend-tls", unmatchedPathPrefix + "=insecure_skip_verify:true"},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+		if !strings.Contains(unmatchedOutput, "does not select any proxy route") {
+			testingT.Fatalf("expected a backend tls policy for %s to be rejected without a matching proxy route, got:\n%s", unmatchedPathPrefix, unmatchedOutput)
+		}
+	}
+	runCommandExpectExitCode(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir(), "--proxy", "/api=" + backendServer.URL},
+		map[string]string{
+			"GOCOVERDIR":                        coverageDirectoryPath,
+			"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": invalidDevelopmentDirectory,
+		},
+		1,
+	)
+	runCommandExpectExitCode(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir(), "--proxy", "/api=" + backendServer.URL},
+		map[string]string{
+			"GOCOVERDIR":                        coverageDirectoryPath,
+			"GHTTP_HTTPS_CERTIFICATE_DIRECTORY": invalidBundlePath,
+		},
+		1,
+	)
+}
+
+func createTestCertificateAuthority(testingT *testing.T) testCertificateAuthority {
+	testingT.Helper()
+	privateKey, keyErr := rsa.GenerateKey(rand.Reader, 2048)
+	if keyErr != nil {
+		testingT.Fatalf("generate test ca key: %v", keyErr)
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          generateTestSerialNumber(testingT),
+		Subject:               pkix.Name{CommonName: "ghttp integration test ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certificateDER, certificateErr := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if certificateErr != nil {
+		testingT.Fatalf("create test ca certificate: %v", certificateErr)
+	}
+	certificate, parseErr := x509.ParseCertificate(certificateDER)
+	if parseErr != nil {
+		testingT.Fatalf("parse test ca certificate: %v", parseErr)
+	}
+	certificatePath := filepath.Join(testingT.TempDir(), "test-ca.pem")
+	if writeErr := os.WriteFile(certificatePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER}), 0o600); writeErr != nil {
+		testingT.Fatalf("write test ca certificate: %v", writeErr)
+	}
+	return testCertificateAuthority{certificate: certificate, privateKey: privateKey, certificatePath: certificatePath}
+}
+
+func (authority testCertificateAuthority) issueCertificate(testingT *testing.T, commonName string, dnsNames []string, ipAddresses []net.IP, extendedKeyUsage x509.ExtKeyUsage) testIssuedCertificate {
+	testingT.Helper()
+	privateKey, keyErr := rsa.GenerateKey(rand.Reader, 2048)
+	if keyErr != nil {
+		testingT.Fatalf("generate test leaf key: %v", keyErr)
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          generateTestSerialNumber(testingT),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		DNSNames:              dnsNames,
+		IPAddresses:           ipAddresses,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{extendedKeyUsage},
+		BasicConstraintsValid: true,
+	}
+	certificateDER, certificateErr := x509.CreateCertificate(rand.Reader, &template, authority.certificate, &privateKey.PublicKey, authority.privateKey)
+	if certificateErr != nil {
+		testingT.Fatalf("create test leaf certificate: %v", certificateErr)
+	}
+	certificatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER})
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	tlsCertificate, pairErr := tls.X509KeyPair(certificatePEM, privateKeyPEM)
+	if pairErr != nil {
+		testingT.Fatalf("build test leaf key pair: %v", pairErr)
+	}
+	outputDirectory := testingT.TempDir()
+	certificatePath := filepath.Join(outputDirectory, "leaf.pem")
+	privateKeyPath := filepath.Join(outputDirectory, "leaf.key")
+	if writeErr := os.WriteFile(certificatePath, certificatePEM, 0o600); writeErr != nil {
+		testingT.Fatalf("write test leaf certificate: %v", writeErr)
+	}
+	if writeErr := os.WriteFile(privateKeyPath, privateKeyPEM, 0o600); writeErr != nil {
+		testingT.Fatalf("write test leaf private key: %v", writeErr)
+	}
+	return testIssuedCertificate{tlsCertificate: tlsCertificate, certificatePath: certificatePath, privateKeyPath: privateKeyPath}
+}
+
+func (authority testCertificateAuthority) pool() *x509.CertPool {
+	certificatePool := x509.NewCertPool()
+	certificatePool.AddCert(authority.certificate)
+	return certificatePool
+}
+
+func generateTestSerialNumber(testingT *testing.T) *big.Int {
+	testingT.Helper()
+	serialNumber, serialErr := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if serialErr != nil {
+		testingT.Fatalf("generate test serial number: %v", serialErr)
+	}
+	return serialNumber
+}
+
+func startTLSBackend(testingT *testing.T, handler http.Handler, tlsConfig *tls.Config) *httptest.Server {
+	testingT.Helper()
+	backendServer := httptest.NewUnstartedServer(handler)
+	backendServer.TLS = tlsConfig
+	backendServer.StartTLS()
+	testingT.Cleanup(backendServer.Close)
+	return backendServer
+}
+
+func startTLSWebSocketEchoBackend(testingT *testing.T, certificate tls.Certificate) string {
+	testingT.Helper()
+	backendListener, listenErr := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if listenErr != nil {
+		testingT.Fatalf("start tls websocket backend listener: %v", listenErr)
+	}
+	testingT.Cleanup(func() {
+		_ = backendListener.Close()
+	})
+	go func() {
+		for {
+			connection, acceptErr := backendListener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go serveRawWebSocketEcho(connection)
+		}
+	}()
+	return backendListener.Addr().String()
+}
+
+func serveRawWebSocketEcho(connection net.Conn) {
+	defer connection.Close()
+	reader := bufio.NewReader(connection)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			return
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+	}
+	_, _ = io.WriteString(connection, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	buffer := make([]byte, 1024)
+	for {
+		readCount, readErr := reader.Read(buffer)
+		if readCount > 0 {
+			_, _ = connection.Write(buffer[:readCount])
+		}
+		if readErr != nil {
+			return
+		}
+	}
+}
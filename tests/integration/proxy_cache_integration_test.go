@@ -0,0 +1,323 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const proxyCacheStaleWait = 1200 * time.Millisecond
+
+type proxyCacheAdminState struct {
+	Mode    string `json:"mode"`
+	Entries int    `json:"entries"`
+	Purged  int    `json:"purged"`
+}
+
+// proxyCacheBackend counts requests per path so tests can tell cache hits from backend round trips.
+type proxyCacheBackend struct {
+	mutex        sync.Mutex
+	requestCount map[string]int
+	failing      bool
+}
+
+func (backend *proxyCacheBackend) record(requestPath string) int {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	backend.requestCount[requestPath]++
+	return backend.requestCount[requestPath]
+}
+
+func (backend *proxyCacheBackend) count(requestPath string) int {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	return backend.requestCount[requestPath]
+}
+
+func (backend *proxyCacheBackend) setFailing(failing bool) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	backend.failing = failing
+}
+
+func (backend *proxyCacheBackend) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	requestNumber := backend.record(request.URL.Path)
+	backend.mutex.Lock()
+	failing := backend.failing
+	backend.mutex.Unlock()
+	switch request.URL.Path {
+	case "/api/fresh":
+		responseWriter.Header().Set("Cache-Control", "max-age=60")
+	case "/api/vary":
+		responseWriter.Header().Set("Cache-Control", "public, max-age=60")
+		responseWriter.Header().Set("Vary", "Accept-Language")
+		_, _ = fmt.Fprintf(responseWriter, "%s-%d", request.Header.Get("Accept-Language"), requestNumber)
+		return
+	case "/api/no-store":
+		responseWriter.Header().Set("Cache-Control", "no-store")
+	case "/api/expires":
+		responseWriter.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	case "/api/swr":
+		responseWriter.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=60")
+	case "/api/sie":
+		if failing {
+			http.Error(responseWriter, "backend down", http.StatusServiceUnavailable)
+			return
+		}
+		responseWriter.Header().Set("Cache-Control", "max-age=1, stale-if-error=60")
+	case "/api/etag":
+		responseWriter.Header().Set("Cache-Control", "max-age=1")
+		responseWriter.Header().Set("ETag", `"v1"`)
+		if request.Header.Get("If-None-Match") == `"v1"` {
+			responseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	case "/api/cookie":
+		responseWriter.Header().Set("Cache-Control", "max-age=60")
+		responseWriter.Header().Set("Set-Cookie", "session=1")
+	}
+	_, _ = fmt.Fprintf(responseWriter, "%s-%d", request.URL.Path, requestNumber)
+}
+
+func exerciseProxyCacheFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	cacheBackend := &proxyCacheBackend{requestCount: map[string]int{}}
+	backendServer := httptest.NewServer(cacheBackend)
+	testingT.Cleanup(backendServer.Close)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+
+	cachePort := allocateFreePort(testingT)
+	cacheBaseURL := fmt.Sprintf("http://127.0.0.1:%d", cachePort)
+	cacheServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(cachePort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + backendServer.URL,
+			"--proxy-cache", "memory",
+			"--proxy-cache-ttl", "/api/plain=1h",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		cacheBaseURL+"/__ghttp/cache",
+		false,
+	)
+
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", nil, "MISS", "/api/fresh-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", nil, "HIT", "/api/fresh-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodHead, cacheBaseURL+"/api/fresh", nil, "HIT", "")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh?page=2", nil, "MISS", "/api/fresh-2")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", map[string]string{"Cache-Control": "no-cache"}, "MISS", "/api/fresh-3")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", nil, "HIT", "/api/fresh-3")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", map[string]string{"Cache-Control": "no-store"}, "MISS", "/api/fresh-4")
+
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/vary", map[string]string{"Accept-Language": "en"}, "MISS", "en-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/vary", map[string]string{"Accept-Language": "fr"}, "MISS", "fr-2")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/vary", map[string]string{"Accept-Language": "en"}, "HIT", "en-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/vary", map[string]string{"Accept-Language": "fr", "Authorization": "Bearer token"}, "HIT", "fr-2")
+
+	for _, uncachedPath := range []string{"/api/no-store", "/api/unlisted", "/api/cookie"} {
+		expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+uncachedPath, nil, "MISS", uncachedPath+"-1")
+		expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+uncachedPath, nil, "MISS", uncachedPath+"-2")
+	}
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", map[string]string{"Authorization": "Bearer token"}, "HIT", "/api/fresh-3")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/plain", nil, "MISS", "/api/plain-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/plain", nil, "HIT", "/api/plain-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/expires", nil, "MISS", "/api/expires-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/expires", nil, "HIT", "/api/expires-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodPost, cacheBaseURL+"/api/fresh", nil, "", "/api/fresh-5")
+
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/swr", nil, "MISS", "/api/swr-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/sie", nil, "MISS", "/api/sie-1")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/etag", nil, "MISS", "/api/etag-1")
+	time.Sleep(proxyCacheStaleWait)
+
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/swr", nil, "STALE", "/api/swr-1")
+	waitForProxyCacheBackendCount(testingT, cacheBackend, "/api/swr", 2)
+	waitForProxyCacheBody(testingT, httpClient, cacheBaseURL+"/api/swr", "/api/swr-2")
+
+	cacheBackend.setFailing(true)
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/sie", nil, "STALE", "/api/sie-1")
+	cacheBackend.setFailing(false)
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/sie", nil, "MISS", "/api/sie-3")
+
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/etag", nil, "HIT", "/api/etag-1")
+	if etagRequests := cacheBackend.count("/api/etag"); etagRequests != 2 {
+		testingT.Fatalf("expected one conditional revalidation of /api/etag, backend saw %d requests", etagRequests)
+	}
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/etag", nil, "HIT", "/api/etag-1")
+
+	adminState := executeProxyCacheAdminRequest(testingT, httpClient, http.MethodGet, cacheBaseURL+"/__ghttp/cache", http.StatusOK)
+	if adminState.Mode != "memory" || adminState.Entries < 8 {
+		testingT.Fatalf("unexpected cache state: %+v", adminState)
+	}
+	purgedState := executeProxyCacheAdminRequest(testingT, httpClient, http.MethodDelete, cacheBaseURL+"/__ghttp/cache?prefix=/api/vary", http.StatusOK)
+	if purgedState.Purged != 2 || purgedState.Entries != adminState.Entries-2 {
+		testingT.Fatalf("unexpected prefix purge result: %+v", purgedState)
+	}
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/vary", map[string]string{"Accept-Language": "en"}, "MISS", "en-3")
+	executeProxyCacheAdminRequest(testingT, httpClient, http.MethodHead, cacheBaseURL+"/__ghttp/cache", http.StatusOK)
+	executeProxyCacheAdminRequest(testingT, httpClient, http.MethodPut, cacheBaseURL+"/__ghttp/cache", http.StatusMethodNotAllowed)
+	emptyState := executeProxyCacheAdminRequest(testingT, httpClient, http.MethodPost, cacheBaseURL+"/__ghttp/cache", http.StatusOK)
+	if emptyState.Entries != 0 {
+		testingT.Fatalf("expected full purge to empty the cache, got %+v", emptyState)
+	}
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, cacheBaseURL+"/api/fresh", nil, "MISS", "/api/fresh-6")
+	if stopErr := cacheServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop cache server: %v", stopErr)
+	}
+
+	cacheDirectory := filepath.Join(testingT.TempDir(), "cache")
+	diskArguments := []string{
+		"--directory", siteDirectory,
+		"--proxy", "/api=" + backendServer.URL,
+		"--proxy-cache", "disk",
+		"--proxy-cache-dir", cacheDirectory,
+	}
+	diskPort := allocateFreePort(testingT)
+	diskBaseURL := fmt.Sprintf("http://127.0.0.1:%d", diskPort)
+	diskServer := startGHTTPProcessWithArguments(testingT, repositoryRoot, binaryPath, append([]string{strconv.Itoa(diskPort)}, diskArguments...), map[string]string{"GOCOVERDIR": coverageDirectoryPath}, diskBaseURL+"/__ghttp/cache", false)
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, diskBaseURL+"/api/fresh?disk=1", nil, "MISS", "/api/fresh-7")
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, diskBaseURL+"/api/fresh?disk=2", nil, "MISS", "/api/fresh-8")
+	executeProxyCacheAdminRequest(testingT, httpClient, http.MethodDelete, diskBaseURL+"/__ghttp/cache?prefix=/api/fresh?disk=2", http.StatusOK)
+	if stopErr := diskServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop disk cache server: %v", stopErr)
+	}
+	cacheFiles, _ := filepath.Glob(filepath.Join(cacheDirectory, "*.json"))
+	if len(cacheFiles) != 1 {
+		testingT.Fatalf("expected one persisted cache file, found %v", cacheFiles)
+	}
+	corruptFilePath := filepath.Join(cacheDirectory, "corrupt.json")
+	if writeErr := os.WriteFile(corruptFilePath, []byte("{not json"), 0o600); writeErr != nil {
+		testingT.Fatalf("write corrupt cache file: %v", writeErr)
+	}
+
+	restartedPort := allocateFreePort(testingT)
+	restartedBaseURL := fmt.Sprintf("http://127.0.0.1:%d", restartedPort)
+	restartedServer := startGHTTPProcessWithArguments(testingT, repositoryRoot, binaryPath, append([]string{strconv.Itoa(restartedPort)}, diskArguments...), map[string]string{"GOCOVERDIR": coverageDirectoryPath}, restartedBaseURL+"/__ghttp/cache", false)
+	expectProxyCacheResponse(testingT, httpClient, http.MethodGet, restartedBaseURL+"/api/fresh?disk=1", nil, "HIT", "/api/fresh-7")
+	if _, statErr := os.Stat(corruptFilePath); !os.IsNotExist(statErr) {
+		testingT.Fatalf("expected corrupt cache file to be discarded, stat error: %v", statErr)
+	}
+	if stopErr := restartedServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop restarted disk cache server: %v", stopErr)
+	}
+
+	blockedDirectoryPath := filepath.Join(testingT.TempDir(), "blocked")
+	if writeErr := os.WriteFile(blockedDirectoryPath, []byte("file"), 0o600); writeErr != nil {
+		testingT.Fatalf("write blocking file: %v", writeErr)
+	}
+	invalidArguments := [][]string{
+		{"--proxy-cache", "memory"},
+		{"--proxy-cache-ttl", "/api=1m"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "redis"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "disk"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "memory", "--proxy-cache-dir", cacheDirectory},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "disk", "--proxy-cache-dir", filepath.Join(blockedDirectoryPath, "cache")},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "memory", "--proxy-cache-ttl", "/api"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "memory", "--proxy-cache-ttl", "api=1m"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-cache", "memory", "--proxy-cache-ttl", "/api=soon"},
+	}
+	for _, arguments := range invalidArguments {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory}, arguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func expectProxyCacheResponse(testingT *testing.T, httpClient *http.Client, method string, requestURL string, requestHeaders map[string]string, expectedCacheStatus string, expectedBody string) {
+	testingT.Helper()
+	request, requestErr := http.NewRequest(method, requestURL, nil)
+	if requestErr != nil {
+		testingT.Fatalf("build %s %s: %v", method, requestURL, requestErr)
+	}
+	for headerName, headerValue := range requestHeaders {
+		request.Header.Set(headerName, headerValue)
+	}
+	response, responseErr := httpClient.Do(request)
+	if responseErr != nil {
+		testingT.Fatalf("%s %s: %v", method, requestURL, responseErr)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || response.Header.Get("X-Cache") != expectedCacheStatus || string(responseBody) != expectedBody {
+		testingT.Fatalf("%s %s: expected %s %q, got status=%d X-Cache=%q body=%q", method, requestURL, expectedCacheStatus, expectedBody, response.StatusCode, response.Header.Get("X-Cache"), responseBody)
+	}
+	if expectedCacheStatus == "HIT" || expectedCacheStatus == "STALE" {
+		if _, ageErr := strconv.Atoi(response.Header.Get("Age")); ageErr != nil {
+			testingT.Fatalf("%s %s: expected an Age header on a cached response, got %q", method, requestURL, response.Header.Get("Age"))
+		}
+	}
+}
+
+func waitForProxyCacheBackendCount(testingT *testing.T, backend *proxyCacheBackend, requestPath string, expectedCount int) {
+	testingT.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.count(requestPath) < expectedCount {
+		if time.Now().After(deadline) {
+			testingT.Fatalf("backend saw %d requests for %s, expected %d", backend.count(requestPath), requestPath, expectedCount)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForProxyCacheBody polls until a background revalidation has replaced the cached body.
+func waitForProxyCacheBody(testingT *testing.T, httpClient *http.Client, requestURL string, expectedBody string) {
+	testingT.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		response, responseErr := httpClient.Get(requestURL)
+		if responseErr != nil {
+			testingT.Fatalf("get %s: %v", requestURL, responseErr)
+		}
+		responseBody, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if response.Header.Get("X-Cache") == "HIT" && strings.TrimSpace(string(responseBody)) == expectedBody {
+			return
+		}
+		if time.Now().After(deadline) {
+			testingT.Fatalf("expected revalidated body %q for %s, got X-Cache=%q body=%q", expectedBody, requestURL, response.Header.Get("X-Cache"), responseBody)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func executeProxyCacheAdminRequest(testingT *testing.T, httpClient *http.Client, method string, requestURL string, expectedStatus int) proxyCacheAdminState {
+	testingT.Helper()
+	request, requestErr := http.NewRequest(method, requestURL, nil)
+	if requestErr != nil {
+		testingT.Fatalf("build cache admin request: %v", requestErr)
+	}
+	response, responseErr := httpClient.Do(request)
+	if responseErr != nil {
+		testingT.Fatalf("cache admin %s: %v", method, responseErr)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode != expectedStatus {
+		testingT.Fatalf("cache admin %s: expected %d, got %d body=%s", method, expectedStatus, response.StatusCode, responseBody)
+	}
+	var adminState proxyCacheAdminState
+	if expectedStatus == http.StatusOK && method != http.MethodHead {
+		if decodeErr := json.Unmarshal(responseBody, &adminState); decodeErr != nil {
+			testingT.Fatalf("decode cache admin response %s: %v", responseBody, decodeErr)
+		}
+	}
+	return adminState
+}
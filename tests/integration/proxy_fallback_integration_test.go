@@ -0,0 +1,133 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseProxyFallbackFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	for relativePath, content := range map[string]string{
+		"app/static.txt":    "static-app",
+		"pf/static.txt":     "static-pf",
+		"down/offline.txt":  "static-offline",
+		"plain/static.txt":  "static-plain",
+		"app/nested/ok.txt": "static-nested",
+	} {
+		filePath := filepath.Join(siteDirectory, filepath.FromSlash(relativePath))
+		if mkdirErr := os.MkdirAll(filepath.Dir(filePath), 0o755); mkdirErr != nil {
+			testingT.Fatalf("create fallback fixture directory: %v", mkdirErr)
+		}
+		if writeErr := os.WriteFile(filePath, []byte(content), 0o600); writeErr != nil {
+			testingT.Fatalf("write fallback fixture: %v", writeErr)
+		}
+	}
+
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("X-Backend", "yes")
+		if strings.HasSuffix(request.URL.Path, "/dynamic") || strings.HasPrefix(request.URL.Path, "/app/") {
+			_, _ = io.WriteString(responseWriter, "backend:"+request.Method+":"+request.URL.Path)
+			return
+		}
+		http.NotFound(responseWriter, request)
+	}))
+	testingT.Cleanup(backendServer.Close)
+	unavailableBackendURL := fmt.Sprintf("http://127.0.0.1:%d", allocateFreePort(testingT))
+
+	proxyPort := allocateFreePort(testingT)
+	proxyBaseURL := fmt.Sprintf("http://127.0.0.1:%d", proxyPort)
+	proxyServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(proxyPort),
+			"--directory", siteDirectory,
+			"--proxy", "/app=" + backendServer.URL,
+			"--proxy", "/pf=" + backendServer.URL,
+			"--proxy", "/down=" + unavailableBackendURL,
+			"--proxy", "/plain=" + backendServer.URL,
+			"--proxy-fallback", "/app=static-first",
+			"--proxy-fallback", "/pf=proxy-first",
+			"--proxy-fallback", "/down=proxy-first",
+			"--proxy-fallback", "/plain=proxy",
+			"--proxy-streaming", "/pf=unbuffered",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		proxyBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	expectations := []struct {
+		requestPath    string
+		expectedStatus int
+		expectedBody   string
+		fromBackend    bool
+	}{
+		{requestPath: "/app/static.txt", expectedStatus: http.StatusOK, expectedBody: "static-app"},
+		{requestPath: "/app/nested/ok.txt", expectedStatus: http.StatusOK, expectedBody: "static-nested"},
+		{requestPath: "/app/page", expectedStatus: http.StatusOK, expectedBody: "backend:GET:/app/page", fromBackend: true},
+		{requestPath: "/pf/dynamic", expectedStatus: http.StatusOK, expectedBody: "backend:GET:/pf/dynamic", fromBackend: true},
+		{requestPath: "/pf/static.txt", expectedStatus: http.StatusOK, expectedBody: "static-pf"},
+		{requestPath: "/down/offline.txt", expectedStatus: http.StatusOK, expectedBody: "static-offline"},
+		{requestPath: "/plain/static.txt", expectedStatus: http.StatusNotFound, expectedBody: "404 page not found\n", fromBackend: true},
+	}
+	for _, expectation := range expectations {
+		statusCode, headers, body := executeHTTPGet(testingT, httpClient, proxyBaseURL, expectation.requestPath)
+		servedByBackend := headers.Get("X-Backend") == "yes"
+		if statusCode != expectation.expectedStatus || body != expectation.expectedBody || servedByBackend != expectation.fromBackend {
+			testingT.Fatalf("unexpected fallback response for %s: status=%d body=%q backend=%t", expectation.requestPath, statusCode, body, servedByBackend)
+		}
+	}
+	missingStatusCode, missingHeaders, _ := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/pf/missing.txt")
+	if missingStatusCode != http.StatusNotFound || missingHeaders.Get("X-Backend") != "" {
+		testingT.Fatalf("expected proxy-first miss to fall back to the static 404, got status=%d headers=%v", missingStatusCode, missingHeaders)
+	}
+
+	postResponse, postErr := httpClient.Post(proxyBaseURL+"/app/static.txt", "text/plain", strings.NewReader("payload"))
+	if postErr != nil {
+		testingT.Fatalf("post static-first route: %v", postErr)
+	}
+	postBody, _ := io.ReadAll(postResponse.Body)
+	_ = postResponse.Body.Close()
+	if string(postBody) != "backend:POST:/app/static.txt" {
+		testingT.Fatalf("expected static-first POST to go to backend, got %q", postBody)
+	}
+	headResponse, headErr := httpClient.Head(proxyBaseURL + "/app/page")
+	if headErr != nil {
+		testingT.Fatalf("head static-first route: %v", headErr)
+	}
+	_ = headResponse.Body.Close()
+	if headResponse.StatusCode != http.StatusOK || headResponse.Header.Get("X-Backend") != "yes" {
+		testingT.Fatalf("expected static-first HEAD miss to go to backend, got status=%d", headResponse.StatusCode)
+	}
+	if stopErr := proxyServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop proxy fallback server: %v", stopErr)
+	}
+
+	invalidArgumentSets := [][]string{
+		{"--proxy-fallback", "/app=static-first"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-fallback", "/app"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-fallback", "=static-first"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-fallback", "app=static-first"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-fallback", "/app=backend-first"},
+	}
+	for _, invalidArguments := range invalidArgumentSets {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory}, invalidArguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
@@ -0,0 +1,260 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	grpcTestStatusHeader         = "Grpc-Status"
+	grpcTestReceivedContentType  = "X-Received-Content-Type"
+	grpcTestStreamReleaseTimeout = 5 * time.Second
+)
+
+func exerciseGRPCProxyFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	releaseStream := make(chan struct{})
+	backendServer := startHTTP2CleartextBackend(testingT, http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		requestBody, readErr := io.ReadAll(request.Body)
+		if readErr != nil {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		responseWriter.Header().Set(grpcTestReceivedContentType, request.Header.Get("Content-Type"))
+		responseWriter.Header().Set("Content-Type", "application/grpc+proto")
+		if request.ProtoMajor != 2 || request.Header.Get("Te") != "trailers" || !strings.HasPrefix(request.Header.Get("Content-Type"), "application/grpc") {
+			responseWriter.Header().Set(grpcTestStatusHeader, "3")
+			responseWriter.WriteHeader(http.StatusOK)
+			return
+		}
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/Stream"):
+			_, _ = responseWriter.Write(encodeGRPCTestFrame("first"))
+			http.NewResponseController(responseWriter).Flush()
+			select {
+			case <-releaseStream:
+			case <-time.After(grpcTestStreamReleaseTimeout):
+			}
+			_, _ = responseWriter.Write(encodeGRPCTestFrame("second"))
+			responseWriter.Header().Set(http.TrailerPrefix+grpcTestStatusHeader, "0")
+		case strings.HasSuffix(request.URL.Path, "/Fail"):
+			responseWriter.Header().Set(http.TrailerPrefix+grpcTestStatusHeader, "5")
+			responseWriter.Header().Set(http.TrailerPrefix+"Grpc-Message", "not found")
+			responseWriter.WriteHeader(http.StatusOK)
+		default:
+			_, _ = responseWriter.Write(requestBody)
+			responseWriter.Header().Set(http.TrailerPrefix+grpcTestStatusHeader, "0")
+		}
+	}))
+	unavailableBackendPort := allocateFreePort(testingT)
+
+	proxyPort := allocateFreePort(testingT)
+	proxyBaseURL := fmt.Sprintf("http://127.0.0.1:%d", proxyPort)
+	backendURL := strings.Replace(backendServer.URL, "http://", "h2c://", 1)
+	proxyServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(proxyPort),
+			"--directory", testingT.TempDir(),
+			"--h2c",
+			"--proxy", "/grpc=" + backendURL,
+			"--proxy", "/buffered=" + backendURL,
+			"--proxy", "/plain=" + backendURL,
+			"--proxy", fmt.Sprintf("/down=h2c://127.0.0.1:%d", unavailableBackendPort),
+			"--proxy-streaming", "/buffered=buffered",
+			"--proxy-grpc-web", "/grpc=enabled",
+			"--proxy-grpc-web", "/down=enabled",
+			"--proxy-grpc-web", "/plain=disabled",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		proxyBaseURL+"/",
+		false,
+	)
+	cleartextHTTP2Protocols := new(http.Protocols)
+	cleartextHTTP2Protocols.SetUnencryptedHTTP2(true)
+	grpcClient := &http.Client{Timeout: browseModeRequestTimeout, Transport: &http.Transport{Protocols: cleartextHTTP2Protocols}}
+	grpcWebClient := &http.Client{Timeout: browseModeRequestTimeout}
+
+	for _, requestPath := range []string{"/grpc/echo.Echo/Unary", "/buffered/echo.Echo/Unary"} {
+		unaryResponse := executeGRPCTestRequest(testingT, grpcClient, proxyBaseURL+requestPath, "application/grpc+proto", encodeGRPCTestFrame("hello"))
+		unaryBody := readGRPCTestResponseBody(testingT, unaryResponse)
+		if unaryResponse.ProtoMajor != 2 || !bytes.Equal(unaryBody, encodeGRPCTestFrame("hello")) || unaryResponse.Trailer.Get(grpcTestStatusHeader) != "0" {
+			testingT.Fatalf("expected grpc unary echo through %s with trailers, got proto=%d body=%q trailer=%v", requestPath, unaryResponse.ProtoMajor, unaryBody, unaryResponse.Trailer)
+		}
+	}
+
+	streamResponse := executeGRPCTestRequest(testingT, grpcClient, proxyBaseURL+"/grpc/echo.Echo/Stream", "application/grpc", encodeGRPCTestFrame("start"))
+	firstFrame := make([]byte, len(encodeGRPCTestFrame("first")))
+	if _, readErr := io.ReadFull(streamResponse.Body, firstFrame); readErr != nil {
+		testingT.Fatalf("read first grpc stream frame: %v", readErr)
+	}
+	close(releaseStream)
+	remainingFrames := readGRPCTestResponseBody(testingT, streamResponse)
+	if !bytes.Equal(firstFrame, encodeGRPCTestFrame("first")) || !bytes.Equal(remainingFrames, encodeGRPCTestFrame("second")) || streamResponse.Trailer.Get(grpcTestStatusHeader) != "0" {
+		testingT.Fatalf("expected grpc stream frames without flush delay, got first=%q rest=%q trailer=%v", firstFrame, remainingFrames, streamResponse.Trailer)
+	}
+
+	failResponse := executeGRPCTestRequest(testingT, grpcClient, proxyBaseURL+"/grpc/echo.Echo/Fail", "application/grpc", encodeGRPCTestFrame("missing"))
+	readGRPCTestResponseBody(testingT, failResponse)
+	if failResponse.Trailer.Get(grpcTestStatusHeader) != "5" || failResponse.Trailer.Get("Grpc-Message") != "not found" {
+		testingT.Fatalf("expected grpc error trailers to be preserved, got %v", failResponse.Trailer)
+	}
+
+	unavailableResponse := executeGRPCTestRequest(testingT, grpcClient, proxyBaseURL+"/down/echo.Echo/Unary", "application/grpc", encodeGRPCTestFrame("hello"))
+	readGRPCTestResponseBody(testingT, unavailableResponse)
+	if unavailableResponse.StatusCode != http.StatusOK || unavailableResponse.Header.Get(grpcTestStatusHeader) != "14" || unavailableResponse.Header.Get("Grpc-Message") == "" {
+		testingT.Fatalf("expected unavailable grpc backend to report grpc-status 14, got status=%d headers=%v", unavailableResponse.StatusCode, unavailableResponse.Header)
+	}
+	unavailableHTTPStatusCode, _, _ := executeHTTPGet(testingT, grpcWebClient, proxyBaseURL, "/down/index.html")
+	if unavailableHTTPStatusCode != http.StatusBadGateway {
+		testingT.Fatalf("expected non-grpc request to unavailable backend to fail with 502, got %d", unavailableHTTPStatusCode)
+	}
+
+	webResponse := executeGRPCTestRequest(testingT, grpcWebClient, proxyBaseURL+"/grpc/echo.Echo/Unary", "application/grpc-web+proto", encodeGRPCTestFrame("web"))
+	webBody := readGRPCTestResponseBody(testingT, webResponse)
+	expectedWebBody := append(encodeGRPCTestFrame("web"), encodeGRPCWebTestTrailerFrame("grpc-status: 0\r\n")...)
+	if webResponse.Header.Get("Content-Type") != "application/grpc-web+proto" || webResponse.Header.Get(grpcTestReceivedContentType) != "application/grpc+proto" || !bytes.Equal(webBody, expectedWebBody) {
+		testingT.Fatalf("expected grpc-web translation, got headers=%v body=%q", webResponse.Header, webBody)
+	}
+
+	webTextResponse := executeGRPCTestRequest(testingT, grpcWebClient, proxyBaseURL+"/grpc/echo.Echo/Unary", "application/grpc-web-text", []byte(base64.StdEncoding.EncodeToString(encodeGRPCTestFrame("text"))))
+	webTextBody := decodeGRPCWebTextTestBody(testingT, readGRPCTestResponseBody(testingT, webTextResponse))
+	expectedWebTextBody := append(encodeGRPCTestFrame("text"), encodeGRPCWebTestTrailerFrame("grpc-status: 0\r\n")...)
+	if webTextResponse.Header.Get("Content-Type") != "application/grpc-web-text+proto" || !bytes.Equal(webTextBody, expectedWebTextBody) {
+		testingT.Fatalf("expected grpc-web-text translation, got headers=%v body=%q", webTextResponse.Header, webTextBody)
+	}
+
+	webFailResponse := executeGRPCTestRequest(testingT, grpcWebClient, proxyBaseURL+"/grpc/echo.Echo/Fail", "application/grpc-web", encodeGRPCTestFrame("missing"))
+	webFailBody := readGRPCTestResponseBody(testingT, webFailResponse)
+	if !bytes.Equal(webFailBody, encodeGRPCWebTestTrailerFrame("grpc-message: not found\r\ngrpc-status: 5\r\n")) {
+		testingT.Fatalf("expected grpc-web trailer frame with error status, got %q", webFailBody)
+	}
+
+	webUnavailableResponse := executeGRPCTestRequest(testingT, grpcWebClient, proxyBaseURL+"/down/echo.Echo/Unary", "application/grpc-web-text", []byte(base64.StdEncoding.EncodeToString(encodeGRPCTestFrame("web"))))
+	readGRPCTestResponseBody(testingT, webUnavailableResponse)
+	if webUnavailableResponse.Header.Get(grpcTestStatusHeader) != "14" || webUnavailableResponse.Header.Get("Content-Type") != "application/grpc-web-text" {
+		testingT.Fatalf("expected unavailable grpc-web backend to report grpc-status 14, got headers=%v", webUnavailableResponse.Header)
+	}
+
+	untranslatedResponse := executeGRPCTestRequest(testingT, grpcWebClient, proxyBaseURL+"/plain/echo.Echo/Unary", "application/grpc-web+proto", encodeGRPCTestFrame("plain"))
+	readGRPCTestResponseBody(testingT, untranslatedResponse)
+	if untranslatedResponse.Header.Get(grpcTestReceivedContentType) != "application/grpc-web+proto" {
+		testingT.Fatalf("expected grpc-web to pass through untranslated on disabled route, got headers=%v", untranslatedResponse.Header)
+	}
+	if stopErr := proxyServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop grpc proxy server: %v", stopErr)
+	}
+
+	withoutH2CPort := allocateFreePort(testingT)
+	withoutH2CBaseURL := fmt.Sprintf("http://127.0.0.1:%d", withoutH2CPort)
+	withoutH2CServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(withoutH2CPort), "--directory", testingT.TempDir(), "--proxy", "/grpc=" + backendURL},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		withoutH2CBaseURL+"/",
+		false,
+	)
+	priorKnowledgeRequest, _ := http.NewRequest(http.MethodPost, withoutH2CBaseURL+"/grpc/echo.Echo/Unary", bytes.NewReader(encodeGRPCTestFrame("hello")))
+	priorKnowledgeRequest.Header.Set("Content-Type", "application/grpc")
+	if priorKnowledgeResponse, priorKnowledgeErr := grpcClient.Do(priorKnowledgeRequest); priorKnowledgeErr == nil {
+		priorKnowledgeResponse.Body.Close()
+		testingT.Fatalf("expected a proxying server without --h2c to refuse cleartext HTTP/2, got %s", priorKnowledgeResponse.Proto)
+	}
+	if stopErr := withoutH2CServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop grpc proxy server without h2c: %v", stopErr)
+	}
+
+	invalidArgumentSets := [][]string{
+		{"--proxy-grpc-web", "/grpc=enabled"},
+		{"--proxy", "/grpc=" + backendURL, "--proxy-grpc-web", "/grpc"},
+		{"--proxy", "/grpc=" + backendURL, "--proxy-grpc-web", "=enabled"},
+		{"--proxy", "/grpc=" + backendURL, "--proxy-grpc-web", "grpc=enabled"},
+		{"--proxy", "/grpc=" + backendURL, "--proxy-grpc-web", "/grpc=maybe"},
+		{"--proxy", "/grpc=h2c://"},
+	}
+	for _, invalidArguments := range invalidArgumentSets {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir()}, invalidArguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func startHTTP2CleartextBackend(testingT *testing.T, handler http.Handler) *httptest.Server {
+	testingT.Helper()
+	backendServer := httptest.NewUnstartedServer(handler)
+	backendServer.Config.Protocols = new(http.Protocols)
+	backendServer.Config.Protocols.SetHTTP1(true)
+	backendServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	backendServer.Start()
+	testingT.Cleanup(backendServer.Close)
+	return backendServer
+}
+
+func executeGRPCTestRequest(testingT *testing.T, httpClient *http.Client, requestURL string, contentType string, requestBody []byte) *http.Response {
+	testingT.Helper()
+	request, requestErr := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(requestBody))
+	if requestErr != nil {
+		testingT.Fatalf("build grpc request %s: %v", requestURL, requestErr)
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Te", "trailers")
+	response, responseErr := httpClient.Do(request)
+	if responseErr != nil {
+		testingT.Fatalf("execute grpc request %s: %v", requestURL, responseErr)
+	}
+	return response
+}
+
+func readGRPCTestResponseBody(testingT *testing.T, response *http.Response) []byte {
+	testingT.Helper()
+	defer response.Body.Close()
+	responseBody, readErr := io.ReadAll(response.Body)
+	if readErr != nil {
+		testingT.Fatalf("read grpc response body: %v", readErr)
+	}
+	return responseBody
+}
+
+func encodeGRPCTestFrame(message string) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func encodeGRPCWebTestTrailerFrame(trailerBlock string) []byte {
+	frame := encodeGRPCTestFrame(trailerBlock)
+	frame[0] = 0x80
+	return frame
+}
+
+// decodeGRPCWebTextTestBody decodes a grpc-web-text body that may consist of several independently padded chunks.
+func decodeGRPCWebTextTestBody(testingT *testing.T, encodedBody []byte) []byte {
+	testingT.Helper()
+	var decodedBody []byte
+	for offset := 0; offset < len(encodedBody); offset += 4 {
+		quantum, decodeErr := base64.StdEncoding.DecodeString(string(encodedBody[offset:min(offset+4, len(encodedBody))]))
+		if decodeErr != nil {
+			testingT.Fatalf("decode grpc-web-text body %q: %v", encodedBody, decodeErr)
+		}
+		decodedBody = append(decodedBody, quantum...)
+	}
+	return decodedBody
+}
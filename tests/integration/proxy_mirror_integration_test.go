@@ -0,0 +1,241 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	proxyMirrorWaitTimeout = 5 * time.Second
+	proxyMirrorLogSettle   = 300 * time.Millisecond
+)
+
+type mirroredRequest struct {
+	method     string
+	requestURI string
+	body       string
+	header     string
+}
+
+func exerciseProxyMirrorFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	primaryServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		requestBody, _ := io.ReadAll(request.Body)
+		_, _ = fmt.Fprintf(responseWriter, "primary %s %s %s", request.Method, request.URL.RequestURI(), requestBody)
+	}))
+	testingT.Cleanup(primaryServer.Close)
+
+	shadowRequests := make(chan mirroredRequest, 16)
+	shadowServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		requestBody, _ := io.ReadAll(request.Body)
+		shadowRequests <- mirroredRequest{method: request.Method, requestURI: request.URL.RequestURI(), body: string(requestBody), header: request.Header.Get("X-Trace")}
+		http.Error(responseWriter, "shadow disagrees", http.StatusTeapot)
+	}))
+	testingT.Cleanup(shadowServer.Close)
+
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	mirrorPort := allocateFreePort(testingT)
+	mirrorBaseURL := fmt.Sprintf("http://127.0.0.1:%d", mirrorPort)
+	mirrorServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(mirrorPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + primaryServer.URL,
+			"--proxy-mirror", "/api=" + shadowServer.URL + "/shadow/",
+			"--proxy-mirror-body-limit", "8",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		mirrorBaseURL+"/",
+		false,
+	)
+	primaryExpectations := []struct {
+		method       string
+		path         string
+		body         string
+		expectedBody string
+	}{
+		{method: http.MethodGet, path: "/api/items?page=1", expectedBody: "primary GET /api/items?page=1 "},
+		{method: http.MethodPost, path: "/api/items", body: "small", expectedBody: "primary POST /api/items small"},
+		{method: http.MethodPost, path: "/api/items", body: "this body is over the limit", expectedBody: "primary POST /api/items this body is over the limit"},
+	}
+	for _, expectation := range primaryExpectations {
+		request, requestErr := http.NewRequest(expectation.method, mirrorBaseURL+expectation.path, strings.NewReader(expectation.body))
+		if requestErr != nil {
+			testingT.Fatalf("build mirrored request: %v", requestErr)
+		}
+		request.Header.Set("X-Trace", "trace-1")
+		response, responseErr := httpClient.Do(request)
+		if responseErr != nil {
+			testingT.Fatalf("%s %s: %v", expectation.method, expectation.path, responseErr)
+		}
+		responseBody, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusOK || string(responseBody) != expectation.expectedBody {
+			testingT.Fatalf("mirroring changed the primary response for %s %s: status=%d body=%q", expectation.method, expectation.path, response.StatusCode, responseBody)
+		}
+	}
+	expectedShadowRequests := []mirroredRequest{
+		{method: http.MethodGet, requestURI: "/shadow/api/items?page=1", header: "trace-1"},
+		{method: http.MethodPost, requestURI: "/shadow/api/items", body: "small", header: "trace-1"},
+	}
+	receivedShadowRequests := map[mirroredRequest]bool{}
+	for range expectedShadowRequests {
+		receivedShadowRequests[waitForMirroredRequest(testingT, shadowRequests)] = true
+	}
+	for _, expectedShadowRequest := range expectedShadowRequests {
+		if !receivedShadowRequests[expectedShadowRequest] {
+			testingT.Fatalf("expected shadow backend to receive %+v, got %+v", expectedShadowRequest, receivedShadowRequests)
+		}
+	}
+	time.Sleep(proxyMirrorLogSettle)
+	if len(shadowRequests) != 0 {
+		testingT.Fatalf("expected oversized request body to skip the mirror, got %+v", <-shadowRequests)
+	}
+	if stopErr := mirrorServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop mirror server: %v", stopErr)
+	}
+	mirrorLogs := mirrorServer.logBuffer.String()
+	if strings.Count(mirrorLogs, "proxy mirror completed") != 2 || !strings.Contains(mirrorLogs, "status=418") || !strings.Contains(mirrorLogs, "request body exceeds mirror limit") {
+		testingT.Fatalf("expected mirror status and skip logs, got:\n%s", mirrorLogs)
+	}
+
+	releaseShadow := make(chan struct{})
+	blockedShadowRequests := make(chan string, 4)
+	blockingShadowServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		blockedShadowRequests <- request.URL.Path
+		<-releaseShadow
+	}))
+	testingT.Cleanup(blockingShadowServer.Close)
+	closedShadowURL := fmt.Sprintf("http://127.0.0.1:%d", allocateFreePort(testingT))
+	limitedPort := allocateFreePort(testingT)
+	limitedBaseURL := fmt.Sprintf("http://127.0.0.1:%d", limitedPort)
+	limitedServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(limitedPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + primaryServer.URL,
+			"--proxy-mirror-concurrency", "1",
+		},
+		map[string]string{
+			"GOCOVERDIR":                coverageDirectoryPath,
+			"GHTTP_SERVE_PROXY_MIRRORS": "/api=" + blockingShadowServer.URL + ",/api/down=" + closedShadowURL,
+		},
+		limitedBaseURL+"/",
+		false,
+	)
+	executeHTTPGet(testingT, httpClient, limitedBaseURL, "/api/down/status")
+	time.Sleep(proxyMirrorLogSettle)
+	executeHTTPGet(testingT, httpClient, limitedBaseURL, "/api/first")
+	select {
+	case blockedPath := <-blockedShadowRequests:
+		if blockedPath != "/api/first" {
+			testingT.Fatalf("unexpected mirrored path %s", blockedPath)
+		}
+	case <-time.After(proxyMirrorWaitTimeout):
+		testingT.Fatalf("expected blocking shadow backend to receive the first mirror")
+	}
+	secondStatusCode, _, secondBody := executeHTTPGet(testingT, httpClient, limitedBaseURL, "/api/second")
+	if secondStatusCode != http.StatusOK || secondBody != "primary GET /api/second " {
+		testingT.Fatalf("expected primary response while mirrors are saturated, got status=%d body=%q", secondStatusCode, secondBody)
+	}
+	close(releaseShadow)
+	time.Sleep(proxyMirrorLogSettle)
+	if stopErr := limitedServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop limited mirror server: %v", stopErr)
+	}
+	limitedLogs := limitedServer.logBuffer.String()
+	if !strings.Contains(limitedLogs, "proxy mirror failed") || !strings.Contains(limitedLogs, "mirror concurrency limit reached") || len(blockedShadowRequests) != 0 {
+		testingT.Fatalf("expected mirror failure and concurrency skip logs, got:\n%s", limitedLogs)
+	}
+
+	staticFileDirectory := filepath.Join(siteDirectory, "api", "static")
+	if mkdirErr := os.MkdirAll(staticFileDirectory, 0o755); mkdirErr != nil {
+		testingT.Fatalf("create static fallback directory: %v", mkdirErr)
+	}
+	if writeErr := os.WriteFile(filepath.Join(staticFileDirectory, "local.txt"), []byte("local file"), 0o644); writeErr != nil {
+		testingT.Fatalf("write static fallback file: %v", writeErr)
+	}
+	answeredPort := allocateFreePort(testingT)
+	answeredBaseURL := fmt.Sprintf("http://127.0.0.1:%d", answeredPort)
+	answeredServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(answeredPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + primaryServer.URL,
+			"--proxy-mirror", "/api=" + shadowServer.URL,
+			"--proxy-fallback", "/api/static=static-first",
+			"--proxy-cache", "memory",
+			"--proxy-cache-ttl", "/api=1m",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		answeredBaseURL+"/",
+		false,
+	)
+	if statusCode, _, body := executeHTTPGet(testingT, httpClient, answeredBaseURL, "/api/static/local.txt"); statusCode != http.StatusOK || body != "local file" {
+		testingT.Fatalf("expected the static-first route to serve the local file, got status=%d body=%q", statusCode, body)
+	}
+	for range 2 {
+		if statusCode, _, body := executeHTTPGet(testingT, httpClient, answeredBaseURL, "/api/cached"); statusCode != http.StatusOK || body != "primary GET /api/cached " {
+			testingT.Fatalf("expected the cached route to serve the primary response, got status=%d body=%q", statusCode, body)
+		}
+	}
+	if receivedRequest := waitForMirroredRequest(testingT, shadowRequests); receivedRequest.requestURI != "/api/cached" {
+		testingT.Fatalf("expected only the cache miss to be mirrored, got %+v", receivedRequest)
+	}
+	time.Sleep(proxyMirrorLogSettle)
+	if len(shadowRequests) != 0 {
+		testingT.Fatalf("expected static fallback and cache hits to skip the mirror, got %+v", <-shadowRequests)
+	}
+	if stopErr := answeredServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop answered mirror server: %v", stopErr)
+	}
+
+	invalidArguments := [][]string{
+		{"--proxy-mirror", "/api=" + shadowServer.URL},
+		{"--proxy", "/api=" + primaryServer.URL, "--proxy-mirror", "api=" + shadowServer.URL},
+		{"--proxy", "/api=" + primaryServer.URL, "--proxy-mirror", "/api"},
+		{"--proxy", "/api=" + primaryServer.URL, "--proxy-mirror", "=" + shadowServer.URL},
+		{"--proxy", "/api=" + primaryServer.URL, "--proxy-mirror", "/api=ftp://shadow"},
+		{"--proxy", "/api=" + primaryServer.URL, "--proxy-mirror", "/api=" + shadowServer.URL, "--proxy-mirror-concurrency", "0"},
+		{"--proxy", "/api=" + primaryServer.URL, "--proxy-mirror", "/api=" + shadowServer.URL, "--proxy-mirror-body-limit", "-1"},
+	}
+	for _, arguments := range invalidArguments {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory}, arguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func waitForMirroredRequest(testingT *testing.T, shadowRequests chan mirroredRequest) mirroredRequest {
+	testingT.Helper()
+	select {
+	case receivedRequest := <-shadowRequests:
+		return receivedRequest
+	case <-time.After(proxyMirrorWaitTimeout):
+		testingT.Fatalf("timed out waiting for a mirrored request")
+		return mirroredRequest{}
+	}
+}
@@ -0,0 +1,218 @@
+package integration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	mountedAppHTML = `<html><head><base href="/"><link rel="stylesheet" href="/style.css"></head>` +
+		`<body style="background:url(/bg.png)"><a href="/about">About</a><a href="//cdn.example.com/lib.js">CDN</a>` +
+		`<a href="https://example.com/x">External</a><img src="/logo.png" srcset="/small.png 1x, /large.png 2x">` +
+		`<form action='/submit'></form></body></html>`
+	mountedAppRewrittenHTML = `<html><head><base href="/app/"><link rel="stylesheet" href="/app/style.css"></head>` +
+		`<body style="background:url(/app/bg.png)"><a href="/app/about">About</a><a href="//cdn.example.com/lib.js">CDN</a>` +
+		`<a href="https://example.com/x">External</a><img src="/app/logo.png" srcset="/app/small.png 1x, /app/large.png 2x">` +
+		`<form action='/app/submit'></form></body></html>`
+	mountedAppCSS                   = `body{background:url("/bg.png")} @import "/base.css";`
+	mountedAppJS                    = `fetch("/api/items"); const protocolRelative = "//cdn.example.com/x";`
+	mountedAppRewrittenJS           = `fetch("/js/api/items"); const protocolRelative = "//cdn.example.com/x";`
+	mountedAppStreamingHTML         = `<a href="/stream-link">stream</a>`
+	mountedAppPrefixedHTML          = `<a href="/app/already">Mounted</a><a href="/app">Root</a><a href="/apple">Sibling</a>`
+	mountedAppRewrittenPrefixedHTML = `<a href="/app/already">Mounted</a><a href="/app">Root</a><a href="/app/apple">Sibling</a>`
+	proxyRewriteMaxBody             = 16 << 20
+)
+
+func exerciseProxyRewriteFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	var oversizedCompressedHTML bytes.Buffer
+	oversizedWriter := gzip.NewWriter(&oversizedCompressedHTML)
+	_, _ = io.WriteString(oversizedWriter, strings.Repeat(`<a href="/x">`, proxyRewriteMaxBody/len(`<a href="/x">`)+1))
+	_ = oversizedWriter.Close()
+	var backendHost string
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("X-Backend-Path", request.URL.Path)
+		responseWriter.Header().Set("X-Backend-Prefix", request.Header.Get("X-Forwarded-Prefix"))
+		switch request.URL.Path {
+		case "/":
+			responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+			responseWriter.Header().Add("Set-Cookie", "session=abc; Path=/; HttpOnly")
+			responseWriter.Header().Add("Set-Cookie", "scoped=1; Path=/admin")
+			_, _ = io.WriteString(responseWriter, mountedAppHTML)
+		case "/login-redirect":
+			http.Redirect(responseWriter, request, "/login?next=%2F", http.StatusFound)
+		case "/absolute-redirect":
+			http.Redirect(responseWriter, request, "http://"+backendHost+"/home", http.StatusFound)
+		case "/external-redirect":
+			http.Redirect(responseWriter, request, "https://example.com/elsewhere", http.StatusFound)
+		case "/style.css":
+			responseWriter.Header().Set("Content-Type", "text/css")
+			_, _ = io.WriteString(responseWriter, mountedAppCSS)
+		case "/app.js":
+			responseWriter.Header().Set("Content-Type", "application/javascript")
+			_, _ = io.WriteString(responseWriter, mountedAppJS)
+		case "/compressed":
+			responseWriter.Header().Set("Content-Type", "text/html")
+			responseWriter.Header().Set("Content-Encoding", "gzip")
+			gzipWriter := gzip.NewWriter(responseWriter)
+			_, _ = io.WriteString(gzipWriter, mountedAppHTML)
+			_ = gzipWriter.Close()
+		case "/brotli":
+			responseWriter.Header().Set("Content-Type", "text/html")
+			responseWriter.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(responseWriter, mountedAppStreamingHTML)
+		case "/prefixed":
+			responseWriter.Header().Set("Content-Type", "text/html")
+			responseWriter.Header().Add("Set-Cookie", "mounted=1; Path=/app/admin")
+			_, _ = io.WriteString(responseWriter, mountedAppPrefixedHTML)
+		case "/prefixed-redirect":
+			http.Redirect(responseWriter, request, "/app/login", http.StatusFound)
+		case "/oversized-gzip":
+			responseWriter.Header().Set("Content-Type", "text/html")
+			responseWriter.Header().Set("Content-Encoding", "gzip")
+			_, _ = responseWriter.Write(oversizedCompressedHTML.Bytes())
+		case "/broken-gzip":
+			responseWriter.Header().Set("Content-Type", "text/html")
+			responseWriter.Header().Set("Content-Encoding", "gzip")
+			_, _ = io.WriteString(responseWriter, "not gzip")
+		default:
+			responseWriter.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(responseWriter, mountedAppStreamingHTML)
+		}
+	}))
+	testingT.Cleanup(backendServer.Close)
+	backendHost = strings.TrimPrefix(backendServer.URL, "http://")
+
+	rewritePort := allocateFreePort(testingT)
+	rewriteBaseURL := fmt.Sprintf("http://127.0.0.1:%d", rewritePort)
+	rewriteServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(rewritePort),
+			"--directory", siteDirectory,
+			"--proxy", "/app=" + backendServer.URL,
+			"--proxy", "/js=" + backendServer.URL,
+			"--proxy-rewrite", "/app/=html+css",
+			"--proxy-rewrite", "/js=js",
+			"--proxy-streaming", "/app/stream=unbuffered",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		rewriteBaseURL+"/app/",
+		false,
+	)
+	noRedirectClient := &http.Client{
+		Timeout: browseModeRequestTimeout,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	htmlStatusCode, htmlHeaders, htmlBody := executeHTTPGet(testingT, noRedirectClient, rewriteBaseURL, "/app/")
+	if htmlStatusCode != http.StatusOK || htmlBody != mountedAppRewrittenHTML {
+		testingT.Fatalf("unexpected rewritten html: status=%d body=%s", htmlStatusCode, htmlBody)
+	}
+	if htmlHeaders.Get("X-Backend-Path") != "/" || htmlHeaders.Get("X-Backend-Prefix") != "/app" {
+		testingT.Fatalf("expected mount prefix to be stripped and forwarded, got headers %v", htmlHeaders)
+	}
+	if cookies := htmlHeaders.Values("Set-Cookie"); len(cookies) != 2 || cookies[0] != "session=abc; Path=/app/; HttpOnly" || cookies[1] != "scoped=1; Path=/app/admin" {
+		testingT.Fatalf("unexpected rewritten cookies: %v", cookies)
+	}
+	expectedLocations := map[string]string{
+		"/app/login-redirect":    "/app/login?next=%2F",
+		"/app/absolute-redirect": "/app/home",
+		"/app/external-redirect": "https://example.com/elsewhere",
+		"/app/prefixed-redirect": "/app/login",
+	}
+	for requestPath, expectedLocation := range expectedLocations {
+		redirectStatusCode, redirectHeaders, _ := executeHTTPGet(testingT, noRedirectClient, rewriteBaseURL, requestPath)
+		if redirectStatusCode != http.StatusFound || redirectHeaders.Get("Location") != expectedLocation {
+			testingT.Fatalf("unexpected redirect for %s: status=%d location=%q", requestPath, redirectStatusCode, redirectHeaders.Get("Location"))
+		}
+	}
+	expectedBodies := map[string]string{
+		"/app/style.css":  `body{background:url("/app/bg.png")} @import "/app/base.css";`,
+		"/app/app.js":     mountedAppJS,
+		"/app/stream":     mountedAppStreamingHTML,
+		"/js/app.js":      mountedAppRewrittenJS,
+		"/js/other":       mountedAppStreamingHTML,
+		"/app/brotli":     mountedAppStreamingHTML,
+		"/app/other-page": `<a href="/app/stream-link">stream</a>`,
+		"/app/prefixed":   mountedAppRewrittenPrefixedHTML,
+	}
+	for requestPath, expectedBody := range expectedBodies {
+		statusCode, _, body := executeHTTPGet(testingT, noRedirectClient, rewriteBaseURL, requestPath)
+		if statusCode != http.StatusOK || body != expectedBody {
+			testingT.Fatalf("unexpected rewrite result for %s: status=%d body=%q", requestPath, statusCode, body)
+		}
+	}
+
+	compressedRequest, _ := http.NewRequest(http.MethodGet, rewriteBaseURL+"/app/compressed", nil)
+	compressedRequest.Header.Set("Accept-Encoding", "gzip")
+	compressedResponse, compressedErr := noRedirectClient.Do(compressedRequest)
+	if compressedErr != nil {
+		testingT.Fatalf("get compressed page: %v", compressedErr)
+	}
+	compressedBody, _ := io.ReadAll(compressedResponse.Body)
+	_ = compressedResponse.Body.Close()
+	if compressedResponse.Header.Get("Content-Encoding") != "gzip" || compressedResponse.Header.Get("Content-Length") != strconv.Itoa(len(compressedBody)) {
+		testingT.Fatalf("expected rewritten body to stay gzip-encoded, got headers %v", compressedResponse.Header)
+	}
+	gzipReader, gzipErr := gzip.NewReader(bytes.NewReader(compressedBody))
+	if gzipErr != nil {
+		testingT.Fatalf("open rewritten gzip body: %v", gzipErr)
+	}
+	decompressedBody, _ := io.ReadAll(gzipReader)
+	if string(decompressedBody) != mountedAppRewrittenHTML {
+		testingT.Fatalf("unexpected rewritten gzip html: %s", decompressedBody)
+	}
+	if _, prefixedHeaders, _ := executeHTTPGet(testingT, noRedirectClient, rewriteBaseURL, "/app/prefixed"); prefixedHeaders.Get("Set-Cookie") != "mounted=1; Path=/app/admin" {
+		testingT.Fatalf("expected a cookie path under the mount prefix to be kept, got %q", prefixedHeaders.Get("Set-Cookie"))
+	}
+	oversizedRequest, _ := http.NewRequest(http.MethodGet, rewriteBaseURL+"/app/oversized-gzip", nil)
+	oversizedRequest.Header.Set("Accept-Encoding", "gzip")
+	oversizedResponse, oversizedErr := noRedirectClient.Do(oversizedRequest)
+	if oversizedErr != nil {
+		testingT.Fatalf("get oversized compressed page: %v", oversizedErr)
+	}
+	oversizedBody, _ := io.ReadAll(oversizedResponse.Body)
+	_ = oversizedResponse.Body.Close()
+	if oversizedResponse.StatusCode != http.StatusOK || !bytes.Equal(oversizedBody, oversizedCompressedHTML.Bytes()) {
+		testingT.Fatalf("expected a body that decompresses past the rewrite limit to pass through unchanged, got status=%d length=%d", oversizedResponse.StatusCode, len(oversizedBody))
+	}
+	brokenStatusCode, _, _ := executeHTTPGet(testingT, noRedirectClient, rewriteBaseURL, "/app/broken-gzip")
+	if brokenStatusCode != http.StatusBadGateway {
+		testingT.Fatalf("expected undecodable gzip body to fail with 502, got %d", brokenStatusCode)
+	}
+	if stopErr := rewriteServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop rewrite server: %v", stopErr)
+	}
+
+	invalidArguments := [][]string{
+		{"--proxy-rewrite", "/app=html"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-rewrite", "/app"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-rewrite", "=html"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-rewrite", "app=html"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-rewrite", "/=html"},
+		{"--proxy", "/app=" + backendServer.URL, "--proxy-rewrite", "/app=html+xml"},
+	}
+	for _, arguments := range invalidArguments {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory}, arguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
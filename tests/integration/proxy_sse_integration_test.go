@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	sseBackendIdle        = 600 * time.Millisecond
+	sseLogSettle          = 300 * time.Millisecond
+	sseDisconnectDeadline = 5 * time.Second
+)
+
+type sseBackendRequest struct {
+	acceptEncoding string
+	lastEventID    string
+}
+
+func exerciseProxySSEFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	backendRequests := make(chan sseBackendRequest, 8)
+	backendCancelled := make(chan struct{}, 1)
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		flusher := responseWriter.(http.Flusher)
+		switch request.URL.Path {
+		case "/events/ticks":
+			backendRequests <- sseBackendRequest{acceptEncoding: request.Header.Get("Accept-Encoding"), lastEventID: request.Header.Get("Last-Event-ID")}
+			responseWriter.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(responseWriter, "retry: 1000\r\nid: 42\r\ndata: one\r\n\r\n: backend comment\n\n")
+			flusher.Flush()
+			time.Sleep(sseBackendIdle)
+			_, _ = io.WriteString(responseWriter, "id: 43\nevent: tick\ndata: two\ndata: more\n\n")
+		case "/events/endless":
+			responseWriter.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			responseWriter.Header().Set("Cache-Control", "no-store")
+			_, _ = io.WriteString(responseWriter, "data: first\n\n")
+			flusher.Flush()
+			<-request.Context().Done()
+			backendCancelled <- struct{}{}
+		default:
+			responseWriter.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(responseWriter, `{"stream":false}`)
+		}
+	}))
+	testingT.Cleanup(backendServer.Close)
+
+	ssePort := allocateFreePort(testingT)
+	sseBaseURL := fmt.Sprintf("http://127.0.0.1:%d", ssePort)
+	sseServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(ssePort),
+			"--directory", siteDirectory,
+			"--proxy", "/events=" + backendServer.URL,
+			"--proxy-streaming", "/events=sse:150ms",
+			"--proxy-streaming", "/events/endless=SSE",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		sseBaseURL+"/events/plain",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+
+	ticksRequest, _ := http.NewRequest(http.MethodGet, sseBaseURL+"/events/ticks", nil)
+	ticksRequest.Header.Set("Accept", "text/event-stream")
+	ticksRequest.Header.Set("Accept-Encoding", "gzip")
+	ticksRequest.Header.Set("Last-Event-ID", "41")
+	ticksResponse, ticksErr := httpClient.Do(ticksRequest)
+	if ticksErr != nil {
+		testingT.Fatalf("get event stream: %v", ticksErr)
+	}
+	ticksBody, _ := io.ReadAll(ticksResponse.Body)
+	_ = ticksResponse.Body.Close()
+	if ticksResponse.Header.Get("X-Accel-Buffering") != "no" || ticksResponse.Header.Get("Cache-Control") != "no-cache" || ticksResponse.Header.Get("Content-Encoding") != "" {
+		testingT.Fatalf("unexpected event stream headers: %v", ticksResponse.Header)
+	}
+	ticksText := string(ticksBody)
+	firstEventEnd := strings.Index(ticksText, "data: one\r\n\r\n")
+	heartbeatIndex := strings.Index(ticksText, ": heartbeat\n\n")
+	secondEventIndex := strings.Index(ticksText, "id: 43\n")
+	if firstEventEnd < 0 || heartbeatIndex < firstEventEnd || secondEventIndex < heartbeatIndex {
+		testingT.Fatalf("expected heartbeats between events, got %q", ticksText)
+	}
+	backendRequest := <-backendRequests
+	if backendRequest.acceptEncoding != "identity" || backendRequest.lastEventID != "41" {
+		testingT.Fatalf("expected identity encoding and Last-Event-ID pass-through, got %+v", backendRequest)
+	}
+
+	plainStatusCode, plainHeaders, plainBody := executeHTTPGet(testingT, httpClient, sseBaseURL, "/events/plain")
+	if plainStatusCode != http.StatusOK || plainBody != `{"stream":false}` || plainHeaders.Get("X-Accel-Buffering") != "" {
+		testingT.Fatalf("expected non-event-stream responses to pass through: status=%d headers=%v body=%s", plainStatusCode, plainHeaders, plainBody)
+	}
+
+	endlessResponse, endlessErr := httpClient.Get(sseBaseURL + "/events/endless")
+	if endlessErr != nil {
+		testingT.Fatalf("get endless stream: %v", endlessErr)
+	}
+	if endlessResponse.Header.Get("Cache-Control") != "no-store" {
+		testingT.Fatalf("expected backend Cache-Control to be kept, got %v", endlessResponse.Header)
+	}
+	firstLine, readErr := bufio.NewReader(endlessResponse.Body).ReadString('\n')
+	if readErr != nil || firstLine != "data: first\n" {
+		testingT.Fatalf("unexpected first endless event %q: %v", firstLine, readErr)
+	}
+	_ = endlessResponse.Body.Close()
+	select {
+	case <-backendCancelled:
+	case <-time.After(sseDisconnectDeadline):
+		testingT.Fatalf("expected client disconnect to cancel the backend request")
+	}
+	time.Sleep(sseLogSettle)
+	if stopErr := sseServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop sse server: %v", stopErr)
+	}
+	sseLogs := sseServer.logBuffer.String()
+	for _, expectedLog := range []string{"proxy sse stream ended", "events=2", `last_event_id="41"`, `reason="backend closed"`, "events=1", `reason="client disconnected"`} {
+		if !strings.Contains(sseLogs, expectedLog) {
+			testingT.Fatalf("expected %q in sse logs, got:\n%s", expectedLog, sseLogs)
+		}
+	}
+	if strings.Count(sseLogs, "proxy sse stream ended") != 2 {
+		testingT.Fatalf("expected one stream summary per event stream, got:\n%s", sseLogs)
+	}
+
+	for _, invalidMapping := range []string{"/events=sse:soon", "/events=sse:-1s", "/events=unbuffered:1s"} {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--proxy", "/events=" + backendServer.URL, "--proxy-streaming", invalidMapping},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
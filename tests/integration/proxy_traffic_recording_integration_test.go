@@ -0,0 +1,301 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	proxyRecordingWaitTimeout = 5 * time.Second
+	proxyRecordingMaxBody     = 1 << 20
+)
+
+type recordedProxyExchange struct {
+	Request struct {
+		Method     string      `json:"method"`
+		Path       string      `json:"path"`
+		Query      string      `json:"query"`
+		Headers    http.Header `json:"headers"`
+		Body       string      `json:"body"`
+		BodySHA256 string      `json:"body_sha256"`
+	} `json:"request"`
+	Response struct {
+		Status     int         `json:"status"`
+		Headers    http.Header `json:"headers"`
+		Body       string      `json:"body"`
+		BodyBase64 []byte      `json:"body_base64"`
+		Truncated  bool        `json:"truncated"`
+		Streamed   bool        `json:"streamed"`
+	} `json:"response"`
+}
+
+func exerciseProxyTrafficRecordingFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	recordingDirectory := filepath.Join(testingT.TempDir(), "recordings")
+	binaryPayload := []byte{0xff, 0x00, 0xfe, 0x01}
+	largePayload := strings.Repeat("a", proxyRecordingMaxBody+1024)
+
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/api/binary":
+			responseWriter.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = responseWriter.Write(binaryPayload)
+			return
+		case "/api/large":
+			_, _ = io.WriteString(responseWriter, largePayload)
+			return
+		case "/api/events":
+			responseWriter.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(responseWriter, "data: one\n\n")
+			_ = http.NewResponseController(responseWriter).Flush()
+			select {
+			case <-request.Context().Done():
+			case <-time.After(proxyRecordingWaitTimeout):
+			}
+			return
+		}
+		requestBody, _ := io.ReadAll(request.Body)
+		responseWriter.Header().Set("Set-Cookie", "session=secret")
+		responseWriter.WriteHeader(http.StatusAccepted)
+		_, _ = fmt.Fprintf(responseWriter, "backend %s %s?%s %s", request.Method, request.URL.Path, request.URL.RawQuery, requestBody)
+	}))
+	testingT.Cleanup(backendServer.Close)
+
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	recordPort := allocateFreePort(testingT)
+	recordBaseURL := fmt.Sprintf("http://127.0.0.1:%d", recordPort)
+	recordServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(recordPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + backendServer.URL,
+			"--proxy-record", recordingDirectory,
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		recordBaseURL+"/",
+		false,
+	)
+	getStatusCode, getBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodGet, recordBaseURL+"/api/items?page=2", "", "Bearer token")
+	if getStatusCode != http.StatusAccepted || getBody != "backend GET /api/items?page=2 " {
+		testingT.Fatalf("unexpected recorded GET response: status=%d body=%q", getStatusCode, getBody)
+	}
+	postStatusCode, postBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodPost, recordBaseURL+"/api/items", "alpha", "")
+	if postStatusCode != http.StatusAccepted || postBody != "backend POST /api/items? alpha" {
+		testingT.Fatalf("unexpected recorded POST response: status=%d body=%q", postStatusCode, postBody)
+	}
+	binaryStatusCode, binaryBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodGet, recordBaseURL+"/api/binary", "", "")
+	if binaryStatusCode != http.StatusOK || binaryBody != string(binaryPayload) {
+		testingT.Fatalf("unexpected recorded binary response: status=%d body=%q", binaryStatusCode, binaryBody)
+	}
+	largeStatusCode, largeBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodGet, recordBaseURL+"/api/large", "", "")
+	if largeStatusCode != http.StatusOK || largeBody != largePayload {
+		testingT.Fatalf("expected the large response to reach the client in full, got status=%d length=%d", largeStatusCode, len(largeBody))
+	}
+	eventsResponse, eventsErr := httpClient.Get(recordBaseURL + "/api/events")
+	if eventsErr != nil {
+		testingT.Fatalf("open event stream: %v", eventsErr)
+	}
+	firstEvent := make([]byte, len("data: one\n\n"))
+	if _, readErr := io.ReadFull(eventsResponse.Body, firstEvent); readErr != nil || string(firstEvent) != "data: one\n\n" {
+		testingT.Fatalf("expected the first event, got %q: %v", firstEvent, readErr)
+	}
+	// The stream is still open, so its exchange must already be on disk.
+	recordedExchanges := waitForProxyRecordings(testingT, recordingDirectory, 5)
+	_ = eventsResponse.Body.Close()
+	if stopErr := recordServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop recording server: %v", stopErr)
+	}
+
+	exchangesByPath := map[string]recordedProxyExchange{}
+	for _, exchange := range recordedExchanges {
+		exchangesByPath[exchange.Request.Method+" "+exchange.Request.Path] = exchange
+	}
+	recordedGet := exchangesByPath["GET /api/items"]
+	if recordedGet.Request.Query != "page=2" || recordedGet.Request.Headers.Get("Authorization") != "REDACTED" || recordedGet.Response.Headers.Get("Set-Cookie") != "REDACTED" || recordedGet.Response.Status != http.StatusAccepted {
+		testingT.Fatalf("unexpected recorded GET exchange: %+v", recordedGet)
+	}
+	recordedPost := exchangesByPath["POST /api/items"]
+	if recordedPost.Request.Body != "alpha" || recordedPost.Request.BodySHA256 == "" || recordedPost.Response.Body != "backend POST /api/items? alpha" {
+		testingT.Fatalf("unexpected recorded POST exchange: %+v", recordedPost)
+	}
+	recordedBinary := exchangesByPath["GET /api/binary"]
+	if !bytes.Equal(recordedBinary.Response.BodyBase64, binaryPayload) || recordedBinary.Response.Body != "" {
+		testingT.Fatalf("expected binary response body to be recorded as base64, got %+v", recordedBinary)
+	}
+	recordedLarge := exchangesByPath["GET /api/large"]
+	if !recordedLarge.Response.Truncated || recordedLarge.Response.Body != largePayload[:proxyRecordingMaxBody] {
+		testingT.Fatalf("expected the large response body to be recorded truncated, got truncated=%t length=%d", recordedLarge.Response.Truncated, len(recordedLarge.Response.Body))
+	}
+	recordedEvents := exchangesByPath["GET /api/events"]
+	if !recordedEvents.Response.Streamed || recordedEvents.Response.Body != "" || recordedEvents.Response.Headers.Get("Content-Type") != "text/event-stream" {
+		testingT.Fatalf("expected the event stream to be recorded without a body when it opened, got %+v", recordedEvents.Response)
+	}
+
+	closedBackendURL := fmt.Sprintf("http://127.0.0.1:%d", allocateFreePort(testingT))
+	replayPort := allocateFreePort(testingT)
+	replayBaseURL := fmt.Sprintf("http://127.0.0.1:%d", replayPort)
+	replayServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(replayPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + closedBackendURL,
+			"--proxy-replay", recordingDirectory,
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		replayBaseURL+"/",
+		false,
+	)
+	replayExpectations := []struct {
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{method: http.MethodGet, path: "/api/items?page=2", expectedStatus: http.StatusAccepted, expectedBody: "backend GET /api/items?page=2 "},
+		{method: http.MethodPost, path: "/api/items", body: "different", expectedStatus: http.StatusAccepted, expectedBody: "backend POST /api/items? alpha"},
+		{method: http.MethodGet, path: "/api/binary", expectedStatus: http.StatusOK, expectedBody: string(binaryPayload)},
+	}
+	for _, expectation := range replayExpectations {
+		statusCode, body := executeProxyRecordingRequest(testingT, httpClient, expectation.method, replayBaseURL+expectation.path, expectation.body, "")
+		if statusCode != expectation.expectedStatus || body != expectation.expectedBody {
+			testingT.Fatalf("unexpected replay response for %s %s: status=%d body=%q", expectation.method, expectation.path, statusCode, body)
+		}
+	}
+	missStatusCode, missBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodGet, replayBaseURL+"/api/items?page=3", "", "")
+	if missStatusCode != http.StatusBadGateway || !strings.Contains(missBody, "no recorded exchange matches the request: GET /api/items?page=3") {
+		testingT.Fatalf("expected replay miss to fail with 502, got status=%d body=%q", missStatusCode, missBody)
+	}
+	if stopErr := replayServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop replay server: %v", stopErr)
+	}
+
+	passthroughPort := allocateFreePort(testingT)
+	passthroughBaseURL := fmt.Sprintf("http://127.0.0.1:%d", passthroughPort)
+	passthroughServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(passthroughPort),
+			"--directory", siteDirectory,
+			"--proxy", "/api=" + backendServer.URL,
+			"--proxy-replay-match", "method,path,body",
+			"--proxy-replay-miss", "passthrough",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath, "GHTTP_SERVE_PROXY_REPLAY": recordingDirectory},
+		passthroughBaseURL+"/",
+		false,
+	)
+	matchedStatusCode, matchedBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodPost, passthroughBaseURL+"/api/items?ignored=true", "alpha", "")
+	if matchedStatusCode != http.StatusAccepted || matchedBody != "backend POST /api/items? alpha" {
+		testingT.Fatalf("expected body-hash match to replay, got status=%d body=%q", matchedStatusCode, matchedBody)
+	}
+	missedStatusCode, missedBody := executeProxyRecordingRequest(testingT, httpClient, http.MethodPost, passthroughBaseURL+"/api/items", "beta", "")
+	if missedStatusCode != http.StatusAccepted || missedBody != "backend POST /api/items? beta" {
+		testingT.Fatalf("expected replay miss to pass through, got status=%d body=%q", missedStatusCode, missedBody)
+	}
+	if stopErr := passthroughServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop passthrough replay server: %v", stopErr)
+	}
+
+	recordingFilePath := filepath.Join(testingT.TempDir(), "not-a-directory")
+	writeMockTestFile(testingT, recordingFilePath, "")
+	invalidRecordingDirectory := testingT.TempDir()
+	invalidRecordings := map[string]string{
+		"syntax.json":    "{",
+		"status.json":    `{"request":{"method":"GET","path":"/api/a"},"response":{"status":42}}`,
+		"exclusive.json": `{"request":{"method":"GET","path":"/api/a"},"response":{"status":200,"body":"a","body_base64":"YQ=="}}`,
+	}
+	invalidArguments := [][]string{
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-record", recordingDirectory, "--proxy-replay", recordingDirectory},
+		{"--proxy-record", recordingDirectory},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-record", recordingFilePath},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-replay", recordingDirectory, "--proxy-replay-match", "header"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-replay", recordingDirectory, "--proxy-replay-match", " "},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-replay", recordingDirectory, "--proxy-replay-miss", "ignore"},
+		{"--proxy", "/api=" + backendServer.URL, "--proxy-replay", filepath.Join(recordingDirectory, "missing")},
+	}
+	for fileName, content := range invalidRecordings {
+		invalidRecordingSubdirectory := filepath.Join(invalidRecordingDirectory, strings.TrimSuffix(fileName, ".json"))
+		writeMockTestFile(testingT, filepath.Join(invalidRecordingSubdirectory, fileName), content)
+		invalidArguments = append(invalidArguments, []string{"--proxy", "/api=" + backendServer.URL, "--proxy-replay", invalidRecordingSubdirectory})
+	}
+	for _, arguments := range invalidArguments {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory}, arguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func executeProxyRecordingRequest(testingT *testing.T, httpClient *http.Client, method string, requestURL string, requestBody string, authorization string) (int, string) {
+	testingT.Helper()
+	request, requestErr := http.NewRequest(method, requestURL, strings.NewReader(requestBody))
+	if requestErr != nil {
+		testingT.Fatalf("build %s %s request: %v", method, requestURL, requestErr)
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, responseErr := httpClient.Do(request)
+	if responseErr != nil {
+		testingT.Fatalf("%s %s: %v", method, requestURL, responseErr)
+	}
+	defer response.Body.Close()
+	responseBody, readErr := io.ReadAll(response.Body)
+	if readErr != nil {
+		testingT.Fatalf("read %s %s response: %v", method, requestURL, readErr)
+	}
+	return response.StatusCode, string(responseBody)
+}
+
+// waitForProxyRecordings polls because an exchange is written once the proxy finishes reading the backend body,
+// which can race the client reading the proxied response.
+func waitForProxyRecordings(testingT *testing.T, recordingDirectory string, expectedCount int) []recordedProxyExchange {
+	testingT.Helper()
+	deadline := time.Now().Add(proxyRecordingWaitTimeout)
+	for {
+		recordingPaths, _ := filepath.Glob(filepath.Join(recordingDirectory, "*.json"))
+		if len(recordingPaths) >= expectedCount || time.Now().After(deadline) {
+			if len(recordingPaths) != expectedCount {
+				testingT.Fatalf("expected %d recorded exchanges, found %d", expectedCount, len(recordingPaths))
+			}
+			exchanges := make([]recordedProxyExchange, 0, len(recordingPaths))
+			for _, recordingPath := range recordingPaths {
+				recordingBytes, readErr := os.ReadFile(recordingPath)
+				if readErr != nil {
+					testingT.Fatalf("read recorded exchange: %v", readErr)
+				}
+				var exchange recordedProxyExchange
+				if decodeErr := json.Unmarshal(recordingBytes, &exchange); decodeErr != nil {
+					testingT.Fatalf("decode recorded exchange %s: %v", recordingPath, decodeErr)
+				}
+				exchanges = append(exchanges, exchange)
+			}
+			return exchanges
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func exerciseUnixSocketProxyFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	socketDirectory, directoryErr := os.MkdirTemp("", "ghttp-sock")
+	if directoryErr != nil {
+		testingT.Fatalf("create unix socket directory: %v", directoryErr)
+	}
+	testingT.Cleanup(func() {
+		_ = os.RemoveAll(socketDirectory)
+	})
+	httpSocketPath := filepath.Join(socketDirectory, "app.sock")
+	webSocketSocketPath := filepath.Join(socketDirectory, "ws.sock")
+	missingSocketPath := filepath.Join(socketDirectory, "missing.sock")
+
+	startUnixSocketHTTPBackend(testingT, httpSocketPath, http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		_, _ = io.WriteString(responseWriter, "unix-backend:"+request.URL.Path)
+	}))
+	startUnixSocketWebSocketEchoBackend(testingT, webSocketSocketPath)
+
+	proxyPort := allocateFreePort(testingT)
+	proxyBaseURL := fmt.Sprintf("http://127.0.0.1:%d", proxyPort)
+	proxyServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(proxyPort),
+			"--directory", testingT.TempDir(),
+			"--proxy", "/unix=unix://" + httpSocketPath,
+			"--proxy", "/encoded=http+unix://" + url.PathEscape(httpSocketPath) + "/base",
+			"--proxy", "/ws=unix://" + webSocketSocketPath,
+			"--proxy", "/encoded-ws=http+unix://" + url.PathEscape(webSocketSocketPath),
+			"--proxy", "/missing=unix://" + missingSocketPath,
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		proxyBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	expectedBodies := map[string]string{
+		"/unix/check":    "unix-backend:/unix/check",
+		"/encoded/check": "unix-backend:/base/encoded/check",
+	}
+	for requestPath, expectedBody := range expectedBodies {
+		statusCode, _, body := executeHTTPGet(testingT, httpClient, proxyBaseURL, requestPath)
+		if statusCode != http.StatusOK || body != expectedBody {
+			testingT.Fatalf("expected %s to proxy over unix socket with body %q, got status=%d body=%s", requestPath, expectedBody, statusCode, body)
+		}
+	}
+	missingStatusCode, _, _ := executeHTTPGet(testingT, httpClient, proxyBaseURL, "/missing/check")
+	if missingStatusCode != http.StatusBadGateway {
+		testingT.Fatalf("expected missing unix socket to fail with 502, got %d", missingStatusCode)
+	}
+	proxyHostPort := fmt.Sprintf("127.0.0.1:%d", proxyPort)
+	performWebSocketUpgradeRoundTrip(testingT, proxyHostPort, "/ws")
+	performWebSocketUpgradeRoundTrip(testingT, proxyHostPort, "/encoded-ws")
+	performWebSocketUpgradeExpectedFailure(testingT, proxyHostPort, "/missing")
+	if stopErr := proxyServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop unix socket proxy server: %v", stopErr)
+	}
+
+	invalidBackendURLs := []string{
+		"unix://",
+		"unix://host/app.sock",
+		"http+unix://",
+		"http+unix:///base",
+		"http+unix://%zz/base",
+		"unix:///run/app.sock?query=1",
+	}
+	for _, invalidBackendURL := range invalidBackendURLs {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir(), "--proxy", "/api=" + invalidBackendURL},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
+
+func startUnixSocketHTTPBackend(testingT *testing.T, socketPath string, handler http.Handler) {
+	testingT.Helper()
+	backendListener, listenErr := net.Listen("unix", socketPath)
+	if listenErr != nil {
+		testingT.Fatalf("start unix socket backend listener: %v", listenErr)
+	}
+	backendServer := &http.Server{Handler: handler}
+	testingT.Cleanup(func() {
+		_ = backendServer.Close()
+	})
+	go func() {
+		_ = backendServer.Serve(backendListener)
+	}()
+}
+
+func startUnixSocketWebSocketEchoBackend(testingT *testing.T, socketPath string) {
+	testingT.Helper()
+	backendListener, listenErr := net.Listen("unix", socketPath)
+	if listenErr != nil {
+		testingT.Fatalf("start unix socket websocket backend listener: %v", listenErr)
+	}
+	testingT.Cleanup(func() {
+		_ = backendListener.Close()
+	})
+	go func() {
+		for {
+			connection, acceptErr := backendListener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go serveRawWebSocketEcho(connection)
+		}
+	}()
+}
@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type inspectedWebSocketFrame struct {
+	Path      string `json:"path"`
+	Direction string `json:"direction"`
+	Opcode    string `json:"opcode"`
+	Size      int    `json:"size"`
+	Payload   string `json:"payload"`
+}
+
+func exerciseProxyWebSocketInspectorFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	backendAddress := startFramedWebSocketBackend(testingT, make(chan webSocketBackendHandshake, 8), make(chan struct{}, 8))
+
+	inspectorPort := allocateFreePort(testingT)
+	inspectorHostPort := fmt.Sprintf("127.0.0.1:%d", inspectorPort)
+	inspectorBaseURL := "http://" + inspectorHostPort
+	inspectorServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(inspectorPort),
+			"--directory", testingT.TempDir(),
+			"--proxy", "/live=http://" + backendAddress,
+			"--proxy-websocket-log", "/live/chat=payload:60",
+			"--proxy-websocket-log", "/live/idle=frames",
+			"--proxy-websocket-log", "/live/large=payload:65536",
+			"--proxy-websocket-redact", `s3cr[a-z0-9]+`,
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		inspectorBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+
+	pageStatusCode, pageHeaders, pageBody := executeHTTPGet(testingT, httpClient, inspectorBaseURL, "/__ghttp/websocket")
+	if pageStatusCode != http.StatusOK || !strings.HasPrefix(pageHeaders.Get("Content-Type"), "text/html") || !strings.Contains(pageBody, `new EventSource("/__ghttp/websocket/frames")`) {
+		testingT.Fatalf("expected the frame debug page, got status=%d headers=%v", pageStatusCode, pageHeaders)
+	}
+	streamRequest, _ := http.NewRequest(http.MethodGet, inspectorBaseURL+"/__ghttp/websocket/frames", nil)
+	streamRequest.Header.Set("Accept", "text/event-stream")
+	streamResponse, streamErr := (&http.Client{}).Do(streamRequest)
+	if streamErr != nil || streamResponse.Header.Get("Content-Type") != "text/event-stream" {
+		testingT.Fatalf("open live frame stream: %v %v", streamErr, streamResponse)
+	}
+	liveFrames := make(chan inspectedWebSocketFrame, 16)
+	go func() {
+		defer close(liveFrames)
+		streamScanner := bufio.NewScanner(streamResponse.Body)
+		for streamScanner.Scan() {
+			eventData, isData := strings.CutPrefix(streamScanner.Text(), "data: ")
+			var liveFrame inspectedWebSocketFrame
+			if isData && json.Unmarshal([]byte(eventData), &liveFrame) == nil {
+				liveFrames <- liveFrame
+			}
+		}
+	}()
+
+	chatConnection, chatReader, _ := openFramedWebSocket(testingT, inspectorHostPort, "/live/chat", "chat.v2")
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodeText, masked: true, payload: []byte(`{"token":"s3cret42","message":"hi"}`)})
+	if echoFrame := readWebSocketTestDataFrame(testingT, chatReader); string(echoFrame.payload) != `echo: {"token":"s3cret42","message":"hi"}` {
+		testingT.Fatalf("expected inspected frames to be relayed unchanged, got %q", echoFrame.payload)
+	}
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodeText, masked: true, payload: []byte(strings.Repeat("long", 40))})
+	_ = readWebSocketTestDataFrame(testingT, chatReader)
+	select {
+	case liveFrame := <-liveFrames:
+		if liveFrame.Path != "/live/chat" || liveFrame.Direction != "in" || liveFrame.Opcode != "text" || !strings.Contains(liveFrame.Payload, `"token": "[REDACTED]"`) {
+			testingT.Fatalf("unexpected live frame %+v", liveFrame)
+		}
+	case <-time.After(browseModeRequestTimeout):
+		testingT.Fatalf("expected frames on the live stream")
+	}
+	_ = streamResponse.Body.Close()
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodeClose, masked: true, payload: []byte{0x03, 0xE8}})
+	for {
+		if closeFrame := readWebSocketTestFrame(testingT, chatReader); closeFrame.opcode == webSocketTestOpcodeClose {
+			break
+		}
+	}
+	_ = chatConnection.Close()
+
+	largePayload := strings.Repeat("x", 70000)
+	largeConnection, largeReader, _ := openFramedWebSocket(testingT, inspectorHostPort, "/live/large", "chat.v2")
+	writeWebSocketTestFrame(testingT, largeConnection, webSocketTestFrame{opcode: webSocketTestOpcodeText, masked: true, payload: []byte(largePayload)})
+	if echoFrame := readWebSocketTestDataFrame(testingT, largeReader); len(echoFrame.payload) != len("echo: ")+len(largePayload) {
+		testingT.Fatalf("expected a frame above the capture limit to be relayed, got %d bytes", len(echoFrame.payload))
+	}
+	_ = largeConnection.Close()
+
+	idleConnection, idleReader, _ := openFramedWebSocket(testingT, inspectorHostPort, "/live/idle", "")
+	writeWebSocketTestFrame(testingT, idleConnection, webSocketTestFrame{opcode: webSocketTestOpcodeText, masked: true, payload: []byte("quiet-payload")})
+	_ = readWebSocketTestDataFrame(testingT, idleReader)
+	_ = idleConnection.Close()
+
+	time.Sleep(webSocketLogSettle)
+	framesStatusCode, _, framesBody := executeHTTPGet(testingT, httpClient, inspectorBaseURL, "/__ghttp/websocket/frames")
+	var recentFrames []inspectedWebSocketFrame
+	if framesStatusCode != http.StatusOK || json.Unmarshal([]byte(framesBody), &recentFrames) != nil || len(recentFrames) < 7 {
+		testingT.Fatalf("expected recent frames as JSON, got status=%d body=%s", framesStatusCode, framesBody)
+	}
+	if recentFrames[len(recentFrames)-1].Path != "/live/idle" || recentFrames[len(recentFrames)-1].Payload != "" {
+		testingT.Fatalf("expected frames mode to omit payloads, got %+v", recentFrames[len(recentFrames)-1])
+	}
+	postRequest, _ := http.NewRequest(http.MethodPost, inspectorBaseURL+"/__ghttp/websocket/frames", nil)
+	if postResponse, postErr := httpClient.Do(postRequest); postErr != nil || postResponse.StatusCode != http.StatusMethodNotAllowed {
+		testingT.Fatalf("expected 405 for POST to the frame feed, got %v err=%v", postResponse, postErr)
+	}
+
+	if stopErr := inspectorServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop websocket inspector server: %v", stopErr)
+	}
+	inspectorLogs := inspectorServer.logBuffer.String()
+	for _, expectedLog := range []string{
+		`proxy websocket frame path="/live/chat" direction="in" opcode="text" size=35 payload="{`,
+		`"token": "[REDACTED]"`,
+		`direction="out" opcode="text"`,
+		`payload="` + strings.Repeat("long", 15) + `…"`,
+		`opcode="close" size=2 payload="1000"`,
+		`proxy websocket frame path="/live/idle" direction="in" opcode="text" size=13`,
+		`proxy websocket frame path="/live/large" direction="in" opcode="text" size=70000 payload="` + strings.Repeat("x", 65536) + `…"`,
+		`"POST /__ghttp/websocket/frames HTTP/1.1" 405 19 websocket-inspector`,
+	} {
+		if !strings.Contains(inspectorLogs, expectedLog) {
+			testingT.Fatalf("expected %q in websocket frame logs, got:\n%s", expectedLog, inspectorLogs)
+		}
+	}
+	if strings.Contains(inspectorLogs, "s3cret42") || strings.Contains(inspectorLogs, "quiet-payload") {
+		testingT.Fatalf("expected redacted and metadata-only payloads to stay out of the logs, got:\n%s", inspectorLogs)
+	}
+
+	for _, invalidArguments := range [][]string{
+		{"--proxy", "/live=http://" + backendAddress, "--proxy-websocket-log", "/live=payload:0"},
+		{"--proxy", "/live=http://" + backendAddress, "--proxy-websocket-log", "/live=verbose"},
+		{"--proxy", "/live=http://" + backendAddress, "--proxy-websocket-log", "live=frames"},
+		{"--proxy", "/live=http://" + backendAddress, "--proxy-websocket-log", "/live=frames", "--proxy-websocket-redact", "("},
+		{"--proxy", "/live=http://" + backendAddress, "--proxy-websocket-redact", "secret"},
+		{"--proxy-websocket-log", "/live=frames"},
+	} {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir()}, invalidArguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
@@ -0,0 +1,391 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	webSocketTestOpcodeText  = 0x1
+	webSocketTestOpcodeClose = 0x8
+	webSocketTestOpcodePing  = 0x9
+	webSocketTestOpcodePong  = 0xA
+	webSocketPingInterval    = 150 * time.Millisecond
+	webSocketIdleTimeout     = time.Second
+	webSocketLogSettle       = 300 * time.Millisecond
+)
+
+type webSocketTestFrame struct {
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+type webSocketBackendHandshake struct {
+	subprotocols string
+	extensions   string
+	connection   string
+	forwardedFor string
+}
+
+func exerciseProxyWebSocketFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	backendHandshakes := make(chan webSocketBackendHandshake, 8)
+	backendPings := make(chan struct{}, 8)
+	backendAddress := startFramedWebSocketBackend(testingT, backendHandshakes, backendPings)
+
+	proxyPort := allocateFreePort(testingT)
+	proxyHostPort := fmt.Sprintf("127.0.0.1:%d", proxyPort)
+	proxyServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(proxyPort),
+			"--directory", testingT.TempDir(),
+			"--proxy", "/live=http://" + backendAddress,
+			"--proxy-websocket-ping", webSocketPingInterval.String(),
+			"--proxy-websocket-idle-timeout", webSocketIdleTimeout.String(),
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		"http://"+proxyHostPort+"/",
+		false,
+	)
+
+	chatConnection, chatReader, chatHeaders := openFramedWebSocket(testingT, proxyHostPort, "/live/chat", "chat.v1, chat.v2")
+	if chatHeaders.Get("Sec-WebSocket-Protocol") != "chat.v2" || chatHeaders.Get("Sec-WebSocket-Extensions") != "permessage-deflate; server_no_context_takeover" {
+		testingT.Fatalf("expected negotiated subprotocol and extension to pass through, got %v", chatHeaders)
+	}
+	backendHandshake := <-backendHandshakes
+	if backendHandshake.subprotocols != "chat.v1, chat.v2" || !strings.HasPrefix(backendHandshake.extensions, "permessage-deflate") || backendHandshake.connection != "Upgrade" || backendHandshake.forwardedFor != "127.0.0.1" {
+		testingT.Fatalf("unexpected upgrade request at the backend: %+v", backendHandshake)
+	}
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodeText, masked: true, payload: []byte("hello")})
+	echoFrame := readWebSocketTestDataFrame(testingT, chatReader)
+	if echoFrame.opcode != webSocketTestOpcodeText || echoFrame.masked || string(echoFrame.payload) != "echo: hello" {
+		testingT.Fatalf("unexpected echo frame %+v", echoFrame)
+	}
+	largePayload := strings.Repeat("x", 70000)
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodeText, masked: true, payload: []byte(largePayload)})
+	if largeEcho := readWebSocketTestDataFrame(testingT, chatReader); string(largeEcho.payload) != "echo: "+largePayload {
+		testingT.Fatalf("unexpected large echo frame of %d bytes", len(largeEcho.payload))
+	}
+	pingFrame := readWebSocketTestFrame(testingT, chatReader)
+	if pingFrame.opcode != webSocketTestOpcodePing || pingFrame.masked {
+		testingT.Fatalf("expected an unmasked keepalive ping from the proxy, got %+v", pingFrame)
+	}
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodePong, masked: true, payload: pingFrame.payload})
+	select {
+	case <-backendPings:
+	case <-time.After(browseModeRequestTimeout):
+		testingT.Fatalf("expected the proxy to ping the backend")
+	}
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodePong, masked: true, payload: []byte("client-pong")})
+	if forwardedPong := readWebSocketTestDataFrame(testingT, chatReader); forwardedPong.opcode != webSocketTestOpcodeText || string(forwardedPong.payload) != "pong: client-pong" {
+		testingT.Fatalf("expected non-keepalive pongs to reach the backend, got %+v", forwardedPong)
+	}
+	writeWebSocketTestFrame(testingT, chatConnection, webSocketTestFrame{opcode: webSocketTestOpcodeClose, masked: true, payload: []byte{0x03, 0xE8}})
+	for {
+		closeFrame := readWebSocketTestFrame(testingT, chatReader)
+		if closeFrame.opcode == webSocketTestOpcodeClose {
+			break
+		}
+	}
+	if _, readErr := chatReader.ReadByte(); readErr == nil {
+		testingT.Fatalf("expected the tunnel to close after the closing handshake")
+	}
+	_ = chatConnection.Close()
+
+	idleConnection, idleReader, _ := openFramedWebSocket(testingT, proxyHostPort, "/live/idle", "")
+	<-backendHandshakes
+	idleStart := time.Now()
+	pingsBeforeClose := 0
+	_ = idleConnection.SetReadDeadline(time.Now().Add(browseModeRequestTimeout))
+	for {
+		idleFrame, readErr := readWebSocketTestFrameOrError(idleReader)
+		if readErr != nil {
+			break
+		}
+		if idleFrame.opcode == webSocketTestOpcodePing {
+			pingsBeforeClose++
+		}
+	}
+	_ = idleConnection.Close()
+	if idleDuration := time.Since(idleStart); idleDuration < webSocketIdleTimeout-webSocketPingInterval || pingsBeforeClose == 0 {
+		testingT.Fatalf("expected pings and an idle close after %s, got %d pings and a close after %s", webSocketIdleTimeout, pingsBeforeClose, idleDuration)
+	}
+
+	rogueConnection, dialErr := net.DialTimeout("tcp", proxyHostPort, browseModeRequestTimeout)
+	if dialErr != nil {
+		testingT.Fatalf("dial websocket proxy: %v", dialErr)
+	}
+	writeWebSocketHandshake(testingT, rogueConnection, proxyHostPort, "/live/rogue", "chat.v1")
+	rogueResponse, rogueErr := http.ReadResponse(bufio.NewReader(rogueConnection), nil)
+	if rogueErr != nil || rogueResponse.StatusCode != http.StatusBadGateway {
+		testingT.Fatalf("expected 502 for an unoffered subprotocol, got %v err=%v", rogueResponse, rogueErr)
+	}
+	_ = rogueConnection.Close()
+
+	deniedConnection, deniedDialErr := net.DialTimeout("tcp", proxyHostPort, browseModeRequestTimeout)
+	if deniedDialErr != nil {
+		testingT.Fatalf("dial websocket proxy: %v", deniedDialErr)
+	}
+	writeWebSocketHandshake(testingT, deniedConnection, proxyHostPort, "/live/denied", "")
+	deniedResponse, deniedErr := http.ReadResponse(bufio.NewReader(deniedConnection), nil)
+	if deniedErr != nil {
+		testingT.Fatalf("read rejected upgrade response: %v", deniedErr)
+	}
+	deniedBody, _ := io.ReadAll(deniedResponse.Body)
+	_ = deniedConnection.Close()
+	if deniedResponse.StatusCode != http.StatusForbidden || string(deniedBody) != "origin not allowed" || deniedResponse.Header.Get("X-Denied-By") != "backend" {
+		testingT.Fatalf("expected backend rejection to pass through, got status=%d headers=%v body=%q", deniedResponse.StatusCode, deniedResponse.Header, deniedBody)
+	}
+
+	time.Sleep(webSocketLogSettle)
+	if stopErr := proxyServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop websocket proxy server: %v", stopErr)
+	}
+	proxyLogs := proxyServer.logBuffer.String()
+	for _, expectedLog := range []string{"proxy websocket closed", `path="/live/chat"`, `reason="client closed"`, `path="/live/idle"`, `reason="idle timeout"`, "bytes_in=", "bytes_out="} {
+		if !strings.Contains(proxyLogs, expectedLog) {
+			testingT.Fatalf("expected %q in websocket logs, got:\n%s", expectedLog, proxyLogs)
+		}
+	}
+	for _, logLine := range strings.Split(proxyLogs, "\n") {
+		if strings.Contains(logLine, `path="/live/chat"`) && (strings.Contains(logLine, "bytes_in=0 ") || strings.Contains(logLine, "bytes_out=0 ")) {
+			testingT.Fatalf("expected non-zero websocket byte counters, got %q", logLine)
+		}
+	}
+
+	for _, invalidDuration := range []string{"--proxy-websocket-ping=-1s", "--proxy-websocket-idle-timeout=soon"} {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir(), "--proxy", "/live=http://" + backendAddress, invalidDuration},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+	runCommandExpectExitCode(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir(), "--proxy-websocket-ping", "1s"},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		1,
+	)
+}
+
+// startFramedWebSocketBackend serves a frame-aware WebSocket backend: /live/chat negotiates chat.v2 with
+// permessage-deflate and echoes text frames, /live/idle accepts and stays quiet, /live/rogue selects a subprotocol the
+// client never offered and /live/denied rejects the upgrade.
+func startFramedWebSocketBackend(testingT *testing.T, handshakes chan<- webSocketBackendHandshake, pings chan<- struct{}) string {
+	testingT.Helper()
+	backendListener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		testingT.Fatalf("start websocket backend listener: %v", listenErr)
+	}
+	testingT.Cleanup(func() {
+		_ = backendListener.Close()
+	})
+	go func() {
+		for {
+			connection, acceptErr := backendListener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go serveFramedWebSocketBackend(connection, handshakes, pings)
+		}
+	}()
+	return backendListener.Addr().String()
+}
+
+func serveFramedWebSocketBackend(connection net.Conn, handshakes chan<- webSocketBackendHandshake, pings chan<- struct{}) {
+	defer connection.Close()
+	reader := bufio.NewReader(connection)
+	upgradeRequest, requestErr := http.ReadRequest(reader)
+	if requestErr != nil {
+		return
+	}
+	switch upgradeRequest.URL.Path {
+	case "/live/denied":
+		_, _ = io.WriteString(connection, "HTTP/1.1 403 Forbidden\r\nX-Denied-By: backend\r\nContent-Length: 18\r\n\r\norigin not allowed")
+		return
+	case "/live/rogue":
+		_, _ = io.WriteString(connection, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Protocol: rogue\r\n\r\n")
+		return
+	case "/live/idle":
+		handshakes <- webSocketBackendHandshake{}
+		_, _ = io.WriteString(connection, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	default:
+		handshakes <- webSocketBackendHandshake{
+			subprotocols: upgradeRequest.Header.Get("Sec-WebSocket-Protocol"),
+			extensions:   upgradeRequest.Header.Get("Sec-WebSocket-Extensions"),
+			connection:   upgradeRequest.Header.Get("Connection"),
+			forwardedFor: upgradeRequest.Header.Get("X-Forwarded-For"),
+		}
+		_, _ = io.WriteString(connection, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Protocol: chat.v2\r\nSec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover\r\n\r\n")
+	}
+	for {
+		frame, readErr := readWebSocketTestFrameOrError(reader)
+		if readErr != nil || !frame.masked {
+			return
+		}
+		var reply webSocketTestFrame
+		switch frame.opcode {
+		case webSocketTestOpcodeText:
+			reply = webSocketTestFrame{opcode: webSocketTestOpcodeText, payload: append([]byte("echo: "), frame.payload...)}
+		case webSocketTestOpcodePong:
+			reply = webSocketTestFrame{opcode: webSocketTestOpcodeText, payload: append([]byte("pong: "), frame.payload...)}
+		case webSocketTestOpcodePing:
+			select {
+			case pings <- struct{}{}:
+			default:
+			}
+			reply = webSocketTestFrame{opcode: webSocketTestOpcodePong, payload: frame.payload}
+		case webSocketTestOpcodeClose:
+			_, _ = connection.Write(encodeWebSocketTestFrame(webSocketTestFrame{opcode: webSocketTestOpcodeClose, payload: frame.payload}))
+			return
+		}
+		if _, writeErr := connection.Write(encodeWebSocketTestFrame(reply)); writeErr != nil {
+			return
+		}
+	}
+}
+
+func openFramedWebSocket(testingT *testing.T, hostPort string, requestPath string, subprotocols string) (net.Conn, *bufio.Reader, http.Header) {
+	testingT.Helper()
+	connection, dialErr := net.DialTimeout("tcp", hostPort, browseModeRequestTimeout)
+	if dialErr != nil {
+		testingT.Fatalf("dial websocket proxy %s: %v", hostPort, dialErr)
+	}
+	_ = connection.SetDeadline(time.Now().Add(browseModeRequestTimeout))
+	writeWebSocketHandshake(testingT, connection, hostPort, requestPath, subprotocols)
+	reader := bufio.NewReader(connection)
+	handshakeResponse, responseErr := http.ReadResponse(reader, nil)
+	if responseErr != nil || handshakeResponse.StatusCode != http.StatusSwitchingProtocols {
+		testingT.Fatalf("expected websocket 101 response for %s, got %v err=%v", requestPath, handshakeResponse, responseErr)
+	}
+	return connection, reader, handshakeResponse.Header
+}
+
+func writeWebSocketHandshake(testingT *testing.T, connection net.Conn, hostPort string, requestPath string, subprotocols string) {
+	testingT.Helper()
+	handshakeLines := []string{
+		"GET " + requestPath + " HTTP/1.1",
+		"Host: " + hostPort,
+		"Connection: keep-alive, Upgrade",
+		"Upgrade: websocket",
+		"Sec-WebSocket-Version: 13",
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==",
+		"Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits",
+	}
+	if subprotocols != "" {
+		handshakeLines = append(handshakeLines, "Sec-WebSocket-Protocol: "+subprotocols)
+	}
+	if _, writeErr := io.WriteString(connection, strings.Join(handshakeLines, "\r\n")+"\r\n\r\n"); writeErr != nil {
+		testingT.Fatalf("write websocket handshake: %v", writeErr)
+	}
+}
+
+func writeWebSocketTestFrame(testingT *testing.T, connection net.Conn, frame webSocketTestFrame) {
+	testingT.Helper()
+	if _, writeErr := connection.Write(encodeWebSocketTestFrame(frame)); writeErr != nil {
+		testingT.Fatalf("write websocket frame: %v", writeErr)
+	}
+}
+
+func encodeWebSocketTestFrame(frame webSocketTestFrame) []byte {
+	encoded := []byte{0x80 | frame.opcode}
+	maskBit := byte(0)
+	if frame.masked {
+		maskBit = 0x80
+	}
+	switch payloadLength := len(frame.payload); {
+	case payloadLength < 126:
+		encoded = append(encoded, maskBit|byte(payloadLength))
+	case payloadLength <= 0xFFFF:
+		encoded = append(encoded, maskBit|126)
+		encoded = binary.BigEndian.AppendUint16(encoded, uint16(payloadLength))
+	default:
+		encoded = append(encoded, maskBit|127)
+		encoded = binary.BigEndian.AppendUint64(encoded, uint64(payloadLength))
+	}
+	if !frame.masked {
+		return append(encoded, frame.payload...)
+	}
+	maskKey := []byte{0x12, 0x34, 0x56, 0x78}
+	encoded = append(encoded, maskKey...)
+	for payloadIndex, payloadByte := range frame.payload {
+		encoded = append(encoded, payloadByte^maskKey[payloadIndex%len(maskKey)])
+	}
+	return encoded
+}
+
+func readWebSocketTestFrame(testingT *testing.T, reader *bufio.Reader) webSocketTestFrame {
+	testingT.Helper()
+	frame, readErr := readWebSocketTestFrameOrError(reader)
+	if readErr != nil {
+		testingT.Fatalf("read websocket frame: %v", readErr)
+	}
+	return frame
+}
+
+// readWebSocketTestDataFrame skips keepalive pings that the proxy may interleave with data frames.
+func readWebSocketTestDataFrame(testingT *testing.T, reader *bufio.Reader) webSocketTestFrame {
+	testingT.Helper()
+	for {
+		frame := readWebSocketTestFrame(testingT, reader)
+		if frame.opcode != webSocketTestOpcodePing {
+			return frame
+		}
+	}
+}
+
+func readWebSocketTestFrameOrError(reader *bufio.Reader) (webSocketTestFrame, error) {
+	frameHeader := make([]byte, 2)
+	if _, readErr := io.ReadFull(reader, frameHeader); readErr != nil {
+		return webSocketTestFrame{}, readErr
+	}
+	frame := webSocketTestFrame{opcode: frameHeader[0] & 0x0F, masked: frameHeader[1]&0x80 != 0}
+	payloadLength := uint64(frameHeader[1] & 0x7F)
+	switch payloadLength {
+	case 126:
+		extendedLength := make([]byte, 2)
+		if _, readErr := io.ReadFull(reader, extendedLength); readErr != nil {
+			return webSocketTestFrame{}, readErr
+		}
+		payloadLength = uint64(binary.BigEndian.Uint16(extendedLength))
+	case 127:
+		extendedLength := make([]byte, 8)
+		if _, readErr := io.ReadFull(reader, extendedLength); readErr != nil {
+			return webSocketTestFrame{}, readErr
+		}
+		payloadLength = binary.BigEndian.Uint64(extendedLength)
+	}
+	maskKey := make([]byte, 4)
+	if frame.masked {
+		if _, readErr := io.ReadFull(reader, maskKey); readErr != nil {
+			return webSocketTestFrame{}, readErr
+		}
+	}
+	frame.payload = make([]byte, payloadLength)
+	if _, readErr := io.ReadFull(reader, frame.payload); readErr != nil {
+		return webSocketTestFrame{}, readErr
+	}
+	if frame.masked {
+		for payloadIndex := range frame.payload {
+			frame.payload[payloadIndex] ^= maskKey[payloadIndex%len(maskKey)]
+		}
+	}
+	return frame, nil
+}
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exerciseRequestLimitsFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	backendServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/api/slow" {
+			time.Sleep(300 * time.Millisecond)
+			_, _ = io.WriteString(responseWriter, strings.Repeat("slow-response", 1<<16))
+			return
+		}
+		requestBody, readErr := io.ReadAll(request.Body)
+		if readErr != nil {
+			http.Error(responseWriter, readErr.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(responseWriter, "received:"+strconv.Itoa(len(requestBody)))
+	}))
+	testingT.Cleanup(backendServer.Close)
+
+	limitsPort := allocateFreePort(testingT)
+	limitsHostPort := fmt.Sprintf("127.0.0.1:%d", limitsPort)
+	limitsBaseURL := "http://" + limitsHostPort
+	limitsServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{
+			strconv.Itoa(limitsPort),
+			"--directory", testingT.TempDir(),
+			"--proxy", "/api=" + backendServer.URL,
+			"--max-body-bytes", "64",
+			"--route-limit", "/api/upload=max_body_bytes:4096",
+			"--route-limit", "/api/slow=write_timeout:100ms",
+			"--read-timeout", "1s",
+			"--max-header-bytes", "4096",
+		},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		limitsBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	postBody := func(requestPath string, requestBody io.Reader) (int, bool, string) {
+		testingT.Helper()
+		postResponse, postErr := httpClient.Post(limitsBaseURL+requestPath, "application/octet-stream", requestBody)
+		if postErr != nil {
+			testingT.Fatalf("post %s: %v", requestPath, postErr)
+		}
+		defer postResponse.Body.Close()
+		responseBody, _ := io.ReadAll(postResponse.Body)
+		return postResponse.StatusCode, postResponse.Close, string(responseBody)
+	}
+
+	expectations := []struct {
+		requestPath    string
+		requestBody    io.Reader
+		expectedStatus int
+		expectedBody   string
+	}{
+		{requestPath: "/api/echo", requestBody: strings.NewReader(strings.Repeat("a", 64)), expectedStatus: http.StatusOK, expectedBody: "received:64"},
+		{requestPath: "/api/echo", requestBody: strings.NewReader(strings.Repeat("a", 128)), expectedStatus: http.StatusRequestEntityTooLarge},
+		{requestPath: "/api/echo", requestBody: io.MultiReader(strings.NewReader(strings.Repeat("b", 100))), expectedStatus: http.StatusRequestEntityTooLarge},
+		{requestPath: "/api/upload", requestBody: io.MultiReader(strings.NewReader(strings.Repeat("c", 2048))), expectedStatus: http.StatusOK, expectedBody: "received:2048"},
+		{requestPath: "/api/upload", requestBody: strings.NewReader(strings.Repeat("d", 8192)), expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, expectation := range expectations {
+		statusCode, connectionClosed, responseBody := postBody(expectation.requestPath, expectation.requestBody)
+		if statusCode != expectation.expectedStatus {
+			testingT.Fatalf("expected status %d for %s, got %d body=%q", expectation.expectedStatus, expectation.requestPath, statusCode, responseBody)
+		}
+		if expectation.expectedBody != "" && responseBody != expectation.expectedBody {
+			testingT.Fatalf("expected body %q for %s, got %q", expectation.expectedBody, expectation.requestPath, responseBody)
+		}
+		if statusCode == http.StatusRequestEntityTooLarge && !connectionClosed {
+			testingT.Fatalf("expected oversize bodies to close the connection for %s", expectation.requestPath)
+		}
+	}
+
+	slowConnection, dialErr := net.Dial("tcp", limitsHostPort)
+	if dialErr != nil {
+		testingT.Fatalf("dial slow client: %v", dialErr)
+	}
+	_, _ = fmt.Fprintf(slowConnection, "POST /api/echo HTTP/1.1\r\nHost: %s\r\nContent-Length: 40\r\n\r\nonly-part", limitsHostPort)
+	_ = slowConnection.SetReadDeadline(time.Now().Add(browseModeRequestTimeout))
+	slowResponse, slowErr := http.ReadResponse(bufio.NewReader(slowConnection), nil)
+	if slowErr != nil || slowResponse.StatusCode != http.StatusRequestTimeout {
+		testingT.Fatalf("expected 408 for a slow request body, got %v err=%v", slowResponse, slowErr)
+	}
+	_ = slowConnection.Close()
+
+	headerRequest, _ := http.NewRequest(http.MethodGet, limitsBaseURL+"/", nil)
+	headerRequest.Header.Set("X-Oversized", strings.Repeat("h", 16<<10))
+	if headerResponse, headerErr := httpClient.Do(headerRequest); headerErr != nil || headerResponse.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		testingT.Fatalf("expected 431 for oversized headers, got %v err=%v", headerResponse, headerErr)
+	}
+
+	if slowWriteResponse, slowWriteErr := httpClient.Get(limitsBaseURL + "/api/slow"); slowWriteErr == nil {
+		_, _ = io.Copy(io.Discard, slowWriteResponse.Body)
+		_ = slowWriteResponse.Body.Close()
+	}
+
+	if stopErr := limitsServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop request limits server: %v", stopErr)
+	}
+	limitsLogs := limitsServer.logBuffer.String()
+	for _, expectedLog := range []string{
+		`request body too large path="/api/echo" limit=64 content_length=128`,
+		`request body too large path="/api/echo" limit=64`,
+		`request body too large path="/api/upload" limit=4096 content_length=8192`,
+		`request body read timed out path="/api/echo"`,
+		`response write timed out path="/api/slow"`,
+	} {
+		if !strings.Contains(limitsLogs, expectedLog) {
+			testingT.Fatalf("expected %q in request limit logs, got:\n%s", expectedLog, limitsLogs)
+		}
+	}
+
+	for _, invalidArguments := range [][]string{
+		{"--max-body-bytes", "-1"},
+		{"--read-timeout", "-1s"},
+		{"--max-header-bytes", "-1"},
+		{"--route-limit", "/api=max_body_bytes:-1"},
+		{"--route-limit", "/api=write_timeout:soon"},
+		{"--route-limit", "/api=retries:3"},
+		{"--route-limit", "api=max_body_bytes:1"},
+		{"--route-limit", "/api"},
+	} {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			append([]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", testingT.TempDir()}, invalidArguments...),
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+}
@@ -0,0 +1,255 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// restSlowUploadSettle gives a partially sent request body time to reach the REST handler.
+const restSlowUploadSettle = 200 * time.Millisecond
+
+const restStoreFixture = `{
+  "posts": [
+    {"id": 1, "title": "Hello", "views": 10, "author": {"name": "ann"}},
+    {"id": 2, "title": "World", "views": 5, "author": {"name": "bob"}},
+    {"id": 3, "title": "Again", "views": 20, "author": {"name": "ann"}}
+  ],
+  "profile": {"name": "ghttp"}
+}
+`
+
+func exerciseRESTStoreFlows(testingT *testing.T, repositoryRoot string, binaryPath string, coverageDirectoryPath string) {
+	testingT.Helper()
+	siteDirectory := testingT.TempDir()
+	dataFilePath := filepath.Join(siteDirectory, "data.json")
+	for fileName, content := range map[string]string{
+		"data.json":  restStoreFixture,
+		"array.json": `[{"id":1}]`,
+		"mixed.json": `{"posts":[1,2]}`,
+		"notes.txt":  "not json",
+	} {
+		if writeErr := os.WriteFile(filepath.Join(siteDirectory, fileName), []byte(content), 0o600); writeErr != nil {
+			testingT.Fatalf("write rest fixture: %v", writeErr)
+		}
+	}
+
+	restPort := allocateFreePort(testingT)
+	restBaseURL := fmt.Sprintf("http://127.0.0.1:%d", restPort)
+	restServer := startGHTTPProcessWithArguments(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(restPort), "--directory", siteDirectory, "--rest", "/db=data.json"},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		restBaseURL+"/",
+		false,
+	)
+	httpClient := &http.Client{Timeout: browseModeRequestTimeout}
+	sendJSON := func(method string, requestPath string, requestBody string) (int, http.Header, string) {
+		testingT.Helper()
+		request, _ := http.NewRequest(method, restBaseURL+requestPath, strings.NewReader(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		response, responseErr := httpClient.Do(request)
+		if responseErr != nil {
+			testingT.Fatalf("%s %s: %v", method, requestPath, responseErr)
+		}
+		defer response.Body.Close()
+		responseBody, _ := io.ReadAll(response.Body)
+		return response.StatusCode, response.Header, string(responseBody)
+	}
+	listIDs := func(requestPath string) ([]string, http.Header) {
+		testingT.Helper()
+		statusCode, responseHeaders, responseBody := executeHTTPGet(testingT, httpClient, restBaseURL, requestPath)
+		var items []map[string]any
+		if statusCode != http.StatusOK || json.Unmarshal([]byte(responseBody), &items) != nil {
+			testingT.Fatalf("expected a JSON list for %s, got status=%d body=%s", requestPath, statusCode, responseBody)
+		}
+		itemIDs := []string{}
+		for _, item := range items {
+			itemIDs = append(itemIDs, fmt.Sprint(item["id"]))
+		}
+		return itemIDs, responseHeaders
+	}
+
+	documentStatus, documentHeaders, documentBody := executeHTTPGet(testingT, httpClient, restBaseURL, "/db")
+	if documentStatus != http.StatusOK || documentHeaders.Get("Content-Type") != "application/json; charset=utf-8" || !strings.Contains(documentBody, `"profile":{"name":"ghttp"}`) {
+		testingT.Fatalf("expected the whole document at the mount path, got status=%d body=%s", documentStatus, documentBody)
+	}
+	for requestPath, expectedIDs := range map[string]string{
+		"/db/posts": "1,2,3",
+		"/db/posts?author.name=ann&_sort=views&_order=desc": "3,1",
+		"/db/posts?views_gte=10&title_like=^h":              "1",
+		"/db/posts?views_ne=10&views_lte=10":                "2",
+		"/db/posts?q=WOR":                                   "2",
+		"/db/posts?id=1&id=3":                               "1,3",
+		"/db/posts?_start=1&_end=2":                         "2",
+		"/db/posts?_sort=title&_limit=2":                    "3,1",
+	} {
+		if itemIDs, _ := listIDs(requestPath); strings.Join(itemIDs, ",") != expectedIDs {
+			testingT.Fatalf("expected ids %s for %s, got %v", expectedIDs, requestPath, itemIDs)
+		}
+	}
+	pagedIDs, pagedHeaders := listIDs("/db/posts?_page=2&_limit=2")
+	if strings.Join(pagedIDs, ",") != "3" || pagedHeaders.Get("X-Total-Count") != "3" ||
+		!strings.Contains(pagedHeaders.Get("Link"), `</db/posts?_limit=2&_page=1>; rel="prev"`) || strings.Contains(pagedHeaders.Get("Link"), `rel="next"`) {
+		testingT.Fatalf("expected the second page with links, got ids=%v headers=%v", pagedIDs, pagedHeaders)
+	}
+	if itemStatus, _, itemBody := executeHTTPGet(testingT, httpClient, restBaseURL, "/db/posts/2"); itemStatus != http.StatusOK || !strings.Contains(itemBody, `"title":"World"`) {
+		testingT.Fatalf("expected post 2, got status=%d body=%s", itemStatus, itemBody)
+	}
+
+	createStatus, createHeaders, createBody := sendJSON(http.MethodPost, "/db/posts", `{"title":"New"}`)
+	if createStatus != http.StatusCreated || createHeaders.Get("Location") != "/db/posts/4" || !strings.Contains(createBody, `"id":4`) {
+		testingT.Fatalf("expected a created post with the next id, got status=%d headers=%v body=%s", createStatus, createHeaders, createBody)
+	}
+	expectations := []struct {
+		method         string
+		requestPath    string
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{method: http.MethodPut, requestPath: "/db/posts/4", requestBody: `{"title":"Replaced <b>"}`, expectedStatus: http.StatusOK, expectedBody: `{"id":4,"title":"Replaced <b>"}`},
+		{method: http.MethodPatch, requestPath: "/db/posts/1", requestBody: `{"views":11}`, expectedStatus: http.StatusOK, expectedBody: `"title":"Hello","views":11`},
+		{method: http.MethodDelete, requestPath: "/db/posts/2", expectedStatus: http.StatusNoContent},
+		{method: http.MethodGet, requestPath: "/db/posts/2", expectedStatus: http.StatusNotFound, expectedBody: `"error":"item 2 not found"`},
+		{method: http.MethodDelete, requestPath: "/db/posts/2", expectedStatus: http.StatusNotFound},
+		{method: http.MethodPatch, requestPath: "/db/posts/9", requestBody: `{"views":1}`, expectedStatus: http.StatusNotFound},
+		{method: http.MethodPatch, requestPath: "/db/profile", requestBody: `{"theme":"dark"}`, expectedStatus: http.StatusOK, expectedBody: `{"name":"ghttp","theme":"dark"}`},
+		{method: http.MethodPatch, requestPath: "/db/profile", requestBody: `["dark"]`, expectedStatus: http.StatusBadRequest},
+		{method: http.MethodPut, requestPath: "/db/profile", requestBody: `["a",1]`, expectedStatus: http.StatusBadRequest, expectedBody: `must only hold objects`},
+		{method: http.MethodPost, requestPath: "/db/posts", requestBody: `{"id":1,"title":"Taken"}`, expectedStatus: http.StatusConflict},
+		{method: http.MethodPost, requestPath: "/db/posts", requestBody: `[1]`, expectedStatus: http.StatusBadRequest},
+		{method: http.MethodPost, requestPath: "/db/profile", requestBody: `{}`, expectedStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodDelete, requestPath: "/db/posts", expectedStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, requestPath: "/db", requestBody: `{}`, expectedStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, requestPath: "/db/comments", expectedStatus: http.StatusNotFound},
+		{method: http.MethodGet, requestPath: "/db/profile/1", expectedStatus: http.StatusNotFound},
+		{method: http.MethodGet, requestPath: "/db/posts/1/comments", expectedStatus: http.StatusNotFound},
+		{method: http.MethodGet, requestPath: "/db/posts?_page=0", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodGet, requestPath: "/db/posts?_page=4611686018427387905&_limit=2", expectedStatus: http.StatusBadRequest, expectedBody: `invalid _page`},
+		{method: http.MethodGet, requestPath: "/db/posts?_start=9223372036854775807&_limit=2", expectedStatus: http.StatusBadRequest, expectedBody: `invalid _start`},
+		{method: http.MethodGet, requestPath: "/db/posts?title_like=(", expectedStatus: http.StatusBadRequest},
+	}
+	for _, expectation := range expectations {
+		statusCode, _, responseBody := sendJSON(expectation.method, expectation.requestPath, expectation.requestBody)
+		if statusCode != expectation.expectedStatus || !strings.Contains(responseBody, expectation.expectedBody) {
+			testingT.Fatalf("expected %d with %q for %s %s, got %d %s", expectation.expectedStatus, expectation.expectedBody, expectation.method, expectation.requestPath, statusCode, responseBody)
+		}
+	}
+
+	persistedBytes, readErr := os.ReadFile(dataFilePath)
+	var persistedDocument struct {
+		Posts   []map[string]any  `json:"posts"`
+		Profile map[string]string `json:"profile"`
+	}
+	if readErr != nil || json.Unmarshal(persistedBytes, &persistedDocument) != nil || len(persistedDocument.Posts) != 3 || persistedDocument.Profile["theme"] != "dark" {
+		testingT.Fatalf("expected changes persisted to the data file, got %s (%v)", persistedBytes, readErr)
+	}
+	if !strings.Contains(string(persistedBytes), `"Replaced <b>"`) || !strings.HasPrefix(string(persistedBytes), "{\n  \"posts\"") {
+		testingT.Fatalf("expected an indented, unescaped data file, got %s", persistedBytes)
+	}
+	if siteEntries, _ := os.ReadDir(siteDirectory); len(siteEntries) != 4 {
+		testingT.Fatalf("expected no temporary files next to the data file, got %v", siteEntries)
+	}
+	if staticStatus, _, staticBody := executeHTTPGet(testingT, httpClient, restBaseURL, "/data.json"); staticStatus != http.StatusOK || !strings.Contains(staticBody, `"Replaced <b>"`) {
+		testingT.Fatalf("expected the data file to stay available as a static file, got status=%d", staticStatus)
+	}
+
+	uploadReader, uploadWriter := io.Pipe()
+	slowUploadStatus := make(chan int, 1)
+	go func() {
+		request, _ := http.NewRequest(http.MethodPost, restBaseURL+"/db/posts", uploadReader)
+		request.Header.Set("Content-Type", "application/json")
+		response, responseErr := httpClient.Do(request)
+		if responseErr != nil {
+			slowUploadStatus <- 0
+			return
+		}
+		_ = response.Body.Close()
+		slowUploadStatus <- response.StatusCode
+	}()
+	_, _ = uploadWriter.Write([]byte(`{"title":`))
+	time.Sleep(restSlowUploadSettle)
+	if profileStatus, _, _ := executeHTTPGet(testingT, httpClient, restBaseURL, "/db/profile"); profileStatus != http.StatusOK {
+		testingT.Fatalf("expected reads to proceed while an upload is still arriving, got status=%d", profileStatus)
+	}
+	_, _ = uploadWriter.Write([]byte(`"Slow upload"}`))
+	_ = uploadWriter.Close()
+	if uploadStatus := <-slowUploadStatus; uploadStatus != http.StatusCreated {
+		testingT.Fatalf("expected the slow upload to be created, got status=%d", uploadStatus)
+	}
+
+	if writeErr := os.WriteFile(dataFilePath, []byte(`{"posts":[{"id":"a1","title":"Edited"}]}`), 0o600); writeErr != nil {
+		testingT.Fatalf("edit data file: %v", writeErr)
+	}
+	if itemIDs, _ := listIDs("/db/posts"); strings.Join(itemIDs, ",") != "a1" {
+		testingT.Fatalf("expected external edits to be picked up, got %v", itemIDs)
+	}
+	if createStatus, _, createBody := sendJSON(http.MethodPost, "/db/posts", `{"title":"Random"}`); createStatus != http.StatusCreated || len(createBody) < len(`{"id":"0123456789abcdef"`) {
+		testingT.Fatalf("expected a random id next to string ids, got status=%d body=%s", createStatus, createBody)
+	}
+	if writeErr := os.WriteFile(dataFilePath, []byte(`{"posts":`), 0o600); writeErr != nil {
+		testingT.Fatalf("break data file: %v", writeErr)
+	}
+	if itemIDs, _ := listIDs("/db/posts"); len(itemIDs) != 2 || itemIDs[0] != "a1" {
+		testingT.Fatalf("expected the last valid document after a broken edit, got %v", itemIDs)
+	}
+	_, _ = listIDs("/db/posts")
+
+	if stopErr := restServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop rest server: %v", stopErr)
+	}
+	restLogs := restServer.logBuffer.String()
+	for _, expectedLog := range []string{
+		`rest store saved file="` + dataFilePath + `" collection="posts" method="POST"`,
+		`collection="profile" method="PATCH"`,
+		`"DELETE /db/posts/2 HTTP/1.1" 204 - rest`,
+		`rest store reloaded file="` + dataFilePath + `"`,
+		`rest store reload failed`,
+	} {
+		if !strings.Contains(restLogs, expectedLog) {
+			testingT.Fatalf("expected %q in rest store logs, got:\n%s", expectedLog, restLogs)
+		}
+	}
+	if strings.Count(restLogs, "rest store reload failed") != 1 {
+		testingT.Fatalf("expected a broken data file to be reported once, got:\n%s", restLogs)
+	}
+
+	for _, invalidMapping := range []string{"db=data.json", "/db", "/db=missing.json", "/db=notes.txt", "/db=array.json", "/db=mixed.json", "/db=data.json,/db=data.json"} {
+		runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--rest", invalidMapping},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+	}
+	outsideFilePath := filepath.Join(testingT.TempDir(), "outside.json")
+	if writeErr := os.WriteFile(outsideFilePath, []byte(`{"secrets":[]}`), 0o644); writeErr != nil {
+		testingT.Fatalf("write outside store: %v", writeErr)
+	}
+	relativeOutsidePath, _ := filepath.Rel(siteDirectory, outsideFilePath)
+	for _, escapingMapping := range []string{"/db=" + outsideFilePath, "/db=" + filepath.ToSlash(relativeOutsidePath)} {
+		escapeOutput := runCommandExpectExitCode(
+			testingT,
+			repositoryRoot,
+			binaryPath,
+			[]string{strconv.Itoa(allocateFreePort(testingT)), "--directory", siteDirectory, "--rest", escapingMapping},
+			map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+			1,
+		)
+		if !strings.Contains(escapeOutput, "must be a relative path inside the served directory") {
+			testingT.Fatalf("expected %s to be rejected as outside the served directory, got:\n%s", escapingMapping, escapeOutput)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exerciseTLSProtocolFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string) {
+	testingT.Helper()
+	certificatePath, privateKeyPath := generateSelfSignedCertificatePair(testingT)
+	environment := map[string]string{"GOCOVERDIR": coverageDirectoryPath}
+	handshake := func(requestURL string, clientConfig *tls.Config) (*tls.ConnectionState, error) {
+		testingT.Helper()
+		clientConfig.InsecureSkipVerify = true
+		client := &http.Client{Timeout: browseModeRequestTimeout, Transport: &http.Transport{TLSClientConfig: clientConfig, DisableKeepAlives: true}}
+		response, responseErr := client.Get(requestURL)
+		if responseErr != nil {
+			return nil, responseErr
+		}
+		defer response.Body.Close()
+		return response.TLS, nil
+	}
+	startTLSServer := func(extraArguments ...string) (*startedGHTTPServer, string) {
+		testingT.Helper()
+		port := allocateFreePort(testingT)
+		probeURL := fmt.Sprintf("https://127.0.0.1:%d/hello.html", port)
+		arguments := append([]string{strconv.Itoa(port), "--directory", siteDirectory, "--tls-cert", certificatePath, "--tls-key", privateKeyPath, "--logging-type", "JSON"}, extraArguments...)
+		return startGHTTPProcessWithArguments(testingT, repositoryRoot, binaryPath, arguments, environment, probeURL, true), probeURL
+	}
+	expectLogs := func(startedServer *startedGHTTPServer, expectedLogs ...string) {
+		testingT.Helper()
+		if stopErr := startedServer.stop(); stopErr != nil {
+			testingT.Fatalf("stop tls server: %v\n%s", stopErr, startedServer.logBuffer.String())
+		}
+		for _, expectedLog := range expectedLogs {
+			if !strings.Contains(startedServer.logBuffer.String(), expectedLog) {
+				testingT.Fatalf("expected the JSON logs to contain %s, got:\n%s", expectedLog, startedServer.logBuffer.String())
+			}
+		}
+	}
+
+	modernServer, modernURL := startTLSServer("--tls-preset", "modern")
+	if _, handshakeErr := handshake(modernURL, &tls.Config{MaxVersion: tls.VersionTLS12}); handshakeErr == nil {
+		testingT.Fatalf("expected the modern preset to refuse TLS 1.2 clients")
+	}
+	if connectionState, handshakeErr := handshake(modernURL, &tls.Config{}); handshakeErr != nil || connectionState.Version != tls.VersionTLS13 {
+		testingT.Fatalf("expected the modern preset to negotiate TLS 1.3, got %v %v", connectionState, handshakeErr)
+	}
+	expectLogs(modernServer, `"tls_version":"TLS 1.3"`, `"tls_cipher":"TLS_`)
+
+	oldServer, oldURL := startTLSServer("--tls-preset", "old")
+	legacyClientConfig := &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS10, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}}
+	if connectionState, handshakeErr := handshake(oldURL, legacyClientConfig); handshakeErr != nil || connectionState.Version != tls.VersionTLS10 {
+		testingT.Fatalf("expected the old preset to accept TLS 1.0 clients, got %v %v", connectionState, handshakeErr)
+	}
+	expectLogs(oldServer, `"tls_version":"TLS 1.0"`, `"tls_cipher":"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"`)
+
+	strictServer, strictURL := startTLSServer(
+		"--tls-min-version", "1.2", "--tls-max-version", "TLSv1.2",
+		"--tls-cipher-suites", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "--tls-curves", "P-384",
+	)
+	if _, handshakeErr := handshake(strictURL, &tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, MaxVersion: tls.VersionTLS12}); handshakeErr == nil {
+		testingT.Fatalf("expected a client without the configured cipher suite to be refused")
+	}
+	connectionState, handshakeErr := handshake(strictURL, &tls.Config{})
+	if handshakeErr != nil || connectionState.Version != tls.VersionTLS12 || connectionState.CipherSuite != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 || connectionState.CurveID != tls.CurveP384 {
+		testingT.Fatalf("expected TLS 1.2 with the configured cipher suite and curve, got %v %v", connectionState, handshakeErr)
+	}
+	expectLogs(strictServer, `"tls_version":"TLS 1.2"`, `"tls_cipher":"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"`)
+
+	servePort := strconv.Itoa(allocateFreePort(testingT))
+	tlsArguments := []string{"--tls-cert", certificatePath, "--tls-key", privateKeyPath}
+	for _, invalidCase := range []struct {
+		arguments      []string
+		expectedOutput string
+	}{
+		{[]string{"--tls-preset", "modern"}, "require --https or --tls-cert"},
+		{append([]string{"--tls-preset", "strict"}, tlsArguments...), `unknown preset "strict"`},
+		{append([]string{"--tls-min-version", "1.3", "--tls-max-version", "1.2"}, tlsArguments...), "minimum version TLS 1.3 is above maximum version TLS 1.2"},
+		{append([]string{"--tls-min-version", "1.4"}, tlsArguments...), `unknown TLS version "1.4"`},
+		{append([]string{"--tls-preset", "modern", "--tls-cipher-suites", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, tlsArguments...), "cipher suites only apply to TLS 1.2 and earlier"},
+		{append([]string{"--tls-cipher-suites", "TLS_AES_128_GCM_SHA256"}, tlsArguments...), "is a TLS 1.3 cipher suite"},
+		{append([]string{"--tls-cipher-suites", "TLS_FAKE_SUITE"}, tlsArguments...), `unknown cipher suite "TLS_FAKE_SUITE"`},
+		{append([]string{"--tls-curves", "P-192"}, tlsArguments...), `unknown curve "P-192"`},
+		{append([]string{"--tls-min-version", "1.0", "--tls-max-version", "1.1", "--tls-cipher-suites", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, tlsArguments...), "is not available between TLS 1.0 and TLS 1.1"},
+		{append([]string{"--protocol", "HTTP/2", "--tls-min-version", "1.0", "--tls-max-version", "1.1"}, tlsArguments...), "HTTP/2 requires TLS 1.2 or later"},
+		{append([]string{"--protocol", "HTTP/2", "--tls-cipher-suites", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}, tlsArguments...), "HTTP/2 requires TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	} {
+		output := runCommandExpectExitCode(testingT, repositoryRoot, binaryPath, append([]string{servePort, "--directory", siteDirectory}, invalidCase.arguments...), environment, 1)
+		if !strings.Contains(output, invalidCase.expectedOutput) {
+			testingT.Fatalf("expected %v to report %q, got:\n%s", invalidCase.arguments, invalidCase.expectedOutput, output)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const (
+	tlsReloadWaitTimeout = 10 * time.Second
+	// tlsReloadPollSettle outlasts one poll of the certificate files, so a rejected change has been seen by then.
+	tlsReloadPollSettle = 1500 * time.Millisecond
+)
+
+func exerciseTLSCertificateReloadFlows(testingT *testing.T, repositoryRoot string, binaryPath string, siteDirectory string, coverageDirectoryPath string) {
+	testingT.Helper()
+	authority := createTestCertificateAuthority(testingT)
+	loopbackAddresses := []net.IP{net.ParseIP("127.0.0.1")}
+	firstCertificate := authority.issueCertificate(testingT, "first", []string{"localhost"}, loopbackAddresses, x509.ExtKeyUsageServerAuth)
+	secondCertificate := authority.issueCertificate(testingT, "second", []string{"localhost"}, loopbackAddresses, x509.ExtKeyUsageServerAuth)
+	thirdCertificate := authority.issueCertificate(testingT, "third", []string{"localhost"}, loopbackAddresses, x509.ExtKeyUsageServerAuth)
+
+	tlsDirectory := testingT.TempDir()
+	certificatePath := filepath.Join(tlsDirectory, "server.pem")
+	privateKeyPath := filepath.Join(tlsDirectory, "server.key")
+	installFile := func(sourcePath string, targetPath string) {
+		testingT.Helper()
+		contents, readErr := os.ReadFile(sourcePath)
+		if readErr != nil {
+			testingT.Fatalf("read %s: %v", sourcePath, readErr)
+		}
+		if writeErr := os.WriteFile(targetPath, contents, 0o600); writeErr != nil {
+			testingT.Fatalf("write %s: %v", targetPath, writeErr)
+		}
+	}
+	installFile(firstCertificate.certificatePath, certificatePath)
+	installFile(firstCertificate.privateKeyPath, privateKeyPath)
+
+	newClient := func() *http.Client {
+		return &http.Client{
+			Timeout:   browseModeRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: authority.pool()}, DisableKeepAlives: true},
+		}
+	}
+	port := allocateFreePort(testingT)
+	probeURL := fmt.Sprintf("https://127.0.0.1:%d/hello.html", port)
+	servedCommonName := func() string {
+		testingT.Helper()
+		response, responseErr := newClient().Get(probeURL)
+		if responseErr != nil {
+			testingT.Fatalf("GET %s: %v", probeURL, responseErr)
+		}
+		defer response.Body.Close()
+		return response.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	waitForCommonName := func(expectedCommonName string) {
+		testingT.Helper()
+		deadline := time.Now().Add(tlsReloadWaitTimeout)
+		for servedCommonName() != expectedCommonName {
+			if time.Now().After(deadline) {
+				testingT.Fatalf("expected the server to present %q after the reload", expectedCommonName)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	reloadServer := startGHTTPProcessWithStartupClient(
+		testingT,
+		repositoryRoot,
+		binaryPath,
+		[]string{strconv.Itoa(port), "--directory", siteDirectory, "--tls-cert", certificatePath, "--tls-key", privateKeyPath, "--logging-type", "JSON"},
+		map[string]string{"GOCOVERDIR": coverageDirectoryPath},
+		probeURL,
+		newClient(),
+	)
+	if commonName := servedCommonName(); commonName != "first" {
+		testingT.Fatalf("expected the initial certificate, got %q", commonName)
+	}
+
+	installFile(secondCertificate.certificatePath, certificatePath)
+	installFile(secondCertificate.privateKeyPath, privateKeyPath)
+	waitForCommonName("second")
+
+	installFile(thirdCertificate.certificatePath, certificatePath)
+	time.Sleep(tlsReloadPollSettle)
+	if commonName := servedCommonName(); commonName != "second" {
+		testingT.Fatalf("expected a certificate that does not match its key to be rejected, got %q", commonName)
+	}
+	if writeErr := os.WriteFile(privateKeyPath, []byte("not a key"), 0o600); writeErr != nil {
+		testingT.Fatalf("corrupt private key: %v", writeErr)
+	}
+	if commonName := servedCommonName(); commonName != "second" {
+		testingT.Fatalf("expected an unreadable key to keep the previous certificate, got %q", commonName)
+	}
+	if signalErr := reloadServer.command.Process.Signal(syscall.SIGHUP); signalErr != nil {
+		testingT.Fatalf("send SIGHUP: %v", signalErr)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if commonName := servedCommonName(); commonName != "second" {
+		testingT.Fatalf("expected the server to survive a failed SIGHUP reload, got %q", commonName)
+	}
+	installFile(thirdCertificate.privateKeyPath, privateKeyPath)
+	if signalErr := reloadServer.command.Process.Signal(syscall.SIGHUP); signalErr != nil {
+		testingT.Fatalf("send SIGHUP: %v", signalErr)
+	}
+	waitForCommonName("third")
+
+	if stopErr := reloadServer.stop(); stopErr != nil {
+		testingT.Fatalf("stop reload server: %v\n%s", stopErr, reloadServer.logBuffer.String())
+	}
+	reloadLogs := reloadServer.logBuffer.String()
+	for _, expectedLog := range []string{
+		`"msg":"tls certificate reloaded"`,
+		`"msg":"tls certificate reload failed"`,
+		`"trigger":"file change"`,
+		`"trigger":"SIGHUP"`,
+		"private key does not match public key",
+	} {
+		if !strings.Contains(reloadLogs, expectedLog) {
+			testingT.Fatalf("expected the reload logs to contain %s, got:\n%s", expectedLog, reloadLogs)
+		}
+	}
+}